@@ -1,27 +1,37 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/internal/filemode"
 	"github.com/lucasrod16/trac/internal/index"
 	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/pkg/trac"
 	"github.com/spf13/cobra"
 )
 
 type addOptions struct {
 	// Files to stage
 	files []string
+	// Interactively choose hunks to stage
+	patch bool
 }
 
 func NewAddCmd() *cobra.Command {
 	opts := &addOptions{}
 
-	return &cobra.Command{
-		Use:   "add [file...]",
+	cmd := &cobra.Command{
+		Use:   "add [-p] [file...]",
 		Short: "Add file contents to the index",
 		Long: `
 	This command updates the index using the current content found in the working tree, to prepare the content staged for the next commit.
@@ -33,43 +43,60 @@ func NewAddCmd() *cobra.Command {
 	This command can be performed multiple times before a commit. It only adds the content of the specified file(s) at the time the add command is run; if
 	you want subsequent changes included in the next commit, then you must run trac add again to add the new content to the index.
 
+	Naming a tracked file that has been removed from the working tree stages its removal.
+
 	The trac status command can be used to obtain a summary of which files have changes that are staged for the next commit.
+
+	With --patch, the differences between the index and the working tree of tracked files are presented one hunk at a time, and only the selected
+	hunks are staged. The staged content is written to the object database immediately, so it no longer depends on the file on disk.
 	`,
-		Args: cobra.MinimumNArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.patch {
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !opts.patch {
+				repo, err := openRepository()
+				if err != nil {
+					return err
+				}
+				return stageFiles(repo, args)
+			}
 			cwd, err := os.Getwd()
 			if err != nil {
 				return err
 			}
-			l, err := layout.New(cwd)
+			l, err := repoLayout()
 			if err != nil {
 				return err
 			}
-			if err := l.ValidateIsRepo(); err != nil {
-				return err
-			}
-			if args[0] == "." {
-				files, err := getFilesRecursively(cwd)
-				if err != nil {
-					return err
-				}
-				opts.files = files
-			} else if info, err := os.Stat(args[0]); err == nil && info.IsDir() {
-				root := filepath.Join(cwd, info.Name())
-				files, err := getFilesRecursively(root)
-				if err != nil {
-					return err
-				}
-				opts.files = files
-			} else {
-				opts.files = args
-			}
-			if err := stageFiles(l, opts); err != nil {
+			files, err := resolveFiles(cwd, args)
+			if err != nil {
 				return err
 			}
-			return nil
+			opts.files = files
+			return stagePatch(cmd.InOrStdin(), cmd.OutOrStdout(), l, opts)
 		},
 	}
+	cmd.Flags().BoolVarP(&opts.patch, "patch", "p", false, "Interactively choose hunks of changes to stage")
+	return cmd
+}
+
+// resolveFiles expands the path arguments of add into the files they refer to.
+func resolveFiles(cwd string, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if args[0] == "." {
+		return getFilesRecursively(cwd)
+	}
+	if info, err := os.Lstat(args[0]); err == nil && info.IsDir() {
+		root := filepath.Join(cwd, info.Name())
+		return getFilesRecursively(root)
+	}
+	return args, nil
 }
 
 func getFilesRecursively(root string) ([]string, error) {
@@ -101,19 +128,108 @@ func getFilesRecursively(root string) ([]string, error) {
 	return files, nil
 }
 
-func stageFiles(l *layout.Layout, opts *addOptions) error {
+func stageFiles(repo *trac.Repository, files []string) error {
+	err := repo.Add(context.Background(), files...)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "add" {
+		return fmt.Errorf("failed to add file %s: %w", pathErr.Path, pathErr.Err)
+	}
+	return err
+}
+
+// stagePatch interactively stages hunks of the differences between the index and the working tree.
+// When no files are given, every tracked file is considered.
+func stagePatch(in io.Reader, w io.Writer, l *layout.Layout, opts *addOptions) error {
 	idx := index.New()
 	err := idx.Load(l)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
-	for _, file := range opts.files {
-		if err := idx.Add(file, l); err != nil {
-			return fmt.Errorf("failed to add file %s: %w", file, err)
+	keys, err := trackedKeys(idx, l, opts.files)
+	if err != nil {
+		return err
+	}
+	prompt := newHunkPrompt(in, w, "stage")
+	found := false
+	for _, key := range keys {
+		staged, err := readStaged(l, idx, key)
+		if err != nil {
+			return err
+		}
+		relPath, err := l.RelPath(key)
+		if err != nil {
+			return err
+		}
+		if filemode.Get(idx.Modes, key) == filemode.Symlink {
+			// Symbolic links are staged whole, with trac add.
+			continue
+		}
+		current, err := os.ReadFile(filepath.Join(l.Root, relPath))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		base := diff.SplitLines(staged)
+		hunks := diff.Hunks(diff.Lines(base, diff.SplitLines(current)), hunkContext)
+		if len(hunks) == 0 {
+			continue
+		}
+		found = true
+		selected, err := prompt.selectHunks(relPath, base, hunks)
+		if err != nil {
+			return err
+		}
+		if len(selected) > 0 {
+			result, err := diff.Apply(base, selected)
+			if err != nil {
+				return err
+			}
+			hash, err := object.Write(l, synthesize(result, current, staged))
+			if err != nil {
+				return err
+			}
+			idx.Staged[key] = hash
+		}
+		if prompt.quit {
+			break
 		}
 	}
+	if !found {
+		fmt.Fprintln(w, "No changes.")
+		return nil
+	}
 	if err := idx.Write(l); err != nil {
 		return fmt.Errorf("failed to write updated index: %w", err)
 	}
 	return nil
 }
+
+// trackedKeys returns the index keys of the given paths, ignoring paths that are not staged.
+// When no paths are given, all index keys are returned in sorted order.
+func trackedKeys(idx *index.Index, l *layout.Layout, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return slices.Sorted(maps.Keys(idx.Staged)), nil
+	}
+	var keys []string
+	for _, path := range paths {
+		key, ok, err := idx.Find(path, l)
+		if err != nil {
+			return nil, err
+		}
+		if ok && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// readStaged reads the staged content of the index entry key.
+func readStaged(l *layout.Layout, idx *index.Index, key string) ([]byte, error) {
+	data, err := object.Read(l, idx.Staged[key])
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("staged content of %s is missing from the object database", key)
+	}
+	return data, err
+}
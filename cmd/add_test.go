@@ -37,14 +37,26 @@ func TestAddCommand(t *testing.T) {
 		require.NoError(t, os.WriteFile(testPath, []byte("some content"), 0644))
 		require.NoError(t, addCmd(t, testPath))
 
-		idx := getIndex(t, tmpdir)
-
 		expected, err := utils.HashFile(testPath)
 		require.NoError(t, err)
-		actual := idx.Staged[testPath]
+		actual := getIndex(t, tmpdir).Staged["test.txt"]
 		require.Equal(t, expected, actual)
 	})
 
+	t.Run("add writes file content to the object database", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+
+		testPath := filepath.Join(tmpdir, "test.txt")
+		require.NoError(t, os.WriteFile(testPath, []byte("some content"), 0644))
+		require.NoError(t, addCmd(t, testPath))
+
+		hash := stagedHash(t, tmpdir, testPath)
+		data, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "objects", hash[:2], hash[2:]))
+		require.NoError(t, err)
+		require.Equal(t, "some content", string(data))
+	})
+
 	t.Run("add multiple files", func(t *testing.T) {
 		tmpdir := initRepository(t)
 		require.NoError(t, os.Chdir(tmpdir))
@@ -56,16 +68,14 @@ func TestAddCommand(t *testing.T) {
 
 		require.NoError(t, addCmd(t, testPath1, testPath2))
 
-		idx := getIndex(t, tmpdir)
-
 		expected, err := utils.HashFile(testPath1)
 		require.NoError(t, err)
-		actual := idx.Staged[testPath1]
+		actual := stagedHash(t, tmpdir, testPath1)
 		require.Equal(t, expected, actual)
 
 		expected, err = utils.HashFile(testPath2)
 		require.NoError(t, err)
-		actual = idx.Staged[testPath2]
+		actual = stagedHash(t, tmpdir, testPath2)
 		require.Equal(t, expected, actual)
 	})
 
@@ -83,8 +93,6 @@ func TestAddCommand(t *testing.T) {
 
 		require.NoError(t, addCmd(t, "."))
 
-		idx := getIndex(t, tmpdir)
-
 		testPath1, err := filepath.Rel(tmpdir, testPath1)
 		require.NoError(t, err)
 		testPath2, err = filepath.Rel(tmpdir, testPath2)
@@ -92,12 +100,12 @@ func TestAddCommand(t *testing.T) {
 
 		expected, err := utils.HashFile(testPath1)
 		require.NoError(t, err)
-		actual := idx.Staged[testPath1]
+		actual := stagedHash(t, tmpdir, testPath1)
 		require.Equal(t, expected, actual)
 
 		expected, err = utils.HashFile(testPath2)
 		require.NoError(t, err)
-		actual = idx.Staged[testPath2]
+		actual = stagedHash(t, tmpdir, testPath2)
 		require.Equal(t, expected, actual)
 	})
 
@@ -115,8 +123,6 @@ func TestAddCommand(t *testing.T) {
 
 		require.NoError(t, addCmd(t, subdir))
 
-		idx := getIndex(t, tmpdir)
-
 		testPath1, err := filepath.Rel(tmpdir, testPath1)
 		require.NoError(t, err)
 		testPath2, err = filepath.Rel(tmpdir, testPath2)
@@ -124,12 +130,12 @@ func TestAddCommand(t *testing.T) {
 
 		expected, err := utils.HashFile(testPath1)
 		require.NoError(t, err)
-		actual := idx.Staged[testPath1]
+		actual := stagedHash(t, tmpdir, testPath1)
 		require.Equal(t, expected, actual)
 
 		expected, err = utils.HashFile(testPath2)
 		require.NoError(t, err)
-		actual = idx.Staged[testPath2]
+		actual = stagedHash(t, tmpdir, testPath2)
 		require.Equal(t, expected, actual)
 	})
 
@@ -139,4 +145,69 @@ func TestAddCommand(t *testing.T) {
 		invalidFilePath := filepath.Join(tmpdir, "invalid.txt")
 		require.Error(t, addCmd(t, invalidFilePath))
 	})
+
+	t.Run("patch stages selected hunks", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, numberedLines(20, nil))
+
+		modified := numberedLines(20, map[int]string{2: "changed 2", 15: "changed 15"})
+		require.NoError(t, os.WriteFile(testFile, []byte(modified), 0644))
+
+		out, err := addPatchCmd(t, "y\nn\n")
+		require.NoError(t, err)
+		require.Contains(t, out, "diff --trac a/test.txt b/test.txt")
+		require.Contains(t, out, "@@ -1,5 +1,5 @@")
+		require.Contains(t, out, "(1/2) Stage this hunk [y,n,q,a,d,e,?]? ")
+		require.Equal(t, numberedLines(20, map[int]string{2: "changed 2"}), stagedContent(t, tmpdir, testFile))
+
+		// the remaining hunk is still offered
+		_, err = addPatchCmd(t, "y\n", testFile)
+		require.NoError(t, err)
+		require.Equal(t, modified, stagedContent(t, tmpdir, testFile))
+
+		out, err = addPatchCmd(t, "")
+		require.NoError(t, err)
+		require.Equal(t, "No changes.\n", out)
+	})
+
+	t.Run("patch splits hunks", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, numberedLines(10, nil))
+		require.NoError(t, os.WriteFile(testFile, []byte(numberedLines(10, map[int]string{3: "changed 3", 6: "changed 6"})), 0644))
+
+		out, err := addPatchCmd(t, "s\nn\ny\n")
+		require.NoError(t, err)
+		require.Contains(t, out, "[y,n,q,a,d,s,e,?]")
+		require.Contains(t, out, "Split into 2 hunks.")
+		require.Equal(t, numberedLines(10, map[int]string{6: "changed 6"}), stagedContent(t, tmpdir, testFile))
+	})
+
+	t.Run("patch edits hunks", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, numberedLines(5, nil))
+		require.NoError(t, os.WriteFile(testFile, []byte(numberedLines(5, map[int]string{3: "changed 3"})), 0644))
+
+		t.Setenv("TRAC_EDITOR", "sed -i s/changed/edited/")
+		_, err := addPatchCmd(t, "e\n")
+		require.NoError(t, err)
+		require.Equal(t, numberedLines(5, map[int]string{3: "edited 3"}), stagedContent(t, tmpdir, testFile))
+	})
+
+	t.Run("patch quits", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, numberedLines(5, nil))
+		require.NoError(t, os.WriteFile(testFile, []byte(numberedLines(5, map[int]string{1: "changed 1"})), 0644))
+
+		_, err := addPatchCmd(t, "q\n")
+		require.NoError(t, err)
+		require.Equal(t, numberedLines(5, nil), stagedContent(t, tmpdir, testFile))
+	})
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type amOptions struct {
+	threeWay bool // -3, --3way
+	fuzz     int  // --fuzz
+}
+
+func NewAmCmd() *cobra.Command {
+	opts := &amOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "am [--3way] [<mbox>...]",
+		Short: "Commit a series of patch emails",
+		Long: `
+	Applies the patch emails in the named mbox files, or standard input if none are named, such as those written by trac
+	format-patch, and commits each one in turn. A commit takes its message from the email's subject and body, and its author and
+	date from the email's sender and date.
+
+	Patches are applied to both the index and the working tree, so the index must not hold changes that have not been committed.
+	Hunks are applied as trac apply does, and with --3way, a patch that does not apply is merged with the content it was made from.
+
+	trac am stops at the first patch that does not apply or leaves conflicts, keeping the commits made before it. A patch with
+	conflicts is left in the working tree to be resolved and committed by hand.
+	`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			in := cmd.InOrStdin()
+			if len(args) > 0 {
+				var mbox bytes.Buffer
+				for _, name := range args {
+					data, err := os.ReadFile(name)
+					if err != nil {
+						return err
+					}
+					mbox.Write(data)
+				}
+				in = &mbox
+			}
+			return runAm(in, cmd.OutOrStdout(), repo, opts)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.threeWay, "3way", "3", false, "Merge patches that do not apply with the content they were made from")
+	cmd.Flags().IntVar(&opts.fuzz, "fuzz", 2, "Lines of context that may be ignored at each end of a hunk")
+	return cmd
+}
+
+func runAm(r io.Reader, w io.Writer, repo *trac.Repository, opts *amOptions) error {
+	commits, err := repo.Am(context.Background(), r, &trac.AmOptions{ThreeWay: opts.threeWay, Fuzz: opts.fuzz})
+	for _, c := range commits {
+		fmt.Fprintf(w, "Applying: %s\n", c.Summary())
+	}
+	return err
+}
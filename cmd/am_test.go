@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmCommand(t *testing.T) {
+	src := initRepository(t)
+	require.NoError(t, os.Chdir(src))
+	_, err := fastImportCmd(t, gitStream)
+	require.NoError(t, err)
+	mbox, err := formatPatchCmd(t, "--root", "--stdout", "main")
+	require.NoError(t, err)
+	srcRepo, err := trac.Open(src)
+	require.NoError(t, err)
+
+	t.Run("recreates commits with their authors", func(t *testing.T) {
+		dst := initRepository(t)
+		require.NoError(t, os.Chdir(dst))
+		out, err := amCmd(t, mbox)
+		require.NoError(t, err)
+		require.Equal(t, "Applying: First commit\nApplying: Rename and edit\nApplying: Delete\nApplying: Merge branch 'feature'\n", out)
+
+		dstRepo, err := trac.Open(dst)
+		require.NoError(t, err)
+		for _, rev := range []string{"main", "main~1", "main~2", "main~3"} {
+			want, err := srcRepo.Resolve(rev)
+			require.NoError(t, err)
+			got, err := dstRepo.Resolve(rev)
+			require.NoError(t, err)
+			wantCommit, err := srcRepo.ReadCommit(want)
+			require.NoError(t, err)
+			gotCommit, err := dstRepo.ReadCommit(got)
+			require.NoError(t, err)
+			require.Equal(t, wantCommit.Message, gotCommit.Message)
+			require.Equal(t, wantCommit.Files, gotCommit.Files)
+			require.Equal(t, wantCommit.Modes, gotCommit.Modes)
+			require.Equal(t, wantCommit.Author.String(), gotCommit.Author.String())
+			require.True(t, wantCommit.Author.Time.Equal(gotCommit.Author.Time))
+		}
+		data, err := os.ReadFile(filepath.Join(dst, "run.sh"))
+		require.NoError(t, err)
+		require.Equal(t, "#!/bin/sh\n", string(data))
+		info, err := os.Stat(filepath.Join(dst, "run.sh"))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		status, err := statusCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, status, "nothing to commit")
+	})
+
+	t.Run("stops at the first patch that does not apply", func(t *testing.T) {
+		dst := initRepository(t)
+		require.NoError(t, os.Chdir(dst))
+		first, _, _ := strings.Cut(mbox[1:], "\nFrom ")
+		_, err := amCmd(t, "F"+first)
+		require.NoError(t, err)
+
+		commitFile(t, dst, filepath.Join(dst, "a.txt"), "changed\n")
+		out, err := amCmd(t, mbox)
+		require.ErrorIs(t, err, trac.ErrPatchDoesNotApply)
+		require.Empty(t, out)
+		require.Contains(t, err.Error(), `patch 1/4 "First commit"`)
+	})
+
+	t.Run("refuses to run with staged changes", func(t *testing.T) {
+		dst := initRepository(t)
+		require.NoError(t, os.Chdir(dst))
+		require.NoError(t, os.WriteFile(filepath.Join(dst, "other.txt"), []byte("other\n"), 0644))
+		require.NoError(t, addCmd(t, "other.txt"))
+		_, err := amCmd(t, mbox)
+		require.ErrorIs(t, err, trac.ErrDirtyIndex)
+	})
+
+	t.Run("applies a change of mode alone", func(t *testing.T) {
+		src := initRepository(t)
+		require.NoError(t, os.Chdir(src))
+		path := filepath.Join(src, "tool.sh")
+		commitFile(t, src, path, "echo hi\n")
+		root, err := formatPatchCmd(t, "--root", "--stdout", "HEAD")
+		require.NoError(t, err)
+		dst := initRepository(t)
+		require.NoError(t, os.Chdir(dst))
+		_, err = amCmd(t, root)
+		require.NoError(t, err)
+
+		require.NoError(t, os.Chdir(src))
+		require.NoError(t, os.Chmod(path, 0755))
+		require.NoError(t, addCmd(t, path))
+		require.NoError(t, commitCmd(t, "-m", "Make tool executable"))
+		chmod, err := formatPatchCmd(t, "--stdout", "HEAD~1")
+		require.NoError(t, err)
+		require.Contains(t, chmod, "old mode 100644\nnew mode 100755\n")
+
+		require.NoError(t, os.Chdir(dst))
+		out, err := amCmd(t, chmod)
+		require.NoError(t, err)
+		require.Equal(t, "Applying: Make tool executable\n", out)
+		info, err := os.Stat(filepath.Join(dst, "tool.sh"))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		dstRepo, err := trac.Open(dst)
+		require.NoError(t, err)
+		head, err := dstRepo.Resolve("HEAD")
+		require.NoError(t, err)
+		c, err := dstRepo.ReadCommit(head)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"tool.sh": trac.ModeExecutable}, c.Modes)
+	})
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type applyOptions struct {
+	check    bool // --check
+	index    bool // --index
+	cached   bool // --cached
+	threeWay bool // -3, --3way
+	fuzz     int  // --fuzz
+}
+
+func NewApplyCmd() *cobra.Command {
+	opts := &applyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "apply [--check] [--3way] [--index | --cached] [<patch>...]",
+		Short: "Apply a patch to the working tree",
+		Long: `
+	Applies the changes in unified diff patches, such as those written by trac diff, trac format-patch, git diff or diff -u, to the
+	working tree. The patches are read from the named files, or from standard input if none are named.
+
+	A hunk whose lines have moved since the patch was made is applied where its context is now found. If its context has changed
+	too, up to --fuzz lines of context at either end of the hunk are ignored to find where it applies. Hunks that did not apply
+	where they were recorded are reported.
+
+	With --3way, a file the patch does not apply to is merged with the content the patch was made from, when the repository has it.
+	Conflicting changes are left in the file between conflict markers to be resolved by hand.
+
+	With --index, the changes are applied to the index as well, and the files they touch must not have unstaged changes. With
+	--cached, they are applied to the index only. Nothing is changed unless the whole patch applies; --check only reports whether
+	it does.
+	`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return runApply(cmd.InOrStdin(), cmd.OutOrStdout(), repo, "", opts)
+			}
+			for _, name := range args {
+				f, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				err = runApply(f, cmd.OutOrStdout(), repo, name, opts)
+				f.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.check, "check", false, "Only check whether the patch applies")
+	cmd.Flags().BoolVar(&opts.index, "index", false, "Apply the patch to the index as well as the working tree")
+	cmd.Flags().BoolVar(&opts.cached, "cached", false, "Apply the patch to the index only")
+	cmd.Flags().BoolVarP(&opts.threeWay, "3way", "3", false, "Merge files the patch does not apply to with the content it was made from")
+	cmd.Flags().IntVar(&opts.fuzz, "fuzz", 2, "Lines of context that may be ignored at each end of a hunk")
+	cmd.MarkFlagsMutuallyExclusive("index", "cached")
+	return cmd
+}
+
+func runApply(r io.Reader, w io.Writer, repo *trac.Repository, name string, opts *applyOptions) error {
+	files, err := repo.Apply(context.Background(), r, &trac.ApplyOptions{
+		Check:    opts.check,
+		Index:    opts.index,
+		Cached:   opts.cached,
+		ThreeWay: opts.threeWay,
+		Fuzz:     opts.fuzz,
+	})
+	if err != nil {
+		if name != "" {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return err
+	}
+	return printPatchedFiles(w, files)
+}
+
+// printPatchedFiles reports hunks that applied away from where they were
+// recorded, and files that were merged, returning an error if any merge
+// left conflicts.
+func printPatchedFiles(w io.Writer, files []trac.PatchedFile) error {
+	var conflicted []string
+	for _, f := range files {
+		for n, h := range f.Hunks {
+			switch {
+			case h.Fuzz > 0:
+				fmt.Fprintf(w, "%s: hunk #%d succeeded at %d with fuzz %d (offset %d line(s))\n", f.Path, n+1, h.Line, h.Fuzz, h.Offset)
+			case h.Offset != 0:
+				fmt.Fprintf(w, "%s: hunk #%d succeeded at %d (offset %d line(s))\n", f.Path, n+1, h.Line, h.Offset)
+			}
+		}
+		switch {
+		case f.Conflicts > 0:
+			fmt.Fprintf(w, "%s: applied with %d conflict(s)\n", f.Path, f.Conflicts)
+			conflicted = append(conflicted, f.Path)
+		case f.Merged:
+			fmt.Fprintf(w, "%s: applied cleanly by merging\n", f.Path)
+		}
+	}
+	if len(conflicted) > 0 {
+		return fmt.Errorf("%w in %d file(s); resolve them and add the results", trac.ErrPatchConflict, len(conflicted))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+// lines returns n lines "line 1" to "line n", with the lines in replace changed.
+func lines(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = fmt.Sprintf("line %d", i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func TestApplyCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	file := filepath.Join(tmpdir, "file.txt")
+	commitFile(t, tmpdir, file, lines(20, nil))
+	commitFile(t, tmpdir, file, lines(20, map[int]string{10: "changed"}))
+	patch, err := diffCmd(t, "HEAD~1", "HEAD")
+	require.NoError(t, err)
+	mbox, err := formatPatchCmd(t, "--stdout", "HEAD~1")
+	require.NoError(t, err)
+	commitFile(t, tmpdir, file, lines(20, nil))
+
+	reset := func(t *testing.T, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+		require.NoError(t, addCmd(t, file))
+	}
+	readFile := func(t *testing.T) string {
+		t.Helper()
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("applies a diff to the working tree", func(t *testing.T) {
+		reset(t, lines(20, nil))
+		out, err := applyCmd(t, patch)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		require.Equal(t, lines(20, map[int]string{10: "changed"}), readFile(t))
+		status, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, " M file.txt\n", status)
+	})
+
+	t.Run("applies a patch file to the index", func(t *testing.T) {
+		reset(t, lines(20, nil))
+		name := filepath.Join(t.TempDir(), "0001.patch")
+		require.NoError(t, os.WriteFile(name, []byte(mbox), 0644))
+		_, err := applyCmd(t, "", "--index", name)
+		require.NoError(t, err)
+		status, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "M  file.txt\n", status)
+	})
+
+	t.Run("reports hunks applied at an offset or with fuzz", func(t *testing.T) {
+		reset(t, "new\n"+lines(20, map[int]string{7: "edited"}))
+		out, err := applyCmd(t, patch)
+		require.NoError(t, err)
+		require.Equal(t, "file.txt: hunk #1 succeeded at 9 with fuzz 1 (offset 1 line(s))\n", out)
+		require.Equal(t, "new\n"+lines(20, map[int]string{7: "edited", 10: "changed"}), readFile(t))
+
+		reset(t, lines(20, map[int]string{7: "edited"}))
+		_, err = applyCmd(t, patch, "--fuzz", "0")
+		require.ErrorIs(t, err, trac.ErrPatchDoesNotApply)
+	})
+
+	t.Run("checks without changing anything", func(t *testing.T) {
+		reset(t, lines(20, nil))
+		_, err := applyCmd(t, patch, "--check")
+		require.NoError(t, err)
+		require.Equal(t, lines(20, nil), readFile(t))
+
+		reset(t, lines(20, map[int]string{10: "edited"}))
+		_, err = applyCmd(t, patch, "--check")
+		require.ErrorIs(t, err, trac.ErrPatchDoesNotApply)
+	})
+
+	t.Run("applies to the index only with --cached", func(t *testing.T) {
+		reset(t, lines(20, nil))
+		require.NoError(t, os.WriteFile(file, []byte("unstaged\n"), 0644))
+		_, err := applyCmd(t, patch, "--cached")
+		require.NoError(t, err)
+		require.Equal(t, "unstaged\n", readFile(t))
+		status, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "MM file.txt\n", status)
+
+		_, err = applyCmd(t, patch, "--index")
+		require.ErrorIs(t, err, trac.ErrPatchDoesNotApply)
+	})
+
+	t.Run("merges with --3way", func(t *testing.T) {
+		reset(t, lines(20, map[int]string{10: "edited"}))
+		_, err := applyCmd(t, patch)
+		require.ErrorIs(t, err, trac.ErrPatchDoesNotApply)
+
+		out, err := applyCmd(t, mbox, "--3way")
+		require.ErrorIs(t, err, trac.ErrPatchConflict)
+		require.Equal(t, "file.txt: applied with 1 conflict(s)\n", out)
+		require.Equal(t, lines(20, map[int]string{10: "<<<<<<< ours\nedited\n=======\nchanged\n>>>>>>> theirs"}), readFile(t))
+	})
+
+	t.Run("adds and deletes files", func(t *testing.T) {
+		reset(t, lines(20, nil))
+		patch := "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+new\n--- a/file.txt\n+++ /dev/null\n@@ -1,20 +0,0 @@\n" +
+			strings.ReplaceAll("-"+strings.TrimSuffix(lines(20, nil), "\n"), "\n", "\n-") + "\n"
+		_, err := applyCmd(t, patch)
+		require.NoError(t, err)
+		require.NoFileExists(t, file)
+		data, err := os.ReadFile(filepath.Join(tmpdir, "new.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "new\n", string(data))
+
+		_, err = applyCmd(t, patch)
+		require.ErrorIs(t, err, trac.ErrPatchDoesNotApply)
+	})
+}
@@ -0,0 +1,374 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/bisect"
+	"github.com/lucasrod16/trac/internal/checkout"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/spf13/cobra"
+)
+
+// skipExitCode is the exit code a "bisect run" command uses to skip the current commit.
+const skipExitCode = 125
+
+func NewBisectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bisect",
+		Short: "Use binary search to find the commit that introduced a bug",
+		Long: `
+	Searches the history between a known good commit and a known bad commit for the first bad commit. Start a session with "trac bisect start",
+	then mark commits as good or bad. Each time, trac checks out the commit halfway between the remaining candidates until the first bad commit is found.
+
+	Commits that cannot be tested can be marked with "trac bisect skip". "trac bisect run <cmd>" automates the search using the exit code of cmd:
+	0 means good, 125 means skip, any other code below 128 means bad. When finished, "trac bisect reset" returns to the original commit.
+	`,
+	}
+	cmd.AddCommand(newBisectStartCmd())
+	cmd.AddCommand(newBisectMarkCmd(bisect.Good, "Mark commits as good", cobra.ArbitraryArgs))
+	cmd.AddCommand(newBisectMarkCmd(bisect.Bad, "Mark a commit as bad", cobra.MaximumNArgs(1)))
+	cmd.AddCommand(newBisectMarkCmd(bisect.Skip, "Mark commits as untestable", cobra.ArbitraryArgs))
+	cmd.AddCommand(newBisectResetCmd())
+	cmd.AddCommand(newBisectLogCmd())
+	cmd.AddCommand(newBisectReplayCmd())
+	cmd.AddCommand(newBisectRunCmd())
+	return cmd
+}
+
+func newBisectStartCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start [<bad> [<good>...]]",
+		Short: "Start a bisect session",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return bisectStart(cmd.OutOrStdout(), l, args)
+		},
+	}
+}
+
+func newBisectMarkCmd(term, short string, args cobra.PositionalArgs) *cobra.Command {
+	return &cobra.Command{
+		Use:   term + " [<rev>...]",
+		Short: short,
+		Args:  args,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			state, err := bisect.Load(l)
+			if err != nil {
+				return err
+			}
+			if err := bisectMark(l, state, term, args); err != nil {
+				return err
+			}
+			_, err = bisectNext(cmd.OutOrStdout(), l, state)
+			return err
+		},
+	}
+}
+
+func newBisectResetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset [<rev>]",
+		Short: "Finish a bisect session and return to the original commit",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return bisectReset(cmd.OutOrStdout(), l, args)
+		},
+	}
+}
+
+func newBisectLogCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "log",
+		Short: "Show the commits marked so far",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			data, err := bisect.ReadLog(l)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+}
+
+func newBisectReplayCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay <logfile>",
+		Short: "Replay a bisect session from a log",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return bisectReplay(cmd.OutOrStdout(), l, args[0])
+		},
+	}
+}
+
+func newBisectRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <cmd> [<arg>...]",
+		Short: "Automatically bisect by running a command on each commit",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return bisectRun(cmd.OutOrStdout(), cmd.ErrOrStderr(), l, args)
+		},
+	}
+	// Flags after the command belong to the command.
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+func bisectStart(w io.Writer, l *layout.Layout, args []string) error {
+	state, err := bisect.Start(l)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		if err := bisectMark(l, state, bisect.Bad, args[:1]); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		if err := bisectMark(l, state, bisect.Good, args[1:]); err != nil {
+			return err
+		}
+	}
+	_, err = bisectNext(w, l, state)
+	return err
+}
+
+// bisectMark marks each revision with term. HEAD is marked when no revisions are given.
+func bisectMark(l *layout.Layout, state *bisect.State, term string, revs []string) error {
+	if len(revs) == 0 {
+		revs = []string{"HEAD"}
+	}
+	for _, rev := range revs {
+		hash, err := commit.Resolve(rev, l)
+		if err != nil {
+			return err
+		}
+		if err := state.Mark(l, term, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bisectNext checks out the next commit to test, or reports the outcome of the session.
+func bisectNext(w io.Writer, l *layout.Layout, state *bisect.State) (*bisect.Step, error) {
+	step, err := state.Next(l)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case step.FirstBad != "":
+		c, err := commit.Load(step.FirstBad, l)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w, "%s is the first bad commit\n", step.FirstBad)
+		fmt.Fprintf(w, "Date:   %s\n\n", c.Timestamp.Format("Mon Jan 2 15:04:05 2006 -0700"))
+		for _, line := range strings.Split(c.Message, "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	case len(step.Candidates) > 0:
+		fmt.Fprintln(w, "There are only 'skip'ped commits left to test.")
+		fmt.Fprintln(w, "The first bad commit could be any of:")
+		for _, hash := range step.Candidates {
+			fmt.Fprintln(w, hash)
+		}
+	case step.Next == "":
+		switch {
+		case state.Bad == "" && len(state.Good) == 0:
+			fmt.Fprintln(w, "status: waiting for both good and bad commits")
+		case state.Bad == "":
+			fmt.Fprintf(w, "status: waiting for bad commit, %d good commit(s) known\n", len(state.Good))
+		default:
+			fmt.Fprintln(w, "status: waiting for good commit(s), bad commit known")
+		}
+	default:
+		if err := checkout.Detach(l, step.Next); err != nil {
+			return nil, err
+		}
+		c, err := commit.Load(step.Next, l)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w, "Bisecting: %d revisions left to test after this (roughly %d steps)\n", step.Remaining, step.Steps())
+		fmt.Fprintf(w, "[%s] %s\n", step.Next, c.Summary())
+	}
+	return step, nil
+}
+
+func bisectReset(w io.Writer, l *layout.Layout, args []string) error {
+	state, err := bisect.Load(l)
+	if errors.Is(err, bisect.ErrNotBisecting) {
+		fmt.Fprintln(w, "We are not bisecting.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	target, branch := state.Start, state.StartRef
+	if len(args) > 0 {
+		target, err = commit.Resolve(args[0], l)
+		if err != nil {
+			return err
+		}
+		branch = ""
+	}
+	if branch != "" {
+		// Return to the branch the session started on, wherever it points now.
+		if target, err = refs.Read(l, branch); err != nil {
+			return err
+		}
+	}
+	head, err := commit.GetParentHash(l)
+	if err != nil {
+		return err
+	}
+	if head != target {
+		if err := checkout.Commit(l, target); err != nil {
+			return err
+		}
+	}
+	if branch != "" {
+		err = refs.SetHead(l, branch)
+	} else {
+		err = refs.DetachHead(l, target)
+	}
+	if err != nil {
+		return err
+	}
+	if err := bisect.Clear(l); err != nil {
+		return err
+	}
+	return printHead(w, l, target)
+}
+
+func bisectReplay(w io.Writer, l *layout.Layout, logfile string) error {
+	f, err := os.Open(logfile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var state *bisect.State
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "trac" || fields[1] != "bisect" {
+			continue
+		}
+		switch term, revs := fields[2], fields[3:]; term {
+		case "start":
+			if err := bisectReset(io.Discard, l, nil); err != nil {
+				return err
+			}
+			if err := bisectStart(io.Discard, l, revs); err != nil {
+				return err
+			}
+			if state, err = bisect.Load(l); err != nil {
+				return err
+			}
+		case bisect.Good, bisect.Bad, bisect.Skip:
+			if state == nil {
+				return bisect.ErrNotBisecting
+			}
+			if err := bisectMark(l, state, term, revs); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid bisect log entry %q", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("no bisect session found in %s", logfile)
+	}
+	_, err = bisectNext(w, l, state)
+	return err
+}
+
+func bisectRun(stdout, stderr io.Writer, l *layout.Layout, args []string) error {
+	state, err := bisect.Load(l)
+	if err != nil {
+		return err
+	}
+	if state.Bad == "" || len(state.Good) == 0 {
+		return errors.New("bisect run cannot continue without both good and bad commits")
+	}
+	for {
+		fmt.Fprintf(stdout, "running %s\n", strings.Join(args, " "))
+		run := exec.Command(args[0], args[1:]...)
+		run.Dir = l.Root
+		run.Stdout = stdout
+		run.Stderr = stderr
+		err := run.Run()
+
+		var exitErr *exec.ExitError
+		var code int
+		switch {
+		case err == nil:
+			code = 0
+		case errors.As(err, &exitErr):
+			code = exitErr.ExitCode()
+		default:
+			return fmt.Errorf("bisect run failed: %w", err)
+		}
+
+		var term string
+		switch {
+		case code == 0:
+			term = bisect.Good
+		case code == skipExitCode:
+			term = bisect.Skip
+		case code > 0 && code < 128:
+			term = bisect.Bad
+		default:
+			return fmt.Errorf("bisect run failed: exit code %d from %q is < 0 or >= 128", code, strings.Join(args, " "))
+		}
+		if err := bisectMark(l, state, term, nil); err != nil {
+			return err
+		}
+		step, err := bisectNext(stdout, l, state)
+		if err != nil {
+			return err
+		}
+		if step.Next == "" {
+			return nil
+		}
+	}
+}
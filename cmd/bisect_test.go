@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/bisect"
+	"github.com/stretchr/testify/require"
+)
+
+// bisectHistory creates a linear history of n commits where every commit from
+// firstBad onwards (1-based) contains the word "bug". It returns the commit hashes in order.
+func bisectHistory(t *testing.T, repoPath string, n, firstBad int) []string {
+	t.Helper()
+	testFile := filepath.Join(repoPath, "test.txt")
+	var hashes []string
+	for i := 1; i <= n; i++ {
+		content := fmt.Sprintf("version %d\n", i)
+		if i >= firstBad {
+			content += "bug\n"
+		}
+		hashes = append(hashes, commitFile(t, repoPath, testFile, content))
+	}
+	return hashes
+}
+
+func TestBisectCommand(t *testing.T) {
+	t.Run("not bisecting", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		bisectHistory(t, tmpdir, 2, 2)
+
+		_, err := bisectCmd(t, "good")
+		require.ErrorIs(t, err, bisect.ErrNotBisecting)
+
+		out, err := bisectCmd(t, "reset")
+		require.NoError(t, err)
+		require.Equal(t, "We are not bisecting.\n", out)
+	})
+
+	t.Run("manual bisect", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		hashes := bisectHistory(t, tmpdir, 8, 5)
+
+		out, err := bisectCmd(t, "start")
+		require.NoError(t, err)
+		require.Equal(t, "status: waiting for both good and bad commits\n", out)
+
+		out, err = bisectCmd(t, "bad")
+		require.NoError(t, err)
+		require.Equal(t, "status: waiting for good commit(s), bad commit known\n", out)
+
+		out, err = bisectCmd(t, "good", hashes[0])
+		require.NoError(t, err)
+		require.Contains(t, out, "Bisecting: ")
+
+		for range 10 {
+			data, err := os.ReadFile(filepath.Join(tmpdir, "test.txt"))
+			require.NoError(t, err)
+			term := "good"
+			if strings.Contains(string(data), "bug") {
+				term = "bad"
+			}
+			out, err = bisectCmd(t, term)
+			require.NoError(t, err)
+			if !strings.HasPrefix(out, "Bisecting: ") {
+				break
+			}
+		}
+		require.True(t, strings.HasPrefix(out, hashes[4]+" is the first bad commit\n"), out)
+
+		log, err := bisectCmd(t, "log")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(log, "trac bisect start\n"))
+		require.Contains(t, log, "trac bisect good "+hashes[0]+"\n")
+
+		out, err = bisectCmd(t, "reset")
+		require.NoError(t, err)
+		require.Equal(t, hashes[7], headHash(t, tmpdir))
+		require.Contains(t, out, "HEAD is now at "+hashes[7][:8])
+		require.NoFileExists(t, filepath.Join(tmpdir, ".trac", "bisect.json"))
+
+		// replaying the log reaches the same conclusion
+		logFile := filepath.Join(t.TempDir(), "bisect.log")
+		require.NoError(t, os.WriteFile(logFile, []byte(log), 0644))
+		out, err = bisectCmd(t, "replay", logFile)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, hashes[4]+" is the first bad commit\n"), out)
+	})
+
+	t.Run("skipped commits", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		hashes := bisectHistory(t, tmpdir, 3, 2)
+
+		out, err := bisectCmd(t, "start", hashes[2], hashes[0])
+		require.NoError(t, err)
+		require.Contains(t, out, "["+hashes[1]+"]")
+
+		out, err = bisectCmd(t, "skip")
+		require.NoError(t, err)
+		require.Contains(t, out, "There are only 'skip'ped commits left to test.")
+		require.Contains(t, out, hashes[1]+"\n"+hashes[2]+"\n")
+	})
+
+	t.Run("bisect run", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		hashes := bisectHistory(t, tmpdir, 10, 7)
+
+		_, err := bisectCmd(t, "start", "HEAD", hashes[0])
+		require.NoError(t, err)
+
+		out, err := bisectCmd(t, "run", "sh", "-c", "! grep -q bug test.txt")
+		require.NoError(t, err)
+		require.Contains(t, out, hashes[6]+" is the first bad commit\n")
+
+		_, err = bisectCmd(t, "reset")
+		require.NoError(t, err)
+		require.Equal(t, hashes[9], headHash(t, tmpdir))
+	})
+
+	t.Run("bisect run with exit code out of range", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		hashes := bisectHistory(t, tmpdir, 4, 3)
+
+		_, err := bisectCmd(t, "start", "HEAD", hashes[0])
+		require.NoError(t, err)
+		_, err = bisectCmd(t, "run", "sh", "-c", "exit 130")
+		require.ErrorContains(t, err, "exit code 130")
+	})
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/blame"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/spf13/cobra"
+)
+
+type blameOptions struct {
+	lineRange        string // -L
+	ignoreWhitespace bool   // -w
+	porcelain        bool   // --porcelain
+}
+
+func NewBlameCmd() *cobra.Command {
+	opts := &blameOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "blame <file> [<rev>]",
+		Short: "Show what revision last modified each line of a file",
+		Long: `
+	Annotates each line in the given file with information from the commit which introduced the line. When a revision is given, the file is annotated
+	as it was at that revision instead of HEAD.
+
+	The history of the file is followed through the parent of each commit, and every line is attributed to the commit where it last changed.
+	`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			rev := "HEAD"
+			if len(args) > 1 {
+				rev = args[1]
+			}
+			return runBlame(cmd.OutOrStdout(), l, args[0], rev, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.lineRange, "lines", "L", "", "Annotate only the line range given by <start>,<end>")
+	cmd.Flags().BoolVarP(&opts.ignoreWhitespace, "ignore-whitespace", "w", false, "Ignore whitespace when comparing revisions")
+	cmd.Flags().BoolVar(&opts.porcelain, "porcelain", false, "Show output in a format designed for machine consumption")
+	return cmd
+}
+
+func runBlame(w io.Writer, l *layout.Layout, path, rev string, opts *blameOptions) error {
+	start, end, err := parseLineRange(opts.lineRange)
+	if err != nil {
+		return err
+	}
+	lines, err := blame.File(l, path, rev, blame.Options{
+		Start:            start,
+		End:              end,
+		IgnoreWhitespace: opts.ignoreWhitespace,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.porcelain {
+		relPath, err := l.RelPath(path)
+		if err != nil {
+			return err
+		}
+		writeBlamePorcelain(w, filepath.ToSlash(relPath), lines)
+		return nil
+	}
+	writeBlame(w, lines)
+	return nil
+}
+
+// parseLineRange parses the argument to -L, which is one of "<start>,<end>", "<start>,+<count>", "<start>," or ",<end>".
+func parseLineRange(s string) (start, end int, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	startStr, endStr, _ := strings.Cut(s, ",")
+	if startStr != "" {
+		start, err = strconv.Atoi(startStr)
+		if err != nil || start < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q", s)
+		}
+	}
+	if count, ok := strings.CutPrefix(endStr, "+"); ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q", s)
+		}
+		return start, max(start, 1) + n - 1, nil
+	}
+	if endStr != "" {
+		end, err = strconv.Atoi(endStr)
+		if err != nil || end < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q", s)
+		}
+	}
+	if start > 0 && end > 0 && end < start {
+		start, end = end, start
+	}
+	return start, end, nil
+}
+
+func writeBlame(w io.Writer, lines []blame.Line) {
+	if len(lines) == 0 {
+		return
+	}
+	width := len(strconv.Itoa(lines[len(lines)-1].FinalLine))
+	for _, line := range lines {
+		hash := line.Hash[:8]
+		if line.Boundary {
+			hash = "^" + line.Hash[:7]
+		}
+		timestamp := line.Commit.Timestamp.Format("2006-01-02 15:04:05 -0700")
+		fmt.Fprintf(w, "%s (%s %*d) %s\n", hash, timestamp, width, line.FinalLine, line.Text)
+	}
+}
+
+// writeBlamePorcelain writes each line preceded by a header of the form
+// "<hash> <orig-line> <final-line> [<group-size>]", where the group size is
+// only present on the first line of a run of lines from the same commit.
+// Commit information is emitted the first time each commit appears.
+func writeBlamePorcelain(w io.Writer, path string, lines []blame.Line) {
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		if i == 0 || lines[i-1].Hash != line.Hash {
+			group := 1
+			for group < len(lines)-i && lines[i+group].Hash == line.Hash {
+				group++
+			}
+			fmt.Fprintf(w, "%s %d %d %d\n", line.Hash, line.OrigLine, line.FinalLine, group)
+			if !seen[line.Hash] {
+				seen[line.Hash] = true
+				fmt.Fprintf(w, "committer-time %d\n", line.Commit.Timestamp.Unix())
+				fmt.Fprintf(w, "committer-tz %s\n", line.Commit.Timestamp.Format("-0700"))
+				fmt.Fprintf(w, "summary %s\n", line.Commit.Summary())
+				if line.Boundary {
+					fmt.Fprintln(w, "boundary")
+				}
+			}
+			fmt.Fprintf(w, "filename %s\n", path)
+		} else {
+			fmt.Fprintf(w, "%s %d %d\n", line.Hash, line.OrigLine, line.FinalLine)
+		}
+		fmt.Fprintf(w, "\t%s\n", line.Text)
+	}
+}
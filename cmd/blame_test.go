@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlameCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := blameCmd(t, "test.txt")
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("no commits", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := blameCmd(t, "test.txt")
+		require.ErrorIs(t, err, commit.ErrNoCommits)
+	})
+
+	t.Run("path not in commit", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "a\n")
+		_, err := blameCmd(t, "other.txt")
+		require.ErrorIs(t, err, commit.ErrPathNotInCommit)
+	})
+
+	t.Run("attributes lines across history", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+
+		first := commitFile(t, tmpdir, testFile, "a\nb\nc\n")
+		second := commitFile(t, tmpdir, testFile, "a\nB\nc\nd\n")
+
+		out, err := blameCmd(t, "test.txt")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		require.Len(t, lines, 4)
+		require.True(t, strings.HasPrefix(lines[0], "^"+first[:7]))
+		require.True(t, strings.HasPrefix(lines[1], second[:8]))
+		require.True(t, strings.HasPrefix(lines[2], "^"+first[:7]))
+		require.True(t, strings.HasPrefix(lines[3], second[:8]))
+		require.True(t, strings.HasSuffix(lines[1], "2) B"))
+
+		// blame an older revision
+		out, err = blameCmd(t, "test.txt", "HEAD~1")
+		require.NoError(t, err)
+		require.Equal(t, 3, strings.Count(out, "^"+first[:7]))
+	})
+
+	t.Run("line range", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+
+		commitFile(t, tmpdir, testFile, "a\nb\nc\n")
+		second := commitFile(t, tmpdir, testFile, "a\nB\nc\n")
+
+		out, err := blameCmd(t, "-L", "2,2", "test.txt")
+		require.NoError(t, err)
+		require.Equal(t, 1, strings.Count(out, "\n"))
+		require.True(t, strings.HasPrefix(out, second[:8]))
+
+		out, err = blameCmd(t, "-L", "2,+2", "test.txt")
+		require.NoError(t, err)
+		require.Equal(t, 2, strings.Count(out, "\n"))
+
+		_, err = blameCmd(t, "-L", "5,6", "test.txt")
+		require.EqualError(t, err, "file has only 3 lines")
+
+		_, err = blameCmd(t, "-L", "x,y", "test.txt")
+		require.Error(t, err)
+	})
+
+	t.Run("ignore whitespace", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+
+		first := commitFile(t, tmpdir, testFile, "func() {\nreturn\n}\n")
+		second := commitFile(t, tmpdir, testFile, "func() {\n\treturn\n}\n")
+
+		out, err := blameCmd(t, "-L", "2,2", "test.txt")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, second[:8]))
+
+		out, err = blameCmd(t, "-w", "-L", "2,2", "test.txt")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, "^"+first[:7]))
+	})
+
+	t.Run("porcelain", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+
+		first := commitFile(t, tmpdir, testFile, "a\nb\n")
+
+		out, err := blameCmd(t, "--porcelain", "test.txt")
+		require.NoError(t, err)
+		expected := fmt.Sprintf("%s 1 1 2\n", first) +
+			"committer-time "
+		require.True(t, strings.HasPrefix(out, expected))
+		require.Contains(t, out, "summary update test.txt\nboundary\nfilename test.txt\n\ta\n")
+		require.True(t, strings.HasSuffix(out, fmt.Sprintf("%s 2 2\n\tb\n", first)))
+	})
+}
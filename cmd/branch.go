@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type branchOptions struct {
+	delete bool // -d, --delete
+	force  bool // -f, --force
+	format formatOptions
+}
+
+func NewBranchCmd() *cobra.Command {
+	opts := &branchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "branch [-d | -f] [<name> [<start>]]",
+		Short: "List, create, or delete branches",
+		Long: `
+	Without arguments, lists the branches, marking the one HEAD is on with an asterisk. Given a name, creates a branch pointing at the start
+	revision, or HEAD. With --force, an existing branch is moved instead. With --delete, the named branches are deleted.
+
+	The branch HEAD is on can be neither moved nor deleted.
+
+	When listing, --porcelain prints "<marker> <name> <hash>" per branch, where the marker is "*" for the current branch and a space otherwise.
+	--json prints an array of branch objects. The porcelain and JSON formats are stable across releases.
+	`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.delete {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.MaximumNArgs(2)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			if err := opts.format.check(porcelainV1); err != nil {
+				return err
+			}
+			return runBranch(cmd.OutOrStdout(), repo, args, opts)
+		},
+	}
+	addFormatFlags(cmd, &opts.format, porcelainV1)
+	cmd.Flags().BoolVarP(&opts.delete, "delete", "d", false, "Delete the named branches")
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "Move the branch if it already exists")
+	cmd.MarkFlagsMutuallyExclusive("delete", "force")
+	return cmd
+}
+
+type branchJSON struct {
+	Name    string `json:"name"`
+	Ref     string `json:"ref"`
+	Hash    string `json:"hash"`
+	Current bool   `json:"current"`
+}
+
+func runBranch(w io.Writer, repo *trac.Repository, args []string, opts *branchOptions) error {
+	if opts.format.machine() && (opts.delete || len(args) > 0) {
+		return errors.New("output formats only apply to listing branches")
+	}
+	switch {
+	case opts.delete:
+		for _, name := range args {
+			if err := repo.DeleteBranch(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Deleted branch %s\n", name)
+		}
+		return nil
+	case len(args) > 0:
+		start := "HEAD"
+		if len(args) > 1 {
+			start = args[1]
+		}
+		if opts.force {
+			return repo.SetBranch(args[0], start)
+		}
+		return repo.CreateBranch(args[0], start)
+	}
+	branches, err := repo.Branches()
+	if err != nil {
+		return err
+	}
+	current, _, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if opts.format.json {
+		out := make([]branchJSON, len(branches))
+		for i, b := range branches {
+			out[i] = branchJSON{Name: b.Short(), Ref: b.Name, Hash: b.Hash, Current: b.Name == current}
+		}
+		return writeJSON(w, out)
+	}
+	for _, b := range branches {
+		if opts.format.porcelain != "" {
+			marker := ' '
+			if b.Name == current {
+				marker = '*'
+			}
+			opts.format.record(w, "%c %s %s", marker, b.Short(), b.Hash)
+			continue
+		}
+		if b.Name == current {
+			fmt.Fprintf(w, "* %s\n", b.Short())
+		} else {
+			fmt.Fprintf(w, "  %s\n", b.Short())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	testFile := filepath.Join(tmpdir, "test.txt")
+	first := commitFile(t, tmpdir, testFile, "first")
+	second := commitFile(t, tmpdir, testFile, "second")
+
+	t.Run("create and list", func(t *testing.T) {
+		_, err := branchCmd(t, "feature", first)
+		require.NoError(t, err)
+		out, err := branchCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "  feature\n* main\n", out)
+
+		_, err = branchCmd(t, "feature")
+		require.ErrorIs(t, err, refs.ErrExists)
+		_, err = branchCmd(t, "bad..name")
+		require.ErrorIs(t, err, refs.ErrInvalidName)
+	})
+
+	t.Run("force moves a branch", func(t *testing.T) {
+		_, err := branchCmd(t, "-f", "feature", second)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "refs", "heads", "feature"))
+		require.NoError(t, err)
+		require.Equal(t, second+"\n", string(data))
+
+		_, err = branchCmd(t, "-f", "main", first)
+		require.ErrorIs(t, err, trac.ErrCurrentBranch)
+	})
+
+	t.Run("commits advance the current branch", func(t *testing.T) {
+		out, err := checkoutCmd(t, "feature")
+		require.NoError(t, err)
+		require.Equal(t, "Switched to branch 'feature'\n", out)
+		third := commitFile(t, tmpdir, testFile, "third")
+		data, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "refs", "heads", "feature"))
+		require.NoError(t, err)
+		require.Equal(t, third+"\n", string(data))
+		data, err = os.ReadFile(filepath.Join(tmpdir, ".trac", "refs", "heads", "main"))
+		require.NoError(t, err)
+		require.Equal(t, second+"\n", string(data))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := branchCmd(t, "-d", "feature")
+		require.ErrorIs(t, err, trac.ErrCurrentBranch)
+		_, err = checkoutCmd(t, "main")
+		require.NoError(t, err)
+		out, err := branchCmd(t, "-d", "feature")
+		require.NoError(t, err)
+		require.Equal(t, "Deleted branch feature\n", out)
+		_, err = branchCmd(t, "-d", "feature")
+		require.ErrorIs(t, err, refs.ErrNotFound)
+	})
+
+	t.Run("machine-readable output", func(t *testing.T) {
+		_, err := branchCmd(t, "topic", first)
+		require.NoError(t, err)
+
+		out, err := branchCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "* main "+second+"\n  topic "+first+"\n", out)
+		out, err = branchCmd(t, "-z")
+		require.NoError(t, err)
+		require.Equal(t, "* main "+second+"\x00  topic "+first+"\x00", out)
+		out, err = branchCmd(t, "--json")
+		require.NoError(t, err)
+		require.Equal(t, `[{"name":"main","ref":"refs/heads/main","hash":"`+second+`","current":true},`+
+			`{"name":"topic","ref":"refs/heads/topic","hash":"`+first+`","current":false}]`+"\n", out)
+
+		_, err = branchCmd(t, "--json", "other")
+		require.Error(t, err)
+		_, err = branchCmd(t, "--porcelain=v2")
+		require.ErrorContains(t, err, "unsupported porcelain version")
+	})
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type bundleCreateOptions struct {
+	all bool // --all
+}
+
+func NewBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Move history between repositories through files",
+		Long: `
+	A bundle is a single file holding refs and the history they point at, for repositories that cannot reach each other. Bundles are
+	written with bundle create and can be cloned and fetched from like any other repository, by giving the bundle's path as the URL.
+
+	A bundle made from a range such as v1.0..main leaves out the history of v1.0, and can only be used in a repository that has it.
+	`,
+		Args: cobra.NoArgs,
+	}
+	cmd.AddCommand(newBundleCreateCmd())
+	cmd.AddCommand(newBundleVerifyCmd())
+	cmd.AddCommand(newBundleListHeadsCmd())
+	cmd.AddCommand(newBundleUnbundleCmd())
+	return cmd
+}
+
+func newBundleCreateCmd() *cobra.Command {
+	opts := &bundleCreateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create <file> [<rev>...]",
+		Short: "Write refs and their history to a bundle",
+		Long: `
+	Writes the given refs and their history to a new bundle file. Each rev is a branch, a tag or HEAD to include, a range A..B including
+	B but leaving out the history of A, or ^A, leaving out the history of A. With --all, every branch and tag and HEAD are included.
+	`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			revs := args[1:]
+			if opts.all {
+				if revs, err = allRefNames(repo, revs); err != nil {
+					return err
+				}
+			}
+			if len(revs) == 0 {
+				return fmt.Errorf("no refs to bundle: name them or use --all")
+			}
+			return runBundleCreate(repo, args[0], revs)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Include every branch and tag, and HEAD")
+	return cmd
+}
+
+// allRefNames adds HEAD and the full names of every branch and tag to revs.
+func allRefNames(repo *trac.Repository, revs []string) ([]string, error) {
+	_, head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if head != "" {
+		revs = append(revs, "HEAD")
+	}
+	return branchAndTagNames(repo, revs)
+}
+
+// branchAndTagNames adds the full names of every branch and tag to revs.
+func branchAndTagNames(repo *trac.Repository, revs []string) ([]string, error) {
+	branches, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range append(branches, tags...) {
+		revs = append(revs, ref.Name)
+	}
+	return revs, nil
+}
+
+// runBundleCreate writes the bundle to a temporary file beside path and moves
+// it into place once complete, so an interrupted write never leaves a partial bundle.
+func runBundleCreate(repo *trac.Repository, path string, revs []string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := repo.CreateBundle(context.Background(), tmp, revs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newBundleVerifyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify <file>",
+		Short: "Check that a bundle is intact and can be used in this repository",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			h, err := repo.VerifyBundle(context.Background(), args[0])
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "The bundle contains %d ref(s):\n", len(h.Refs))
+			printBundleRefs(w, h.Refs)
+			if len(h.Prerequisites) == 0 {
+				fmt.Fprintln(w, "The bundle records a complete history.")
+			} else {
+				fmt.Fprintf(w, "The bundle requires %d commit(s):\n", len(h.Prerequisites))
+				for _, hash := range h.Prerequisites {
+					fmt.Fprintln(w, hash)
+				}
+			}
+			fmt.Fprintf(w, "%s is okay\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newBundleListHeadsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list-heads <file>",
+		Short: "List the refs in a bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h, err := trac.ReadBundleHeader(args[0])
+			if err != nil {
+				return err
+			}
+			printBundleRefs(cmd.OutOrStdout(), h.Refs)
+			return nil
+		},
+	}
+}
+
+func newBundleUnbundleCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unbundle <file>",
+		Short: "Store the objects of a bundle in this repository",
+		Long: `
+	Stores the commits and file contents in a bundle in this repository and lists the refs it holds. No refs are updated; use trac fetch
+	with the bundle's path to update refs as well.
+	`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			h, err := repo.Unbundle(context.Background(), args[0])
+			if err != nil {
+				return err
+			}
+			printBundleRefs(cmd.OutOrStdout(), h.Refs)
+			return nil
+		},
+	}
+}
+
+func printBundleRefs(w io.Writer, refs []trac.Ref) {
+	for _, ref := range refs {
+		fmt.Fprintf(w, "%s %s\n", ref.Hash, ref.Name)
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleCommand(t *testing.T) {
+	src := initRepository(t)
+	require.NoError(t, os.Chdir(src))
+	srcFile := filepath.Join(src, "test.txt")
+	first := commitFile(t, src, srcFile, "one")
+	_, err := tagCmd(t, "v1")
+	require.NoError(t, err)
+	second := commitFile(t, src, srcFile, "two")
+	bundles := t.TempDir()
+	full := filepath.Join(bundles, "full.bundle")
+
+	t.Run("create and inspect", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		out, err := bundleCmd(t, "create", full, "--all")
+		require.NoError(t, err)
+		require.Empty(t, out)
+
+		out, err = bundleCmd(t, "list-heads", full)
+		require.NoError(t, err)
+		require.Equal(t, second+" HEAD\n"+second+" refs/heads/main\n"+first+" refs/tags/v1\n", out)
+
+		out, err = bundleCmd(t, "verify", full)
+		require.NoError(t, err)
+		require.Contains(t, out, "The bundle contains 3 ref(s):\n")
+		require.Contains(t, out, "The bundle records a complete history.\n")
+		require.Contains(t, out, full+" is okay\n")
+
+		_, err = bundleCmd(t, "create", filepath.Join(bundles, "bad.bundle"), "HEAD~1")
+		require.ErrorIs(t, err, trac.ErrRefNotFound)
+		require.NoFileExists(t, filepath.Join(bundles, "bad.bundle"))
+		_, err = bundleCmd(t, "create", filepath.Join(bundles, "none.bundle"))
+		require.Error(t, err)
+		_, err = bundleCmd(t, "list-heads", srcFile)
+		require.ErrorIs(t, err, trac.ErrNotBundle)
+	})
+
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "full")
+
+	t.Run("clone from a bundle", func(t *testing.T) {
+		require.NoError(t, os.Chdir(parent))
+		_, err := cloneCmd(t, full)
+		require.NoError(t, err)
+		require.Equal(t, second, headHash(t, dst))
+		require.Equal(t, first, refHash(t, dst, "refs/tags/v1"))
+		data, err := os.ReadFile(filepath.Join(dst, "test.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "two", string(data))
+		require.NoError(t, os.Chdir(dst))
+		out, err := fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+
+		_, err = pushCmd(t)
+		require.ErrorIs(t, err, trac.ErrPermissionDenied)
+	})
+
+	t.Run("incremental bundles", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		third := commitFile(t, src, srcFile, "three")
+		incremental := filepath.Join(bundles, "incremental.bundle")
+		_, err := bundleCmd(t, "create", incremental, "v1..main")
+		require.NoError(t, err)
+		out, err := bundleCmd(t, "list-heads", incremental)
+		require.NoError(t, err)
+		require.Equal(t, third+" refs/heads/main\n", out)
+
+		// a repository without the prerequisites cannot use the bundle
+		empty := initRepository(t)
+		require.NoError(t, os.Chdir(empty))
+		_, err = bundleCmd(t, "verify", incremental)
+		require.ErrorIs(t, err, trac.ErrMissingPrerequisites)
+		_, err = bundleCmd(t, "unbundle", incremental)
+		require.ErrorIs(t, err, trac.ErrMissingPrerequisites)
+
+		require.NoError(t, os.Chdir(dst))
+		out, err = bundleCmd(t, "verify", incremental)
+		require.NoError(t, err)
+		require.Contains(t, out, "The bundle requires 1 commit(s):\n"+first+"\n")
+
+		out, err = bundleCmd(t, "unbundle", incremental)
+		require.NoError(t, err)
+		require.Equal(t, third+" refs/heads/main\n", out)
+		require.FileExists(t, objectPath(dst, third))
+		require.Equal(t, second, refHash(t, dst, "refs/remotes/origin/main"))
+
+		_, err = remoteCmd(t, "add", "usb", incremental)
+		require.NoError(t, err)
+		out, err = fetchCmd(t, "usb")
+		require.NoError(t, err)
+		require.Contains(t, out, "[new branch]       main -> usb/main\n")
+		require.Equal(t, third, refHash(t, dst, "refs/remotes/usb/main"))
+	})
+
+	t.Run("annotated tags", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		_, err := tagCmd(t, "-a", "-m", "release", "v2", first)
+		require.NoError(t, err)
+		tag := refHash(t, src, "refs/tags/v2")
+		require.NotEqual(t, first, tag)
+		tagged := filepath.Join(bundles, "tagged.bundle")
+		_, err = bundleCmd(t, "create", tagged, "main", "v2")
+		require.NoError(t, err)
+		out, err := bundleCmd(t, "list-heads", tagged)
+		require.NoError(t, err)
+		require.Contains(t, out, tag+" refs/tags/v2\n")
+
+		require.NoError(t, os.Chdir(parent))
+		_, err = cloneCmd(t, tagged)
+		require.NoError(t, err)
+		clone := filepath.Join(parent, "tagged")
+		require.Equal(t, tag, refHash(t, clone, "refs/tags/v2"))
+		require.NoError(t, os.Chdir(clone))
+		_, err = verifyTagCmd(t, "v2")
+		require.ErrorIs(t, err, trac.ErrUnsigned)
+		require.NotContains(t, err.Error(), "lightweight")
+	})
+
+	t.Run("damaged bundle", func(t *testing.T) {
+		data, err := os.ReadFile(full)
+		require.NoError(t, err)
+		data[len(data)-1] ^= 0xff
+		damaged := filepath.Join(bundles, "damaged.bundle")
+		require.NoError(t, os.WriteFile(damaged, data, 0644))
+
+		require.NoError(t, os.Chdir(initRepository(t)))
+		_, err = bundleCmd(t, "verify", damaged)
+		require.Error(t, err)
+		_, err = bundleCmd(t, "list-heads", damaged)
+		require.NoError(t, err)
+	})
+}
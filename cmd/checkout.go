@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type checkoutOptions struct {
+	newBranch string // Create a branch at the revision and switch to it
+}
+
+func NewCheckoutCmd() *cobra.Command {
+	opts := &checkoutOptions{}
+	cmd := &cobra.Command{
+		Use:   "checkout [-b <new-branch>] <rev>",
+		Short: "Switch branches or restore the working tree files of a revision",
+		Long: `
+	Updates the files in the working tree and the index to match the given revision. Files tracked at the current HEAD that do not exist in the
+	revision are removed.
+
+	If the revision is a branch name, HEAD is put on the branch so that new commits advance it. Any other revision detaches HEAD at the commit.
+	With -b, a new branch is created at the revision and checked out.
+
+	The checkout is refused if it would discard changes that have not been committed.
+
+	Once HEAD has moved, the post-checkout hook in .trac/hooks, or the directory set by core.hooksPath, runs in the root of the working
+	tree with the commit HEAD pointed at before, the commit it points at now, and "1", marking a checkout of a branch or commit. A hash
+	of 64 zeros stands for a branch with no commits. The hook's exit status is ignored.
+	`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runCheckout(cmd.OutOrStdout(), cmd.ErrOrStderr(), repo, args[0], opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.newBranch, "branch", "b", "", "Create a new branch at the revision and switch to it")
+	return cmd
+}
+
+func runCheckout(w, hookOutput io.Writer, repo *trac.Repository, rev string, opts *checkoutOptions) error {
+	ctx := context.Background()
+	_, old, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if err := switchTo(ctx, w, repo, rev, opts); err != nil {
+		return err
+	}
+	_, hash, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	hookOpts := trac.HookOptions{Args: []string{orZeroHash(old), orZeroHash(hash), "1"}, Output: hookOutput}
+	if _, err := repo.RunHook(ctx, trac.PostCheckoutHook, hookOpts); err != nil {
+		fmt.Fprintf(hookOutput, "warning: %v\n", err)
+	}
+	return nil
+}
+
+// switchTo checks out rev, first creating the branch named with -b if there is one.
+func switchTo(ctx context.Context, w io.Writer, repo *trac.Repository, rev string, opts *checkoutOptions) error {
+	if opts.newBranch != "" {
+		if err := repo.CreateBranch(opts.newBranch, rev); err != nil {
+			return err
+		}
+		if err := repo.Checkout(ctx, opts.newBranch); err != nil {
+			// leave no trace of a branch that was never checked out
+			_ = repo.DeleteBranch(opts.newBranch)
+			return err
+		}
+		fmt.Fprintf(w, "Switched to a new branch '%s'\n", opts.newBranch)
+		return nil
+	}
+	if err := repo.Checkout(ctx, rev); err != nil {
+		return err
+	}
+	branch, hash, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if branch != "" {
+		fmt.Fprintf(w, "Switched to branch '%s'\n", refs.Short(branch))
+		return nil
+	}
+	c, err := repo.ReadCommit(hash)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "HEAD is now at %s %s\n", hash[:8], c.Summary())
+	return nil
+}
+
+// printHead reports the commit HEAD now points at.
+func printHead(w io.Writer, l *layout.Layout, hash string) error {
+	c, err := commit.Load(hash, l)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "HEAD is now at %s %s\n", hash[:8], c.Summary())
+	return nil
+}
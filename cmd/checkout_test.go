@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/checkout"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckoutCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := checkoutCmd(t, "HEAD")
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "content")
+		_, err := checkoutCmd(t, "deadbeef")
+		require.ErrorIs(t, err, commit.ErrUnknownRevision)
+	})
+
+	t.Run("restores files of an older revision", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		newFile := filepath.Join(tmpdir, "subdir", "new.txt")
+
+		first := commitFile(t, tmpdir, testFile, "first")
+		require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "subdir"), 0755))
+		require.NoError(t, os.WriteFile(newFile, []byte("new"), 0644))
+		require.NoError(t, addCmd(t, newFile))
+		second := commitFile(t, tmpdir, testFile, "second")
+
+		out, err := checkoutCmd(t, first[:8])
+		require.NoError(t, err)
+		require.Equal(t, "HEAD is now at "+first[:8]+" update test.txt\n", out)
+		require.Equal(t, first, headHash(t, tmpdir))
+		data, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		require.Equal(t, "first", string(data))
+		require.NoFileExists(t, newFile)
+		require.Len(t, getIndex(t, tmpdir).Staged, 1)
+
+		_, err = checkoutCmd(t, second)
+		require.NoError(t, err)
+		data, err = os.ReadFile(newFile)
+		require.NoError(t, err)
+		require.Equal(t, "new", string(data))
+		require.Len(t, getIndex(t, tmpdir).Staged, 2)
+	})
+
+	t.Run("restores executable bits and symbolic links", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		script := filepath.Join(tmpdir, "run.sh")
+		link := filepath.Join(tmpdir, "link")
+
+		first := commitFile(t, tmpdir, script, "#!/bin/sh\n")
+		require.NoError(t, os.Chmod(script, 0755))
+		require.NoError(t, os.Symlink("run.sh", link))
+		require.NoError(t, addCmd(t, script, link))
+		require.NoError(t, commitCmd(t, "-m", "make run.sh executable and link to it"))
+		second := headHash(t, tmpdir)
+
+		_, err := checkoutCmd(t, first)
+		require.NoError(t, err)
+		info, err := os.Lstat(script)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+		require.NoFileExists(t, link)
+
+		_, err = checkoutCmd(t, second)
+		require.NoError(t, err)
+		info, err = os.Lstat(script)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		target, err := os.Readlink(link)
+		require.NoError(t, err)
+		require.Equal(t, "run.sh", target)
+		out, err := statusCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, out, "nothing to commit, working tree clean")
+
+		// The mode is part of the file, so changing it is a local change.
+		require.NoError(t, os.Chmod(script, 0644))
+		_, err = checkoutCmd(t, first)
+		require.ErrorIs(t, err, checkout.ErrLocalChanges)
+	})
+
+	t.Run("never writes beneath a symbolic link", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		outside := t.TempDir()
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		first := commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "first")
+
+		// A commit naming a file beneath a symbolic link it also holds is refused.
+		link, err := object.Write(l, []byte(outside))
+		require.NoError(t, err)
+		pwn, err := object.Write(l, []byte("pwn"))
+		require.NoError(t, err)
+		evil := &commit.Commit{
+			Parent:    first,
+			Message:   "evil",
+			Timestamp: time.Now(),
+			Changes:   map[string]string{"link": link, "link/f": pwn},
+			Modes:     map[string]string{"link": filemode.Symlink},
+		}
+		hash, err := evil.Write(l)
+		require.NoError(t, err)
+		require.NoError(t, refs.Write(l, refs.Branch("evil"), hash))
+		_, err = checkoutCmd(t, "evil")
+		require.ErrorIs(t, err, commit.ErrInvalidCommit)
+		require.NoFileExists(t, filepath.Join(outside, "f"))
+
+		// Nor is a file written through an untracked link in the working tree.
+		dir := filepath.Join(tmpdir, "dir")
+		require.NoError(t, os.Mkdir(dir, 0755))
+		second := commitFile(t, tmpdir, filepath.Join(dir, "f"), "content")
+		_, err = checkoutCmd(t, first)
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(dir))
+		require.NoError(t, os.Symlink(outside, dir))
+		_, err = checkoutCmd(t, second)
+		require.ErrorIs(t, err, layout.ErrBeyondSymlink)
+		require.NoFileExists(t, filepath.Join(outside, "f"))
+	})
+
+	t.Run("refuses to discard local changes", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+
+		commitFile(t, tmpdir, testFile, "first")
+		commitFile(t, tmpdir, testFile, "second")
+		require.NoError(t, os.WriteFile(testFile, []byte("modified"), 0644))
+
+		_, err := checkoutCmd(t, "HEAD~1")
+		require.ErrorIs(t, err, checkout.ErrLocalChanges)
+	})
+
+	t.Run("creates a branch with -b", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		first := commitFile(t, tmpdir, testFile, "first")
+		commitFile(t, tmpdir, testFile, "second")
+
+		out, err := checkoutCmd(t, "-b", "topic", first)
+		require.NoError(t, err)
+		require.Equal(t, "Switched to a new branch 'topic'\n", out)
+		head, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "HEAD"))
+		require.NoError(t, err)
+		require.Equal(t, "ref: refs/heads/topic\n", string(head))
+		require.Equal(t, first, headHash(t, tmpdir))
+
+		// the branch is not left behind if the checkout fails
+		require.NoError(t, os.WriteFile(testFile, []byte("modified"), 0644))
+		_, err = checkoutCmd(t, "-b", "other", "main")
+		require.ErrorIs(t, err, checkout.ErrLocalChanges)
+		require.NoFileExists(t, filepath.Join(tmpdir, ".trac", "refs", "heads", "other"))
+	})
+}
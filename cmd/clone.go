@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type cloneOptions struct {
+	origin      string // -o, --origin
+	branch      string // -b, --branch
+	objectStore string // --object-store
+}
+
+func NewCloneCmd() *cobra.Command {
+	opts := &cloneOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "clone <repository> [<directory>]",
+		Short: "Clone a repository into a new directory",
+		Long: `
+	Creates a repository in the given directory, or one named after the source repository, holding its whole history. The source is added as
+	the remote origin, its branches are fetched as remote-tracking branches and its tags as tags, and the branch its HEAD is on is created
+	and checked out.
+
+	The repository is either a local path, the path of a bundle, or the http:// URL of a repository served with trac serve.
+	`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := cloneDir(args[0])
+			if len(args) > 1 {
+				dir = args[1]
+			}
+			return runClone(cmd.OutOrStdout(), args[0], dir, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.origin, "origin", "o", trac.DefaultRemote, "Name of the remote for the source repository")
+	cmd.Flags().StringVarP(&opts.branch, "branch", "b", "", "Check out this branch instead of the one the source's HEAD is on")
+	cmd.Flags().StringVar(&opts.objectStore, "object-store", "loose", "Backend for the object store: loose or file")
+	return cmd
+}
+
+func runClone(w io.Writer, url, dir string, opts *cloneOptions) error {
+	if err := checkObjectStore(opts.objectStore); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Cloning into '%s'...\n", dir)
+	_, err := trac.Clone(context.Background(), url, dir, &trac.CloneOptions{
+		Remote:      opts.origin,
+		Branch:      opts.branch,
+		ObjectStore: opts.objectStore,
+		HTTP:        httpOptions(),
+	})
+	return err
+}
+
+// cloneDir returns the directory a repository is cloned into by default: the
+// last element of its path, without any .bundle extension, or the host of an
+// HTTP URL served from its root.
+func cloneDir(repoURL string) string {
+	if u, err := url.Parse(repoURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			return name
+		}
+		return u.Hostname()
+	}
+	return strings.TrimSuffix(filepath.Base(filepath.Clean(strings.TrimPrefix(repoURL, "file://"))), ".bundle")
+}
@@ -1,70 +1,425 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/lucasrod16/trac/internal/commit"
-	"github.com/lucasrod16/trac/internal/index"
-	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/pkg/trac"
 	"github.com/spf13/cobra"
 )
 
 type commitOptions struct {
-	message string // -m, --message
+	messages []string // -m, --message
+	file     string   // -F, --file
+	template string   // -t, --template
+	edit     bool     // -e, --edit
+	cleanup  string   // --cleanup
+	noVerify bool     // -n, --no-verify
+	amend    bool     // --amend
+	noEdit   bool     // --no-edit
+	all      bool     // -a, --all
+
+	allowEmpty        bool // --allow-empty
+	allowEmptyMessage bool // --allow-empty-message
+	sign              bool // -S, --sign
 }
 
+// Modes of cleaning up a commit message, for --cleanup.
+const (
+	cleanupDefault    = "default"
+	cleanupStrip      = "strip"
+	cleanupWhitespace = "whitespace"
+	cleanupVerbatim   = "verbatim"
+	cleanupScissors   = "scissors"
+)
+
+// scissors marks the start of the part of the message file that is ignored with --cleanup=scissors.
+const scissors = "# ------------------------ >8 ------------------------"
+
+// templateKey is the config key naming a file to start commit messages from.
+const templateKey = "commit.template"
+
 func NewCommitCmd() *cobra.Command {
 	opts := &commitOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "commit",
+		Use:   "commit [-a | --amend] [-S] [-m <msg>]... [-F <file>] [-e] [--cleanup <mode>] [<path>...]",
 		Short: "Record changes to the repository",
 		Long: `
 	Create a new commit containing the current contents of the index and the given log message describing the changes.
 	The new commit is a direct child of HEAD, usually the tip of the current branch, and the branch is updated to point to it.
+
+	With -a, the current contents of every tracked file are staged first, and tracked files deleted from the working tree are
+	removed from the index. Given paths, only the current contents of the tracked files they name, or hold, are committed; changes
+	staged for other files are left staged. A path that matches no tracked file is an error.
+
+	--amend replaces HEAD with a new commit instead, made on top of HEAD's parent and keeping its author. Unless a message is given,
+	it keeps HEAD's message too, which is opened in the editor unless --no-edit is given.
+
+	A commit that records the same files as its parent is refused, unless --allow-empty is given, as for a commit that only marks
+	a point in history. So is a commit with an empty message, unless --allow-empty-message is given.
+
+	-S signs the commit with the Ed25519 key set by signing.key, an unencrypted OpenSSH private key such as ssh-keygen -t ed25519
+	writes, or a PKCS #8 PEM file. See trac verify-commit.
+
+	The message is given with -m, once per paragraph, or read from a file with -F, or from standard input with -F -. Otherwise it is
+	written in an editor, $TRAC_EDITOR, $VISUAL or $EDITOR in that order, or vi, opened on .trac/COMMIT_EDITMSG. The file starts out
+	with the template named by -t or the commit.template option, if any, and a summary of the changes in comment lines starting with
+	"#". -e opens the editor on a message given with -m or -F as well. A commit is aborted if its message is the template left
+	unedited.
+
+	--cleanup chooses how the message is tidied before it is recorded:
+	  strip       Remove leading and trailing blank lines, trailing whitespace and comment lines, and collapse runs of blank
+	              lines. The default when the message is edited.
+	  whitespace  Like strip, but keep comment lines. The default otherwise.
+	  verbatim    Record the message as it is.
+	  scissors    Like whitespace, but when the message is edited, drop everything from the scissors line the file is
+	              given, "` + scissors + `", on.
+
+	Hooks in .trac/hooks, or the directory set by core.hooksPath, run around the commit in the root of the working tree:
+	  pre-commit          Runs first, with no arguments, once -a or paths have staged their changes. A non-zero exit
+	                      status aborts the commit, and like any other abort leaves the index as it was before.
+	  prepare-commit-msg  Runs before the editor with the path of the message file, followed by the source of the message:
+	                      "message" for -m and -F, "template" for a template, "commit" for the message of an
+	                      amended commit, or nothing. It may edit the file.
+	  commit-msg          Runs with the path of the message file after the editor, to check or edit the message.
+	  post-commit         Runs once the commit is recorded, with no arguments. Its exit status is ignored.
+	A non-zero exit status from any of the others aborts the commit. With --no-verify, pre-commit and commit-msg are skipped.
 	`,
-		Args: cobra.MaximumNArgs(0),
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCommit(cmd.OutOrStdout(), opts)
+			if opts.all && len(args) > 0 {
+				return errors.New("paths cannot be used with -a")
+			}
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runCommit(cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(), repo, args, opts)
 		},
 	}
-	cmd.Flags().StringVarP(&opts.message, "message", "m", "", "Commit message")
-	cmd.MarkFlagRequired("message")
+	cmd.Flags().StringArrayVarP(&opts.messages, "message", "m", nil, "Commit message; given more than once, each is a paragraph")
+	cmd.Flags().StringVarP(&opts.file, "file", "F", "", "Read the commit message from a file, or standard input for -")
+	cmd.Flags().StringVarP(&opts.template, "template", "t", "", "Start the message in the editor from this file")
+	cmd.Flags().BoolVarP(&opts.edit, "edit", "e", false, "Edit the message given with -m or -F")
+	cmd.Flags().StringVar(&opts.cleanup, "cleanup", cleanupDefault, "How to clean up the message: strip, whitespace, verbatim, scissors or default")
+	cmd.Flags().BoolVarP(&opts.noVerify, "no-verify", "n", false, "Skip the pre-commit and commit-msg hooks")
+	cmd.Flags().BoolVar(&opts.amend, "amend", false, "Replace HEAD with a new commit")
+	cmd.Flags().BoolVar(&opts.noEdit, "no-edit", false, "Keep the message of the amended commit without editing it")
+	cmd.Flags().BoolVarP(&opts.all, "all", "a", false, "Stage modified and deleted tracked files first")
+	cmd.Flags().BoolVar(&opts.allowEmpty, "allow-empty", false, "Allow a commit that records the same files as its parent")
+	cmd.Flags().BoolVar(&opts.allowEmptyMessage, "allow-empty-message", false, "Allow a commit with an empty message")
+	cmd.Flags().BoolVarP(&opts.sign, "sign", "S", false, "Sign the commit with the key set by signing.key")
+	cmd.MarkFlagsMutuallyExclusive("message", "file")
+	cmd.MarkFlagsMutuallyExclusive("edit", "no-edit")
 	return cmd
 }
 
-func runCommit(w io.Writer, opts *commitOptions) error {
-	cwd, err := os.Getwd()
+func runCommit(r io.Reader, w, hookOutput io.Writer, repo *trac.Repository, paths []string, opts *commitOptions) (err error) {
+	ctx := context.Background()
+	hookOpts := trac.HookOptions{Output: hookOutput}
+	if opts.all || len(paths) > 0 {
+		// Stage the changes first, so that pre-commit sees what is committed,
+		// and unstage them again if no commit is made.
+		restore, stageErr := repo.StageTracked(ctx, paths...)
+		if stageErr != nil {
+			return stageErr
+		}
+		defer func() {
+			if err != nil {
+				err = errors.Join(err, restore())
+			}
+		}()
+	}
+	if !opts.noVerify {
+		if _, err := repo.RunHook(ctx, trac.PreCommitHook, hookOpts); err != nil {
+			return err
+		}
+	}
+	message, err := commitMessage(ctx, r, hookOutput, repo, paths, opts)
 	if err != nil {
 		return err
 	}
-	layout, err := layout.New(cwd)
+	c, err := repo.Commit(ctx, message, trac.CommitOptions{
+		Amend:             opts.amend,
+		All:               opts.all,
+		Paths:             paths,
+		Staged:            true,
+		AllowEmpty:        opts.allowEmpty,
+		AllowEmptyMessage: opts.allowEmptyMessage,
+		Sign:              opts.sign,
+	})
 	if err != nil {
 		return err
 	}
-	if err := layout.ValidateIsRepo(); err != nil {
-		return err
+	fmt.Fprintf(w, "Created commit %s\n", c.Hash)
+	if _, err := repo.RunHook(ctx, trac.PostCommitHook, hookOpts); err != nil {
+		fmt.Fprintf(hookOutput, "warning: %v\n", err)
 	}
-	idx := index.New()
-	if err := idx.Load(layout); err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return commit.ErrNothingAddedToCommit
+	return nil
+}
+
+// commitMessage works out the message of the commit being made: it writes the
+// message given on the command line, or the template, to the message file,
+// passes the file through the prepare-commit-msg hook, the editor and the
+// commit-msg hook, and cleans up what they leave in it.
+func commitMessage(ctx context.Context, r io.Reader, hookOutput io.Writer, repo *trac.Repository, paths []string, opts *commitOptions) (string, error) {
+	var message, source, template string
+	edit := opts.edit
+	switch {
+	case len(opts.messages) > 0:
+		message, source = strings.Join(opts.messages, "\n\n"), "message"
+	case opts.file != "":
+		data, err := readMessageFile(r, opts.file)
+		if err != nil {
+			return "", err
+		}
+		message, source = string(data), "message"
+	case opts.amend:
+		_, hash, err := repo.Head()
+		if err != nil {
+			return "", err
+		}
+		if hash == "" {
+			return "", trac.ErrNoCommits
+		}
+		head, err := repo.ReadCommit(hash)
+		if err != nil {
+			return "", err
+		}
+		message, source = head.Message, "commit"
+		edit = !opts.noEdit
+	default:
+		edit = true
+		name := opts.template
+		if name == "" {
+			var err error
+			if name, err = repo.ConfigValue(templateKey); err != nil {
+				return "", err
+			}
+			name = expandPath(repo.Root(), name)
+		}
+		if name != "" {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return "", fmt.Errorf("could not read commit message template: %w", err)
+			}
+			message, source, template = string(data), "template", string(data)
 		}
-		return err
 	}
-	parentHash, err := commit.GetParentHash(layout)
-	if err != nil {
-		return err
+	mode := opts.cleanup
+	if !slices.Contains([]string{cleanupDefault, cleanupStrip, cleanupWhitespace, cleanupVerbatim, cleanupScissors}, mode) {
+		return "", fmt.Errorf("invalid cleanup mode %q", mode)
+	}
+	if mode == cleanupDefault {
+		mode = cleanupWhitespace
+		if edit {
+			mode = cleanupStrip
+		}
+	}
+
+	var content strings.Builder
+	content.WriteString(message)
+	if message != "" && !strings.HasSuffix(message, "\n") {
+		content.WriteString("\n")
+	}
+	if edit {
+		st, err := repo.Status(ctx)
+		if err != nil {
+			return "", err
+		}
+		writeCommitComments(&content, committedStatus(st, repo.Root(), paths, opts.all), mode)
+	}
+	file := repo.CommitMsgFile()
+	if err := os.WriteFile(file, []byte(content.String()), 0644); err != nil {
+		return "", err
+	}
+
+	args := []string{file}
+	if source != "" {
+		args = append(args, source)
+	}
+	if _, err := repo.RunHook(ctx, trac.PrepareCommitMsgHook, trac.HookOptions{Args: args, Output: hookOutput}); err != nil {
+		return "", err
+	}
+	if edit {
+		if err := launchEditor(file); err != nil {
+			return "", fmt.Errorf("there was a problem with the editor %q: %w", editor(), err)
+		}
+	}
+	if !opts.noVerify {
+		if _, err := repo.RunHook(ctx, trac.CommitMsgHook, trac.HookOptions{Args: []string{file}, Output: hookOutput}); err != nil {
+			return "", err
+		}
 	}
-	newCommit := commit.New(opts.message, parentHash, idx.Staged)
-	commitHash, err := newCommit.Save(layout)
+	data, err := os.ReadFile(file)
 	if err != nil {
-		return err
+		return "", err
 	}
-	fmt.Fprintf(w, "Created commit %s\n", commitHash)
-	return nil
+
+	message = cleanupMessage(string(data), mode, edit)
+	if strings.TrimSpace(message) == "" && !opts.allowEmptyMessage {
+		return "", trac.ErrEmptyMessage
+	}
+	if template != "" && message == cleanupMessage(template, mode, edit) {
+		return "", errors.New("aborting commit; the message template was not edited")
+	}
+	return message, nil
+}
+
+// readMessageFile reads a commit message from the named file, or r for "-".
+func readMessageFile(r io.Reader, name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(r)
+	}
+	return os.ReadFile(name)
+}
+
+// expandPath resolves a path from the configuration: ~/ is the home directory
+// and relative paths are relative to the root of the repository.
+func expandPath(root, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if path != "" && !filepath.IsAbs(path) {
+		return filepath.Join(root, path)
+	}
+	return path
+}
+
+// writeCommitComments writes the comment lines that explain the message file
+// and summarize the changes being committed.
+func writeCommitComments(w io.Writer, st *trac.Status, mode string) {
+	fmt.Fprintln(w)
+	if mode == cleanupScissors {
+		fmt.Fprintln(w, scissors)
+		fmt.Fprintln(w, "# Do not modify or remove the line above.")
+		fmt.Fprintln(w, "# Everything below it will be ignored.")
+	} else if mode == cleanupStrip {
+		fmt.Fprintln(w, "# Please enter the commit message for your changes. Lines starting")
+		fmt.Fprintln(w, "# with '#' will be ignored, and an empty message aborts the commit.")
+	} else {
+		fmt.Fprintln(w, "# Please enter the commit message for your changes. Lines starting")
+		fmt.Fprintln(w, "# with '#' will be kept; you may remove them yourself if you want to.")
+		fmt.Fprintln(w, "# An empty message aborts the commit.")
+	}
+	fmt.Fprintln(w, "#")
+	switch {
+	case st.Branch != "":
+		fmt.Fprintf(w, "# On branch %s\n", shortBranch(st.Branch))
+	case st.Head != "":
+		fmt.Fprintf(w, "# HEAD detached at %s\n", st.Head[:8])
+	}
+	if st.Head == "" {
+		fmt.Fprintln(w, "#")
+		fmt.Fprintln(w, "# Initial commit")
+	}
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintln(w, "#")
+		fmt.Fprintf(w, "# %s\n", title)
+		for _, line := range lines {
+			fmt.Fprintf(w, "#\t%s\n", line)
+		}
+	}
+	changeLines := func(changes []trac.Change) []string {
+		var lines []string
+		for _, c := range changes {
+			lines = append(lines, fmt.Sprintf("%-12s%s", c.Kind.String()+":", changePath(c)))
+		}
+		return lines
+	}
+	section("Changes to be committed:", changeLines(st.Staged))
+	section("Changes not staged for commit:", changeLines(st.Unstaged))
+	var untracked []string
+	for _, path := range st.Untracked {
+		if path = preparePath(path); !slices.Contains(untracked, path) {
+			untracked = append(untracked, path)
+		}
+	}
+	section("Untracked files:", untracked)
+	fmt.Fprintln(w, "#")
+}
+
+// committedStatus adjusts st for the message file so that the changes -a or
+// paths add to the commit are listed as to be committed.
+func committedStatus(st *trac.Status, root string, paths []string, all bool) *trac.Status {
+	if !all && len(paths) == 0 {
+		return st
+	}
+	committed := func(path string) bool {
+		return all || slices.ContainsFunc(paths, func(p string) bool {
+			if abs, err := filepath.Abs(p); err == nil {
+				if rel, err := filepath.Rel(root, abs); err == nil {
+					p = filepath.ToSlash(rel)
+				}
+			}
+			return p == "." || path == p || strings.HasPrefix(path, p+"/")
+		})
+	}
+	adjusted := *st
+	adjusted.Staged, adjusted.Unstaged = nil, nil
+	for _, c := range st.Staged {
+		// Given paths, changes staged for other files are not committed.
+		if all || committed(c.Path) {
+			adjusted.Staged = append(adjusted.Staged, c)
+		}
+	}
+	for _, c := range st.Unstaged {
+		if !committed(c.Path) {
+			adjusted.Unstaged = append(adjusted.Unstaged, c)
+			continue
+		}
+		i := slices.IndexFunc(adjusted.Staged, func(s trac.Change) bool { return s.Path == c.Path })
+		switch {
+		case i < 0:
+			adjusted.Staged = append(adjusted.Staged, c)
+		case c.Kind == trac.Deleted && (adjusted.Staged[i].Kind == trac.Added || adjusted.Staged[i].Kind == trac.Copied):
+			adjusted.Staged = slices.Delete(adjusted.Staged, i, i+1)
+		case c.Kind == trac.Deleted && adjusted.Staged[i].Kind == trac.Renamed:
+			// What is left of a rename whose new file is deleted is the deletion of the old one.
+			s := adjusted.Staged[i]
+			adjusted.Staged[i] = trac.Change{Path: s.OldPath, Kind: trac.Deleted, OldHash: s.OldHash, OldMode: s.OldMode}
+		case c.Kind == trac.Deleted:
+			adjusted.Staged[i].Kind = trac.Deleted
+		}
+	}
+	slices.SortFunc(adjusted.Staged, func(a, b trac.Change) int { return strings.Compare(a.Path, b.Path) })
+	return &adjusted
+}
+
+// cleanupMessage tidies a commit message according to a --cleanup mode other
+// than default. edited reports whether the message went through the editor,
+// which is when the scissors line applies.
+func cleanupMessage(message, mode string, edited bool) string {
+	if mode == cleanupVerbatim {
+		// Only the newline ending the last line of the file is not part of the message.
+		return strings.TrimSuffix(message, "\n")
+	}
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if mode == cleanupScissors && edited && line == scissors {
+			break
+		}
+		if mode == cleanupStrip && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		// Collapse runs of blank lines, and drop those at the start.
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
 }
@@ -3,11 +3,14 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/lucasrod16/trac/internal/commit"
 	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
 	"github.com/lucasrod16/trac/internal/utils"
+	"github.com/lucasrod16/trac/pkg/trac"
 	"github.com/stretchr/testify/require"
 )
 
@@ -56,4 +59,301 @@ func TestCommitCommand(t *testing.T) {
 		require.NoError(t, err)
 		require.NotEmpty(t, latestCommit)
 	})
+
+	t.Run("commit records staged content, not working tree content", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+
+		testFile := filepath.Join(tmpdir, "test.txt")
+		require.NoError(t, os.WriteFile(testFile, []byte("staged"), 0644))
+		require.NoError(t, addCmd(t, testFile))
+		require.NoError(t, os.WriteFile(testFile, []byte("modified after add"), 0644))
+		require.NoError(t, commitCmd(t, "-m", "test commit message"))
+
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		c, err := commit.Load(headHash(t, tmpdir), l)
+		require.NoError(t, err)
+		hash, err := c.Lookup(testFile, l)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(".trac", "objects", hash[:2], hash[2:]))
+		require.NoError(t, err)
+		require.Equal(t, "staged", string(data))
+	})
+
+	t.Run("commit refuses corrupt objects", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+
+		testFile := filepath.Join(tmpdir, "test.txt")
+		require.NoError(t, os.WriteFile(testFile, []byte("content"), 0644))
+		require.NoError(t, addCmd(t, testFile))
+
+		hash := stagedHash(t, tmpdir, testFile)
+		require.NoError(t, os.WriteFile(filepath.Join(".trac", "objects", hash[:2], hash[2:]), []byte("tampered"), 0644))
+
+		err := commitCmd(t, "-m", "test commit message")
+		require.ErrorIs(t, err, object.ErrCorrupt)
+		require.Empty(t, headHash(t, tmpdir))
+	})
+}
+
+func TestCommitMessage(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	file := filepath.Join(tmpdir, "file.txt")
+	stage := func(t *testing.T, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+		require.NoError(t, addCmd(t, file))
+	}
+	setEditor(t, "exit 1\n")
+
+	t.Run("each -m is a paragraph", func(t *testing.T) {
+		stage(t, "one\n")
+		require.NoError(t, commitCmd(t, "-m", "Subject", "-m", "Body line one.\nBody line two.  "))
+		require.Equal(t, "Subject\n\nBody line one.\nBody line two.", headMessage(t, tmpdir))
+	})
+
+	t.Run("reads the message from a file", func(t *testing.T) {
+		stage(t, "two\n")
+		name := filepath.Join(t.TempDir(), "message")
+		require.NoError(t, os.WriteFile(name, []byte("\n\nFrom a file\n\n\n# kept\n"), 0644))
+		require.NoError(t, commitCmd(t, "-F", name))
+		require.Equal(t, "From a file\n\n# kept", headMessage(t, tmpdir))
+		require.Error(t, commitCmd(t, "-F", name, "-m", "both"))
+	})
+
+	t.Run("opens the editor with a summary of the changes", func(t *testing.T) {
+		stage(t, "three\n")
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "untracked.txt"), nil, 0644))
+		saved := filepath.Join(t.TempDir(), "saved")
+		setEditor(t, `cp "$1" `+saved+`
+printf 'Edited subject\n\n# dropped comment\nBody\n' > "$1"
+`)
+		require.NoError(t, commitCmd(t))
+		require.Equal(t, "Edited subject\n\nBody", headMessage(t, tmpdir))
+		data, err := os.ReadFile(saved)
+		require.NoError(t, err)
+		require.Equal(t, `
+# Please enter the commit message for your changes. Lines starting
+# with '#' will be ignored, and an empty message aborts the commit.
+#
+# On branch main
+#
+# Changes to be committed:
+#	modified:   file.txt
+#
+# Untracked files:
+#	untracked.txt
+#
+`, string(data))
+		require.NoError(t, os.Remove(filepath.Join(tmpdir, "untracked.txt")))
+	})
+
+	t.Run("aborts on an empty message", func(t *testing.T) {
+		stage(t, "four\n")
+		before := headHash(t, tmpdir)
+		setEditor(t, "exit 0\n")
+		require.ErrorIs(t, commitCmd(t), trac.ErrEmptyMessage)
+		require.ErrorIs(t, commitCmd(t, "-m", "  "), trac.ErrEmptyMessage)
+		require.Equal(t, before, headHash(t, tmpdir))
+
+		setEditor(t, "exit 3\n")
+		require.ErrorContains(t, commitCmd(t), "there was a problem with the editor")
+	})
+
+	t.Run("edits messages given with -e", func(t *testing.T) {
+		setEditor(t, `sed -i 's/draft/final/' "$1"`+"\n")
+		require.NoError(t, commitCmd(t, "-e", "-m", "draft message"))
+		require.Equal(t, "final message", headMessage(t, tmpdir))
+	})
+
+	t.Run("starts from a template", func(t *testing.T) {
+		stage(t, "five\n")
+		template := filepath.Join(tmpdir, "template.txt")
+		require.NoError(t, os.WriteFile(template, []byte("Area: \n\n# Explain why.\n"), 0644))
+		_, err := configCmd(t, "commit.template", "template.txt")
+		require.NoError(t, err)
+
+		setEditor(t, "exit 0\n")
+		require.ErrorContains(t, commitCmd(t), "template was not edited")
+
+		setEditor(t, `sed -i 's/^Area: $/Area: docs/' "$1"`+"\n")
+		require.NoError(t, commitCmd(t))
+		require.Equal(t, "Area: docs", headMessage(t, tmpdir))
+		_, err = configCmd(t, "--unset", "commit.template")
+		require.NoError(t, err)
+	})
+
+	t.Run("cleanup modes", func(t *testing.T) {
+		edited := "\nSubject  \n\n\n# comment\n" + scissors + "\nbelow\n"
+		setEditor(t, "printf '"+strings.ReplaceAll(edited, "\n", `\n`)+"' > \"$1\"\n")
+		for mode, want := range map[string]string{
+			"strip":      "Subject\n\nbelow",
+			"whitespace": "Subject\n\n# comment\n" + scissors + "\nbelow",
+			"verbatim":   edited,
+			"scissors":   "Subject\n\n# comment",
+		} {
+			stage(t, mode+"\n")
+			require.NoError(t, commitCmd(t, "--cleanup", mode), mode)
+			require.Equal(t, strings.TrimSuffix(want, "\n"), headMessage(t, tmpdir), mode)
+		}
+		require.ErrorContains(t, commitCmd(t, "--cleanup", "nope", "-m", "x"), `invalid cleanup mode "nope"`)
+	})
+}
+
+func TestCommitAmendAndPaths(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	a := filepath.Join(tmpdir, "a.txt")
+	b := filepath.Join(tmpdir, "b.txt")
+	require.NoError(t, os.WriteFile(a, []byte("a1\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("b1\n"), 0644))
+	require.NoError(t, addCmd(t, a, b))
+	require.NoError(t, commitCmd(t, "-m", "first"))
+	first := headHash(t, tmpdir)
+	setEditor(t, "exit 1\n")
+
+	t.Run("--amend replaces HEAD", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(a, []byte("a2\n"), 0644))
+		require.NoError(t, addCmd(t, a))
+		require.NoError(t, commitCmd(t, "-m", "second"))
+		second := headHash(t, tmpdir)
+
+		require.NoError(t, os.WriteFile(b, []byte("b2\n"), 0644))
+		require.NoError(t, addCmd(t, b))
+		require.NoError(t, commitCmd(t, "--amend", "--no-edit"))
+		require.NotEqual(t, second, headHash(t, tmpdir))
+		require.Equal(t, "second", headMessage(t, tmpdir))
+		out, err := logCmd(t, "--oneline")
+		require.NoError(t, err)
+		require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+		status, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Empty(t, status)
+
+		require.NoError(t, commitCmd(t, "--amend", "-m", "reworded"))
+		require.Equal(t, "reworded", headMessage(t, tmpdir))
+
+		setEditor(t, `sed -i 's/reworded/edited/' "$1"`+"\n")
+		require.NoError(t, commitCmd(t, "--amend"))
+		require.Equal(t, "edited", headMessage(t, tmpdir))
+		setEditor(t, "exit 1\n")
+
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		c, err := commit.Load(headHash(t, tmpdir), l)
+		require.NoError(t, err)
+		require.Equal(t, first, c.Parent)
+	})
+
+	t.Run("--amend needs a commit", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		file := filepath.Join(tmpdir, "file.txt")
+		require.NoError(t, os.WriteFile(file, []byte("content"), 0644))
+		require.NoError(t, addCmd(t, file))
+		require.ErrorIs(t, commitCmd(t, "--amend", "-m", "x"), trac.ErrNoCommits)
+		require.ErrorIs(t, commitCmd(t, "--amend", "--no-edit"), trac.ErrNoCommits)
+	})
+	require.NoError(t, os.Chdir(tmpdir))
+
+	t.Run("-a stages modified and deleted files", func(t *testing.T) {
+		untracked := filepath.Join(tmpdir, "untracked.txt")
+		require.NoError(t, os.WriteFile(untracked, nil, 0644))
+		require.NoError(t, os.WriteFile(a, []byte("a3\n"), 0644))
+		require.NoError(t, os.Remove(b))
+		require.NoError(t, commitCmd(t, "-a", "-m", "all"))
+		status, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "?? untracked.txt\n", status)
+		require.NoError(t, os.Remove(untracked))
+
+		require.NoError(t, os.WriteFile(b, []byte("b3\n"), 0644))
+		require.NoError(t, addCmd(t, b))
+		require.NoError(t, commitCmd(t, "-m", "restore b"))
+	})
+
+	t.Run("commits only the given paths", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(a, []byte("a4\n"), 0644))
+		require.NoError(t, addCmd(t, a))
+		require.NoError(t, os.WriteFile(b, []byte("b4\n"), 0644))
+		saved := filepath.Join(t.TempDir(), "saved")
+		setEditor(t, `grep '^#' "$1" > `+saved+`
+echo 'Only b' > "$1"
+`)
+		require.NoError(t, commitCmd(t, "b.txt"))
+		require.Equal(t, "Only b", headMessage(t, tmpdir))
+		data, err := os.ReadFile(saved)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "# Changes to be committed:\n#\tmodified:   b.txt\n#\n")
+		require.NotContains(t, string(data), "a.txt")
+
+		status, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "M  a.txt\n", status)
+
+		require.ErrorIs(t, commitCmd(t, "-m", "x", "missing.txt"), trac.ErrPathNotTracked)
+		require.ErrorContains(t, commitCmd(t, "-a", "-m", "x", "a.txt"), "paths cannot be used with -a")
+	})
+}
+
+func TestCommitEmpty(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	file := filepath.Join(tmpdir, "file.txt")
+
+	require.ErrorIs(t, commitCmd(t, "-m", "nothing"), trac.ErrNothingAdded)
+	require.NoError(t, commitCmd(t, "--allow-empty", "-m", "root"))
+	require.Equal(t, "root", headMessage(t, tmpdir))
+
+	require.NoError(t, os.WriteFile(file, []byte("one\n"), 0644))
+	require.NoError(t, addCmd(t, file))
+	require.NoError(t, commitCmd(t, "-m", "first"))
+	first := headHash(t, tmpdir)
+
+	t.Run("distinguishes unstaged changes from a clean tree", func(t *testing.T) {
+		require.ErrorIs(t, commitCmd(t, "-m", "again"), trac.ErrNothingToCommit)
+
+		untracked := filepath.Join(tmpdir, "untracked.txt")
+		require.NoError(t, os.WriteFile(untracked, nil, 0644))
+		require.ErrorIs(t, commitCmd(t, "-m", "again"), trac.ErrNothingAdded)
+		require.NoError(t, os.Remove(untracked))
+
+		require.NoError(t, os.WriteFile(file, []byte("two\n"), 0644))
+		require.ErrorIs(t, commitCmd(t, "-m", "again"), trac.ErrNothingStaged)
+		require.NoError(t, os.WriteFile(file, []byte("one\n"), 0644))
+		require.Equal(t, first, headHash(t, tmpdir))
+	})
+
+	t.Run("compares trees rather than how paths are recorded", func(t *testing.T) {
+		idx := getIndex(t, tmpdir)
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		key, ok, err := idx.Find(file, l)
+		require.NoError(t, err)
+		require.True(t, ok)
+		hash := idx.Staged[key]
+		delete(idx.Staged, key)
+		idx.Staged[file] = hash
+		require.NoError(t, idx.Write(l))
+		require.ErrorIs(t, commitCmd(t, "-m", "same tree"), trac.ErrNothingToCommit)
+	})
+
+	t.Run("--allow-empty records a marker commit", func(t *testing.T) {
+		require.NoError(t, commitCmd(t, "--allow-empty", "-m", "release 1.0"))
+		require.Equal(t, "release 1.0", headMessage(t, tmpdir))
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		c, err := commit.Load(headHash(t, tmpdir), l)
+		require.NoError(t, err)
+		require.Equal(t, first, c.Parent)
+	})
+
+	t.Run("--allow-empty-message", func(t *testing.T) {
+		require.ErrorIs(t, commitCmd(t, "--allow-empty", "-m", ""), trac.ErrEmptyMessage)
+		require.NoError(t, commitCmd(t, "--allow-empty", "--allow-empty-message", "-m", ""))
+		require.Empty(t, headMessage(t, tmpdir))
+	})
 }
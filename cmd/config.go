@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/spf13/cobra"
+)
+
+type configOptions struct {
+	list  bool // -l, --list
+	unset bool // --unset
+}
+
+func NewConfigCmd() *cobra.Command {
+	opts := &configOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "config [--list | --unset <key> | <key> [<value>]]",
+		Short: "Get and set repository options",
+		Long: `
+	Reads and writes the repository configuration in .trac/config.json. Keys take the form <section>.<name>, e.g. core.chunkThreshold.
+
+	With a key, prints its value. With a key and a value, sets it. With --unset, removes the key. With --list, prints every key and value.
+
+	Recognized options:
+	  commit.template      File to start commit messages written in the editor from, absolute, relative to the root of the
+	                       working tree, or starting with ~/ for the home directory. See commit -t.
+	  core.chunkThreshold  Files at least this many bytes are stored as content-defined chunks, so that edits to large files
+	                       only store the changed chunks. Accepts k, m and g suffixes, e.g. 64m. Unset or 0 disables chunking.
+	  core.fileMode        Set to false to ignore the executable bit of files in the working tree, on file systems that do not
+	                       keep it: changes to it are not shown, and add keeps the mode a file is already staged with.
+	  core.hooksPath       Directory the hooks run by commit, push and checkout are looked up in, absolute or relative to the
+	                       root of the working tree. Defaults to .trac/hooks.
+	  core.objectStore     Backend for the object store: loose (one file per object, the default) or file (a single file,
+	                       .trac/objects.db). Can only be changed while the store is empty; see also init --object-store.
+	  diff.renameThreshold
+	                       How alike, in percent, a deleted and an added file must be to be shown as renamed, e.g. 75%. Used by
+	                       status, log --follow and --name-status, and diff -M and -C when not given one. Defaults to 50%.
+	  signing.allowedSigners
+	                       File of the keys trusted to sign commits and tags, one "<principal> ssh-ed25519 <key>" line per key as
+	                       for ssh-keygen. Used by verify-commit, verify-tag and log --show-signature.
+	  signing.key          Ed25519 private key commits and tags are signed with by commit -S and tag -s: an unencrypted
+	                       OpenSSH key file or a PKCS #8 PEM file. Paths are resolved like commit.template.
+	`,
+		Args:         cobra.RangeArgs(0, 2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return runConfig(cmd.OutOrStdout(), l, args, opts)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.list, "list", "l", false, "List all options and their values")
+	cmd.Flags().BoolVar(&opts.unset, "unset", false, "Remove the option")
+	return cmd
+}
+
+func runConfig(w io.Writer, l *layout.Layout, args []string, opts *configOptions) error {
+	cfg, err := config.Load(l)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.list:
+		if len(args) != 0 {
+			return errors.New("--list takes no arguments")
+		}
+		for _, key := range cfg.Keys() {
+			value, _ := cfg.Get(key)
+			fmt.Fprintf(w, "%s=%s\n", key, value)
+		}
+		return nil
+	case opts.unset:
+		if len(args) != 1 {
+			return errors.New("--unset takes exactly one key")
+		}
+		if args[0] == object.StoreKey {
+			if err := checkStoreChange(l, object.BackendLoose); err != nil {
+				return err
+			}
+		}
+		if err := cfg.Unset(args[0]); err != nil {
+			return err
+		}
+		if err := cfg.Save(l); err != nil {
+			return err
+		}
+		return object.ReleaseStore(l)
+	case len(args) == 1:
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, value)
+		return nil
+	case len(args) == 2:
+		if args[0] == object.StoreKey {
+			if err := checkStoreChange(l, args[1]); err != nil {
+				return err
+			}
+		}
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			return err
+		}
+		if err := cfg.Save(l); err != nil {
+			return err
+		}
+		return object.ReleaseStore(l)
+	default:
+		return errors.New("a key is required")
+	}
+}
+
+// checkStoreChange refuses to switch the object store backend once the current
+// store holds objects, since they would not be visible through the new one.
+func checkStoreChange(l *layout.Layout, backend string) error {
+	if !object.ValidBackend(backend) {
+		return fmt.Errorf("%w: %q", object.ErrUnknownBackend, backend)
+	}
+	hashes, err := object.ListLoose(l)
+	if err != nil {
+		return err
+	}
+	if len(hashes) > 0 {
+		return fmt.Errorf("cannot change %s: the object store already contains %d object(s)", object.StoreKey, len(hashes))
+	}
+	return nil
+}
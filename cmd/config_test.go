@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := configCmd(t, "--list")
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("set, get, list and unset", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+
+		_, err := configCmd(t, "core.chunkThreshold")
+		require.ErrorIs(t, err, config.ErrNotSet)
+
+		_, err = configCmd(t, "core.chunkThreshold", "1m")
+		require.NoError(t, err)
+		_, err = configCmd(t, "remote.origin.url", "../other")
+		require.NoError(t, err)
+		out, err := configCmd(t, "core.chunkThreshold")
+		require.NoError(t, err)
+		require.Equal(t, "1m\n", out)
+		out, err = configCmd(t, "--list")
+		require.NoError(t, err)
+		require.Equal(t, "core.chunkThreshold=1m\nremote.origin.url=../other\n", out)
+
+		_, err = configCmd(t, "--unset", "remote.origin.url")
+		require.NoError(t, err)
+		out, err = configCmd(t, "-l")
+		require.NoError(t, err)
+		require.Equal(t, "core.chunkThreshold=1m\n", out)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := configCmd(t, "nosection", "value")
+		require.ErrorIs(t, err, config.ErrInvalidKey)
+	})
+}
+
+func TestChunkedFiles(t *testing.T) {
+	largeContent := func(seed int64) []byte {
+		data := make([]byte, 2<<20)
+		rand.New(rand.NewSource(seed)).Read(data)
+		return data
+	}
+
+	t.Run("large files share unchanged chunks", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := configCmd(t, "core.chunkThreshold", "1m")
+		require.NoError(t, err)
+
+		testFile := filepath.Join(tmpdir, "large.bin")
+		original := largeContent(1)
+		first := commitFile(t, tmpdir, testFile, string(original))
+		chunked := objectCount(t, tmpdir)
+		require.Greater(t, chunked, 10)
+
+		// an edit in the middle of the file only stores the chunks around it
+		edited := bytes.Clone(original)
+		copy(edited[1<<20:], "edited")
+		commitFile(t, tmpdir, testFile, string(edited))
+		// (at most two new chunks, plus the manifest and the commit)
+		require.LessOrEqual(t, objectCount(t, tmpdir)-chunked, 4)
+
+		// files below the threshold are stored whole
+		smallFile := filepath.Join(tmpdir, "small.txt")
+		before := objectCount(t, tmpdir)
+		commitFile(t, tmpdir, smallFile, "small")
+		require.Equal(t, before+2, objectCount(t, tmpdir))
+
+		out, err := fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+
+		// chunks are reachable, so gc keeps them, and packed chunks are reassembled on checkout
+		_, err = gcCmd(t, "--prune=now")
+		require.NoError(t, err)
+		out, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		_, err = checkoutCmd(t, first)
+		require.NoError(t, err)
+		data, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		require.Equal(t, original, data)
+	})
+
+	t.Run("missing chunk is reported by fsck", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := configCmd(t, "core.chunkThreshold", "1m")
+		require.NoError(t, err)
+
+		testFile := filepath.Join(tmpdir, "large.bin")
+		content := largeContent(2)
+		commitFile(t, tmpdir, testFile, string(content))
+
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		blob := stagedHash(t, tmpdir, testFile)
+		chunks, err := object.Chunks(l, blob)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(objectPath(tmpdir, chunks[3])))
+
+		out, err := fsckCmd(t)
+		require.Error(t, err)
+		require.Equal(t, "missing blob "+chunks[3]+" (chunk of "+blob[:8]+")\n", out)
+	})
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type diffOptions struct {
+	cached     bool   // --cached, --staged
+	context    int    // -U, --unified
+	renames    string // -M, --find-renames
+	copies     string // -C, --find-copies
+	nameStatus bool   // --name-status
+	format     formatOptions
+}
+
+// thresholdDefault is the value -M and -C take when given without one.
+const thresholdDefault = "default"
+
+// findRenames sets how opts find renames and copies from the values of -M and -C.
+func (o *diffOptions) findRenames(opts *trac.DiffOptions) error {
+	for _, flag := range []struct {
+		value string
+		set   *bool
+	}{{o.renames, &opts.Renames}, {o.copies, &opts.Copies}} {
+		if flag.value == "" {
+			continue
+		}
+		*flag.set = true
+		if flag.value == thresholdDefault {
+			continue
+		}
+		threshold, err := diff.ParseThreshold(flag.value)
+		if err != nil {
+			return err
+		}
+		opts.RenameThreshold = threshold
+	}
+	return nil
+}
+
+func NewDiffCmd() *cobra.Command {
+	opts := &diffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "diff [--cached [<rev>] | <rev> <rev>]",
+		Short: "Show changes between commits, the index and the working tree",
+		Long: `
+	Without arguments, shows the changes in the working tree that have not been staged. With --cached, shows the staged changes relative to HEAD,
+	or to the given revision. Given two revisions, shows the changes between them.
+
+	-M pairs deleted files with added files of similar content and shows them as renamed. -C also shows added files that are like a file
+	of the old version as copied from it. Both take the similarity needed, such as -M75%, which otherwise is set by diff.renameThreshold.
+	--name-status prints only the status and path of each changed file, with the old path first for renamed and copied files.
+
+	--porcelain prints one line per changed file, ":<old mode> <new mode> <old hash> <new hash> <status>\t<path>", where the status is A, M or D,
+	or R or C followed by the similarity, such as R090, with the old path before the path. The zero hash stands for a missing file. With -z,
+	the paths follow as separate NUL-terminated fields. --json prints an array of file objects including their hunks. The porcelain and JSON
+	formats are stable across releases.
+	`,
+		// The flag parser would read the similarity attached to -M or -C, as
+		// in -M75%, as more flags, so diff parses its own.
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Flags().Parse(attachThresholds(args)); err != nil {
+				return err
+			}
+			if help, _ := cmd.Flags().GetBool("help"); help {
+				return cmd.Help()
+			}
+			args = cmd.Flags().Args()
+			if len(args) == 1 && !opts.cached {
+				return fmt.Errorf("a single revision can only be compared with the index (--cached)")
+			}
+			if err := cobra.MaximumNArgs(2)(cmd, args); err != nil {
+				return err
+			}
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			if err := opts.format.check(porcelainV1); err != nil {
+				return err
+			}
+			if opts.nameStatus && (opts.format.json || opts.format.porcelain != "") {
+				return fmt.Errorf("--name-status cannot be used with --json or --porcelain")
+			}
+			return runDiff(cmd.OutOrStdout(), repo, args, opts)
+		},
+	}
+	addFormatFlags(cmd, &opts.format, porcelainV1)
+	cmd.Flags().BoolVar(&opts.cached, "cached", false, "Show staged changes")
+	cmd.Flags().BoolVar(&opts.cached, "staged", false, "Synonym for --cached")
+	cmd.Flags().IntVarP(&opts.context, "unified", "U", 3, "Number of lines of context to show")
+	cmd.Flags().StringVarP(&opts.renames, "find-renames", "M", "", "Detect renames, optionally with the similarity `n` needed")
+	cmd.Flags().Lookup("find-renames").NoOptDefVal = thresholdDefault
+	cmd.Flags().StringVarP(&opts.copies, "find-copies", "C", "", "Detect copies as well as renames, optionally with the similarity `n` needed")
+	cmd.Flags().Lookup("find-copies").NoOptDefVal = thresholdDefault
+	cmd.Flags().BoolVar(&opts.nameStatus, "name-status", false, "Show only the status and path of changed files")
+	return cmd
+}
+
+// attachThresholds rewrites -M and -C with a similarity attached, such as
+// -M75%, to the -M=75% form the flag parser reads as a value.
+func attachThresholds(args []string) []string {
+	rewritten := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(rewritten, args[i:]...)
+		}
+		if len(arg) > 2 && (arg[:2] == "-M" || arg[:2] == "-C") && arg[2] != '=' {
+			arg = arg[:2] + "=" + arg[2:]
+		}
+		rewritten = append(rewritten, arg)
+	}
+	return rewritten
+}
+
+func runDiff(w io.Writer, repo *trac.Repository, revs []string, opts *diffOptions) error {
+	ctx := context.Background()
+	diffOpts := &trac.DiffOptions{Context: opts.context}
+	if opts.context == 0 {
+		diffOpts.Context = -1
+	}
+	if err := opts.findRenames(diffOpts); err != nil {
+		return err
+	}
+	var diffs []trac.FileDiff
+	var err error
+	switch {
+	case len(revs) == 2:
+		diffs, err = repo.Diff(ctx, revs[0], revs[1], diffOpts)
+	case opts.cached:
+		rev := ""
+		if len(revs) == 1 {
+			rev = revs[0]
+		}
+		diffs, err = repo.DiffCached(ctx, rev, diffOpts)
+	default:
+		diffs, err = repo.DiffWorkTree(ctx, diffOpts)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.nameStatus:
+		changes := make([]trac.Change, len(diffs))
+		for i, d := range diffs {
+			changes[i] = trac.Change{Path: d.Path, Kind: d.Kind, OldPath: d.OldPath, Similarity: d.Similarity}
+		}
+		writeNameStatus(w, changes)
+	case opts.format.json:
+		return writeJSON(w, newFileDiffsJSON(diffs))
+	case opts.format.porcelain != "":
+		writeRawDiffs(w, &opts.format, diffs)
+	default:
+		printDiffs(w, diffs)
+	}
+	return nil
+}
+
+// printDiffs writes file diffs in unified format, coloring added and removed lines.
+func printDiffs(w io.Writer, diffs []trac.FileDiff) {
+	header := color.New(color.Bold)
+	hunkHeader := color.New(color.FgCyan)
+	insert := color.New(color.FgGreen)
+	remove := color.New(color.FgRed)
+	for _, d := range diffs {
+		header.Fprint(w, d.Header())
+		for _, h := range d.Hunks {
+			hunkHeader.Fprintln(w, h.Header())
+			for _, line := range h.Lines {
+				switch line.Op {
+				case trac.OpInsert:
+					insert.Fprintf(w, "+%s\n", line.Text)
+				case trac.OpDelete:
+					remove.Fprintf(w, "-%s\n", line.Text)
+				default:
+					fmt.Fprintf(w, " %s\n", line.Text)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	testFile := filepath.Join(tmpdir, "test.txt")
+	commitFile(t, tmpdir, testFile, "one\ntwo\n")
+	commitFile(t, tmpdir, testFile, "one\nthree\n")
+
+	t.Run("between revisions", func(t *testing.T) {
+		out, err := diffCmd(t, "HEAD~1", "HEAD")
+		require.NoError(t, err)
+		require.Equal(t, "diff --trac a/test.txt b/test.txt\n--- a/test.txt\n+++ b/test.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+three\n", out)
+	})
+
+	t.Run("unstaged and staged changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(testFile, []byte("one\nthree\nfour\n"), 0644))
+		out, err := diffCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, out, "+four\n")
+		out, err = diffCmd(t, "--cached")
+		require.NoError(t, err)
+		require.Empty(t, out)
+
+		require.NoError(t, addCmd(t, testFile))
+		out, err = diffCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		out, err = diffCmd(t, "--cached", "-U", "0")
+		require.NoError(t, err)
+		require.Contains(t, out, "@@ -2,0 +3 @@\n+four\n")
+	})
+
+	t.Run("single revision without --cached", func(t *testing.T) {
+		_, err := diffCmd(t, "HEAD")
+		require.Error(t, err)
+	})
+
+	t.Run("machine-readable output", func(t *testing.T) {
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		old, err := commit.Load(headHash(t, tmpdir), l)
+		require.NoError(t, err)
+		oldBlob, err := old.Lookup(testFile, l)
+		require.NoError(t, err)
+		newFile := filepath.Join(tmpdir, "new.txt")
+		require.NoError(t, os.WriteFile(newFile, []byte("new\n"), 0644))
+		require.NoError(t, addCmd(t, newFile))
+		newBlob := stagedHash(t, tmpdir, newFile)
+		newContent := stagedHash(t, tmpdir, testFile)
+		zero := strings.Repeat("0", 64)
+
+		out, err := diffCmd(t, "--cached", "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, ":000000 100644 "+zero+" "+newBlob+" A\tnew.txt\n"+
+			":100644 100644 "+oldBlob+" "+newContent+" M\ttest.txt\n", out)
+
+		out, err = diffCmd(t, "--cached", "-z")
+		require.NoError(t, err)
+		require.Equal(t, ":000000 100644 "+zero+" "+newBlob+" A\x00new.txt\x00"+
+			":100644 100644 "+oldBlob+" "+newContent+" M\x00test.txt\x00", out)
+
+		out, err = diffCmd(t, "--cached", "--json")
+		require.NoError(t, err)
+		var files []struct {
+			Path   string
+			Status string
+			Binary bool
+			Hunks  []struct {
+				OldStart, OldLines, NewStart, NewLines int
+				Lines                                  []string
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &files))
+		require.Len(t, files, 2)
+		require.Equal(t, "added", files[0].Status)
+		require.Equal(t, []string{"+new"}, files[0].Hunks[0].Lines)
+		require.Equal(t, "test.txt", files[1].Path)
+		require.Equal(t, []string{" one", " three", "+four"}, files[1].Hunks[0].Lines)
+
+		out, err = diffCmd(t, "HEAD", "HEAD", "--json")
+		require.NoError(t, err)
+		require.Equal(t, "[]\n", out)
+	})
+}
+
+func TestDiffRenames(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	old := filepath.Join(tmpdir, "old.txt")
+	first := commitFile(t, tmpdir, old, "one\ntwo\nthree\nfour\n")
+	require.NoError(t, os.Rename(old, filepath.Join(tmpdir, "new.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "new.txt"), []byte("one\ntwo\nthree\nfive\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "copy.txt"), []byte("one\ntwo\nthree\nfour\n"), 0644))
+	require.NoError(t, addCmd(t, old, "new.txt"))
+
+	t.Run("without detection", func(t *testing.T) {
+		out, err := diffCmd(t, "--cached", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "A\tnew.txt\nD\told.txt\n", out)
+	})
+
+	t.Run("renames", func(t *testing.T) {
+		out, err := diffCmd(t, "--cached", "-M", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "R073\told.txt\tnew.txt\n", out)
+		out, err = diffCmd(t, "--cached", "-M")
+		require.NoError(t, err)
+		require.Equal(t, "diff --trac a/old.txt b/new.txt\nsimilarity index 73%\nrename from old.txt\nrename to new.txt\n"+
+			"--- a/old.txt\n+++ b/new.txt\n@@ -1,4 +1,4 @@\n one\n two\n three\n-four\n+five\n", out)
+		out, err = diffCmd(t, "--cached", "--find-renames=80%", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "A\tnew.txt\nD\told.txt\n", out)
+		out, err = diffCmd(t, "--cached", "-M80%", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "A\tnew.txt\nD\told.txt\n", out)
+		out, err = diffCmd(t, "--cached", "-M70", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "R073\told.txt\tnew.txt\n", out)
+		_, err = diffCmd(t, "--cached", "-M", "--name-status", "--json")
+		require.Error(t, err)
+	})
+
+	t.Run("copies", func(t *testing.T) {
+		require.NoError(t, addCmd(t, "copy.txt"))
+		require.NoError(t, commitCmd(t, "-m", "move old.txt"))
+		zero := strings.Repeat("0", 64)
+		blob := stagedHash(t, tmpdir, "copy.txt")
+
+		out, err := diffCmd(t, first, "HEAD", "-C", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "R100\told.txt\tcopy.txt\nC073\told.txt\tnew.txt\n", out)
+		out, err = diffCmd(t, first, "HEAD", "-C80%", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, "R100\told.txt\tcopy.txt\nA\tnew.txt\n", out)
+		out, err = diffCmd(t, first, "HEAD", "-M", "--porcelain")
+		require.NoError(t, err)
+		require.Contains(t, out, ":100644 100644 "+blob+" "+blob+" R100\told.txt\tcopy.txt\n")
+		out, err = diffCmd(t, first, "HEAD", "-M", "-z")
+		require.NoError(t, err)
+		// Without -C, a file is only renamed once, so the other is added.
+		require.Contains(t, out, " R100\x00old.txt\x00copy.txt\x00:000000 100644 "+zero+" ")
+	})
+}
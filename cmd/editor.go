@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+)
+
+// editor returns the command used to edit text, preferring $TRAC_EDITOR, then $VISUAL, then $EDITOR.
+func editor() string {
+	for _, env := range []string{"TRAC_EDITOR", "VISUAL", "EDITOR"} {
+		if e := os.Getenv(env); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+// launchEditor opens path in the user's editor and waits for it to exit.
+func launchEditor(path string) error {
+	// Run through the shell so the editor setting may include arguments, e.g. "code --wait".
+	cmd := exec.Command("sh", "-c", editor()+` "$@"`, "editor", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/fast"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type fastExportOptions struct {
+	all         bool   // --all
+	importMarks string // --import-marks
+	exportMarks string // --export-marks
+	committer   string // --committer
+}
+
+func NewFastExportCmd() *cobra.Command {
+	opts := &fastExportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "fast-export [--all] [<rev>...]",
+		Short: "Export history as a fast-import stream",
+		Long: `
+	Writes the history of the given refs to standard output in the format read by git fast-import, so that a trac repository can be
+	mirrored into Git or any other tool that reads the format:
+
+	    trac fast-export --all | git fast-import
+
+	Each rev is a branch, a tag or HEAD to export, a range A..B exporting B but leaving out the history of A, or ^A, leaving out the
+	history of A. With --all, every branch and tag is exported. A commit whose parent is left out is exported as a root commit holding
+	every file.
+
+	trac does not record who made a commit, so commits name their author as the committer, or the identity given with --committer if no
+	author was recorded. Annotated tags are written as tag commands with their message, naming the same identity if they record no
+	tagger. Their signatures are left out.
+
+	Marks files make exports incremental: with --import-marks, history exported before is not written again, and --export-marks records
+	what has been exported, so the same file can be passed to both.
+	`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			revs := args
+			if opts.all {
+				if revs, err = branchAndTagNames(repo, revs); err != nil {
+					return err
+				}
+			}
+			if len(revs) == 0 {
+				return fmt.Errorf("no refs to export: name them or use --all")
+			}
+			return runFastExport(cmd.OutOrStdout(), repo, revs, opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Export every branch and tag")
+	cmd.Flags().StringVar(&opts.importMarks, "import-marks", "", "Read marks from an earlier export from this file and skip the history they name")
+	cmd.Flags().StringVar(&opts.exportMarks, "export-marks", "", "Write the marks known at the end of the export to this file")
+	cmd.Flags().StringVar(&opts.committer, "committer", "", `Identity, as "Name <email>", to name as the committer of commits with no recorded author`)
+	return cmd
+}
+
+func runFastExport(w io.Writer, repo *trac.Repository, revs []string, opts *fastExportOptions) error {
+	exportOpts := &trac.FastExportOptions{Committer: opts.committer}
+	if opts.importMarks != "" {
+		marks, err := fast.ReadMarks(opts.importMarks)
+		if err != nil {
+			return err
+		}
+		exportOpts.Marks = marks
+	}
+	marks, err := repo.FastExport(context.Background(), w, revs, exportOpts)
+	if err != nil {
+		return err
+	}
+	if opts.exportMarks != "" {
+		return fast.WriteMarks(opts.exportMarks, marks)
+	}
+	return nil
+}
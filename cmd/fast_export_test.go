@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/fast"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFastExportCommand(t *testing.T) {
+	src := initRepository(t)
+	require.NoError(t, os.Chdir(src))
+	_, err := fastImportCmd(t, gitStream)
+	require.NoError(t, err)
+	marksFile := filepath.Join(t.TempDir(), "marks")
+
+	stream, err := fastExportCmd(t, "--all", "--export-marks", marksFile)
+	require.NoError(t, err)
+	require.Contains(t, stream, `reset refs/heads/feature
+commit refs/heads/feature
+mark :3
+author Ada Lovelace <ada@example.com> 1700000000 +0000
+committer Ada Lovelace <ada@example.com> 1700000000 +0000
+data 13
+First commit
+M 100644 :1 a.txt
+M 100644 :2 "dir/file with space.txt"
+`)
+	require.Contains(t, stream, "from :3\nD a.txt\nM 100644 :4 b.txt\n")
+	require.Contains(t, stream, "reset refs/tags/v1\nfrom :3\n")
+	require.Contains(t, stream, "tag v1-annotated\nfrom :3\ntagger Ada Lovelace <ada@example.com> 1700000000 +0000\ndata 10\nannotated\n")
+
+	marks, err := fast.ReadMarks(marksFile)
+	require.NoError(t, err)
+	srcRepo, err := trac.Open(src)
+	require.NoError(t, err)
+	main, err := srcRepo.Resolve("main")
+	require.NoError(t, err)
+	require.Len(t, marks, 10)
+	require.Equal(t, main, marks[10])
+
+	t.Run("importing the export gives the same history", func(t *testing.T) {
+		dst := initRepository(t)
+		require.NoError(t, os.Chdir(dst))
+		_, err := fastImportCmd(t, stream)
+		require.NoError(t, err)
+		dstRepo, err := trac.Open(dst)
+		require.NoError(t, err)
+		for _, rev := range []string{"main", "main~1", "main~2", "feature", "v1", "v1-annotated"} {
+			want, err := srcRepo.Resolve(rev)
+			require.NoError(t, err)
+			got, err := dstRepo.Resolve(rev)
+			require.NoError(t, err)
+			wantCommit, err := srcRepo.ReadCommit(want)
+			require.NoError(t, err)
+			gotCommit, err := dstRepo.ReadCommit(got)
+			require.NoError(t, err)
+			require.Equal(t, wantCommit.Message, gotCommit.Message)
+			require.Equal(t, wantCommit.Files, gotCommit.Files)
+			require.Equal(t, wantCommit.Author.String(), gotCommit.Author.String())
+			require.True(t, wantCommit.Time.Equal(gotCommit.Time))
+		}
+	})
+
+	t.Run("exports with marks are incremental", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		out, err := fastExportCmd(t, "--all", "--import-marks", marksFile)
+		require.NoError(t, err)
+		require.NotContains(t, out, "commit ")
+		require.NotContains(t, out, "blob\n")
+
+		commitFile(t, src, filepath.Join(src, "b.txt"), "three\n")
+		out, err = fastExportCmd(t, "main", "--import-marks", marksFile, "--export-marks", marksFile, "--committer", "Trac <trac@example.com>")
+		require.NoError(t, err)
+		require.Equal(t, 1, strings.Count(out, "commit refs/heads/main\n"))
+		require.Contains(t, out, "blob\nmark :11\ndata 6\nthree\n\n")
+		require.Contains(t, out, "mark :12\ncommitter Trac <trac@example.com> ")
+		require.Contains(t, out, "data 13\nupdate b.txt\nfrom :10\nM 100644 :11 b.txt\n")
+		marks, err := fast.ReadMarks(marksFile)
+		require.NoError(t, err)
+		require.Equal(t, headHash(t, src), marks[12])
+	})
+
+	t.Run("ranges leave out history", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		out, err := fastExportCmd(t, "main~1..main")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, "blob\nmark :1\n"), out)
+		require.Contains(t, out, "reset refs/heads/main\ncommit refs/heads/main\nmark :")
+		require.NotContains(t, out, "from ")
+		require.Contains(t, out, "M 120000 :2 link\nM 100755 :3 run.sh\n")
+
+		out, err = fastExportCmd(t, "^main", "HEAD")
+		require.NoError(t, err)
+		require.Empty(t, out)
+
+		_, err = fastExportCmd(t)
+		require.Error(t, err)
+		_, err = fastExportCmd(t, "missing")
+		require.ErrorIs(t, err, trac.ErrRefNotFound)
+	})
+}
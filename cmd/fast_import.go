@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/lucasrod16/trac/internal/fast"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type fastImportOptions struct {
+	importMarks         string // --import-marks
+	importMarksIfExists string // --import-marks-if-exists
+	exportMarks         string // --export-marks
+	force               bool   // --force
+	quiet               bool   // --quiet
+}
+
+func NewFastImportCmd() *cobra.Command {
+	opts := &fastImportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "fast-import",
+		Short: "Import history from a fast-export stream",
+		Long: `
+	Reads a stream in the format written by git fast-export from standard input and records its blobs, commits, branches and tags in
+	the repository, so that Git history can be migrated with:
+
+	    git fast-export --all | trac fast-import
+
+	Commits keep their message, author and committer time. trac commits have a single parent, so merges keep their first parent and the
+	content they had after the merge. Executable files and symbolic links keep their mode, and submodules are skipped. Tags made with the
+	tag command become annotated tags, keeping their message and tagger; signatures in the message are kept as text.
+
+	Branches and tags are updated once the whole stream has been read, and only moved forward unless --force is given. The branch HEAD is
+	on is only updated if it has no commits yet, in which case the imported files are checked out. Marks files let a later import continue
+	where this one stopped.
+	`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runFastImport(cmd.InOrStdin(), cmd.OutOrStdout(), repo, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.importMarks, "import-marks", "", "Read marks from an earlier import from this file")
+	cmd.Flags().StringVar(&opts.importMarksIfExists, "import-marks-if-exists", "", "Like --import-marks, but skip a missing file")
+	cmd.Flags().StringVar(&opts.exportMarks, "export-marks", "", "Write the marks known at the end of the import to this file")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Allow updates that are not fast-forwards")
+	cmd.Flags().BoolVar(&opts.quiet, "quiet", false, "Only report errors")
+	cmd.MarkFlagsMutuallyExclusive("import-marks", "import-marks-if-exists")
+	return cmd
+}
+
+func runFastImport(r io.Reader, w io.Writer, repo *trac.Repository, opts *fastImportOptions) error {
+	importOpts := &trac.FastImportOptions{Force: opts.force}
+	if !opts.quiet {
+		importOpts.Progress = w
+	}
+	marksFile := opts.importMarks
+	if marksFile == "" {
+		marksFile = opts.importMarksIfExists
+	}
+	if marksFile != "" {
+		marks, err := fast.ReadMarks(marksFile)
+		if err != nil && !(errors.Is(err, fs.ErrNotExist) && opts.importMarksIfExists != "") {
+			return err
+		}
+		importOpts.Marks = marks
+	}
+
+	res, err := repo.FastImport(context.Background(), r, importOpts)
+	if err != nil {
+		return err
+	}
+	if opts.exportMarks != "" {
+		if err := fast.WriteMarks(opts.exportMarks, res.Marks); err != nil {
+			return err
+		}
+	}
+	out := w
+	if opts.quiet {
+		out = io.Discard
+	}
+	fmt.Fprintf(out, "Imported %d blob(s), %d commit(s) and %d tag(s)\n", res.Blobs, res.Commits, res.Tags)
+	if err := printUpdates(out, "", res.Updates); err != nil {
+		return fmt.Errorf("some refs were not updated: %w", err)
+	}
+	return nil
+}
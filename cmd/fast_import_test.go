@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/fast"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+// gitStream is what git fast-export --all produces for a small repository
+// with a tag, a rename, a deletion, a quoted path, an executable, a symbolic
+// link and a merge.
+const gitStream = `blob
+mark :1
+data 4
+one
+
+blob
+mark :2
+data 2
+x
+
+reset refs/tags/v1
+commit refs/tags/v1
+mark :3
+author Ada Lovelace <ada@example.com> 1700000000 +0000
+committer Ada Lovelace <ada@example.com> 1700000000 +0000
+data 13
+First commit
+M 100644 :1 a.txt
+M 100644 :2 "dir/file with space.txt"
+
+blob
+mark :4
+data 8
+one
+two
+
+commit refs/heads/feature
+mark :5
+author Ada Lovelace <ada@example.com> 1700000000 +0000
+committer Ada Lovelace <ada@example.com> 1700000000 +0000
+data 16
+Rename and edit
+from :3
+R a.txt b.txt
+M 100644 :4 b.txt
+
+commit refs/heads/main
+mark :6
+author Ada Lovelace <ada@example.com> 1700000000 +0000
+committer Ada Lovelace <ada@example.com> 1700000000 +0000
+data 7
+Delete
+from :5
+D "dir/file with space.txt"
+
+blob
+mark :7
+data 5
+b.txt
+blob
+mark :8
+data 10
+#!/bin/sh
+
+commit refs/heads/feature
+mark :9
+author Ada Lovelace <ada@example.com> 1700000000 +0000
+committer Grace Hopper <grace@example.com> 1700000060 +0000
+data 8
+Feature
+from :5
+M 120000 :7 link
+M 100755 :8 run.sh
+
+commit refs/heads/main
+mark :10
+author Ada Lovelace <ada@example.com> 1700000000 +0000
+committer Ada Lovelace <ada@example.com> 1700000000 +0000
+data 23
+Merge branch 'feature'
+from :6
+merge :9
+M 120000 :7 link
+M 100755 :8 run.sh
+
+tag v1-annotated
+mark :11
+from :3
+tagger Ada Lovelace <ada@example.com> 1700000000 +0000
+data 10
+annotated
+
+`
+
+func TestFastImportCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	marksFile := filepath.Join(t.TempDir(), "marks")
+
+	out, err := fastImportCmd(t, gitStream, "--export-marks", marksFile)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, "Imported 5 blob(s), 5 commit(s) and 1 tag(s)\n"), out)
+	for _, ref := range []string{"feature", "main", "v1", "v1-annotated"} {
+		require.Contains(t, out, " "+ref+"\n")
+	}
+
+	marks, err := fast.ReadMarks(marksFile)
+	require.NoError(t, err)
+	require.Equal(t, marks[10], headHash(t, tmpdir))
+	require.Equal(t, marks[9], refHash(t, tmpdir, "refs/heads/feature"))
+	require.Equal(t, marks[3], refHash(t, tmpdir, "refs/tags/v1"))
+	require.Equal(t, marks[11], refHash(t, tmpdir, "refs/tags/v1-annotated"))
+	l, err := layout.New(tmpdir)
+	require.NoError(t, err)
+	tag, err := commit.LoadTag(marks[11], l)
+	require.NoError(t, err)
+	require.Equal(t, marks[3], tag.Object)
+	require.Equal(t, "annotated", tag.Message)
+	require.Equal(t, "Ada Lovelace <ada@example.com>", tag.Tagger.String())
+	require.Equal(t, int64(1700000000), tag.Timestamp.Unix())
+
+	// the branch HEAD is on had no commits yet, so the import checks it out
+	for name, content := range map[string]string{"b.txt": "one\ntwo\n", "run.sh": "#!/bin/sh\n"} {
+		data, err := os.ReadFile(filepath.Join(tmpdir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(data))
+	}
+	target, err := os.Readlink(filepath.Join(tmpdir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "b.txt", target)
+	info, err := os.Stat(filepath.Join(tmpdir, "run.sh"))
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&0100, "run.sh is executable")
+	require.NoFileExists(t, filepath.Join(tmpdir, "a.txt"))
+	out, err = statusCmd(t)
+	require.NoError(t, err)
+	require.Contains(t, out, "nothing to commit, working tree clean")
+	_, err = fsckCmd(t)
+	require.NoError(t, err)
+
+	repo, err := trac.Open(tmpdir)
+	require.NoError(t, err)
+	c, err := repo.ReadCommit(marks[9])
+	require.NoError(t, err)
+	require.Equal(t, "Feature", c.Message)
+	require.Equal(t, marks[5], c.Parent)
+	require.Equal(t, int64(1700000060), c.Time.Unix())
+	require.NotNil(t, c.Author)
+	require.Equal(t, "Ada Lovelace <ada@example.com>", c.Author.String())
+	require.Equal(t, int64(1700000000), c.Author.Time.Unix())
+
+	out, err = logCmd(t, "-n", "1", "feature")
+	require.NoError(t, err)
+	require.Contains(t, out, "Author: Ada Lovelace <ada@example.com>\n")
+
+	c, err = repo.ReadCommit(marks[3])
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "dir/file with space.txt"}, slices.Sorted(maps.Keys(c.Files)))
+
+	t.Run("continuing an import with marks", func(t *testing.T) {
+		stream := `commit refs/heads/feature
+mark :12
+committer Ada Lovelace <ada@example.com> 1700000100 +0100
+data <<EOF
+More work
+
+With a body
+EOF
+from :9
+M 100644 inline notes.txt
+data 5
+notes
+C b.txt copy.txt
+R "run.sh" "scripts/run me.sh"
+
+`
+		_, err := fastImportCmd(t, stream)
+		require.ErrorIs(t, err, trac.ErrUnknownMark)
+
+		out, err := fastImportCmd(t, stream, "--import-marks", marksFile, "--export-marks", marksFile)
+		require.NoError(t, err)
+		require.Contains(t, out, "feature")
+		marks, err := fast.ReadMarks(marksFile)
+		require.NoError(t, err)
+		require.Equal(t, marks[12], refHash(t, tmpdir, "refs/heads/feature"))
+
+		c, err := repo.ReadCommit(marks[12])
+		require.NoError(t, err)
+		require.Equal(t, "More work\n\nWith a body", c.Message)
+		require.Nil(t, c.Author)
+		_, offset := c.Time.Zone()
+		require.Equal(t, 3600, offset)
+		require.Equal(t, []string{"b.txt", "copy.txt", "dir/file with space.txt", "link", "notes.txt", "scripts/run me.sh"}, slices.Sorted(maps.Keys(c.Files)))
+		require.Equal(t, c.Files["b.txt"], c.Files["copy.txt"])
+	})
+
+	t.Run("refs only move forward unless forced", func(t *testing.T) {
+		stream := "reset refs/heads/feature\nfrom :3\n\n"
+		out, err := fastImportCmd(t, stream, "--import-marks", marksFile)
+		require.ErrorContains(t, err, "some refs were not updated")
+		require.Contains(t, out, "non-fast-forward")
+		require.NotEqual(t, marks[3], refHash(t, tmpdir, "refs/heads/feature"))
+
+		_, err = fastImportCmd(t, stream, "--import-marks", marksFile, "--force")
+		require.NoError(t, err)
+		require.Equal(t, marks[3], refHash(t, tmpdir, "refs/heads/feature"))
+
+		// the checked out branch is never moved under the working tree
+		_, err = fastImportCmd(t, "reset refs/heads/main\nfrom :3\n", "--import-marks", marksFile, "--force")
+		require.ErrorContains(t, err, "some refs were not updated")
+		require.Equal(t, marks[10], headHash(t, tmpdir))
+	})
+
+	t.Run("invalid streams", func(t *testing.T) {
+		header := "commit refs/heads/bad\ncommitter A <a@example.com> 1700000000 +0000\ndata 0\n"
+		tests := map[string]struct {
+			stream string
+			err    error
+		}{
+			"unknown command":   {"frobnicate\n", trac.ErrInvalidStream},
+			"unsupported":       {"ls \"path\"\n", trac.ErrUnsupportedCommand},
+			"truncated data":    {"blob\ndata 10\nshort", trac.ErrInvalidStream},
+			"missing committer": {"commit refs/heads/bad\ndata 0\n", trac.ErrInvalidStream},
+			"escaping path":     {header + "M 100644 inline ../escape\ndata 0\n", trac.ErrInvalidStream},
+			"repository path":   {header + "M 100644 inline .trac/HEAD\ndata 0\n", trac.ErrInvalidStream},
+			"git object":        {header + "M 100644 " + strings.Repeat("a", 40) + " file\n", trac.ErrInvalidStream},
+			"missing done":      {"feature done\n", trac.ErrInvalidStream},
+			"beneath a symlink": {header + "M 120000 inline link\ndata 4\n/tmp\nM 100644 inline link/f\ndata 3\npwn\n", trac.ErrInvalidStream},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := fastImportCmd(t, tt.stream)
+				require.ErrorIs(t, err, tt.err)
+			})
+		}
+		require.Empty(t, refHash(t, tmpdir, "refs/heads/bad"))
+	})
+}
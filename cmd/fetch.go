@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type fetchOptions struct {
+	force bool // -f, --force
+	tags  bool // -t, --tags
+}
+
+func NewFetchCmd() *cobra.Command {
+	opts := &fetchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "fetch [<remote> [<refspec>...]]",
+		Short: "Download history from another repository",
+		Long: `
+	Copies the commits and file contents of the remote's refs that are missing from this repository, and updates the local refs that the
+	remote's fetch refspecs map them to, by default refs/remotes/<remote>/<branch> for every branch. The remote defaults to origin. Refspecs
+	given on the command line, such as refs/heads/main:refs/remotes/origin/main, are used instead of the configured ones.
+
+	Refs are only moved forward, to commits that contain their current commit, unless the refspec starts with "+" or --force is given.
+	`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			name := trac.DefaultRemote
+			if len(args) > 0 {
+				name, args = args[0], args[1:]
+			}
+			return runFetch(cmd.OutOrStdout(), repo, name, args, opts)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "Allow updates that are not fast-forwards")
+	cmd.Flags().BoolVarP(&opts.tags, "tags", "t", false, "Fetch every tag as well")
+	return cmd
+}
+
+func runFetch(w io.Writer, repo *trac.Repository, name string, refspecs []string, opts *fetchOptions) error {
+	updates, err := repo.Fetch(context.Background(), name, &trac.FetchOptions{Refspecs: refspecs, Force: opts.force, Tags: opts.tags, HTTP: httpOptions()})
+	if err != nil {
+		return err
+	}
+	if err := printUpdates(w, "From "+remoteURL(repo, name), updates); err != nil {
+		return fmt.Errorf("some refs were not updated: %w", err)
+	}
+	return nil
+}
+
+// remoteURL returns the URL of a remote for display, with any password masked,
+// or its name if it cannot be found.
+func remoteURL(repo *trac.Repository, name string) string {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return name
+	}
+	for _, r := range remotes {
+		if r.Name == name {
+			if u, err := url.Parse(r.URL); err == nil && u.User != nil {
+				return u.Redacted()
+			}
+			return r.URL
+		}
+	}
+	return name
+}
+
+// errRejected is returned by printUpdates when an update was rejected.
+var errRejected = errors.New("rejected")
+
+// printUpdates reports the refs a fetch or push changed, one per line, under
+// a header naming the other repository, if any. Updates without a source ref
+// are reported by their destination alone. It returns errRejected if any update
+// was rejected.
+func printUpdates(w io.Writer, header string, updates []trac.RefUpdate) error {
+	var lines []string
+	var rejected bool
+	for _, u := range updates {
+		names := fmt.Sprintf("%s -> %s", refs.Short(u.Src), refs.Short(u.Dst))
+		if u.Src == "" {
+			names = refs.Short(u.Dst)
+		}
+		var line string
+		switch u.Status {
+		case trac.UpToDate:
+			continue
+		case trac.Created:
+			kind := "branch"
+			if strings.HasPrefix(u.Dst, refs.TagsPrefix) {
+				kind = "tag"
+			}
+			line = fmt.Sprintf(" * %-18s %s", "[new "+kind+"]", names)
+		case trac.FastForward:
+			line = fmt.Sprintf("   %-18s %s", u.Old[:8]+".."+u.New[:8], names)
+		case trac.Forced:
+			line = fmt.Sprintf(" + %-18s %s  (forced update)", u.Old[:8]+"..."+u.New[:8], names)
+		case trac.NonFastForward:
+			rejected = true
+			line = fmt.Sprintf(" ! %-18s %s  (non-fast-forward)", "[rejected]", names)
+		case trac.CurrentBranch:
+			rejected = true
+			line = fmt.Sprintf(" ! %-18s %s  (branch is checked out)", "[rejected]", names)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 {
+		if header != "" {
+			fmt.Fprintln(w, header)
+		}
+		fmt.Fprintln(w, strings.Join(lines, "\n"))
+	}
+	if rejected {
+		return errRejected
+	}
+	return nil
+}
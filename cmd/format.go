@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+// Machine-readable output formats.
+//
+// The porcelain and JSON formats are meant for scripts and are kept stable
+// across releases: existing porcelain fields and JSON keys never change meaning,
+// and new information is only ever added as new JSON keys or new porcelain versions.
+const (
+	porcelainV1 = "v1"
+	porcelainV2 = "v2"
+)
+
+// modeNone is the mode shown in porcelain output where a file does not exist.
+const modeNone = "000000"
+
+// zeroHash stands in for the hash of a file or commit that does not exist.
+var zeroHash = strings.Repeat("0", 64)
+
+type formatOptions struct {
+	porcelain string // --porcelain[=<version>]
+	json      bool   // --json
+	nul       bool   // -z
+}
+
+// addFormatFlags registers --porcelain, --json and -z on a command.
+func addFormatFlags(cmd *cobra.Command, opts *formatOptions, versions ...string) {
+	cmd.Flags().StringVar(&opts.porcelain, "porcelain", "", fmt.Sprintf("Give the output in a stable, easy-to-parse format (%s)", strings.Join(versions, ", ")))
+	cmd.Flags().Lookup("porcelain").NoOptDefVal = porcelainV1
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Give the output as JSON")
+	cmd.Flags().BoolVarP(&opts.nul, "null", "z", false, "Terminate porcelain records with NUL instead of newline and leave paths unquoted; implies --porcelain")
+}
+
+// check validates the format flags against the porcelain versions a command supports.
+func (o *formatOptions) check(versions ...string) error {
+	if o.json && (o.porcelain != "" || o.nul) {
+		return errors.New("--json cannot be combined with --porcelain or -z")
+	}
+	if o.nul && o.porcelain == "" {
+		o.porcelain = porcelainV1
+	}
+	if o.porcelain != "" && !slices.Contains(versions, o.porcelain) {
+		return fmt.Errorf("unsupported porcelain version %q, expected one of %s", o.porcelain, strings.Join(versions, ", "))
+	}
+	return nil
+}
+
+// machine reports whether output is for a machine rather than a person.
+func (o *formatOptions) machine() bool {
+	return o.porcelain != "" || o.json
+}
+
+// record writes a porcelain record followed by its terminator.
+func (o *formatOptions) record(w io.Writer, format string, args ...any) {
+	fmt.Fprintf(w, format, args...)
+	if o.nul {
+		io.WriteString(w, "\x00")
+	} else {
+		io.WriteString(w, "\n")
+	}
+}
+
+// path returns a path for a porcelain record. Without -z, paths containing
+// quotes, backslashes or control characters are quoted as Go string literals,
+// so every record fits on one line.
+func (o *formatOptions) path(path string) string {
+	if o.nul {
+		return path
+	}
+	for _, r := range path {
+		if r < 0x20 || r == 0x7f || r == '"' || r == '\\' {
+			return strconv.Quote(path)
+		}
+	}
+	return path
+}
+
+// writeJSON writes v as a single line of JSON.
+func writeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// kindName is the name of a change kind in JSON output.
+func kindName(k trac.ChangeKind) string {
+	switch k {
+	case trac.Added:
+		return "added"
+	case trac.Modified:
+		return "modified"
+	case trac.Deleted:
+		return "deleted"
+	case trac.Renamed:
+		return "renamed"
+	case trac.Copied:
+		return "copied"
+	}
+	return string(k)
+}
+
+// statusCode is the status of a change in porcelain and --name-status output:
+// its kind, followed for renames and copies by the similarity, e.g. "R090".
+func statusCode(kind trac.ChangeKind, similarity int) string {
+	if kind == trac.Renamed || kind == trac.Copied {
+		return fmt.Sprintf("%c%03d", kind, similarity)
+	}
+	return string(kind)
+}
+
+// orZero returns hash, or the zero hash if it is empty.
+func orZero(hash string) string {
+	if hash == "" {
+		return zeroHash
+	}
+	return hash
+}
+
+// orNone returns the mode shown for a file with the given mode, empty where
+// the file does not exist.
+func orNone(mode string) string {
+	if mode == "" {
+		return modeNone
+	}
+	return mode
+}
+
+type changeJSON struct {
+	Path       string `json:"path"`
+	Status     string `json:"status"`
+	OldHash    string `json:"oldHash"`
+	NewHash    string `json:"newHash"`
+	OldMode    string `json:"oldMode,omitempty"`
+	NewMode    string `json:"newMode,omitempty"`
+	OldPath    string `json:"oldPath,omitempty"`    // Renamed and copied files only
+	Similarity int    `json:"similarity,omitempty"` // Renamed and copied files only
+}
+
+func newChangeJSON(c trac.Change) changeJSON {
+	return changeJSON{Path: c.Path, Status: kindName(c.Kind), OldHash: c.OldHash, NewHash: c.NewHash, OldMode: c.OldMode, NewMode: c.NewMode, OldPath: c.OldPath, Similarity: c.Similarity}
+}
+
+type commitJSON struct {
+	Hash    string          `json:"hash"`
+	Parent  string          `json:"parent"`
+	Time    time.Time       `json:"time"`
+	Message string          `json:"message"`
+	Author  *trac.Signature `json:"author,omitempty"`
+}
+
+func newCommitJSON(c *trac.Commit) commitJSON {
+	return commitJSON{Hash: c.Hash, Parent: c.Parent, Time: c.Time, Message: c.Message, Author: c.Author}
+}
+
+type hunkJSON struct {
+	OldStart int      `json:"oldStart"`
+	OldLines int      `json:"oldLines"`
+	NewStart int      `json:"newStart"`
+	NewLines int      `json:"newLines"`
+	Lines    []string `json:"lines"` // Each line prefixed with ' ', '+' or '-'
+}
+
+type fileDiffJSON struct {
+	changeJSON
+	Binary bool       `json:"binary"`
+	Hunks  []hunkJSON `json:"hunks"`
+}
+
+func newFileDiffsJSON(diffs []trac.FileDiff) []fileDiffJSON {
+	result := make([]fileDiffJSON, 0, len(diffs))
+	for _, d := range diffs {
+		fd := fileDiffJSON{
+			changeJSON: changeJSON{Path: d.Path, Status: kindName(d.Kind), OldHash: d.OldHash, NewHash: d.NewHash, OldMode: d.OldMode, NewMode: d.NewMode, OldPath: d.OldPath, Similarity: d.Similarity},
+			Binary:     d.Binary,
+			Hunks:      make([]hunkJSON, 0, len(d.Hunks)),
+		}
+		for _, h := range d.Hunks {
+			hj := hunkJSON{OldStart: h.OldStart, OldLines: h.OldLines, NewStart: h.NewStart, NewLines: h.NewLines, Lines: make([]string, len(h.Lines))}
+			for i, line := range h.Lines {
+				hj.Lines[i] = string(rune(line.Op)) + line.Text
+			}
+			fd.Hunks = append(fd.Hunks, hj)
+		}
+		result = append(result, fd)
+	}
+	return result
+}
+
+// writeRawDiffs writes one porcelain record per changed file:
+//
+//	:<old mode> <new mode> <old hash> <new hash> <status>\t<path>
+//
+// Renamed and copied files have a status such as R090 and are given by their
+// old and new paths, "<status>\t<old path>\t<path>". With -z, each path is a
+// separate record: ":... <status>\0<path>\0".
+func writeRawDiffs(w io.Writer, opts *formatOptions, diffs []trac.FileDiff) {
+	for _, d := range diffs {
+		header := fmt.Sprintf(":%s %s %s %s %s", orNone(d.OldMode), orNone(d.NewMode), orZero(d.OldHash), orZero(d.NewHash), statusCode(d.Kind, d.Similarity))
+		paths := []string{d.Path}
+		if d.OldPath != "" {
+			paths = []string{d.OldPath, d.Path}
+		}
+		if opts.nul {
+			opts.record(w, "%s", header)
+			for _, p := range paths {
+				opts.record(w, "%s", p)
+			}
+			continue
+		}
+		for i, p := range paths {
+			paths[i] = opts.path(p)
+		}
+		opts.record(w, "%s\t%s", header, strings.Join(paths, "\t"))
+	}
+}
+
+// writeNameStatus writes the status and path of each change, one per line,
+// for --name-status. Renamed and copied files show their old path too.
+func writeNameStatus(w io.Writer, changes []trac.Change) {
+	for _, c := range changes {
+		if c.OldPath != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", statusCode(c.Kind, c.Similarity), c.OldPath, c.Path)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", statusCode(c.Kind, c.Similarity), c.Path)
+		}
+	}
+}
+
+// changePath is how a change names its file for people: "<old> -> <new>" for
+// renamed and copied files, the path otherwise.
+func changePath(c trac.Change) string {
+	if c.OldPath != "" {
+		return c.OldPath + " -> " + c.Path
+	}
+	return c.Path
+}
+
+// writeCommitRecord writes a commit as a porcelain record:
+//
+//	<hash> <parent> <unix time> <summary>
+//
+// The parent is the zero hash for the first commit. With -z, the full message
+// is given in place of the summary.
+func writeCommitRecord(w io.Writer, opts *formatOptions, c *trac.Commit) {
+	message := c.Summary()
+	if opts.nul {
+		message = c.Message
+	}
+	opts.record(w, "%s %s %d %s", c.Hash, orZero(c.Parent), c.Time.Unix(), message)
+}
+
+// shortBranch returns the short name of the branch HEAD is on, or "" when detached.
+func shortBranch(branch string) string {
+	if branch == "" {
+		return ""
+	}
+	return refs.Short(branch)
+}
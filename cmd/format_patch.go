@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type formatPatchOptions struct {
+	outputDir string // -o, --output-directory
+	stdout    bool   // --stdout
+	root      bool   // --root
+	from      string // --from
+}
+
+func NewFormatPatchCmd() *cobra.Command {
+	opts := &formatPatchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "format-patch [-o <dir>] [--stdout] [--root] <range>",
+		Short: "Prepare commits as patch emails",
+		Long: `
+	Writes each commit in the range as a patch email, ready to be sent or applied with trac am in another repository. The range is
+	A..B for the commits after A up to B, or A for the commits after A up to HEAD; with --root, A names the last commit to include
+	and every commit before it is included too.
+
+	Each patch is written to its own file, named after its number in the series and its subject, such as 0001-Fix-typo.patch, and
+	the names of the files are printed. With --stdout, the patches are written to standard output instead, as a single mbox file.
+
+	An email carries the commit's message and changes, and names the commit's author as its sender, or the identity given with
+	--from if no author was recorded.
+	`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runFormatPatch(cmd.OutOrStdout(), repo, args[0], opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.outputDir, "output-directory", "o", "", "Write the patch files to this directory rather than the current one")
+	cmd.Flags().BoolVar(&opts.stdout, "stdout", false, "Write the patches to standard output as an mbox file")
+	cmd.Flags().BoolVar(&opts.root, "root", false, "Include every commit up to the named one")
+	cmd.Flags().StringVar(&opts.from, "from", "", `Sender, as "Name <email>", of patches for commits with no recorded author`)
+	cmd.MarkFlagsMutuallyExclusive("output-directory", "stdout")
+	return cmd
+}
+
+func runFormatPatch(w io.Writer, repo *trac.Repository, rev string, opts *formatPatchOptions) error {
+	patches, err := repo.FormatPatches(context.Background(), rev, &trac.FormatPatchOptions{Root: opts.root, From: opts.from})
+	if err != nil {
+		return err
+	}
+	if opts.stdout {
+		for _, p := range patches {
+			if _, err := w.Write(p.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if opts.outputDir != "" {
+		if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+			return err
+		}
+	}
+	for i, p := range patches {
+		name := filepath.Join(opts.outputDir, fmt.Sprintf("%04d-%s.patch", i+1, patchSlug(p.Commit.Summary())))
+		if err := os.WriteFile(name, p.Text, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
+
+// patchSlug turns a commit subject into a file name, keeping letters, digits
+// and dots and replacing runs of anything else with a dash.
+func patchSlug(subject string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range subject {
+		if r < 128 && (r == '.' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	slug := strings.Trim(sb.String(), ".")
+	if len(slug) > 52 {
+		slug = strings.Trim(slug[:52], "-.")
+	}
+	if slug == "" {
+		return "patch"
+	}
+	return slug
+}
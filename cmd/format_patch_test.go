@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatPatchCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	_, err := fastImportCmd(t, gitStream)
+	require.NoError(t, err)
+
+	t.Run("writes a mailbox to standard output", func(t *testing.T) {
+		out, err := formatPatchCmd(t, "--root", "--stdout", "main")
+		require.NoError(t, err)
+		require.Contains(t, out, `From: Ada Lovelace <ada@example.com>
+Date: Tue, 14 Nov 2023 22:13:20 +0000
+Subject: [PATCH 1/4] First commit
+
+---
+diff --trac a/a.txt b/a.txt
+new file mode 100644
+`)
+		require.Contains(t, out, "Subject: [PATCH 3/4] Delete\n")
+		require.Contains(t, out, "diff --trac a/dir/file with space.txt b/dir/file with space.txt\ndeleted file mode 100644\n")
+		require.Contains(t, out, "diff --trac a/link b/link\nnew file mode 120000\n")
+		require.Contains(t, out, "diff --trac a/run.sh b/run.sh\nnew file mode 100755\n")
+	})
+
+	t.Run("writes a file per patch", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "patches")
+		out, err := formatPatchCmd(t, "-o", dir, "main~2..main")
+		require.NoError(t, err)
+		first, second := filepath.Join(dir, "0001-Delete.patch"), filepath.Join(dir, "0002-Merge-branch-feature.patch")
+		require.Equal(t, first+"\n"+second+"\n", out)
+		data, err := os.ReadFile(second)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "Subject: [PATCH 2/2] Merge branch 'feature'\n")
+	})
+
+	t.Run("names the sender of commits with no author", func(t *testing.T) {
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "b.txt"), "three\n")
+		out, err := formatPatchCmd(t, "--stdout", "--from", "Trac <trac@example.com>", "HEAD~1")
+		require.NoError(t, err)
+		require.Contains(t, out, "From: Trac <trac@example.com>\n")
+		require.Contains(t, out, "Subject: [PATCH] update b.txt\n")
+	})
+
+	t.Run("rejects unknown revisions", func(t *testing.T) {
+		_, err := formatPatchCmd(t, "--stdout", "nope..main")
+		require.Error(t, err)
+	})
+}
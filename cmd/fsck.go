@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/fsck"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/spf13/cobra"
+)
+
+type fsckOptions struct {
+	lostFound bool // --lost-found
+}
+
+func NewFsckCmd() *cobra.Command {
+	opts := &fsckOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Verify the connectivity and validity of objects in the database",
+		Long: `
+	Rehashes every object in the object database to make sure its content matches its name, validates commit objects, and walks history from HEAD,
+	every branch, tag and remote-tracking branch, any bisect session in progress and the index to find objects that are missing. Objects that
+	nothing refers to are reported as dangling.
+
+	Exits with a non-zero status if any corrupt, invalid or missing objects are found. Dangling objects alone do not cause a failure.
+	`,
+		Args:         cobra.ExactArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return runFsck(cmd.OutOrStdout(), l, opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.lostFound, "lost-found", false, "Write dangling objects into .trac/lost-found/")
+	return cmd
+}
+
+func runFsck(w io.Writer, l *layout.Layout, opts *fsckOptions) error {
+	report, err := fsck.Check(l)
+	if err != nil {
+		return err
+	}
+	for _, p := range report.Problems {
+		fmt.Fprintln(w, p)
+	}
+	if opts.lostFound {
+		if err := fsck.WriteLostFound(l, report.Dangling()); err != nil {
+			return err
+		}
+	}
+	if n := report.Errors(); n > 0 {
+		return fmt.Errorf("%w: %d problem(s) found", fsck.ErrProblemsFound, n)
+	}
+	return nil
+}
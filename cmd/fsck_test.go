@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/fsck"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFsckCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := fsckCmd(t)
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("healthy repository", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, "first")
+		commitFile(t, tmpdir, testFile, "second")
+
+		out, err := fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	})
+
+	t.Run("dangling objects", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, "first")
+		orphan := commitFile(t, tmpdir, testFile, "second")
+
+		// committing something else on top of the first commit and moving the
+		// branch there leaves the second commit unreachable
+		_, err := checkoutCmd(t, "HEAD~1")
+		require.NoError(t, err)
+		commitFile(t, tmpdir, testFile, "third")
+		_, err = branchCmd(t, "-f", "main", "HEAD")
+		require.NoError(t, err)
+
+		// staging and unstaging a file leaves its blob unreachable
+		unstaged := filepath.Join(tmpdir, "unstaged.txt")
+		require.NoError(t, os.WriteFile(unstaged, []byte("unstaged"), 0644))
+		require.NoError(t, addCmd(t, unstaged))
+		blob := stagedHash(t, tmpdir, unstaged)
+		_, err = resetCmd(t, "", unstaged)
+		require.NoError(t, err)
+
+		out, err := fsckCmd(t, "--lost-found")
+		require.NoError(t, err)
+		require.Contains(t, out, "dangling commit "+orphan+"\n")
+		require.Contains(t, out, "dangling blob "+blob+"\n")
+		require.NotContains(t, out, "missing")
+
+		data, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "lost-found", "commit", orphan))
+		require.NoError(t, err)
+		require.Equal(t, orphan+"\n", string(data))
+		data, err = os.ReadFile(filepath.Join(tmpdir, ".trac", "lost-found", "other", blob))
+		require.NoError(t, err)
+		require.Equal(t, "unstaged", string(data))
+	})
+
+	t.Run("corrupt object", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, "content")
+
+		blob := stagedHash(t, tmpdir, testFile)
+		require.NoError(t, os.WriteFile(objectPath(tmpdir, blob), []byte("tampered"), 0644))
+
+		out, err := fsckCmd(t)
+		require.ErrorIs(t, err, fsck.ErrProblemsFound)
+		require.Contains(t, out, "corrupt object "+blob)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, "content")
+
+		blob := stagedHash(t, tmpdir, testFile)
+		require.NoError(t, os.Remove(objectPath(tmpdir, blob)))
+
+		out, err := fsckCmd(t)
+		require.ErrorIs(t, err, fsck.ErrProblemsFound)
+		require.Contains(t, out, "missing blob "+blob)
+	})
+
+	t.Run("invalid commit", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		hash, err := object.Write(l, []byte(`{"message": "no timestamp"}`))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, ".trac", "HEAD"), []byte(hash+"\n"), 0644))
+
+		out, err := fsckCmd(t)
+		require.ErrorIs(t, err, fsck.ErrProblemsFound)
+		require.Contains(t, out, "invalid commit "+hash)
+	})
+}
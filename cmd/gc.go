@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"io"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/gc"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/spf13/cobra"
+)
+
+type gcOptions struct {
+	prune string // --prune
+}
+
+func NewGCCmd() *cobra.Command {
+	opts := &gcOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Clean up unnecessary files and optimize the repository",
+		Long: `
+	Runs housekeeping tasks on the repository. Unreachable objects older than the --prune time, two weeks by default, are removed from the object
+	database. The grace period keeps objects written by commands that are still running, and lets recently abandoned work be recovered with fsck.
+
+	All reachable objects are then repacked into a single pack with delta compression, replacing existing packs and loose copies.
+	`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return runGC(cmd.OutOrStdout(), l, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.prune, "prune", gc.DefaultExpire, "Prune unreachable objects older than this time")
+	return cmd
+}
+
+func runGC(w io.Writer, l *layout.Layout, opts *gcOptions) error {
+	expire, err := gc.ParseExpire(opts.prune, time.Now())
+	if err != nil {
+		return err
+	}
+	result, err := gc.Prune(l, gc.Options{Expire: expire})
+	if err != nil {
+		return err
+	}
+	printPruneResult(w, result, false)
+
+	// Unreachable objects still in their grace period are kept loose so they can expire later.
+	if err := gc.UnpackUnreachable(l, expire); err != nil {
+		return err
+	}
+	reachable, err := graph.Reachable(l)
+	if err != nil {
+		return err
+	}
+	stats, err := gc.Repack(l, gc.RepackOptions{
+		Window: gc.DefaultWindow,
+		Depth:  gc.DefaultDepth,
+		All:    true,
+		Delete: true,
+		Filter: func(hash string) bool { return reachable[hash] },
+	})
+	if err != nil {
+		return err
+	}
+	printRepackStats(w, stats)
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := gcCmd(t)
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("prunes objects past the grace period", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "content")
+		recent := unreachableBlob(t, tmpdir, "recent")
+		old := unreachableBlob(t, tmpdir, "old")
+		longAgo := time.Now().Add(-30 * 24 * time.Hour)
+		require.NoError(t, os.Chtimes(objectPath(tmpdir, old), longAgo, longAgo))
+
+		out, err := gcCmd(t)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, "Pruned 1 unreachable object(s), reclaiming 3 bytes\n"))
+		require.Contains(t, out, "Packed 2 object(s)")
+		require.NoFileExists(t, objectPath(tmpdir, old))
+		require.FileExists(t, objectPath(tmpdir, recent))
+
+		// reachable objects were moved into the pack
+		require.NoFileExists(t, objectPath(tmpdir, headHash(t, tmpdir)))
+		out, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "dangling blob "+recent+"\n", out)
+	})
+}
@@ -2,12 +2,24 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/lucasrod16/trac/internal/commit"
 	"github.com/lucasrod16/trac/internal/index"
 	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/sign"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,10 +58,11 @@ func commitCmd(t *testing.T, args ...string) error {
 	return cmd.Execute()
 }
 
-func statusCmd(t *testing.T) (output string, err error) {
+func statusCmd(t *testing.T, args ...string) (output string, err error) {
 	t.Helper()
 	cmd := NewStatusCmd()
 	var buf bytes.Buffer
+	cmd.SetArgs(append([]string{}, args...))
 	cmd.SetOut(&buf)
 	cmd.SetErr(&buf)
 	err = cmd.Execute()
@@ -64,3 +77,442 @@ func getIndex(t *testing.T, repoPath string) *index.Index {
 	require.NoError(t, idx.Load(l))
 	return idx
 }
+
+func blameCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewBlameCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// commitFile writes content to path, stages it and commits it, returning the new commit hash.
+func commitFile(t *testing.T, repoPath, path, content string) string {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, addCmd(t, path))
+	require.NoError(t, commitCmd(t, "-m", "update "+filepath.Base(path)))
+	return headHash(t, repoPath)
+}
+
+func headHash(t *testing.T, repoPath string) string {
+	t.Helper()
+	l, err := layout.New(repoPath)
+	require.NoError(t, err)
+	hash, err := commit.GetParentHash(l)
+	require.NoError(t, err)
+	return hash
+}
+
+func checkoutCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewCheckoutCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func bisectCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewBisectCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func addPatchCmd(t *testing.T, input string, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewAddCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(append([]string{"-p"}, args...))
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func resetCmd(t *testing.T, input string, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewResetCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// stagedHash returns the content hash path is staged with, whichever form of the path it is staged under.
+func stagedHash(t *testing.T, repoPath, path string) string {
+	t.Helper()
+	l, err := layout.New(repoPath)
+	require.NoError(t, err)
+	idx := getIndex(t, repoPath)
+	key, ok, err := idx.Find(path, l)
+	require.NoError(t, err)
+	require.True(t, ok, "%s is not staged", path)
+	return idx.Staged[key]
+}
+
+// stagedContent returns the staged content of path.
+func stagedContent(t *testing.T, repoPath, path string) string {
+	t.Helper()
+	l, err := layout.New(repoPath)
+	require.NoError(t, err)
+	data, err := object.Read(l, stagedHash(t, repoPath, path))
+	require.NoError(t, err)
+	return string(data)
+}
+
+// numberedLines returns n lines of the form "line <i>", replacing the lines given in changes.
+func numberedLines(n int, changes map[int]string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := changes[i]; ok {
+			sb.WriteString(line + "\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func fsckCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewFsckCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// objectPath returns the path of an object in the repository at repoPath.
+func objectPath(repoPath, hash string) string {
+	return filepath.Join(repoPath, ".trac", "objects", hash[:2], hash[2:])
+}
+
+func pruneCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewPruneCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func gcCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewGCCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// unreachableBlob stages and unstages a file, leaving its blob unreachable, and returns the blob hash.
+func unreachableBlob(t *testing.T, repoPath, content string) string {
+	t.Helper()
+	path := filepath.Join(repoPath, "unstaged.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, addCmd(t, path))
+	hash := stagedHash(t, repoPath, path)
+	_, err := resetCmd(t, "", path)
+	require.NoError(t, err)
+	return hash
+}
+
+func repackCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewRepackCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func configCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewConfigCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func objectCount(t *testing.T, repoPath string) int {
+	t.Helper()
+	l, err := layout.New(repoPath)
+	require.NoError(t, err)
+	hashes, err := object.List(l)
+	require.NoError(t, err)
+	return len(hashes)
+}
+
+func objectCountLoose(t *testing.T, repoPath string) int {
+	t.Helper()
+	l, err := layout.New(repoPath)
+	require.NoError(t, err)
+	hashes, err := object.ListLoose(l)
+	require.NoError(t, err)
+	return len(hashes)
+}
+
+func logCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewLogCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func diffCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewDiffCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func showCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewShowCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func branchCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewBranchCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func tagCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewTagCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func cloneCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewCloneCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func remoteCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewRemoteCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func fetchCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewFetchCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func pushCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewPushCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// refHash returns the commit a ref points at, given its full name, or "" if it does not exist.
+func refHash(t *testing.T, repoPath, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(repoPath, ".trac", filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+	require.NoError(t, err)
+	return strings.TrimSpace(string(data))
+}
+
+func bundleCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewBundleCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func fastImportCmd(t *testing.T, input string, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewFastImportCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func fastExportCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewFastExportCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func formatPatchCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewFormatPatchCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func applyCmd(t *testing.T, input string, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewApplyCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func amCmd(t *testing.T, input string, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewAmCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// writeHook installs a shell script as the named hook of the repository at repoPath.
+func writeHook(t *testing.T, repoPath, name, script string) {
+	t.Helper()
+	path := filepath.Join(repoPath, ".trac", "hooks", name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+}
+
+// headMessage returns the message of the commit HEAD points at.
+func headMessage(t *testing.T, repoPath string) string {
+	t.Helper()
+	l, err := layout.New(repoPath)
+	require.NoError(t, err)
+	c, err := commit.Load(headHash(t, repoPath), l)
+	require.NoError(t, err)
+	return c.Message
+}
+
+// setEditor makes a shell script, run with the path of the file to edit, the editor for the rest of the test.
+func setEditor(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "editor")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+	t.Setenv("TRAC_EDITOR", path)
+}
+
+func verifyCommitCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewVerifyCommitCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+func verifyTagCmd(t *testing.T, args ...string) (output string, err error) {
+	t.Helper()
+	cmd := NewVerifyTagCmd()
+	var buf bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&buf)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	return buf.String(), err
+}
+
+// writeSigningKey writes a new Ed25519 private key to a PEM file in dir and
+// returns the path of the file and the key's line for an allowed signers file.
+func writeSigningKey(t *testing.T, dir, principal string) (path, signer string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path = filepath.Join(dir, principal+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return path, principal + " " + sign.MarshalPublicKey(key.Public().(ed25519.PublicKey)) + "\n"
+}
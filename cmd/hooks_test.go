@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	t.Run("commit hooks", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		file := filepath.Join(tmpdir, "file.txt")
+		require.NoError(t, os.WriteFile(file, []byte("one\n"), 0644))
+		require.NoError(t, addCmd(t, file))
+
+		writeHook(t, tmpdir, "pre-commit", "echo pre-commit >> hooks.log\nexit 1\n")
+		err := commitCmd(t, "-m", "first")
+		require.ErrorIs(t, err, trac.ErrHookFailed)
+		require.ErrorContains(t, err, "pre-commit hook exited with status 1")
+		require.Empty(t, headHash(t, tmpdir))
+
+		writeHook(t, tmpdir, "pre-commit", "echo pre-commit >> hooks.log\n")
+		writeHook(t, tmpdir, "prepare-commit-msg", `echo "prepare-commit-msg $(basename "$1") $2" >> hooks.log
+printf '\nPrepared.\n' >> "$1"
+`)
+		writeHook(t, tmpdir, "commit-msg", `echo commit-msg >> hooks.log
+grep -q "^Signed-off-by:" "$1" || { echo "missing sign-off" >&2; exit 1; }
+`)
+		writeHook(t, tmpdir, "post-commit", "echo post-commit >> hooks.log\nexit 1\n")
+		require.NoError(t, os.Remove(filepath.Join(tmpdir, "hooks.log")))
+		err = commitCmd(t, "-m", "first")
+		require.ErrorContains(t, err, "commit-msg hook exited with status 1")
+		require.Empty(t, headHash(t, tmpdir))
+
+		require.NoError(t, os.Remove(filepath.Join(tmpdir, "hooks.log")))
+		require.NoError(t, commitCmd(t, "-m", "first\n\nSigned-off-by: Ada <ada@example.com>"))
+		log, err := os.ReadFile(filepath.Join(tmpdir, "hooks.log"))
+		require.NoError(t, err)
+		require.Equal(t, "pre-commit\nprepare-commit-msg COMMIT_EDITMSG message\ncommit-msg\npost-commit\n", string(log))
+		repo, err := trac.Open(tmpdir)
+		require.NoError(t, err)
+		c, err := repo.ReadCommit(headHash(t, tmpdir))
+		require.NoError(t, err)
+		require.Equal(t, "first\n\nSigned-off-by: Ada <ada@example.com>\n\nPrepared.", c.Message)
+
+		// --no-verify skips pre-commit and commit-msg
+		require.NoError(t, os.Remove(filepath.Join(tmpdir, "hooks.log")))
+		writeHook(t, tmpdir, "pre-commit", "exit 1\n")
+		require.NoError(t, os.WriteFile(file, []byte("two\n"), 0644))
+		require.NoError(t, addCmd(t, file))
+		require.NoError(t, commitCmd(t, "--no-verify", "-m", "second"))
+		log, err = os.ReadFile(filepath.Join(tmpdir, "hooks.log"))
+		require.NoError(t, err)
+		require.Equal(t, "prepare-commit-msg COMMIT_EDITMSG message\npost-commit\n", string(log))
+	})
+
+	t.Run("pre-commit runs after -a and paths stage their changes", func(t *testing.T) {
+		for _, args := range [][]string{{"-a"}, {"file.txt"}} {
+			tmpdir := initRepository(t)
+			require.NoError(t, os.Chdir(tmpdir))
+			file := filepath.Join(tmpdir, "file.txt")
+			commitFile(t, tmpdir, file, "one\n")
+			require.NoError(t, os.WriteFile(file, []byte("two\n"), 0644))
+			// The hook keeps a copy of the index it sees, then changes the file.
+			writeHook(t, tmpdir, "pre-commit", "cp .trac/index.json index.seen\necho three > file.txt\n")
+
+			require.NoError(t, commitCmd(t, append(args, "-m", "second")...))
+			seen, err := os.ReadFile(filepath.Join(tmpdir, "index.seen"))
+			require.NoError(t, err)
+			index, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "index.json"))
+			require.NoError(t, err)
+			require.Equal(t, string(index), string(seen))
+			require.Equal(t, "two\n", stagedContent(t, tmpdir, "file.txt"))
+			repo, err := trac.Open(tmpdir)
+			require.NoError(t, err)
+			c, err := repo.ReadCommit(headHash(t, tmpdir))
+			require.NoError(t, err)
+			require.Equal(t, stagedHash(t, tmpdir, "file.txt"), c.Files["file.txt"])
+			status, err := statusCmd(t, "--porcelain")
+			require.NoError(t, err)
+			require.Equal(t, " M file.txt\n?? index.seen\n", status)
+		}
+	})
+
+	t.Run("an aborted commit unstages what -a and paths staged", func(t *testing.T) {
+		for _, args := range [][]string{{"-a"}, {"file.txt"}} {
+			tmpdir := initRepository(t)
+			require.NoError(t, os.Chdir(tmpdir))
+			file := filepath.Join(tmpdir, "file.txt")
+			commitFile(t, tmpdir, file, "one\n")
+			require.NoError(t, os.WriteFile(file, []byte("two\n"), 0644))
+			index, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "index.json"))
+			require.NoError(t, err)
+
+			writeHook(t, tmpdir, "pre-commit", "exit 1\n")
+			err = commitCmd(t, append(args, "-m", "second")...)
+			require.ErrorIs(t, err, trac.ErrHookFailed)
+			// An empty message aborts the commit after pre-commit has run.
+			writeHook(t, tmpdir, "pre-commit", "exit 0\n")
+			err = commitCmd(t, append(args, "-m", " ")...)
+			require.ErrorIs(t, err, trac.ErrEmptyMessage)
+
+			after, err := os.ReadFile(filepath.Join(tmpdir, ".trac", "index.json"))
+			require.NoError(t, err)
+			require.Equal(t, string(index), string(after))
+			require.Equal(t, "one\n", stagedContent(t, tmpdir, "file.txt"))
+			status, err := statusCmd(t, "--porcelain")
+			require.NoError(t, err)
+			require.Equal(t, " M file.txt\n", status)
+		}
+	})
+
+	t.Run("hooks that are not executable are ignored", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		writeHook(t, tmpdir, "pre-commit", "exit 1\n")
+		require.NoError(t, os.Chmod(filepath.Join(tmpdir, ".trac", "hooks", "pre-commit"), 0644))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "file.txt"), "one\n")
+	})
+
+	t.Run("configured hooks path", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "githooks"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "githooks", "pre-commit"), []byte("#!/bin/sh\necho \"$TRAC_DIR\" > hooks.log\n"), 0755))
+		_, err := configCmd(t, "core.hooksPath", "githooks")
+		require.NoError(t, err)
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "file.txt"), "one\n")
+		log, err := os.ReadFile(filepath.Join(tmpdir, "hooks.log"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(tmpdir, ".trac")+"\n", string(log))
+	})
+
+	t.Run("post-checkout", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		file := filepath.Join(tmpdir, "file.txt")
+		first := commitFile(t, tmpdir, file, "one\n")
+		second := commitFile(t, tmpdir, file, "two\n")
+		writeHook(t, tmpdir, "post-checkout", "echo \"$@\" >> hooks.log\nexit 1\n")
+
+		_, err := checkoutCmd(t, first)
+		require.NoError(t, err)
+		_, err = checkoutCmd(t, "main")
+		require.NoError(t, err)
+		log, err := os.ReadFile(filepath.Join(tmpdir, "hooks.log"))
+		require.NoError(t, err)
+		require.Equal(t, second+" "+first+" 1\n"+first+" "+second+" 1\n", string(log))
+	})
+
+	t.Run("pre-push", func(t *testing.T) {
+		src := initRepository(t)
+		require.NoError(t, os.Chdir(src))
+		base := commitFile(t, src, filepath.Join(src, "file.txt"), "one\n")
+		_, err := branchCmd(t, "feature")
+		require.NoError(t, err)
+		parent := t.TempDir()
+		require.NoError(t, os.Chdir(parent))
+		_, err = cloneCmd(t, src, "copy")
+		require.NoError(t, err)
+		dst := filepath.Join(parent, "copy")
+		require.NoError(t, os.Chdir(dst))
+		_, err = checkoutCmd(t, "-b", "work", "main")
+		require.NoError(t, err)
+		pushed := commitFile(t, dst, filepath.Join(dst, "file.txt"), "two\n")
+
+		writeHook(t, dst, "pre-push", "echo \"$@\" > hooks.log\ncat >> hooks.log\nexit 1\n")
+		_, err = pushCmd(t, "origin", "work:feature", "main:incoming")
+		require.ErrorIs(t, err, trac.ErrHookFailed)
+		require.Empty(t, refHash(t, src, "refs/heads/incoming"))
+		require.Equal(t, base, refHash(t, src, "refs/heads/feature"))
+		log, err := os.ReadFile(filepath.Join(dst, "hooks.log"))
+		require.NoError(t, err)
+		require.Equal(t, strings.Join([]string{
+			"origin " + src,
+			"refs/heads/main " + base + " refs/heads/incoming " + strings.Repeat("0", 64),
+			"refs/heads/work " + pushed + " refs/heads/feature " + base,
+		}, "\n")+"\n", string(log))
+
+		_, err = pushCmd(t, "--no-verify", "origin", "work:feature")
+		require.NoError(t, err)
+		require.Equal(t, pushed, refHash(t, src, "refs/heads/feature"))
+	})
+}
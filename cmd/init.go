@@ -1,23 +1,26 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
-	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/pkg/trac"
 	"github.com/spf13/cobra"
 )
 
 type initOptions struct {
 	// repo path argument
-	repoPath string
+	repoPath    string
+	objectStore string // --object-store
 }
 
 func NewInitCmd() *cobra.Command {
 	opts := &initOptions{}
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "init [repoPath]",
 		Short: "Initialize a new trac repository",
 		Long:  "Initialize a new trac repository at the specified path. If no path is provided, the current working directory will be used.",
@@ -32,23 +35,34 @@ func NewInitCmd() *cobra.Command {
 				}
 				opts.repoPath = cwd
 			}
-			l, err := layout.New(opts.repoPath)
-			if err != nil {
-				return err
-			}
-			return initRepo(cmd.OutOrStdout(), l)
+			return initRepo(cmd.OutOrStdout(), opts)
 		},
 	}
+	cmd.Flags().StringVar(&opts.objectStore, "object-store", "loose", "Backend for the object store: loose or file")
+	return cmd
 }
 
-func initRepo(w io.Writer, l *layout.Layout) error {
-	if l.Exists() {
-		fmt.Fprintf(w, "Reinitialized existing trac repository in %s\n", l.Config)
+func initRepo(w io.Writer, opts *initOptions) error {
+	if err := checkObjectStore(opts.objectStore); err != nil {
+		return err
+	}
+	repo, err := trac.Init(opts.repoPath, &trac.InitOptions{ObjectStore: opts.objectStore})
+	if errors.Is(err, trac.ErrRepositoryExists) {
+		fmt.Fprintf(w, "Reinitialized existing trac repository in %s\n", repo.Dir())
 		return nil
 	}
-	if err := l.Init(); err != nil {
+	if err != nil {
 		return err
 	}
-	fmt.Fprintf(w, "Initialized empty trac repository in %s\n", l.Config)
+	fmt.Fprintf(w, "Initialized empty trac repository in %s\n", repo.Dir())
+	return nil
+}
+
+// checkObjectStore refuses an --object-store backend that core.objectStore
+// cannot select, such as memory, whose objects would be gone by the next command.
+func checkObjectStore(backend string) error {
+	if !object.ValidBackend(backend) {
+		return fmt.Errorf("%w: %q", object.ErrUnknownBackend, backend)
+	}
 	return nil
 }
@@ -3,9 +3,13 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
 	"github.com/stretchr/testify/require"
 )
 
@@ -36,3 +40,85 @@ func TestInitCommand(t *testing.T) {
 	require.NoError(t, cmd.Execute())
 	require.Equal(t, fmt.Sprintf("Reinitialized existing trac repository in %s\n", repoPath), buf.String())
 }
+
+func TestObjectStoreBackends(t *testing.T) {
+	t.Run("file store", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		cmd := NewInitCmd()
+		cmd.SetOut(io.Discard)
+		cmd.SetArgs([]string{tmpdir, "--object-store", "file"})
+		require.NoError(t, cmd.Execute())
+		require.NoError(t, os.Chdir(tmpdir))
+
+		testFile := filepath.Join(tmpdir, "test.txt")
+		first := commitFile(t, tmpdir, testFile, "one\n")
+		commitFile(t, tmpdir, testFile, "two\n")
+		require.FileExists(t, filepath.Join(tmpdir, ".trac", "objects.db"))
+		require.NoFileExists(t, objectPath(tmpdir, first))
+
+		out, err := fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		_, err = checkoutCmd(t, first)
+		require.NoError(t, err)
+		data, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		require.Equal(t, "one\n", string(data))
+
+		// gc packs reachable objects and removes them from the store; the second
+		// commit is still reachable through main after the checkout
+		_, err = gcCmd(t)
+		require.NoError(t, err)
+		_, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, 0, objectCountLoose(t, tmpdir))
+
+		// the backend cannot be changed once the store is in use
+		commitFile(t, tmpdir, testFile, "three\n")
+		_, err = configCmd(t, "core.objectStore", "loose")
+		require.ErrorContains(t, err, "already contains")
+	})
+
+	t.Run("embedded memory store", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		store := object.NewMemoryStore()
+		object.UseStore(l, store)
+
+		hash := commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "in memory\n")
+		ok, err := store.Has(hash)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.NoFileExists(t, objectPath(tmpdir, hash))
+		out, err := fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		cmd := NewInitCmd()
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs([]string{t.TempDir(), "--object-store", "s3"})
+		require.ErrorIs(t, cmd.Execute(), object.ErrUnknownBackend)
+	})
+
+	t.Run("memory is only for embedding", func(t *testing.T) {
+		// Each command would start with an empty store, so neither init nor
+		// the configuration can select it.
+		tmpdir := t.TempDir()
+		cmd := NewInitCmd()
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs([]string{tmpdir, "--object-store", "memory"})
+		require.ErrorIs(t, cmd.Execute(), object.ErrUnknownBackend)
+		require.NoDirExists(t, filepath.Join(tmpdir, ".trac"))
+
+		tmpdir = initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := configCmd(t, "core.objectStore", "memory")
+		require.ErrorIs(t, err, object.ErrUnknownBackend)
+	})
+}
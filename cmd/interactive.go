@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/diff"
+)
+
+// hunkContext is the number of unchanged lines shown around each hunk.
+const hunkContext = 3
+
+const hunkHelp = `y - %[1]s this hunk
+n - do not %[1]s this hunk
+q - quit; do not %[1]s this hunk or any of the remaining ones
+a - %[1]s this hunk and all later hunks in the file
+d - do not %[1]s this hunk or any of the later hunks in the file
+s - split the current hunk into smaller hunks
+e - manually edit the current hunk
+? - print help
+`
+
+// hunkPrompt asks which hunks of a change to apply, one hunk at a time.
+type hunkPrompt struct {
+	in     *bufio.Reader
+	out    io.Writer
+	action string // Verb describing what selecting a hunk does, e.g. "stage"
+	quit   bool   // Set once the user has asked to stop
+}
+
+func newHunkPrompt(in io.Reader, out io.Writer, action string) *hunkPrompt {
+	return &hunkPrompt{in: bufio.NewReader(in), out: out, action: action}
+}
+
+// selectHunks presents the hunks of a change to path and returns the ones selected, in order.
+// Selected hunks may have been split or edited by the user, in which case they are checked
+// to still apply to base, the version of the file the hunks are applied to.
+func (p *hunkPrompt) selectHunks(path string, base []string, hunks []diff.Hunk) ([]diff.Hunk, error) {
+	path = filepath.ToSlash(path)
+	fmt.Fprintf(p.out, "diff --trac a/%[1]s b/%[1]s\n--- a/%[1]s\n+++ b/%[1]s\n", path)
+
+	var selected []diff.Hunk
+	queue := slices.Clone(hunks)
+	for i := 0; i < len(queue); {
+		h := queue[i]
+		fmt.Fprint(p.out, h.String())
+		splittable := len(h.Split()) > 1
+		options := "y,n,q,a,d,e,?"
+		if splittable {
+			options = "y,n,q,a,d,s,e,?"
+		}
+		fmt.Fprintf(p.out, "(%d/%d) %s this hunk [%s]? ", i+1, len(queue), capitalize(p.action), options)
+
+		answer, err := p.in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" && errors.Is(err, io.EOF) {
+			// Treat the end of input like quitting.
+			fmt.Fprintln(p.out)
+			p.quit = true
+			return selected, nil
+		}
+		switch answer {
+		case "y":
+			selected = append(selected, h)
+			i++
+		case "n":
+			i++
+		case "q":
+			p.quit = true
+			return selected, nil
+		case "a":
+			return append(selected, queue[i:]...), nil
+		case "d":
+			return selected, nil
+		case "s":
+			if !splittable {
+				fmt.Fprintln(p.out, "Sorry, cannot split this hunk")
+				continue
+			}
+			parts := h.Split()
+			fmt.Fprintf(p.out, "Split into %d hunks.\n", len(parts))
+			queue = slices.Replace(queue, i, i+1, parts...)
+		case "e":
+			edited, err := p.editHunk(h)
+			if err == nil {
+				_, err = diff.Apply(base, []diff.Hunk{edited})
+			}
+			if err != nil {
+				fmt.Fprintf(p.out, "Your edited hunk does not apply: %v\n", err)
+				continue
+			}
+			selected = append(selected, edited)
+			i++
+		default:
+			fmt.Fprintf(p.out, hunkHelp, p.action)
+		}
+	}
+	return selected, nil
+}
+
+// editHunk lets the user edit a hunk in their editor and parses the result.
+func (p *hunkPrompt) editHunk(h diff.Hunk) (diff.Hunk, error) {
+	f, err := os.CreateTemp("", "trac-hunk-*.diff")
+	if err != nil {
+		return diff.Hunk{}, err
+	}
+	defer os.Remove(f.Name())
+
+	fmt.Fprintln(f, "# Manual hunk edit mode -- see bottom for a quick guide.")
+	body, _ := strings.CutPrefix(h.String(), h.Header()+"\n")
+	fmt.Fprint(f, body)
+	fmt.Fprintf(f, `# ---
+# To remove '-' lines, make them ' ' lines (context).
+# To remove '+' lines, delete them.
+# Lines starting with # will be removed.
+# If the patch applies cleanly, the edited hunk will immediately be marked for %s.
+`, p.action+"ing")
+	if err := f.Close(); err != nil {
+		return diff.Hunk{}, err
+	}
+	if err := launchEditor(f.Name()); err != nil {
+		return diff.Hunk{}, err
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return diff.Hunk{}, err
+	}
+	return diff.ParseHunk(h.OldStart, h.NewStart, string(data))
+}
+
+// synthesize converts the lines produced by applying hunks back into file content,
+// preserving the trailing newline of whichever version the result matches.
+func synthesize(lines []string, versions ...[]byte) []byte {
+	for _, v := range versions {
+		if slices.Equal(lines, diff.SplitLines(v)) {
+			return v
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+// dateFormat is the layout used to show commit dates.
+const dateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+type logOptions struct {
+	maxCount      int    // -n, --max-count
+	oneline       bool   // --oneline
+	showSignature bool   // --show-signature
+	follow        string // --follow
+	nameStatus    bool   // --name-status
+	format        formatOptions
+}
+
+func NewLogCmd() *cobra.Command {
+	opts := &logOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "log [<rev>] [--follow <path>]",
+		Short: "Show commit logs",
+		Long: `
+	Lists the commits reachable from the given revision, or HEAD, newest first. Each commit is shown with its hash, date and message.
+
+	--porcelain prints one line per commit, "<hash> <parent> <unix time> <summary>", with the zero hash as the parent of the first commit. With -z,
+	records are NUL-terminated and carry the full message instead of the summary. --json prints an array of commit objects. The porcelain and
+	JSON formats are stable across releases.
+
+	--show-signature checks the signature of each signed commit, as trac verify-commit does, and shows the outcome above it.
+
+	--follow lists only the commits that changed the given file, following it back through renames to the commit that added it.
+	--name-status shows the status and path of the files each commit changed below it, with the old path first for renamed files; with
+	--follow, only those of the followed file. Renames are found as diff.renameThreshold sets; see trac config.
+	`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			rev := "HEAD"
+			if len(args) > 0 {
+				rev = args[0]
+			}
+			if err := opts.format.check(porcelainV1); err != nil {
+				return err
+			}
+			if opts.nameStatus && (opts.format.json || opts.format.porcelain != "") {
+				return fmt.Errorf("--name-status cannot be used with --json or --porcelain")
+			}
+			if opts.follow != "" {
+				if opts.follow, err = filepath.Abs(opts.follow); err != nil {
+					return err
+				}
+			}
+			return runLog(cmd.OutOrStdout(), repo, rev, opts)
+		},
+	}
+	addFormatFlags(cmd, &opts.format, porcelainV1)
+	cmd.Flags().IntVarP(&opts.maxCount, "max-count", "n", 0, "Limit the number of commits to show")
+	cmd.Flags().BoolVar(&opts.oneline, "oneline", false, "Show each commit on a single line as its abbreviated hash and summary")
+	cmd.Flags().BoolVar(&opts.showSignature, "show-signature", false, "Check the signatures of signed commits")
+	cmd.Flags().StringVar(&opts.follow, "follow", "", "Show only commits that changed the file at `path`, following it through renames")
+	cmd.Flags().BoolVar(&opts.nameStatus, "name-status", false, "Show the status and path of the files each commit changed")
+	return cmd
+}
+
+func runLog(w io.Writer, repo *trac.Repository, rev string, opts *logOptions) error {
+	ctx := context.Background()
+	n := 0
+	commits := []commitJSON{}
+	for fc, err := range logCommits(ctx, repo, rev, opts.follow) {
+		if err != nil {
+			return err
+		}
+		c := fc.Commit
+		if opts.maxCount > 0 && n == opts.maxCount {
+			break
+		}
+		switch {
+		case opts.format.json:
+			commits = append(commits, newCommitJSON(c))
+		case opts.format.porcelain != "":
+			writeCommitRecord(w, &opts.format, c)
+		case opts.oneline:
+			if line := signatureLine(repo, c.Hash, opts.showSignature); line != "" {
+				fmt.Fprintln(w, line)
+			}
+			fmt.Fprintf(w, "%s %s\n", color.YellowString(c.Hash[:8]), c.Summary())
+		default:
+			if n > 0 {
+				fmt.Fprintln(w)
+			}
+			printCommit(w, c, signatureLine(repo, c.Hash, opts.showSignature))
+		}
+		if opts.nameStatus {
+			changes := []trac.Change{fc.Change}
+			if opts.follow == "" {
+				if changes, err = repo.Changes(ctx, c.Parent, c.Hash, &trac.DiffOptions{Renames: true}); err != nil {
+					return err
+				}
+			}
+			if !opts.oneline {
+				fmt.Fprintln(w)
+			}
+			writeNameStatus(w, changes)
+		}
+		n++
+	}
+	if opts.format.json {
+		return writeJSON(w, commits)
+	}
+	return nil
+}
+
+// logCommits iterates over the commits log shows: those reachable from rev,
+// or with a path to follow, those that changed it along with the change.
+func logCommits(ctx context.Context, repo *trac.Repository, rev, follow string) iter.Seq2[*trac.FileChange, error] {
+	if follow != "" {
+		return repo.Follow(ctx, rev, follow, nil)
+	}
+	return func(yield func(*trac.FileChange, error) bool) {
+		for c, err := range repo.Log(ctx, rev) {
+			if !yield(&trac.FileChange{Commit: c}, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// printCommit writes the header and indented message of a commit. Commits
+// that record their author show it, along with the time the change was written.
+// signature, if not empty, is shown below the hash.
+func printCommit(w io.Writer, c *trac.Commit, signature string) {
+	color.New(color.FgYellow).Fprintf(w, "commit %s\n", c.Hash)
+	if signature != "" {
+		fmt.Fprintln(w, signature)
+	}
+	date := c.Time
+	if c.Author != nil {
+		fmt.Fprintf(w, "Author: %s\n", c.Author)
+		date = c.Author.Time
+	}
+	fmt.Fprintf(w, "Date:   %s\n\n", date.Local().Format(dateFormat))
+	for _, line := range strings.Split(strings.TrimRight(c.Message, "\n"), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
+
+// signatureLine describes the outcome of checking the signature of a commit
+// for --show-signature, or returns "" if show is false or the commit is not
+// signed.
+func signatureLine(repo *trac.Repository, hash string, show bool) string {
+	if !show {
+		return ""
+	}
+	v, err := repo.VerifyCommit(hash)
+	switch {
+	case errors.Is(err, trac.ErrUnsigned):
+		return ""
+	case errors.Is(err, trac.ErrBadSignature), errors.Is(err, trac.ErrInvalidSignature):
+		return "BAD signature"
+	case err == nil || errors.Is(err, trac.ErrUnknownSigner):
+		var b strings.Builder
+		printVerification(&b, v, err)
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+	return fmt.Sprintf("Cannot check signature: %v", err)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := logCmd(t)
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("no commits", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := logCmd(t)
+		require.ErrorIs(t, err, commit.ErrNoCommits)
+	})
+
+	t.Run("lists history newest first", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		first := commitFile(t, tmpdir, testFile, "first")
+		second := commitFile(t, tmpdir, testFile, "second")
+
+		out, err := logCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, 2, strings.Count(out, "commit "))
+		require.Less(t, strings.Index(out, "commit "+second), strings.Index(out, "commit "+first))
+		require.Contains(t, out, "\n    update test.txt\n")
+		require.Contains(t, out, "Date:   ")
+
+		out, err = logCmd(t, "--oneline", "-n", "1", "HEAD~1")
+		require.NoError(t, err)
+		require.Equal(t, first[:8]+" update test.txt\n", out)
+	})
+
+	t.Run("following a file through renames", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		old := filepath.Join(tmpdir, "old.txt")
+		renamed := filepath.Join(tmpdir, "new.txt")
+		added := commitFile(t, tmpdir, old, "one\ntwo\nthree\n")
+		edited := commitFile(t, tmpdir, old, "one\ntwo\nthree\nfour\n")
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "other.txt"), "other\n")
+		require.NoError(t, os.Rename(old, renamed))
+		require.NoError(t, os.WriteFile(renamed, []byte("one\ntwo\nthree\nfive\n"), 0644))
+		require.NoError(t, addCmd(t, old, renamed))
+		require.NoError(t, commitCmd(t, "-m", "rename old.txt"))
+		moved := headHash(t, tmpdir)
+
+		out, err := logCmd(t, "--oneline", "--name-status", "--follow", "new.txt")
+		require.NoError(t, err)
+		require.Equal(t, moved[:8]+" rename old.txt\nR073\told.txt\tnew.txt\n"+
+			edited[:8]+" update old.txt\nM\told.txt\n"+
+			added[:8]+" update old.txt\nA\told.txt\n", out)
+
+		// Without --follow, each commit lists every file it changed.
+		out, err = logCmd(t, "--oneline", "-n", "1", "--name-status")
+		require.NoError(t, err)
+		require.Equal(t, moved[:8]+" rename old.txt\nR073\told.txt\tnew.txt\n", out)
+		out, err = logCmd(t, "--name-status", "-n", "2")
+		require.NoError(t, err)
+		require.Contains(t, out, "    update other.txt\n\nA\tother.txt\n")
+	})
+
+	t.Run("machine-readable output", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		first := commitFile(t, tmpdir, testFile, "first")
+		second := commitFile(t, tmpdir, testFile, "second")
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		c, err := commit.Load(first, l)
+		require.NoError(t, err)
+		unix := strconv.FormatInt(c.Timestamp.Unix(), 10)
+
+		out, err := logCmd(t, "--porcelain", "HEAD~1")
+		require.NoError(t, err)
+		require.Equal(t, first+" "+strings.Repeat("0", 64)+" "+unix+" update test.txt\n", out)
+
+		out, err = logCmd(t, "-z", "-n", "1")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, second+" "+first+" "))
+		require.True(t, strings.HasSuffix(out, " update test.txt\x00"))
+
+		out, err = logCmd(t, "--json")
+		require.NoError(t, err)
+		var commits []struct {
+			Hash    string    `json:"hash"`
+			Parent  string    `json:"parent"`
+			Time    time.Time `json:"time"`
+			Message string    `json:"message"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &commits))
+		require.Len(t, commits, 2)
+		require.Equal(t, second, commits[0].Hash)
+		require.Equal(t, first, commits[0].Parent)
+		require.Equal(t, "update test.txt", commits[1].Message)
+		require.True(t, c.Timestamp.Equal(commits[1].Time))
+	})
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/gc"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/spf13/cobra"
+)
+
+type pruneOptions struct {
+	expire  string // --expire
+	dryRun  bool   // -n, --dry-run
+	verbose bool   // -v, --verbose
+}
+
+func NewPruneCmd() *cobra.Command {
+	opts := &pruneOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "prune [--expire=<time>]",
+		Short: "Remove unreachable objects from the object database",
+		Long: `
+	Removes objects that are not reachable from HEAD, any branch, tag or remote-tracking branch, a bisect session in progress or the index. These are
+	typically blobs of files that were staged and later unstaged, or commits that no branch or tag leads to any more, such as those of a deleted
+	branch. Unmerged branches are kept.
+
+	Only objects older than the --expire time are removed, which defaults to removing every unreachable object. The time may be "now", "never", a
+	relative date such as "2.weeks.ago", a duration such as "72h", or a date such as "2024-01-31".
+	`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return runPrune(cmd.OutOrStdout(), l, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.expire, "expire", "now", "Only prune unreachable objects older than this time")
+	cmd.Flags().BoolVarP(&opts.dryRun, "dry-run", "n", false, "Report what would be pruned without removing anything")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Report each pruned object")
+	return cmd
+}
+
+func runPrune(w io.Writer, l *layout.Layout, opts *pruneOptions) error {
+	expire, err := gc.ParseExpire(opts.expire, time.Now())
+	if err != nil {
+		return err
+	}
+	result, err := gc.Prune(l, gc.Options{Expire: expire, DryRun: opts.dryRun})
+	if err != nil {
+		return err
+	}
+	if opts.verbose || opts.dryRun {
+		for _, hash := range result.Pruned {
+			fmt.Fprintln(w, hash)
+		}
+	}
+	printPruneResult(w, result, opts.dryRun)
+	return nil
+}
+
+func printPruneResult(w io.Writer, result *gc.Result, dryRun bool) {
+	verb := "Pruned"
+	if dryRun {
+		verb = "Would prune"
+	}
+	fmt.Fprintf(w, "%s %d unreachable object(s), reclaiming %s\n", verb, len(result.Pruned), formatBytes(result.Bytes))
+}
+
+// formatBytes formats a size in bytes using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
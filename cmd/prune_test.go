@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := pruneCmd(t)
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("prunes unreachable objects", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, "first")
+		head := commitFile(t, tmpdir, testFile, "second")
+		blob := unreachableBlob(t, tmpdir, "unstaged")
+
+		out, err := pruneCmd(t, "--dry-run")
+		require.NoError(t, err)
+		require.Equal(t, blob+"\nWould prune 1 unreachable object(s), reclaiming 8 bytes\n", out)
+		require.FileExists(t, objectPath(tmpdir, blob))
+
+		out, err = pruneCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "Pruned 1 unreachable object(s), reclaiming 8 bytes\n", out)
+		require.NoFileExists(t, objectPath(tmpdir, blob))
+		require.FileExists(t, objectPath(tmpdir, head))
+
+		out, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	})
+
+	t.Run("expire keeps recent objects", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "content")
+		blob := unreachableBlob(t, tmpdir, "unstaged")
+
+		out, err := pruneCmd(t, "--expire", "1.hour.ago")
+		require.NoError(t, err)
+		require.Equal(t, "Pruned 0 unreachable object(s), reclaiming 0 bytes\n", out)
+		require.FileExists(t, objectPath(tmpdir, blob))
+
+		_, err = pruneCmd(t, "--expire", "soon")
+		require.EqualError(t, err, `invalid expiry date "soon"`)
+	})
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type pushOptions struct {
+	force    bool // -f, --force
+	noVerify bool // --no-verify
+}
+
+func NewPushCmd() *cobra.Command {
+	opts := &pushOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "push [<remote> [<refspec>...]]",
+		Short: "Update the refs of another repository",
+		Long: `
+	Copies the commits and file contents of the given local refs that are missing from the remote, and updates the remote refs they map to.
+	The remote defaults to origin and the refspec to the current branch. A refspec may name a branch or tag, as in "main", or map it to
+	another name, as in "main:release". Remote-tracking branches are updated to match the pushed refs.
+
+	Remote refs are only moved forward, to commits that contain their current commit, unless the refspec starts with "+" or --force is given.
+	The branch checked out in the remote repository is never updated.
+
+	Before anything is sent, the pre-push hook in .trac/hooks, or the directory set by core.hooksPath, runs in the root of the working
+	tree with the remote's name and URL as arguments and a line on standard input for each remote ref the push would change:
+
+	    <local ref> <local hash> <remote ref> <remote hash>
+
+	where the remote hash is 64 zeros for a ref the remote does not have yet. A non-zero exit status aborts the push. With --no-verify,
+	the hook is skipped.
+	`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			name := trac.DefaultRemote
+			if len(args) > 0 {
+				name, args = args[0], args[1:]
+			}
+			return runPush(cmd.OutOrStdout(), cmd.ErrOrStderr(), repo, name, args, opts)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "Allow updates that are not fast-forwards")
+	cmd.Flags().BoolVar(&opts.noVerify, "no-verify", false, "Skip the pre-push hook")
+	return cmd
+}
+
+func runPush(w, hookOutput io.Writer, repo *trac.Repository, name string, refspecs []string, opts *pushOptions) error {
+	ctx := context.Background()
+	pushOpts := &trac.PushOptions{Force: opts.force, HTTP: httpOptions()}
+	if !opts.noVerify {
+		pushOpts.PrePush = func(updates []trac.RefUpdate) error {
+			var stdin strings.Builder
+			for _, u := range updates {
+				fmt.Fprintf(&stdin, "%s %s %s %s\n", u.Src, u.New, u.Dst, orZeroHash(u.Old))
+			}
+			_, err := repo.RunHook(ctx, trac.PrePushHook, trac.HookOptions{
+				Args:   []string{name, remoteURL(repo, name)},
+				Stdin:  strings.NewReader(stdin.String()),
+				Output: hookOutput,
+			})
+			return err
+		}
+	}
+	updates, err := repo.Push(ctx, name, refspecs, pushOpts)
+	if err != nil {
+		return err
+	}
+	upToDate := true
+	for _, u := range updates {
+		upToDate = upToDate && u.Status == trac.UpToDate
+	}
+	if upToDate {
+		fmt.Fprintln(w, "Everything up-to-date")
+		return nil
+	}
+	if err := printUpdates(w, "To "+remoteURL(repo, name), updates); err != nil {
+		return fmt.Errorf("failed to push some refs: %w", err)
+	}
+	return nil
+}
+
+// orZeroHash returns hash, or 64 zeros, the hash hooks are passed for a ref or
+// HEAD that does not point at a commit, if it is empty.
+func orZeroHash(hash string) string {
+	if hash == "" {
+		return strings.Repeat("0", 64)
+	}
+	return hash
+}
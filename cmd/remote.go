@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+type remoteOptions struct {
+	verbose bool // -v, --verbose
+}
+
+func NewRemoteCmd() *cobra.Command {
+	opts := &remoteOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "remote",
+		Short: "Manage the repositories history is fetched from and pushed to",
+		Long: `
+	Without a subcommand, lists the configured remotes. Each remote is stored in the repository config as remote.<name>.url and
+	remote.<name>.fetch, the whitespace-separated refspecs trac fetch uses, which default to +refs/heads/*:refs/remotes/<name>/*.
+	`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRemoteList(cmd.OutOrStdout(), opts)
+		},
+	}
+	cmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "Show the URL of each remote")
+	cmd.AddCommand(newRemoteAddCmd())
+	cmd.AddCommand(newRemoteRemoveCmd())
+	cmd.AddCommand(newRemoteListCmd(opts))
+	return cmd
+}
+
+func newRemoteAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <name> <url>",
+		Short: "Add a remote",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return repo.AddRemote(args[0], args[1])
+		},
+	}
+}
+
+func newRemoteRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <name>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a remote and its remote-tracking branches",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return repo.RemoveRemote(args[0])
+		},
+	}
+}
+
+func newRemoteListCmd(opts *remoteOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the remotes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRemoteList(cmd.OutOrStdout(), opts)
+		},
+	}
+}
+
+func runRemoteList(w io.Writer, opts *remoteOptions) error {
+	repo, err := openRepository()
+	if err != nil {
+		return err
+	}
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return err
+	}
+	for _, r := range remotes {
+		if opts.verbose {
+			fmt.Fprintf(w, "%s\t%s\n", r.Name, r.URL)
+		} else {
+			fmt.Fprintln(w, r.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneCommand(t *testing.T) {
+	src := initRepository(t)
+	require.NoError(t, os.Chdir(src))
+	_, err := configCmd(t, "core.chunkThreshold", "64k")
+	require.NoError(t, err)
+	data := make([]byte, 512<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+	large := filepath.Join(src, "large.bin")
+	first := commitFile(t, src, large, string(data))
+	require.NoError(t, os.Mkdir(filepath.Join(src, "docs"), 0755))
+	second := commitFile(t, src, filepath.Join(src, "docs", "README"), "readme")
+	_, err = tagCmd(t, "v1", first)
+	require.NoError(t, err)
+	_, err = branchCmd(t, "feature", first)
+	require.NoError(t, err)
+
+	parent := t.TempDir()
+	require.NoError(t, os.Chdir(parent))
+	out, err := cloneCmd(t, src, "copy")
+	require.NoError(t, err)
+	require.Equal(t, "Cloning into 'copy'...\n", out)
+
+	dst := filepath.Join(parent, "copy")
+	require.Equal(t, second, headHash(t, dst))
+	require.Equal(t, second, refHash(t, dst, "refs/remotes/origin/main"))
+	require.Equal(t, first, refHash(t, dst, "refs/remotes/origin/feature"))
+	require.Equal(t, first, refHash(t, dst, "refs/tags/v1"))
+	require.Empty(t, refHash(t, dst, "refs/heads/feature"))
+	data, err = os.ReadFile(filepath.Join(dst, "docs", "README"))
+	require.NoError(t, err)
+	require.Equal(t, "readme", string(data))
+	srcData, err := os.ReadFile(large)
+	require.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(dst, "large.bin"))
+	require.NoError(t, err)
+	require.Equal(t, srcData, data)
+
+	require.NoError(t, os.Chdir(dst))
+	out, err = fsckCmd(t)
+	require.NoError(t, err)
+	require.Empty(t, out)
+	out, err = statusCmd(t)
+	require.NoError(t, err)
+	require.Equal(t, "nothing to commit, working tree clean\n", out)
+	out, err = remoteCmd(t, "-v")
+	require.NoError(t, err)
+	require.Equal(t, "origin\t"+src+"\n", out)
+
+	t.Run("other branch", func(t *testing.T) {
+		require.NoError(t, os.Chdir(parent))
+		_, err := cloneCmd(t, "--branch", "feature", src, "feature")
+		require.NoError(t, err)
+		require.Equal(t, first, headHash(t, filepath.Join(parent, "feature")))
+		require.NoFileExists(t, filepath.Join(parent, "feature", "docs", "README"))
+	})
+
+	t.Run("empty repository", func(t *testing.T) {
+		empty := initRepository(t)
+		require.NoError(t, os.Chdir(parent))
+		_, err := cloneCmd(t, empty, "empty")
+		require.NoError(t, err)
+		require.Empty(t, headHash(t, filepath.Join(parent, "empty")))
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		require.NoError(t, os.Chdir(parent))
+		_, err := cloneCmd(t, t.TempDir(), "nothing")
+		require.ErrorIs(t, err, trac.ErrNotRemote)
+	})
+}
+
+func TestRemoteCommand(t *testing.T) {
+	other := initRepository(t)
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+
+	_, err := remoteCmd(t, "add", "upstream", other)
+	require.NoError(t, err)
+	_, err = remoteCmd(t, "add", "backup", "../backup")
+	require.NoError(t, err)
+	_, err = remoteCmd(t, "add", "upstream", other)
+	require.ErrorIs(t, err, trac.ErrRemoteExists)
+	_, err = remoteCmd(t, "add", "bad/name", other)
+	require.Error(t, err)
+
+	out, err := remoteCmd(t)
+	require.NoError(t, err)
+	require.Equal(t, "backup\nupstream\n", out)
+	out, err = remoteCmd(t, "list", "-v")
+	require.NoError(t, err)
+	require.Equal(t, "backup\t../backup\nupstream\t"+other+"\n", out)
+
+	// fetching creates remote-tracking branches, which go away with the remote
+	require.NoError(t, os.Chdir(other))
+	commitFile(t, other, filepath.Join(other, "test.txt"), "content")
+	require.NoError(t, os.Chdir(tmpdir))
+	_, err = fetchCmd(t, "upstream")
+	require.NoError(t, err)
+	require.NotEmpty(t, refHash(t, tmpdir, "refs/remotes/upstream/main"))
+
+	_, err = remoteCmd(t, "remove", "upstream")
+	require.NoError(t, err)
+	require.Empty(t, refHash(t, tmpdir, "refs/remotes/upstream/main"))
+	_, err = remoteCmd(t, "rm", "upstream")
+	require.ErrorIs(t, err, trac.ErrRemoteNotFound)
+	_, err = fetchCmd(t, "backup")
+	require.Error(t, err)
+}
+
+func TestFetchAndPush(t *testing.T) {
+	src := initRepository(t)
+	require.NoError(t, os.Chdir(src))
+	srcFile := filepath.Join(src, "test.txt")
+	commitFile(t, src, srcFile, "one")
+	parent := t.TempDir()
+	require.NoError(t, os.Chdir(parent))
+	_, err := cloneCmd(t, src, "dst")
+	require.NoError(t, err)
+	dst := filepath.Join(parent, "dst")
+	dstFile := filepath.Join(dst, "test.txt")
+
+	t.Run("fetch fast-forwards remote-tracking branches", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		old := headHash(t, src)
+		second := commitFile(t, src, srcFile, "two")
+		_, err := branchCmd(t, "topic")
+		require.NoError(t, err)
+
+		require.NoError(t, os.Chdir(dst))
+		out, err := fetchCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, out, "From "+src+"\n")
+		require.Contains(t, out, old[:8]+".."+second[:8])
+		require.Contains(t, out, "main -> origin/main\n")
+		require.Contains(t, out, "[new branch]       topic -> origin/topic\n")
+		require.Equal(t, second, refHash(t, dst, "refs/remotes/origin/main"))
+
+		out, err = fetchCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	})
+
+	t.Run("fetch refuses to rewind refs unless forced", func(t *testing.T) {
+		require.NoError(t, os.Chdir(src))
+		_, err := branchCmd(t, "-f", "topic", "HEAD~1")
+		require.NoError(t, err)
+		rewound := refHash(t, src, "refs/heads/topic")
+
+		require.NoError(t, os.Chdir(dst))
+		before := refHash(t, dst, "refs/remotes/origin/topic")
+		out, err := fetchCmd(t, "origin", "refs/heads/topic:refs/remotes/origin/topic")
+		require.ErrorContains(t, err, "some refs were not updated")
+		require.Contains(t, out, "[rejected]")
+		require.Equal(t, before, refHash(t, dst, "refs/remotes/origin/topic"))
+
+		out, err = fetchCmd(t, "--force", "origin", "refs/heads/topic:refs/remotes/origin/topic")
+		require.NoError(t, err)
+		require.Contains(t, out, "(forced update)")
+		require.Equal(t, rewound, refHash(t, dst, "refs/remotes/origin/topic"))
+	})
+
+	t.Run("push", func(t *testing.T) {
+		require.NoError(t, os.Chdir(dst))
+		_, err := checkoutCmd(t, "-b", "work", "origin/main")
+		require.NoError(t, err)
+		pushed := commitFile(t, dst, dstFile, "three")
+
+		// the branch checked out in the remote repository is left alone
+		out, err := pushCmd(t, "origin", "work:main")
+		require.ErrorContains(t, err, "failed to push some refs")
+		require.Contains(t, out, "(branch is checked out)")
+
+		out, err = pushCmd(t, "origin", "work:incoming")
+		require.NoError(t, err)
+		require.Contains(t, out, "To "+src+"\n")
+		require.Contains(t, out, "[new branch]       work -> incoming\n")
+		require.Equal(t, pushed, refHash(t, src, "refs/heads/incoming"))
+		require.Equal(t, pushed, refHash(t, dst, "refs/remotes/origin/incoming"))
+
+		out, err = pushCmd(t, "origin", "work:incoming")
+		require.NoError(t, err)
+		require.Equal(t, "Everything up-to-date\n", out)
+
+		require.NoError(t, os.Chdir(src))
+		out, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		out, err = showCmd(t, "incoming")
+		require.NoError(t, err)
+		require.Contains(t, out, "+three")
+	})
+
+	t.Run("push refuses to rewind refs unless forced", func(t *testing.T) {
+		require.NoError(t, os.Chdir(dst))
+		_, err := checkoutCmd(t, "-b", "rewrite", "work~1")
+		require.NoError(t, err)
+		rewritten := commitFile(t, dst, dstFile, "rewritten")
+
+		out, err := pushCmd(t, "origin", "rewrite:incoming")
+		require.Error(t, err)
+		require.Contains(t, out, "(non-fast-forward)")
+
+		out, err = pushCmd(t, "--force", "origin", "rewrite:incoming")
+		require.NoError(t, err)
+		require.Contains(t, out, "(forced update)")
+		require.Equal(t, rewritten, refHash(t, src, "refs/heads/incoming"))
+
+		// pushing the current branch to its own name
+		out, err = pushCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, out, "rewrite -> rewrite\n")
+		require.Equal(t, rewritten, refHash(t, src, "refs/heads/rewrite"))
+
+		_, err = pushCmd(t, "origin", "nonexistent")
+		require.Error(t, err)
+	})
+}
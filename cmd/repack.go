@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/gc"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/pack"
+	"github.com/spf13/cobra"
+)
+
+type repackOptions struct {
+	all    bool // -a
+	delete bool // -d
+	window int  // --window
+	depth  int  // --depth
+}
+
+func NewRepackCmd() *cobra.Command {
+	opts := &repackOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "repack",
+		Short: "Pack loose objects into a packfile",
+		Long: `
+	Combines loose objects into a pack, storing each object either compressed in full or as a compressed delta against a similar object. Packs are
+	read transparently alongside loose objects.
+
+	With -a, objects in existing packs are included so that everything ends up in a single pack. With -d, loose objects and packs made redundant by
+	the new pack are removed. --window sets how many neighbouring objects are tried as delta bases, and --depth limits the length of delta chains;
+	larger values produce smaller packs at the cost of time.
+	`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return runRepack(cmd.OutOrStdout(), l, opts)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.all, "all", "a", false, "Pack all objects, including those already packed")
+	cmd.Flags().BoolVarP(&opts.delete, "delete", "d", false, "Remove redundant loose objects and packs")
+	cmd.Flags().IntVar(&opts.window, "window", gc.DefaultWindow, "Number of objects to try as delta bases")
+	cmd.Flags().IntVar(&opts.depth, "depth", gc.DefaultDepth, "Maximum delta chain length")
+	return cmd
+}
+
+func runRepack(w io.Writer, l *layout.Layout, opts *repackOptions) error {
+	if opts.window < 0 || opts.depth < 0 {
+		return fmt.Errorf("--window and --depth must not be negative")
+	}
+	stats, err := gc.Repack(l, gc.RepackOptions{
+		Window: opts.window,
+		Depth:  opts.depth,
+		All:    opts.all,
+		Delete: opts.delete,
+	})
+	if err != nil {
+		return err
+	}
+	printRepackStats(w, stats)
+	return nil
+}
+
+func printRepackStats(w io.Writer, stats *pack.Stats) {
+	if stats == nil {
+		fmt.Fprintln(w, "Nothing new to pack.")
+		return
+	}
+	fmt.Fprintf(w, "Packed %d object(s) (%d delta(s)) into %s (%s)\n", stats.Objects, stats.Deltas, stats.Name, formatBytes(stats.Size))
+}
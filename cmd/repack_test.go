@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepackCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := repackCmd(t)
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("nothing to pack", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		out, err := repackCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "Nothing new to pack.\n", out)
+	})
+
+	t.Run("packs objects with deltas", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		var hashes []string
+		for i := range 5 {
+			hashes = append(hashes, commitFile(t, tmpdir, testFile, numberedLines(500, map[int]string{i * 100: "changed"})))
+		}
+
+		out, err := repackCmd(t, "-a", "-d")
+		require.NoError(t, err)
+		require.Contains(t, out, "Packed 10 object(s)")
+		require.NotContains(t, out, "(0 delta(s))")
+		for _, hash := range hashes {
+			require.NoFileExists(t, objectPath(tmpdir, hash))
+		}
+
+		// packed objects are read transparently
+		out, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+		out, err = blameCmd(t, "-L", "400,400", "test.txt")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(out, hashes[4][:8]))
+		_, err = checkoutCmd(t, hashes[0][:8])
+		require.NoError(t, err)
+		data, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		require.Equal(t, numberedLines(500, map[int]string{0: "changed"}), string(data))
+
+		// new objects are packed incrementally next to the existing pack
+		_, err = checkoutCmd(t, hashes[4])
+		require.NoError(t, err)
+		commitFile(t, tmpdir, testFile, "new content")
+		_, err = repackCmd(t, "-d")
+		require.NoError(t, err)
+		packs, err := filepath.Glob(filepath.Join(tmpdir, ".trac", "objects", "pack", "*.idx"))
+		require.NoError(t, err)
+		require.Len(t, packs, 2)
+
+		_, err = repackCmd(t, "-a", "-d")
+		require.NoError(t, err)
+		packs, err = filepath.Glob(filepath.Join(tmpdir, ".trac", "objects", "pack", "*.idx"))
+		require.NoError(t, err)
+		require.Len(t, packs, 1)
+		out, err = fsckCmd(t)
+		require.NoError(t, err)
+		require.Empty(t, out)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := repackCmd(t, "--window", "-1")
+		require.Error(t, err)
+	})
+}
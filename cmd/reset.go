@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/spf13/cobra"
+)
+
+type resetOptions struct {
+	patch bool // -p, --patch
+}
+
+func NewResetCmd() *cobra.Command {
+	opts := &resetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "reset [-p] [<path>...]",
+		Short: "Unstage changes by resetting index entries to HEAD",
+		Long: `
+	Resets the index entries of the given paths to their state at HEAD, undoing "trac add" without touching the working tree. Paths that do not exist
+	at HEAD are removed from the index. When no paths are given, the whole index is reset.
+
+	With --patch, the differences between HEAD and the index are presented one hunk at a time, and only the selected hunks are removed from the index.
+	`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !opts.patch {
+				repo, err := openRepository()
+				if err != nil {
+					return err
+				}
+				return repo.Unstage(context.Background(), args...)
+			}
+			l, err := repoLayout()
+			if err != nil {
+				return err
+			}
+			return unstagePatch(cmd.InOrStdin(), cmd.OutOrStdout(), l, args)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.patch, "patch", "p", false, "Interactively choose hunks of changes to unstage")
+	return cmd
+}
+
+// unstagePatch interactively removes hunks of the differences between HEAD and the index from the index.
+// When no paths are given, every staged file is considered.
+func unstagePatch(in io.Reader, w io.Writer, l *layout.Layout, paths []string) error {
+	head, err := headCommit(l)
+	if err != nil {
+		return err
+	}
+	idx := index.New()
+	if err := idx.Load(l); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	keys, err := trackedKeys(idx, l, paths)
+	if err != nil {
+		return err
+	}
+	prompt := newHunkPrompt(in, w, "unstage")
+	found := false
+	for _, key := range keys {
+		staged, err := readStaged(l, idx, key)
+		if err != nil {
+			return err
+		}
+		var original []byte
+		headHash, err := head.Lookup(key, l)
+		switch {
+		case err == nil:
+			if original, err = object.Read(l, headHash); err != nil {
+				return err
+			}
+		case !errors.Is(err, commit.ErrPathNotInCommit):
+			return err
+		}
+
+		base := diff.SplitLines(staged)
+		hunks := diff.Hunks(diff.Lines(diff.SplitLines(original), base), hunkContext)
+		if len(hunks) == 0 {
+			continue
+		}
+		found = true
+		relPath, err := l.RelPath(key)
+		if err != nil {
+			return err
+		}
+		// Hunks are shown as changes from HEAD to the index, and undone by applying them in reverse.
+		selected, err := prompt.selectHunks(relPath, diff.SplitLines(original), hunks)
+		if err != nil {
+			return err
+		}
+		if len(selected) > 0 {
+			reversed := make([]diff.Hunk, len(selected))
+			for i, h := range selected {
+				reversed[i] = h.Reverse()
+			}
+			result, err := diff.Apply(base, reversed)
+			if err != nil {
+				return err
+			}
+			if headHash == "" && len(result) == 0 {
+				// Unstaging all of a new file removes it from the index.
+				delete(idx.Staged, key)
+			} else {
+				hash, err := object.Write(l, synthesize(result, original, staged))
+				if err != nil {
+					return err
+				}
+				idx.Staged[key] = hash
+			}
+		}
+		if prompt.quit {
+			break
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "No changes.")
+		return nil
+	}
+	if err := idx.Write(l); err != nil {
+		return fmt.Errorf("failed to write updated index: %w", err)
+	}
+	return nil
+}
+
+// headCommit loads the commit HEAD points at, or an empty commit if there are no commits yet.
+func headCommit(l *layout.Layout) (*commit.Commit, error) {
+	hash, err := commit.GetParentHash(l)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return &commit.Commit{Changes: map[string]string{}}, nil
+	}
+	return commit.Load(hash, l)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetCommand(t *testing.T) {
+	t.Run("non-trac repository", func(t *testing.T) {
+		tmpdir := t.TempDir()
+		require.NoError(t, os.Chdir(tmpdir))
+		_, err := resetCmd(t, "")
+		require.EqualError(t, err, layout.ErrNotTracRepository.Error())
+	})
+
+	t.Run("reset paths to HEAD", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		newFile := filepath.Join(tmpdir, "new.txt")
+		commitFile(t, tmpdir, testFile, "original\n")
+
+		require.NoError(t, os.WriteFile(testFile, []byte("modified\n"), 0644))
+		require.NoError(t, os.WriteFile(newFile, []byte("new\n"), 0644))
+		require.NoError(t, addCmd(t, testFile, newFile))
+
+		_, err := resetCmd(t, "", "test.txt")
+		require.NoError(t, err)
+		require.Equal(t, "original\n", stagedContent(t, tmpdir, testFile))
+		require.Len(t, getIndex(t, tmpdir).Staged, 2)
+
+		_, err = resetCmd(t, "")
+		require.NoError(t, err)
+		require.Len(t, getIndex(t, tmpdir).Staged, 1)
+
+		// the working tree is left alone
+		data, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		require.Equal(t, "modified\n", string(data))
+	})
+
+	t.Run("patch unstages selected hunks", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, numberedLines(20, nil))
+
+		modified := numberedLines(20, map[int]string{2: "changed 2", 15: "changed 15"})
+		require.NoError(t, os.WriteFile(testFile, []byte(modified), 0644))
+		_, err := addPatchCmd(t, "a\n")
+		require.NoError(t, err)
+
+		out, err := resetCmd(t, "n\ny\n", "-p")
+		require.NoError(t, err)
+		require.Contains(t, out, "Unstage this hunk")
+		require.Equal(t, numberedLines(20, map[int]string{2: "changed 2"}), stagedContent(t, tmpdir, testFile))
+	})
+
+	t.Run("patch unstages whole file", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		testFile := filepath.Join(tmpdir, "test.txt")
+		commitFile(t, tmpdir, testFile, "content\n")
+
+		require.NoError(t, os.WriteFile(testFile, []byte("modified\n"), 0644))
+		_, err := addPatchCmd(t, "y\n")
+		require.NoError(t, err)
+		require.Equal(t, "modified\n", stagedContent(t, tmpdir, testFile))
+
+		_, err = resetCmd(t, "y\n", "-p", testFile)
+		require.NoError(t, err)
+		require.Equal(t, "content\n", stagedContent(t, tmpdir, testFile))
+	})
+
+	t.Run("patch unstages new file", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "content\n")
+
+		newFile := filepath.Join(tmpdir, "new.txt")
+		require.NoError(t, os.WriteFile(newFile, []byte("new\n"), 0644))
+		require.NoError(t, addCmd(t, newFile))
+
+		_, err := resetCmd(t, "y\n", "-p", newFile)
+		require.NoError(t, err)
+		require.Len(t, getIndex(t, tmpdir).Staged, 1)
+	})
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"os"
 
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/pkg/trac"
 	"github.com/spf13/cobra"
 )
 
@@ -14,7 +16,34 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(NewInitCmd())
 	rootCmd.AddCommand(NewStatusCmd())
 	rootCmd.AddCommand(NewAddCmd())
+	rootCmd.AddCommand(NewResetCmd())
 	rootCmd.AddCommand(NewCommitCmd())
+	rootCmd.AddCommand(NewBlameCmd())
+	rootCmd.AddCommand(NewCheckoutCmd())
+	rootCmd.AddCommand(NewLogCmd())
+	rootCmd.AddCommand(NewDiffCmd())
+	rootCmd.AddCommand(NewShowCmd())
+	rootCmd.AddCommand(NewBranchCmd())
+	rootCmd.AddCommand(NewTagCmd())
+	rootCmd.AddCommand(NewVerifyCommitCmd())
+	rootCmd.AddCommand(NewVerifyTagCmd())
+	rootCmd.AddCommand(NewBisectCmd())
+	rootCmd.AddCommand(NewFsckCmd())
+	rootCmd.AddCommand(NewPruneCmd())
+	rootCmd.AddCommand(NewGCCmd())
+	rootCmd.AddCommand(NewRepackCmd())
+	rootCmd.AddCommand(NewConfigCmd())
+	rootCmd.AddCommand(NewCloneCmd())
+	rootCmd.AddCommand(NewRemoteCmd())
+	rootCmd.AddCommand(NewFetchCmd())
+	rootCmd.AddCommand(NewPushCmd())
+	rootCmd.AddCommand(NewServeCmd())
+	rootCmd.AddCommand(NewBundleCmd())
+	rootCmd.AddCommand(NewFastImportCmd())
+	rootCmd.AddCommand(NewFastExportCmd())
+	rootCmd.AddCommand(NewFormatPatchCmd())
+	rootCmd.AddCommand(NewApplyCmd())
+	rootCmd.AddCommand(NewAmCmd())
 	return rootCmd
 }
 
@@ -25,3 +54,29 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+// openRepository opens the repository in the current working directory.
+func openRepository() (*trac.Repository, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return trac.Open(cwd)
+}
+
+// repoLayout returns the layout of the repository in the current working directory,
+// for commands that work below the level of the public API.
+func repoLayout() (*layout.Layout, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	l, err := layout.New(cwd)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.ValidateIsRepo(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeCommand(t *testing.T) {
+	src := initRepository(t)
+	require.NoError(t, os.Chdir(src))
+	head := commitFile(t, src, filepath.Join(src, "test.txt"), "content")
+	authFile := filepath.Join(t.TempDir(), "users")
+	require.NoError(t, os.WriteFile(authFile, []byte("# users\nalice:secret\n\nbob:hunter2\n"), 0600))
+
+	repo, err := trac.Open(src)
+	require.NoError(t, err)
+	handler, err := newServeHandler(repo, &serveOptions{authFile: authFile})
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- runServe(ctx, ln, handler) }()
+	url := "http://" + ln.Addr().String() + "/"
+
+	parent := t.TempDir()
+	require.NoError(t, os.Chdir(parent))
+	_, err = cloneCmd(t, url, "anonymous")
+	require.ErrorIs(t, err, trac.ErrAuthRequired)
+
+	t.Setenv("TRAC_HTTP_USER", "bob")
+	t.Setenv("TRAC_HTTP_PASSWORD", "wrong")
+	_, err = cloneCmd(t, url, "wrong")
+	require.ErrorIs(t, err, trac.ErrPermissionDenied)
+
+	t.Setenv("TRAC_HTTP_PASSWORD", "hunter2")
+	out, err := cloneCmd(t, url)
+	require.NoError(t, err)
+	require.Equal(t, "Cloning into '127.0.0.1'...\n", out)
+	dst := filepath.Join(parent, "127.0.0.1")
+	require.Equal(t, head, headHash(t, dst))
+
+	// pushing through the server
+	require.NoError(t, os.Chdir(dst))
+	_, err = checkoutCmd(t, "-b", "topic", "HEAD")
+	require.NoError(t, err)
+	pushed := commitFile(t, dst, filepath.Join(dst, "test.txt"), "changed")
+	out, err = pushCmd(t)
+	require.NoError(t, err)
+	require.Contains(t, out, "To "+url+"\n")
+	require.Equal(t, pushed, refHash(t, src, "refs/heads/topic"))
+
+	// passwords in remote URLs are not printed
+	_, err = remoteCmd(t, "add", "alice", "http://alice:secret@"+ln.Addr().String()+"/")
+	require.NoError(t, err)
+	out, err = fetchCmd(t, "alice")
+	require.NoError(t, err)
+	require.Contains(t, out, "From http://alice:xxxxx@"+ln.Addr().String()+"/\n")
+	require.NotContains(t, out, "secret")
+
+	cancel()
+	require.NoError(t, <-done)
+
+	// without --addr, only this machine can connect
+	require.Equal(t, "localhost:8080", NewServeCmd().Flags().Lookup("addr").DefValue)
+}
+
+func TestCloneDir(t *testing.T) {
+	tests := map[string]string{
+		"../repo":                    "repo",
+		"/path/to/repo/":             "repo",
+		"file:///path/to/repo":       "repo",
+		"http://example.com:8080/":   "example.com",
+		"https://example.com/trac/x": "x",
+		"http://user:pw@example.com": "example.com",
+	}
+	for url, want := range tests {
+		require.Equal(t, want, cloneDir(url), url)
+	}
+}
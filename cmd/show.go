@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type showOptions struct {
+	format formatOptions
+}
+
+func NewShowCmd() *cobra.Command {
+	opts := &showOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show [<rev>]",
+		Short: "Show a commit and the changes it introduced",
+		Long: `
+	Shows the hash, date and message of the given commit, or HEAD, followed by the differences between it and its parent.
+
+	--porcelain prints the commit as a line in the porcelain format of trac log, followed by the changed files in the porcelain format of
+	trac diff. --json prints the commit as a JSON object whose "files" key holds the changed files as in trac diff --json.
+	`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			rev := "HEAD"
+			if len(args) > 0 {
+				rev = args[0]
+			}
+			if err := opts.format.check(porcelainV1); err != nil {
+				return err
+			}
+			return runShow(cmd.OutOrStdout(), repo, rev, opts)
+		},
+	}
+	addFormatFlags(cmd, &opts.format, porcelainV1)
+	return cmd
+}
+
+type showJSON struct {
+	commitJSON
+	Files []fileDiffJSON `json:"files"`
+}
+
+func runShow(w io.Writer, repo *trac.Repository, rev string, opts *showOptions) error {
+	hash, err := repo.Resolve(rev)
+	if err != nil {
+		return err
+	}
+	c, err := repo.ReadCommit(hash)
+	if err != nil {
+		return err
+	}
+	diffs, err := repo.Diff(context.Background(), c.Parent, c.Hash, nil)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.format.json:
+		return writeJSON(w, showJSON{commitJSON: newCommitJSON(c), Files: newFileDiffsJSON(diffs)})
+	case opts.format.porcelain != "":
+		writeCommitRecord(w, &opts.format, c)
+		writeRawDiffs(w, &opts.format, diffs)
+		return nil
+	}
+	printCommit(w, c, "")
+	if len(diffs) > 0 {
+		fmt.Fprintln(w)
+	}
+	printDiffs(w, diffs)
+	return nil
+}
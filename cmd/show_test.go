@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	testFile := filepath.Join(tmpdir, "test.txt")
+	first := commitFile(t, tmpdir, testFile, "one\n")
+	second := commitFile(t, tmpdir, testFile, "two\n")
+
+	t.Run("first commit shows every file as added", func(t *testing.T) {
+		out, err := showCmd(t, first)
+		require.NoError(t, err)
+		require.Contains(t, out, "commit "+first+"\n")
+		require.Contains(t, out, "new file mode 100644\n--- /dev/null\n+++ b/test.txt\n@@ -0,0 +1 @@\n+one\n")
+	})
+
+	t.Run("defaults to HEAD", func(t *testing.T) {
+		out, err := showCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, out, "commit "+second+"\n")
+		require.Contains(t, out, "-one\n+two\n")
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		_, err := showCmd(t, "nope")
+		require.ErrorIs(t, err, commit.ErrUnknownRevision)
+	})
+
+	t.Run("machine-readable output", func(t *testing.T) {
+		out, err := showCmd(t, "--porcelain", first)
+		require.NoError(t, err)
+		lines := strings.Split(out, "\n")
+		require.Len(t, lines, 3)
+		require.True(t, strings.HasPrefix(lines[0], first+" "+strings.Repeat("0", 64)+" "))
+		require.True(t, strings.HasPrefix(lines[1], ":000000 100644 "))
+		require.True(t, strings.HasSuffix(lines[1], " A\ttest.txt"))
+
+		out, err = showCmd(t, "--json")
+		require.NoError(t, err)
+		var shown struct {
+			Hash   string `json:"hash"`
+			Parent string `json:"parent"`
+			Files  []struct {
+				Path   string `json:"path"`
+				Status string `json:"status"`
+			} `json:"files"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &shown))
+		require.Equal(t, second, shown.Hash)
+		require.Equal(t, first, shown.Parent)
+		require.Len(t, shown.Files, 1)
+		require.Equal(t, "modified", shown.Files[0].Status)
+	})
+}
@@ -1,89 +1,269 @@
 package cmd
 
 import (
-	"errors"
+	"cmp"
+	"context"
 	"fmt"
 	"io"
-	"io/fs"
-	"os"
-	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/lucasrod16/trac/internal/index"
-	"github.com/lucasrod16/trac/internal/layout"
-	"github.com/lucasrod16/trac/internal/status"
+	"github.com/lucasrod16/trac/pkg/trac"
 	"github.com/spf13/cobra"
 )
 
+type statusOptions struct {
+	format formatOptions
+}
+
 func NewStatusCmd() *cobra.Command {
-	return &cobra.Command{
+	opts := &statusOptions{}
+
+	cmd := &cobra.Command{
 		Use:   "status [repoPath]",
 		Short: "Show the status of the trac repository",
-		Long:  "Display the current status of the trac repository, listing untracked files if any.",
-		Args:  cobra.ExactArgs(0),
+		Long: `
+	Displays the changes staged for the next commit (differences between HEAD and the index), changes in the working tree that have not been
+	staged (differences between the index and the working tree), and files that are not tracked.
+
+	Staged files that were deleted and added again under another name with similar content are shown as renamed. The similarity needed is
+	set by diff.renameThreshold; see trac config.
+
+	--porcelain=v1 prints one line per path, "XY <path>", where X is the staged and Y the unstaged change (A, M, D, R, or a space for none),
+	renamed files as "XY <old path> -> <path>", and untracked files as "?? <path>". --porcelain=v2 starts with "# branch.oid <hash>" and
+	"# branch.head <branch>" headers, then prints "1 XY N... <mode HEAD> <mode index> <mode worktree> <hash HEAD> <hash index> <path>" per
+	changed path, "2 XY N... <mode HEAD> <mode index> <mode worktree> <hash HEAD> <hash index> R<similarity> <path>\t<old path>" per
+	renamed path, and "? <path>" per untracked file. With -z, the old path of a renamed file is a separate NUL-terminated field following its
+	path. --json prints a single JSON object. The porcelain and JSON formats are stable across releases.
+	`,
+		Args: cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cwd, err := os.Getwd()
-			if err != nil {
-				return err
-			}
-			l, err := layout.New(cwd)
+			repo, err := openRepository()
 			if err != nil {
 				return err
 			}
-			if err := l.ValidateIsRepo(); err != nil {
+			if err := opts.format.check(porcelainV1, porcelainV2); err != nil {
 				return err
 			}
-			return showRepoStatus(cmd.OutOrStdout(), l)
+			return showRepoStatus(cmd.OutOrStdout(), repo, opts)
 		},
 	}
+	addFormatFlags(cmd, &opts.format, porcelainV1, porcelainV2)
+	return cmd
 }
 
 // showRepoStatus outputs the current status of the repository.
-func showRepoStatus(w io.Writer, l *layout.Layout) error {
-	idx := index.New()
-	err := idx.Load(l)
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return err
-	}
-	repoStatus, err := status.Get(idx)
+func showRepoStatus(w io.Writer, repo *trac.Repository, opts *statusOptions) error {
+	st, err := repo.Status(context.Background())
 	if err != nil {
 		return err
 	}
-	if !repoStatus.HasTracked() && !repoStatus.HasUntracked() {
-		fmt.Fprintln(w, "nothing to commit (create/copy files and use \"trac add\" to track)")
+	switch {
+	case opts.format.json:
+		return writeStatusJSON(w, st)
+	case opts.format.porcelain != "":
+		writeStatusPorcelain(w, &opts.format, st)
 		return nil
 	}
-	if repoStatus.HasUntracked() {
+	if st.Clean() {
+		if st.Head == "" {
+			fmt.Fprintln(w, "nothing to commit (create/copy files and use \"trac add\" to track)")
+		} else {
+			fmt.Fprintln(w, "nothing to commit, working tree clean")
+		}
+		return nil
+	}
+	sections := 0
+	section := func(title string) {
+		if sections > 0 {
+			fmt.Fprintln(w)
+		}
+		sections++
+		fmt.Fprintln(w, title)
+	}
+	if len(st.Staged) > 0 {
+		section("Changes to be committed:")
+		printChanges(w, st.Staged, color.New(color.FgHiGreen))
+	}
+	if len(st.Unstaged) > 0 {
+		section("Changes not staged for commit:")
+		printChanges(w, st.Unstaged, color.New(color.FgHiRed))
+	}
+	if len(st.Untracked) > 0 {
 		var untracked []string
-		for _, path := range repoStatus.Untracked() {
+		for _, path := range st.Untracked {
 			path = preparePath(path)
 			if !slices.Contains(untracked, path) {
 				untracked = append(untracked, path)
 			}
 		}
-		fmt.Fprintln(w, "Untracked files:")
+		section("Untracked files:")
 		untrackedColor := color.New(color.FgHiRed)
 		for _, path := range untracked {
 			untrackedColor.Fprintf(w, "\t%s\n", path)
 		}
 	}
-	if repoStatus.HasTracked() {
-		fmt.Fprintln(w, "\nChanges to be committed:")
-		for _, filepath := range repoStatus.Tracked() {
-			trackedColor := color.New(color.FgHiGreen)
-			trackedColor.Fprintf(w, "\tnew file:   %s\n", filepath)
-		}
-	}
 	return nil
 }
 
+func printChanges(w io.Writer, changes []trac.Change, c *color.Color) {
+	for _, change := range changes {
+		c.Fprintf(w, "\t%-12s%s\n", change.Kind.String()+":", changePath(change))
+	}
+}
+
+// preparePath collapses an untracked path to its top-level directory.
 func preparePath(path string) string {
-	parts := strings.Split(path, string(filepath.Separator))
-	root := parts[0]
-	if len(parts) > 1 {
-		root += string(filepath.Separator)
+	root, _, nested := strings.Cut(path, "/")
+	if nested {
+		root += "/"
 	}
 	return root
 }
+
+// statusEntry combines the staged and unstaged change of a path.
+type statusEntry struct {
+	path     string
+	staged   *trac.Change
+	unstaged *trac.Change
+}
+
+// statusEntries returns the paths with staged or unstaged changes, sorted by path.
+func statusEntries(st *trac.Status) []statusEntry {
+	byPath := make(map[string]*statusEntry)
+	var entries []*statusEntry
+	entry := func(path string) *statusEntry {
+		e, ok := byPath[path]
+		if !ok {
+			e = &statusEntry{path: path}
+			byPath[path] = e
+			entries = append(entries, e)
+		}
+		return e
+	}
+	for i := range st.Staged {
+		entry(st.Staged[i].Path).staged = &st.Staged[i]
+	}
+	for i := range st.Unstaged {
+		entry(st.Unstaged[i].Path).unstaged = &st.Unstaged[i]
+	}
+	slices.SortFunc(entries, func(a, b *statusEntry) int { return strings.Compare(a.path, b.path) })
+	result := make([]statusEntry, len(entries))
+	for i, e := range entries {
+		result[i] = *e
+	}
+	return result
+}
+
+// xy returns the two-letter status code of an entry.
+func (e statusEntry) xy() string {
+	code := []byte("  ")
+	if e.staged != nil {
+		code[0] = byte(e.staged.Kind)
+	}
+	if e.unstaged != nil {
+		code[1] = byte(e.unstaged.Kind)
+	}
+	return string(code)
+}
+
+// hashes returns the content hash of an entry at HEAD, in the index and in the working tree.
+// Empty hashes mean the file does not exist there.
+func (e statusEntry) hashes() (head, index, workTree string) {
+	if e.staged != nil {
+		head, index = e.staged.OldHash, e.staged.NewHash
+	} else {
+		head, index = e.unstaged.OldHash, e.unstaged.OldHash
+	}
+	workTree = index
+	if e.unstaged != nil {
+		workTree = e.unstaged.NewHash
+	}
+	return head, index, workTree
+}
+
+// modes returns the mode of an entry at HEAD, in the index and in the working
+// tree, like hashes.
+func (e statusEntry) modes() (head, index, workTree string) {
+	if e.staged != nil {
+		head, index = e.staged.OldMode, e.staged.NewMode
+	} else {
+		head, index = e.unstaged.OldMode, e.unstaged.OldMode
+	}
+	workTree = index
+	if e.unstaged != nil {
+		workTree = e.unstaged.NewMode
+	}
+	return head, index, workTree
+}
+
+func writeStatusPorcelain(w io.Writer, opts *formatOptions, st *trac.Status) {
+	if opts.porcelain == porcelainV2 {
+		opts.record(w, "# branch.oid %s", cmp.Or(st.Head, "(initial)"))
+		opts.record(w, "# branch.head %s", cmp.Or(shortBranch(st.Branch), "(detached)"))
+	}
+	for _, e := range statusEntries(st) {
+		var oldPath string
+		var similarity int
+		if e.staged != nil {
+			oldPath, similarity = e.staged.OldPath, e.staged.Similarity
+		}
+		if opts.porcelain == porcelainV1 {
+			switch {
+			case oldPath == "":
+				opts.record(w, "%s %s", e.xy(), opts.path(e.path))
+			case opts.nul:
+				opts.record(w, "%s %s", e.xy(), e.path)
+				opts.record(w, "%s", oldPath)
+			default:
+				opts.record(w, "%s %s -> %s", e.xy(), opts.path(oldPath), opts.path(e.path))
+			}
+			continue
+		}
+		head, index, _ := e.hashes()
+		headMode, indexMode, workTreeMode := e.modes()
+		fields := fmt.Sprintf("%s N... %s %s %s %s %s", e.xy(), orNone(headMode), orNone(indexMode), orNone(workTreeMode), orZero(head), orZero(index))
+		switch {
+		case oldPath == "":
+			opts.record(w, "1 %s %s", fields, opts.path(e.path))
+		case opts.nul:
+			opts.record(w, "2 %s %s %s", fields, statusCode(e.staged.Kind, similarity)[:1]+fmt.Sprint(similarity), e.path)
+			opts.record(w, "%s", oldPath)
+		default:
+			opts.record(w, "2 %s %s %s\t%s", fields, statusCode(e.staged.Kind, similarity)[:1]+fmt.Sprint(similarity), opts.path(e.path), opts.path(oldPath))
+		}
+	}
+	untracked := "??"
+	if opts.porcelain == porcelainV2 {
+		untracked = "?"
+	}
+	for _, path := range st.Untracked {
+		opts.record(w, "%s %s", untracked, opts.path(path))
+	}
+}
+
+type statusJSON struct {
+	Branch    string       `json:"branch"` // Empty when HEAD is detached
+	Head      string       `json:"head"`   // Empty before the first commit
+	Staged    []changeJSON `json:"staged"`
+	Unstaged  []changeJSON `json:"unstaged"`
+	Untracked []string     `json:"untracked"`
+}
+
+func writeStatusJSON(w io.Writer, st *trac.Status) error {
+	out := statusJSON{
+		Branch:    shortBranch(st.Branch),
+		Head:      st.Head,
+		Staged:    make([]changeJSON, 0, len(st.Staged)),
+		Unstaged:  make([]changeJSON, 0, len(st.Unstaged)),
+		Untracked: append([]string{}, st.Untracked...),
+	}
+	for _, c := range st.Staged {
+		out.Staged = append(out.Staged, newChangeJSON(c))
+	}
+	for _, c := range st.Unstaged {
+		out.Unstaged = append(out.Unstaged, newChangeJSON(c))
+	}
+	return writeJSON(w, out)
+}
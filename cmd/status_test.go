@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/utils"
 	"github.com/stretchr/testify/require"
 )
 
@@ -52,4 +54,174 @@ func TestStatusCommand(t *testing.T) {
 		require.Contains(t, out, "Changes to be committed:")
 		require.Contains(t, out, "new file:   test.txt")
 	})
+
+	t.Run("clean working tree", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		commitFile(t, tmpdir, filepath.Join(tmpdir, "test.txt"), "content")
+
+		out, err := statusCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "nothing to commit, working tree clean\n", out)
+	})
+
+	t.Run("changes not staged for commit", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		modified := filepath.Join(tmpdir, "modified.txt")
+		deleted := filepath.Join(tmpdir, "deleted.txt")
+		commitFile(t, tmpdir, modified, "content")
+		commitFile(t, tmpdir, deleted, "content")
+		require.NoError(t, os.WriteFile(modified, []byte("changed"), 0644))
+		require.NoError(t, os.Remove(deleted))
+
+		out, err := statusCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "Changes not staged for commit:\n\tdeleted:    deleted.txt\n\tmodified:   modified.txt\n", out)
+	})
+
+	t.Run("machine-readable output", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		modified := filepath.Join(tmpdir, "modified.txt")
+		first := commitFile(t, tmpdir, modified, "one")
+		blobOne := stagedHash(t, tmpdir, modified)
+		require.NoError(t, os.WriteFile(modified, []byte("two"), 0644))
+		require.NoError(t, addCmd(t, modified))
+		blobTwo := stagedHash(t, tmpdir, modified)
+		require.NoError(t, os.WriteFile(modified, []byte("three"), 0644))
+		blobThree, err := utils.HashFile(modified)
+		require.NoError(t, err)
+		require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "dir"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "dir", "new\tfile"), []byte("new"), 0644))
+
+		out, err := statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "MM modified.txt\n?? \"dir/new\\tfile\"\n", out)
+
+		out, err = statusCmd(t, "-z")
+		require.NoError(t, err)
+		require.Equal(t, "MM modified.txt\x00?? dir/new\tfile\x00", out)
+
+		out, err = statusCmd(t, "--porcelain=v2")
+		require.NoError(t, err)
+		require.Equal(t, "# branch.oid "+first+"\n# branch.head main\n"+
+			"1 MM N... 100644 100644 100644 "+blobOne+" "+blobTwo+" modified.txt\n"+
+			"? \"dir/new\\tfile\"\n", out)
+
+		out, err = statusCmd(t, "--json")
+		require.NoError(t, err)
+		var st struct {
+			Branch    string
+			Head      string
+			Staged    []map[string]string
+			Unstaged  []map[string]string
+			Untracked []string
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &st))
+		require.Equal(t, "main", st.Branch)
+		require.Equal(t, first, st.Head)
+		require.Equal(t, []map[string]string{{"path": "modified.txt", "status": "modified", "oldHash": blobOne, "newHash": blobTwo, "oldMode": "100644", "newMode": "100644"}}, st.Staged)
+		require.Equal(t, []map[string]string{{"path": "modified.txt", "status": "modified", "oldHash": blobTwo, "newHash": blobThree, "oldMode": "100644", "newMode": "100644"}}, st.Unstaged)
+		require.Equal(t, []string{"dir/new\tfile"}, st.Untracked)
+
+		_, err = statusCmd(t, "--porcelain=v3")
+		require.ErrorContains(t, err, "unsupported porcelain version")
+		_, err = statusCmd(t, "--json", "-z")
+		require.Error(t, err)
+	})
+
+	t.Run("file modes", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		script := filepath.Join(tmpdir, "run.sh")
+		link := filepath.Join(tmpdir, "link")
+		first := commitFile(t, tmpdir, script, "#!/bin/sh\n")
+		blob := utils.HashBytes([]byte("#!/bin/sh\n"))
+
+		require.NoError(t, os.Chmod(script, 0755))
+		out, err := statusCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "Changes not staged for commit:\n\tmodified:   run.sh\n", out)
+		out, err = statusCmd(t, "--porcelain=v2")
+		require.NoError(t, err)
+		require.Contains(t, out, "1  M N... 100644 100644 100755 "+blob+" "+blob+" run.sh\n")
+		out, err = diffCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "diff --trac a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n", out)
+
+		// Symbolic links are stored as their target, not followed.
+		require.NoError(t, os.Symlink("run.sh", link))
+		require.NoError(t, addCmd(t, script, link))
+		out, err = statusCmd(t, "--porcelain=v2")
+		require.NoError(t, err)
+		require.Contains(t, out, "1 A  N... 000000 120000 120000 "+zeroHash+" "+utils.HashBytes([]byte("run.sh"))+" link\n")
+		require.Contains(t, out, "1 M  N... 100644 100755 100755 "+blob+" "+blob+" run.sh\n")
+		require.NoError(t, commitCmd(t, "-m", "make run.sh executable"))
+		out, err = diffCmd(t, first, "HEAD", "--porcelain")
+		require.NoError(t, err)
+		require.Contains(t, out, ":100644 100755 "+blob+" "+blob+" M\trun.sh\n")
+
+		// With core.fileMode false, the executable bit of files is ignored.
+		_, err = configCmd(t, "core.fileMode", "false")
+		require.NoError(t, err)
+		require.NoError(t, os.Chmod(script, 0644))
+		out, err = statusCmd(t)
+		require.NoError(t, err)
+		require.Contains(t, out, "nothing to commit, working tree clean")
+		require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0644))
+		require.NoError(t, addCmd(t, script))
+		out, err = statusCmd(t, "--porcelain=v2")
+		require.NoError(t, err)
+		require.Contains(t, out, "1 M  N... 100755 100755 100755 ")
+	})
+
+	t.Run("renames", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+		old := filepath.Join(tmpdir, "old.txt")
+		renamed := filepath.Join(tmpdir, "new.txt")
+		content := "one\ntwo\nthree\nfour\n"
+		commitFile(t, tmpdir, old, content)
+		blob := utils.HashBytes([]byte(content))
+
+		require.NoError(t, os.Rename(old, renamed))
+		require.NoError(t, addCmd(t, old, renamed))
+		out, err := statusCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "Changes to be committed:\n\trenamed:    old.txt -> new.txt\n", out)
+		out, err = statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "R  old.txt -> new.txt\n", out)
+		out, err = statusCmd(t, "--porcelain=v2")
+		require.NoError(t, err)
+		require.Contains(t, out, "2 R  N... 100644 100644 100644 "+blob+" "+blob+" R100 new.txt\told.txt\n")
+		out, err = statusCmd(t, "--porcelain=v2", "-z")
+		require.NoError(t, err)
+		require.Contains(t, out, " R100 new.txt\x00old.txt\x00")
+
+		// A file that changed as it moved is paired by how alike it is.
+		require.NoError(t, os.WriteFile(renamed, []byte("one\ntwo\nthree\nfive\n"), 0644))
+		require.NoError(t, addCmd(t, renamed))
+		out, err = statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "R  old.txt -> new.txt\n", out)
+		_, err = configCmd(t, "diff.renameThreshold", "90%")
+		require.NoError(t, err)
+		out, err = statusCmd(t, "--porcelain")
+		require.NoError(t, err)
+		require.Equal(t, "A  new.txt\nD  old.txt\n", out)
+	})
+
+	t.Run("machine-readable output of an empty repository", func(t *testing.T) {
+		tmpdir := initRepository(t)
+		require.NoError(t, os.Chdir(tmpdir))
+
+		out, err := statusCmd(t, "--porcelain=v2")
+		require.NoError(t, err)
+		require.Equal(t, "# branch.oid (initial)\n# branch.head main\n", out)
+		out, err = statusCmd(t, "--json")
+		require.NoError(t, err)
+		require.Equal(t, `{"branch":"main","head":"","staged":[],"unstaged":[],"untracked":[]}`+"\n", out)
+	})
 }
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+type tagOptions struct {
+	delete   bool     // -d, --delete
+	annotate bool     // -a, --annotate
+	sign     bool     // -s, --sign
+	messages []string // -m, --message
+	verify   bool     // -v, --verify
+}
+
+func NewTagCmd() *cobra.Command {
+	opts := &tagOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "tag [-d | -v] [-a | -s] [-m <msg>]... [<name> [<rev>]]",
+		Short: "List, create, delete or verify tags",
+		Long: `
+	Without arguments, lists the tags. Given a name, creates a tag pointing at the revision, or HEAD. With --delete, the named tags are deleted.
+
+	With -a, the tag is annotated: it points at a tag object recording the commit, the message given with -m, once per paragraph, and
+	when it was made. -s makes an annotated tag signed with the key set by signing.key, and --verify checks the signatures of the named
+	tags like trac verify-tag. Annotated tags stand for their commit wherever a revision is expected.
+	`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.delete || opts.verify {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.MaximumNArgs(2)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runTag(cmd.OutOrStdout(), repo, args, opts)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.delete, "delete", "d", false, "Delete the named tags")
+	cmd.Flags().BoolVarP(&opts.annotate, "annotate", "a", false, "Make an annotated tag")
+	cmd.Flags().BoolVarP(&opts.sign, "sign", "s", false, "Make a signed annotated tag")
+	cmd.Flags().StringArrayVarP(&opts.messages, "message", "m", nil, "Message of an annotated tag; given more than once, each is a paragraph")
+	cmd.Flags().BoolVarP(&opts.verify, "verify", "v", false, "Verify the signatures of the named tags")
+	cmd.MarkFlagsMutuallyExclusive("delete", "verify")
+	return cmd
+}
+
+func runTag(w io.Writer, repo *trac.Repository, args []string, opts *tagOptions) error {
+	switch {
+	case opts.delete:
+		for _, name := range args {
+			if err := repo.DeleteTag(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Deleted tag %s\n", name)
+		}
+		return nil
+	case opts.verify:
+		return runVerifyTag(w, repo, args)
+	case len(args) > 0:
+		rev := "HEAD"
+		if len(args) > 1 {
+			rev = args[1]
+		}
+		if !opts.annotate && !opts.sign && len(opts.messages) == 0 {
+			return repo.CreateTag(args[0], rev)
+		}
+		message := strings.TrimSpace(strings.Join(opts.messages, "\n\n"))
+		if message == "" {
+			return errors.New("an annotated tag needs a message; give one with -m")
+		}
+		return repo.CreateAnnotatedTag(args[0], rev, &trac.TagOptions{Message: message, Sign: opts.sign})
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		fmt.Fprintln(w, t.Short())
+	}
+	return nil
+}
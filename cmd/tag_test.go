@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCommand(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	testFile := filepath.Join(tmpdir, "test.txt")
+	first := commitFile(t, tmpdir, testFile, "first")
+	commitFile(t, tmpdir, testFile, "second")
+
+	_, err := tagCmd(t, "v1.0", first)
+	require.NoError(t, err)
+	_, err = tagCmd(t, "v2.0")
+	require.NoError(t, err)
+	_, err = tagCmd(t, "v1.0")
+	require.ErrorIs(t, err, refs.ErrExists)
+
+	out, err := tagCmd(t)
+	require.NoError(t, err)
+	require.Equal(t, "v1.0\nv2.0\n", out)
+
+	// tags can be used as revisions, and checking one out detaches HEAD
+	out, err = checkoutCmd(t, "v1.0")
+	require.NoError(t, err)
+	require.Equal(t, "HEAD is now at "+first[:8]+" update test.txt\n", out)
+
+	out, err = tagCmd(t, "-d", "v1.0")
+	require.NoError(t, err)
+	require.Equal(t, "Deleted tag v1.0\n", out)
+	_, err = tagCmd(t, "-d", "v1.0")
+	require.ErrorIs(t, err, refs.ErrNotFound)
+}
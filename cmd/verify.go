@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/spf13/cobra"
+)
+
+func NewVerifyCommitCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify-commit <rev>...",
+		Short: "Check the signatures of commits",
+		Long: `
+	Checks the signature of each commit against the allowed signers file set by signing.allowedSigners, a file of lines of the form
+	"<principal> ssh-ed25519 <key>" as used by ssh-keygen. A good signature by an allowed signer is reported with the signer's
+	principal and the fingerprint of their key. A commit that is not signed, whose signature does not match its content, or that
+	was signed by a key that is not an allowed signer fails the check.
+	`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runVerifyCommit(cmd.OutOrStdout(), repo, args)
+		},
+	}
+}
+
+func NewVerifyTagCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify-tag <tag>...",
+		Short: "Check the signatures of tags",
+		Long: `
+	Checks the signature of each annotated tag like trac verify-commit. Lightweight tags have no signature and fail the check.
+	`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := openRepository()
+			if err != nil {
+				return err
+			}
+			return runVerifyTag(cmd.OutOrStdout(), repo, args)
+		},
+	}
+}
+
+func runVerifyCommit(w io.Writer, repo *trac.Repository, revs []string) error {
+	for _, rev := range revs {
+		v, err := repo.VerifyCommit(rev)
+		if err := printVerification(w, v, err); err != nil {
+			return fmt.Errorf("%s: %w", rev, err)
+		}
+	}
+	return nil
+}
+
+func runVerifyTag(w io.Writer, repo *trac.Repository, names []string) error {
+	for _, name := range names {
+		v, err := repo.VerifyTag(name)
+		if err := printVerification(w, v, err); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// printVerification reports a good signature, whether or not its key is an
+// allowed signer, and passes on err.
+func printVerification(w io.Writer, v *trac.Verification, err error) error {
+	switch {
+	case err == nil:
+		fmt.Fprintf(w, "Good signature from %s with key %s\n", v.Principal, v.Fingerprint)
+	case errors.Is(err, trac.ErrUnknownSigner):
+		fmt.Fprintf(w, "Good signature with key %s, which is not an allowed signer\n", v.Fingerprint)
+	}
+	return err
+}
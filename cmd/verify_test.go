@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigning(t *testing.T) {
+	tmpdir := initRepository(t)
+	require.NoError(t, os.Chdir(tmpdir))
+	file := filepath.Join(tmpdir, "file.txt")
+	keys := t.TempDir()
+	aliceKey, alice := writeSigningKey(t, keys, "alice@example.com")
+	malloryKey, _ := writeSigningKey(t, keys, "mallory@example.com")
+	signers := filepath.Join(keys, "allowed_signers")
+	require.NoError(t, os.WriteFile(signers, []byte("# release signers\n"+alice), 0644))
+	stage := func(t *testing.T, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+		require.NoError(t, addCmd(t, file))
+	}
+	setConfig := func(t *testing.T, key, value string) {
+		t.Helper()
+		_, err := configCmd(t, key, value)
+		require.NoError(t, err)
+	}
+
+	stage(t, "one\n")
+	require.ErrorIs(t, commitCmd(t, "-S", "-m", "no key"), trac.ErrNoSigningKey)
+	setConfig(t, "signing.key", aliceKey)
+	require.NoError(t, commitCmd(t, "-S", "-m", "signed"))
+	signed := headHash(t, tmpdir)
+
+	t.Run("verifies signed commits", func(t *testing.T) {
+		_, err := verifyCommitCmd(t, "HEAD")
+		require.ErrorIs(t, err, trac.ErrNoAllowedSigners)
+		setConfig(t, "signing.allowedSigners", signers)
+
+		out, err := verifyCommitCmd(t, "HEAD")
+		require.NoError(t, err)
+		require.Regexp(t, `^Good signature from alice@example.com with key SHA256:\S+\n$`, out)
+	})
+
+	t.Run("rejects unsigned commits and unknown keys", func(t *testing.T) {
+		stage(t, "two\n")
+		require.NoError(t, commitCmd(t, "-m", "unsigned"))
+		_, err := verifyCommitCmd(t, "HEAD")
+		require.ErrorIs(t, err, trac.ErrUnsigned)
+
+		setConfig(t, "signing.key", malloryKey)
+		stage(t, "three\n")
+		require.NoError(t, commitCmd(t, "-S", "-m", "by mallory"))
+		out, err := verifyCommitCmd(t, "HEAD")
+		require.ErrorIs(t, err, trac.ErrUnknownSigner)
+		require.Contains(t, out, "which is not an allowed signer")
+		setConfig(t, "signing.key", aliceKey)
+	})
+
+	t.Run("detects tampering", func(t *testing.T) {
+		l, err := layout.New(tmpdir)
+		require.NoError(t, err)
+		c, err := commit.Load(signed, l)
+		require.NoError(t, err)
+		c.Message = "forged"
+		forged, err := c.Write(l)
+		require.NoError(t, err)
+		_, err = verifyCommitCmd(t, forged)
+		require.ErrorIs(t, err, trac.ErrBadSignature)
+	})
+
+	t.Run("shows signatures in the log", func(t *testing.T) {
+		out, err := logCmd(t, "--oneline", "--show-signature")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 5)
+		require.Contains(t, lines[0], "which is not an allowed signer")
+		require.Contains(t, lines[1], "by mallory")
+		require.Contains(t, lines[2], "unsigned")
+		require.Contains(t, lines[3], "Good signature from alice@example.com")
+		require.Contains(t, lines[4], "signed")
+
+		out, err = logCmd(t, "--show-signature", signed)
+		require.NoError(t, err)
+		require.Regexp(t, `^commit `+signed+`\nGood signature from alice@example.com with key SHA256:\S+\nDate:`, out)
+	})
+
+	t.Run("signs and verifies tags", func(t *testing.T) {
+		_, err := tagCmd(t, "-a", "v0.9")
+		require.ErrorContains(t, err, "needs a message")
+		_, err = tagCmd(t, "-s", "-m", "Release 1.0", "v1.0", signed)
+		require.NoError(t, err)
+		out, err := verifyTagCmd(t, "v1.0")
+		require.NoError(t, err)
+		require.Contains(t, out, "Good signature from alice@example.com")
+		out, err = tagCmd(t, "-v", "v1.0")
+		require.NoError(t, err)
+		require.Contains(t, out, "Good signature from alice@example.com")
+
+		_, err = tagCmd(t, "-a", "-m", "Annotated", "v1.1")
+		require.NoError(t, err)
+		_, err = verifyTagCmd(t, "v1.1")
+		require.ErrorIs(t, err, trac.ErrUnsigned)
+		_, err = tagCmd(t, "v1.2")
+		require.NoError(t, err)
+		_, err = verifyTagCmd(t, "v1.2")
+		require.ErrorIs(t, err, trac.ErrUnsigned)
+
+		// annotated tags stand for their commit
+		out, err = logCmd(t, "--oneline", "v1.0")
+		require.NoError(t, err)
+		require.Equal(t, 1, strings.Count(out, "\n"))
+		out, err = tagCmd(t)
+		require.NoError(t, err)
+		require.Equal(t, "v1.0\nv1.1\nv1.2\n", out)
+		out, err = fsckCmd(t)
+		require.NoError(t, err, out)
+		_, err = gcCmd(t)
+		require.NoError(t, err)
+		_, err = verifyTagCmd(t, "v1.0")
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,203 @@
+package bisect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"os"
+	"slices"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Terms used to mark commits during a bisect session.
+const (
+	Good = "good"
+	Bad  = "bad"
+	Skip = "skip"
+)
+
+// State is the persisted state of a bisect session.
+type State struct {
+	Start    string   `json:"start"`              // Commit HEAD pointed at when the session started
+	StartRef string   `json:"startRef,omitempty"` // Branch HEAD was on when the session started, if any
+	Bad      string   `json:"bad"`
+	Good     []string `json:"good"`
+	Skip     []string `json:"skip"`
+}
+
+// Step describes what a bisect session should do next.
+type Step struct {
+	Next       string   // Commit to test next, empty when the session is finished or waiting for marks
+	Remaining  int      // Number of revisions left to test after Next
+	FirstBad   string   // First bad commit, set once it has been found
+	Candidates []string // Commits that may be the first bad commit when only skipped commits are left
+}
+
+// Steps returns the rough number of steps left to test the remaining revisions.
+func (s *Step) Steps() int {
+	return bits.Len(uint(s.Remaining))
+}
+
+// Start begins a new bisect session from the commit HEAD currently points at.
+// Starting while a session is already in progress keeps the original starting commit.
+func Start(l *layout.Layout) (*State, error) {
+	branch, head, err := refs.ReadHead(l)
+	if err != nil {
+		return nil, err
+	}
+	if head == "" {
+		return nil, commit.ErrNoCommits
+	}
+	state := &State{Start: head, StartRef: branch}
+	if existing, err := Load(l); err == nil {
+		state.Start, state.StartRef = existing.Start, existing.StartRef
+	} else if !errors.Is(err, ErrNotBisecting) {
+		return nil, err
+	}
+	if err := os.WriteFile(l.BisectLog, []byte("trac bisect start\n"), 0644); err != nil {
+		return nil, err
+	}
+	if err := state.Save(l); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Load reads the state of the bisect session in progress.
+func Load(l *layout.Layout) (*State, error) {
+	data, err := os.ReadFile(l.Bisect)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotBisecting
+		}
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Save writes the bisect state to the repository.
+func (s *State) Save(l *layout.Layout) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.Bisect, data, 0644)
+}
+
+// Clear removes all bisect state from the repository.
+func Clear(l *layout.Layout) error {
+	for _, path := range []string{l.Bisect, l.BisectLog} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mark records the given term for a commit and appends it to the bisect log.
+func (s *State) Mark(l *layout.Layout, term, commitHash string) error {
+	c, err := commit.Load(commitHash, l)
+	if err != nil {
+		return err
+	}
+	switch term {
+	case Good:
+		if !slices.Contains(s.Good, commitHash) {
+			s.Good = append(s.Good, commitHash)
+		}
+	case Bad:
+		s.Bad = commitHash
+	case Skip:
+		if !slices.Contains(s.Skip, commitHash) {
+			s.Skip = append(s.Skip, commitHash)
+		}
+	default:
+		return fmt.Errorf("unknown bisect term %q", term)
+	}
+	entry := fmt.Sprintf("# %s: [%s] %s\ntrac bisect %s %s\n", term, commitHash, c.Summary(), term, commitHash)
+	f, err := os.OpenFile(l.BisectLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entry); err != nil {
+		return err
+	}
+	return s.Save(l)
+}
+
+// Next determines the next commit to test, or the first bad commit once it has been found.
+//
+// History is linear, so the revisions left to test are the commits between the
+// most recent good ancestor of the bad commit (exclusive) and the bad commit.
+func (s *State) Next(l *layout.Layout) (*Step, error) {
+	if s.Bad == "" || len(s.Good) == 0 {
+		return &Step{}, nil
+	}
+	// Walk from the bad commit towards the root until a good commit is found.
+	var candidates []string
+	for hash := s.Bad; ; {
+		if slices.Contains(s.Good, hash) {
+			break
+		}
+		candidates = append(candidates, hash)
+		c, err := commit.Load(hash, l)
+		if err != nil {
+			return nil, err
+		}
+		if c.Parent == "" {
+			return nil, ErrNotAncestor
+		}
+		hash = c.Parent
+	}
+	// Order the range from oldest to newest. The bad commit is last.
+	slices.Reverse(candidates)
+
+	var testable []int
+	for i, hash := range candidates[:len(candidates)-1] {
+		if !slices.Contains(s.Skip, hash) {
+			testable = append(testable, i)
+		}
+	}
+	if len(testable) == 0 {
+		if len(candidates) == 1 {
+			return &Step{FirstBad: s.Bad}, nil
+		}
+		return &Step{Candidates: candidates}, nil
+	}
+
+	// Pick the testable commit closest to the middle of the range.
+	mid := (len(candidates) - 1) / 2
+	best := testable[0]
+	for _, i := range testable {
+		if abs(i-mid) < abs(best-mid) {
+			best = i
+		}
+	}
+	return &Step{Next: candidates[best], Remaining: len(testable) / 2}, nil
+}
+
+// ReadLog returns the contents of the bisect log.
+func ReadLog(l *layout.Layout) ([]byte, error) {
+	data, err := os.ReadFile(l.BisectLog)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotBisecting
+	}
+	return data, err
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
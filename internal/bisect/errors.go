@@ -0,0 +1,8 @@
+package bisect
+
+import "errors"
+
+var (
+	ErrNotBisecting = errors.New(`not bisecting (use "trac bisect start" to begin)`)
+	ErrNotAncestor  = errors.New("good commit is not an ancestor of the bad commit")
+)
@@ -0,0 +1,165 @@
+package blame
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// Line is a single line of a blamed file along with the commit that last changed it.
+type Line struct {
+	Hash      string         // Hash of the commit that introduced the line
+	Commit    *commit.Commit // Commit that introduced the line
+	Boundary  bool           // Whether the commit has no parent
+	OrigLine  int            // Line number in the introducing commit (1-based)
+	FinalLine int            // Line number in the blamed revision (1-based)
+	Text      string         // Line content in the blamed revision
+}
+
+// Options controls how a file is blamed.
+type Options struct {
+	Start            int  // First line to blame (1-based), zero for the first line of the file
+	End              int  // Last line to blame (1-based, inclusive), zero for the last line of the file
+	IgnoreWhitespace bool // Look through revisions that only changed whitespace
+}
+
+// pending is a line whose origin has not been found yet.
+type pending struct {
+	final   int // Index of the line in the blamed revision
+	current int // Index of the line in the revision currently being examined
+}
+
+// File attributes each line of path at revision rev to the commit that last changed it.
+func File(l *layout.Layout, path, rev string, opts Options) ([]Line, error) {
+	hash, err := commit.Resolve(rev, l)
+	if err != nil {
+		return nil, err
+	}
+	c, err := commit.Load(hash, l)
+	if err != nil {
+		return nil, err
+	}
+	blobHash, err := c.Lookup(path, l)
+	if err != nil {
+		return nil, fmt.Errorf("no such path %s in %s: %w", path, rev, err)
+	}
+	lines, err := readLines(l, blobHash)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := lineRange(opts, len(lines))
+	if err != nil {
+		return nil, err
+	}
+
+	eq := diff.Exact
+	if opts.IgnoreWhitespace {
+		eq = diff.IgnoreWhitespace
+	}
+
+	result := make([]Line, len(lines))
+	var todo []pending
+	for i := start; i < end; i++ {
+		todo = append(todo, pending{final: i, current: i})
+	}
+	current := lines
+	for len(todo) > 0 {
+		parent, parentBlob, err := parentVersion(l, c, path)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			for _, p := range todo {
+				result[p.final] = Line{
+					Hash:      hash,
+					Commit:    c,
+					Boundary:  c.Parent == "",
+					OrigLine:  p.current + 1,
+					FinalLine: p.final + 1,
+					Text:      lines[p.final],
+				}
+			}
+			break
+		}
+		if parentBlob != blobHash {
+			parentLines, err := readLines(l, parentBlob)
+			if err != nil {
+				return nil, err
+			}
+			origins := make(map[int]int)
+			for _, e := range diff.LinesFunc(parentLines, current, eq) {
+				if e.Op == diff.Equal {
+					origins[e.NewLine] = e.OldLine
+				}
+			}
+			var remaining []pending
+			for _, p := range todo {
+				if old, ok := origins[p.current]; ok {
+					remaining = append(remaining, pending{final: p.final, current: old})
+					continue
+				}
+				result[p.final] = Line{
+					Hash:      hash,
+					Commit:    c,
+					OrigLine:  p.current + 1,
+					FinalLine: p.final + 1,
+					Text:      lines[p.final],
+				}
+			}
+			todo = remaining
+			current = parentLines
+		}
+		hash, c, blobHash = c.Parent, parent, parentBlob
+	}
+	return result[start:end], nil
+}
+
+// parentVersion loads the parent of c and the hash of path within it.
+// A nil parent is returned when c has no parent or the parent does not contain path.
+func parentVersion(l *layout.Layout, c *commit.Commit, path string) (*commit.Commit, string, error) {
+	if c.Parent == "" {
+		return nil, "", nil
+	}
+	parent, err := commit.Load(c.Parent, l)
+	if err != nil {
+		return nil, "", err
+	}
+	blobHash, err := parent.Lookup(path, l)
+	if errors.Is(err, commit.ErrPathNotInCommit) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return parent, blobHash, nil
+}
+
+func readLines(l *layout.Layout, blobHash string) ([]string, error) {
+	data, err := object.Read(l, blobHash)
+	if err != nil {
+		return nil, err
+	}
+	return diff.SplitLines(data), nil
+}
+
+// lineRange converts the requested 1-based inclusive range into slice bounds.
+func lineRange(opts Options, total int) (start, end int, err error) {
+	start, end = 0, total
+	if opts.Start > 0 {
+		start = opts.Start - 1
+	}
+	if opts.End > 0 && opts.End < total {
+		end = opts.End
+	}
+	if start > 0 && start >= total {
+		return 0, 0, fmt.Errorf("file has only %d lines", total)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("invalid line range %d,%d", opts.Start, opts.End)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,209 @@
+// Package bundle reads and writes bundles: single files holding refs and the
+// history they point at, for moving history between repositories that cannot
+// reach each other.
+//
+// A bundle is a text header followed by a pack stream of its objects:
+//
+//	# trac bundle v1
+//	-<hash>        a prerequisite, a commit whose history is left out
+//	<hash> <ref>   a ref and the commit it points at
+//	               an empty line ends the header
+//	pack stream
+//
+// A bundle with prerequisites can only be unbundled into a repository that
+// has them, and so already holds the history the bundle leaves out.
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/gc"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/pack"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+const signature = "# trac bundle v1"
+
+// Header lists what a bundle holds and what it needs.
+type Header struct {
+	Prerequisites []string   // Commits whose history the bundle leaves out
+	Refs          []refs.Ref // Refs in the bundle, possibly including HEAD
+}
+
+// Write writes a bundle of the refs in h and their history, leaving out the
+// history of its prerequisites, to w. Prerequisites that are not in the
+// history of any ref are dropped from h, since nothing depends on them.
+func Write(w io.Writer, l *layout.Layout, h *Header) (*pack.Stats, error) {
+	if len(h.Refs) == 0 {
+		return nil, ErrEmpty
+	}
+	var tips []string
+	for _, ref := range h.Refs {
+		if !slices.Contains(tips, ref.Hash) {
+			tips = append(tips, ref.Hash)
+		}
+	}
+	var prerequisites []string
+	for _, p := range h.Prerequisites {
+		for _, tip := range tips {
+			ok, err := graph.IsAncestor(l, p, tip)
+			if err != nil {
+				return nil, err
+			}
+			if ok && !slices.Contains(prerequisites, p) {
+				prerequisites = append(prerequisites, p)
+				break
+			}
+		}
+	}
+	h.Prerequisites = prerequisites
+	hashes, err := graph.Walk(l, tips, func(hash string) bool { return slices.Contains(prerequisites, hash) })
+	if err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	fmt.Fprintln(&header, signature)
+	for _, p := range h.Prerequisites {
+		fmt.Fprintf(&header, "-%s\n", p)
+	}
+	for _, ref := range h.Refs {
+		fmt.Fprintf(&header, "%s %s\n", ref.Hash, ref.Name)
+	}
+	fmt.Fprintln(&header)
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+	read := func(hash string) ([]byte, error) { return object.ReadRaw(l, hash) }
+	return pack.WriteStream(w, hashes, read, pack.Options{Window: gc.DefaultWindow, Depth: gc.DefaultDepth})
+}
+
+// Reader is an open bundle whose header has been read.
+type Reader struct {
+	Header
+	f *os.File
+	r *bufio.Reader // Positioned at the start of the pack stream
+}
+
+// Open opens the bundle at path and reads its header.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := &Reader{f: f, r: bufio.NewReader(f)}
+	if err := br.readHeader(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return br, nil
+}
+
+// Close closes the bundle file.
+func (br *Reader) Close() error {
+	return br.f.Close()
+}
+
+func (br *Reader) readHeader() error {
+	line, err := br.r.ReadString('\n')
+	if err != nil || strings.TrimSuffix(line, "\n") != signature {
+		return ErrNotBundle
+	}
+	for {
+		line, err := br.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("%w: truncated header", ErrInvalid)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return nil
+		}
+		if hash, ok := strings.CutPrefix(line, "-"); ok {
+			if !object.IsHash(hash) {
+				return fmt.Errorf("%w: bad prerequisite %q", ErrInvalid, line)
+			}
+			br.Prerequisites = append(br.Prerequisites, hash)
+			continue
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || !object.IsHash(hash) || (name != "HEAD" && refs.CheckName(refs.Short(name)) != nil) {
+			return fmt.Errorf("%w: bad ref line %q", ErrInvalid, line)
+		}
+		br.Refs = append(br.Refs, refs.Ref{Name: name, Hash: hash})
+	}
+}
+
+// Check fails with ErrMissingPrerequisite if l lacks any of the bundle's prerequisites.
+func (h *Header) Check(l *layout.Layout) error {
+	var missing []string
+	for _, p := range h.Prerequisites {
+		if !object.Has(l, p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingPrerequisite, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Unbundle stores the bundle's objects in l, which must have its prerequisites.
+// Refs are left alone; they are for the caller to update.
+func (br *Reader) Unbundle(l *layout.Layout) error {
+	if err := br.Check(l); err != nil {
+		return err
+	}
+	_, err := object.ReadStream(l, br.r)
+	return err
+}
+
+// Verify checks that l has the bundle's prerequisites and that its pack stream
+// is intact, without storing anything. Objects are held in memory while the
+// stream is read, since later objects may be deltas against them.
+func (br *Reader) Verify(l *layout.Layout) error {
+	if err := br.Check(l); err != nil {
+		return err
+	}
+	objects := make(map[string][]byte)
+	read := func(hash string) ([]byte, error) {
+		data, ok := objects[hash]
+		if !ok {
+			return nil, fmt.Errorf("%w: delta base %s", pack.ErrNotFound, hash)
+		}
+		return data, nil
+	}
+	write := func(hash string, data []byte) error {
+		objects[hash] = data
+		return nil
+	}
+	hashes, err := pack.ReadStream(br.r, read, write)
+	if err != nil {
+		return err
+	}
+	for _, ref := range br.Refs {
+		if !slices.Contains(hashes, ref.Hash) && !object.Has(l, ref.Hash) {
+			return fmt.Errorf("%w: %s points at %s, which is not in the bundle", ErrInvalid, ref.Name, ref.Hash)
+		}
+	}
+	return nil
+}
+
+// Is reports whether the file at path is a bundle.
+func Is(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	return err == nil && strings.TrimSuffix(line, "\n") == signature
+}
@@ -0,0 +1,10 @@
+package bundle
+
+import "errors"
+
+var (
+	ErrNotBundle           = errors.New("not a bundle")
+	ErrInvalid             = errors.New("invalid bundle")
+	ErrEmpty               = errors.New("refusing to create an empty bundle")
+	ErrMissingPrerequisite = errors.New("repository lacks the commits the bundle requires")
+)
@@ -0,0 +1,224 @@
+package checkout
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Commit updates the working tree and index to match the commit identified by
+// commitHash. Files tracked by the current HEAD that do not exist in the target
+// commit are removed. HEAD is left alone; callers point it at the commit,
+// directly or through a branch, once the checkout has succeeded.
+//
+// Checkout refuses to run when it would discard changes that have not been committed.
+func Commit(l *layout.Layout, commitHash string) error {
+	target, err := commit.Load(commitHash, l)
+	if err != nil {
+		return err
+	}
+	idx := index.New()
+	if err := idx.Load(l); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	current, currentModes, err := currentFiles(l, idx)
+	if err != nil {
+		return err
+	}
+	wanted, err := rootRelative(l, target.Changes)
+	if err != nil {
+		return err
+	}
+	wantedModes, err := rootRelative(l, target.Modes)
+	if err != nil {
+		return err
+	}
+	if err := checkUntracked(l, current, wanted, wantedModes); err != nil {
+		return err
+	}
+
+	for path := range current {
+		if _, ok := wanted[path]; ok {
+			continue
+		}
+		if err := l.CheckParents(path); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(l.Root, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	for path, contentHash := range wanted {
+		mode := filemode.Get(wantedModes, path)
+		if current[path] == contentHash && filemode.Get(currentModes, path) == mode {
+			continue
+		}
+		if err := writeFile(l, path, contentHash, mode); err != nil {
+			return err
+		}
+	}
+
+	idx.Staged = maps.Clone(target.Changes)
+	idx.Modes = maps.Clone(target.Modes)
+	if err := idx.Write(l); err != nil {
+		return fmt.Errorf("failed to write updated index: %w", err)
+	}
+	return nil
+}
+
+// Detach checks out a commit and points HEAD directly at it.
+func Detach(l *layout.Layout, commitHash string) error {
+	if err := Commit(l, commitHash); err != nil {
+		return err
+	}
+	return refs.DetachHead(l, commitHash)
+}
+
+// Branch checks out the commit a branch points at and puts HEAD on the branch,
+// given by its full ref name.
+func Branch(l *layout.Layout, branch string) error {
+	hash, err := refs.Read(l, branch)
+	if err != nil {
+		return err
+	}
+	if err := Commit(l, hash); err != nil {
+		return err
+	}
+	return refs.SetHead(l, branch)
+}
+
+// currentFiles returns the files tracked at HEAD and the modes of those that
+// are not regular files, keyed by their path relative to the repository root.
+// It fails if the index or working tree differ from HEAD.
+func currentFiles(l *layout.Layout, idx *index.Index) (files, modes map[string]string, err error) {
+	headHash, err := commit.GetParentHash(l)
+	if err != nil {
+		return nil, nil, err
+	}
+	head := &commit.Commit{}
+	if headHash != "" {
+		head, err = commit.Load(headHash, l)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if files, err = rootRelative(l, head.Changes); err != nil {
+		return nil, nil, err
+	}
+	if modes, err = rootRelative(l, head.Modes); err != nil {
+		return nil, nil, err
+	}
+	staged, err := rootRelative(l, idx.Staged)
+	if err != nil {
+		return nil, nil, err
+	}
+	stagedModes, err := rootRelative(l, idx.Modes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !maps.Equal(files, staged) || !maps.Equal(modes, filemode.Select(stagedModes, staged)) {
+		return nil, nil, ErrLocalChanges
+	}
+	enabled, err := filemode.Enabled(l)
+	if err != nil {
+		return nil, nil, err
+	}
+	for path, contentHash := range files {
+		hash, mode, err := filemode.Stat(filepath.Join(l.Root, path))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		recorded := filemode.Get(modes, path)
+		if !enabled {
+			mode = filemode.Ignore(mode, recorded)
+		}
+		if hash != contentHash || mode != recorded {
+			return nil, nil, ErrLocalChanges
+		}
+	}
+	return files, modes, nil
+}
+
+// checkUntracked fails if writing the wanted files would clobber untracked files with different content.
+func checkUntracked(l *layout.Layout, current, wanted, wantedModes map[string]string) error {
+	for path, contentHash := range wanted {
+		if _, ok := current[path]; ok {
+			continue
+		}
+		hash, mode, err := filemode.Stat(filepath.Join(l.Root, path))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if hash != contentHash || (mode == filemode.Symlink) != (filemode.Get(wantedModes, path) == filemode.Symlink) {
+			return fmt.Errorf("%w: %s", ErrUntrackedChanges, path)
+		}
+	}
+	return nil
+}
+
+// rootRelative rekeys files by their path relative to the repository root.
+// Commits made before paths were recorded relative to the root may hold absolute
+// paths, which are refused if they lie outside this repository.
+func rootRelative(l *layout.Layout, files map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(files))
+	for path, contentHash := range files {
+		if err := l.ValidatePathInRepo(path); err != nil {
+			return nil, err
+		}
+		relPath, err := l.RelPath(path)
+		if err != nil {
+			return nil, err
+		}
+		result[relPath] = contentHash
+	}
+	return result, nil
+}
+
+// writeFile writes a file with the given content and mode, replacing what is
+// there rather than writing through a symbolic link, and refusing to write
+// beneath one.
+func writeFile(l *layout.Layout, path, contentHash, mode string) error {
+	dst := filepath.Join(l.Root, path)
+	if err := l.MkdirParents(path); err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if mode == filemode.Symlink {
+		target, err := object.Read(l, contentHash)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(string(target), dst)
+	}
+	perm := fs.FileMode(0644)
+	if mode == filemode.Executable {
+		perm = 0755
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if err := object.WriteTo(l, contentHash, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
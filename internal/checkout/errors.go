@@ -0,0 +1,8 @@
+package checkout
+
+import "errors"
+
+var (
+	ErrLocalChanges     = errors.New("your local changes would be overwritten by checkout; commit them before switching revisions")
+	ErrUntrackedChanges = errors.New("untracked working tree files would be overwritten by checkout; move or remove them before switching revisions")
+)
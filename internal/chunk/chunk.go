@@ -0,0 +1,95 @@
+// Package chunk splits content into variable-sized chunks at content-defined
+// boundaries, so that an edit to a large file only changes the chunks around it.
+//
+// Boundaries are found with FastCDC: a gear rolling hash is computed over the
+// input and a chunk ends wherever the hash matches a mask. A stricter mask is
+// used before the target size and a looser one after it, which keeps chunk
+// sizes close to the average.
+package chunk
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	MinSize = 16 << 10  // Smallest chunk, other than the last one
+	AvgSize = 64 << 10  // Target chunk size
+	MaxSize = 256 << 10 // Largest chunk
+
+	// maskS has more bits set than log2(AvgSize) so cut points are rarer before
+	// the target size; maskL has fewer so they are more frequent after it.
+	maskS = uint64(0x9292_524a_4949_0000) // 18 bits
+	maskL = uint64(0x8912_2244_4891_0000) // 14 bits
+)
+
+// gear maps each byte to a pseudo-random value. The table must never change,
+// since it determines where chunk boundaries fall and therefore which chunks
+// existing repositories can share.
+var gear = func() (table [256]uint64) {
+	// splitmix64 with a fixed seed.
+	state := uint64(0x7472_6163_6364_6331)
+	for i := range table {
+		state += 0x9e37_79b9_7f4a_7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58_476d_1ce4_e5b9
+		z = (z ^ (z >> 27)) * 0x94d0_49bb_1331_11eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// cut returns the length of the chunk at the start of data.
+func cut(data []byte) int {
+	n := min(len(data), MaxSize)
+	if n <= MinSize {
+		return n
+	}
+	normal := min(n, AvgSize)
+	var h uint64
+	i := MinSize
+	for ; i < normal; i++ {
+		h = h<<1 + gear[data[i]]
+		if h&maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = h<<1 + gear[data[i]]
+		if h&maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Split reads r to the end and calls fn with each chunk in order.
+// The slice passed to fn is only valid until fn returns.
+func Split(r io.Reader, fn func(chunk []byte) error) error {
+	buf := make([]byte, 2*MaxSize)
+	start, end := 0, 0
+	eof := false
+	for {
+		if !eof && end-start < MaxSize {
+			copy(buf, buf[start:end])
+			end -= start
+			start = 0
+			n, err := io.ReadFull(r, buf[end:])
+			end += n
+			switch {
+			case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+				eof = true
+			case err != nil:
+				return err
+			}
+		}
+		if start == end {
+			return nil
+		}
+		n := cut(buf[start:end])
+		if err := fn(buf[start : start+n]); err != nil {
+			return err
+		}
+		start += n
+	}
+}
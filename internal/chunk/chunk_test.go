@@ -0,0 +1,70 @@
+package chunk
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func split(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var chunks [][]byte
+	require.NoError(t, Split(bytes.NewReader(data), func(c []byte) error {
+		chunks = append(chunks, bytes.Clone(c))
+		return nil
+	}))
+	return chunks
+}
+
+func TestSplit(t *testing.T) {
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	t.Run("reassembles to the input", func(t *testing.T) {
+		chunks := split(t, data)
+		require.Equal(t, data, bytes.Join(chunks, nil))
+		for i, c := range chunks {
+			require.LessOrEqual(t, len(c), MaxSize)
+			if i < len(chunks)-1 {
+				require.GreaterOrEqual(t, len(c), MinSize)
+			}
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		require.Empty(t, split(t, nil))
+	})
+
+	t.Run("small input is a single chunk", func(t *testing.T) {
+		chunks := split(t, data[:MinSize])
+		require.Len(t, chunks, 1)
+	})
+
+	t.Run("insertion only changes nearby chunks", func(t *testing.T) {
+		edited := bytes.Clone(data[:2<<20])
+		edited = append(edited, []byte("inserted")...)
+		edited = append(edited, data[2<<20:]...)
+
+		before := make(map[string]bool)
+		for _, c := range split(t, data) {
+			before[string(c)] = true
+		}
+		after := split(t, edited)
+		changed := 0
+		for _, c := range after {
+			if !before[string(c)] {
+				changed++
+			}
+		}
+		require.LessOrEqual(t, changed, 2)
+		require.Greater(t, len(after), 10)
+	})
+
+	t.Run("uniform input is cut at the maximum size", func(t *testing.T) {
+		chunks := split(t, make([]byte, 3*MaxSize+1))
+		require.Len(t, chunks, 4)
+		require.Len(t, chunks[3], 1)
+	})
+}
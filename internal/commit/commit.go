@@ -1,18 +1,18 @@
 package commit
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
 	"encoding/json"
-	"errors"
-	"io"
-	"io/fs"
-	"os"
+	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/lucasrod16/trac/internal/filemode"
 	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
 )
 
 // Commit represents a commit in the repository.
@@ -21,6 +21,21 @@ type Commit struct {
 	Message   string            `json:"message"`
 	Timestamp time.Time         `json:"timestamp"`
 	Changes   map[string]string `json:"changes"`
+	Modes     map[string]string `json:"modes,omitempty"`  // Modes of files that are not regular files; see package filemode
+	Author    *Signature        `json:"author,omitempty"` // Who wrote the change and when, if recorded
+	Sig       string            `json:"sig,omitempty"`    // Signature over the payload, if signed; see package sign
+}
+
+// Signature identifies a person and when they acted.
+type Signature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Time  time.Time `json:"time"`
+}
+
+// String formats the signature as "Name <email>".
+func (s *Signature) String() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
 }
 
 func New(message, parent string, stagedFiles map[string]string) *Commit {
@@ -32,110 +47,145 @@ func New(message, parent string, stagedFiles map[string]string) *Commit {
 	}
 }
 
+// Summary returns the first line of the commit message.
+func (c *Commit) Summary() string {
+	summary, _, _ := strings.Cut(c.Message, "\n")
+	return summary
+}
+
 // Save writes the commit object to the repository and updates HEAD.
+//
+// File contents are written to the object database when they are staged, so
+// Save only verifies that every object the commit refers to is intact, and
+// that no file is also a directory of others; see CheckTree. It does not
+// check that the commit changes anything; that is up to the caller.
 func (c *Commit) Save(l *layout.Layout) (string, error) {
-	changed, err := c.workingTreeChanged(l)
-	if err != nil {
-		return "", err
+	for filePath, contentHash := range c.Changes {
+		if err := object.Verify(l, contentHash); err != nil {
+			return "", fmt.Errorf("cannot commit %s: %w", filePath, err)
+		}
 	}
-	if !changed {
-		return "", ErrWorkingTreeClean
+	if err := CheckTree(c.Changes); err != nil {
+		return "", fmt.Errorf("cannot commit: %w", err)
 	}
-
-	commitData, err := json.MarshalIndent(c, "", "  ")
+	commitHash, err := c.Write(l)
 	if err != nil {
 		return "", err
 	}
-	hash := sha256.Sum256(commitData)
-	commitHash := hex.EncodeToString(hash[:])
-
-	objectDir := filepath.Join(l.Objects, commitHash[:2])
-	if err := os.MkdirAll(objectDir, 0755); err != nil {
-		return "", err
-	}
-	commitPath := filepath.Join(objectDir, commitHash[2:])
-	if err := os.WriteFile(commitPath, commitData, 0644); err != nil {
+	if err := UpdateHead(l, commitHash); err != nil {
 		return "", err
 	}
-	if err := os.WriteFile(l.HeadFile, []byte(commitHash+"\n"), 0644); err != nil {
-		return "", err
-	}
-	for filePath, contentHash := range c.Changes {
-		if err := copyFileObject(contentHash, filePath, l); err != nil {
-			return "", err
-		}
-	}
 	return commitHash, nil
 }
 
-func (c *Commit) workingTreeChanged(l *layout.Layout) (changed bool, err error) {
-	parentCommit, err := loadCommit(c.Parent, l)
-	if err != nil && !errors.Is(err, ErrEmptyCommitHash) {
-		return false, err
-	}
-	if parentCommit == nil && errors.Is(err, ErrEmptyCommitHash) {
-		return true, nil
-	}
-	if len(c.Changes) != len(parentCommit.Changes) {
-		return true, nil
-	}
-	for path, hash := range c.Changes {
-		if parentHash, ok := parentCommit.Changes[path]; !ok || parentHash != hash {
-			return true, nil
-		}
+// Payload returns what a signature over the commit covers: the commit object
+// as it is stored, without its signature.
+func (c *Commit) Payload() ([]byte, error) {
+	unsigned := *c
+	unsigned.Sig = ""
+	return json.MarshalIndent(&unsigned, "", "  ")
+}
+
+// Write stores the commit object in the object database and returns its hash,
+// without checking its content or moving any refs.
+func (c *Commit) Write(l *layout.Layout) (string, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
 	}
-	return false, nil
+	return object.Write(l, data)
 }
 
-// loadCommit loads a commit object from the object database.
-func loadCommit(commitHash string, l *layout.Layout) (*Commit, error) {
+// Load loads a commit object from the object database.
+func Load(commitHash string, l *layout.Layout) (*Commit, error) {
 	if commitHash == "" {
 		return nil, ErrEmptyCommitHash
 	}
-	commitPath := filepath.Join(l.Objects, commitHash[:2], commitHash[2:])
-	data, err := os.ReadFile(commitPath)
+	data, err := object.Read(l, commitHash)
 	if err != nil {
 		return nil, err
 	}
-	var commit Commit
-	if err := json.Unmarshal(data, &commit); err != nil {
-		return nil, err
-	}
-	return &commit, nil
+	return Parse(data)
 }
 
-func copyFileObject(contentHash string, src string, l *layout.Layout) error {
-	objectDir := filepath.Join(l.Objects, contentHash[:2])
-	if err := os.MkdirAll(objectDir, 0755); err != nil {
-		return err
+// Parse decodes and validates a commit object.
+func Parse(data []byte) (*Commit, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var commit Commit
+	if err := decoder.Decode(&commit); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCommit, err)
 	}
-	objectPath := filepath.Join(objectDir, contentHash[2:])
-
-	if _, err := os.Stat(objectPath); errors.Is(err, fs.ErrNotExist) {
-		srcFile, err := os.Open(src)
-		if err != nil {
-			return err
+	if decoder.More() {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidCommit)
+	}
+	if commit.Timestamp.IsZero() {
+		return nil, fmt.Errorf("%w: missing timestamp", ErrInvalidCommit)
+	}
+	if commit.Parent != "" && !object.IsHash(commit.Parent) {
+		return nil, fmt.Errorf("%w: malformed parent %q", ErrInvalidCommit, commit.Parent)
+	}
+	for path, contentHash := range commit.Changes {
+		if !object.IsHash(contentHash) {
+			return nil, fmt.Errorf("%w: malformed hash %q for %s", ErrInvalidCommit, contentHash, path)
 		}
-		defer srcFile.Close()
-
-		dstFile, err := os.OpenFile(objectPath, os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return err
+	}
+	for path, mode := range commit.Modes {
+		if _, ok := commit.Changes[path]; !ok || !filemode.Valid(mode) {
+			return nil, fmt.Errorf("%w: malformed mode %q for %s", ErrInvalidCommit, mode, path)
 		}
-		defer dstFile.Close()
+	}
+	if err := CheckTree(commit.Changes); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCommit, err)
+	}
+	return &commit, nil
+}
 
-		if _, err := io.Copy(dstFile, srcFile); err != nil {
-			return err
+// CheckTree fails if a path of files is also a directory holding another,
+// which would have the other written beneath a file or a symbolic link.
+func CheckTree(files map[string]string) error {
+	paths := make(map[string]bool, len(files))
+	for p := range files {
+		paths[path.Clean(filepath.ToSlash(p))] = true
+	}
+	for p := range paths {
+		for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if paths[dir] {
+				return fmt.Errorf("%s is both a file and a directory holding %s", dir, p)
+			}
 		}
 	}
 	return nil
 }
 
-// GetParentHash gets the hash of the latest commit from HEAD.
+// GetParentHash gets the hash of the commit HEAD resolves to, or an empty
+// string if there are no commits on the current branch yet.
 func GetParentHash(l *layout.Layout) (string, error) {
-	data, err := os.ReadFile(l.HeadFile)
+	_, hash, err := refs.ReadHead(l)
+	return hash, err
+}
+
+// Lookup returns the content hash recorded for path in the commit.
+// Paths are compared relative to the repository root, so it does not matter
+// whether path or the recorded path is absolute.
+func (c *Commit) Lookup(path string, l *layout.Layout) (string, error) {
+	want, err := l.RelPath(path)
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(data)), nil
+	for filePath, contentHash := range c.Changes {
+		got, err := l.RelPath(filePath)
+		if err != nil {
+			return "", err
+		}
+		if got == want {
+			return contentHash, nil
+		}
+	}
+	return "", ErrPathNotInCommit
+}
+
+// UpdateHead points HEAD at the given commit, moving the current branch if HEAD is on one.
+func UpdateHead(l *layout.Layout, commitHash string) error {
+	return refs.UpdateHead(l, commitHash)
 }
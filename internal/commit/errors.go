@@ -6,4 +6,13 @@ var (
 	ErrEmptyCommitHash      = errors.New("commit hash is empty")
 	ErrWorkingTreeClean     = errors.New("nothing to commit, working tree clean")
 	ErrNothingAddedToCommit = errors.New(`nothing added to commit (use "trac add" to track)`)
+	ErrNothingStaged        = errors.New(`no changes added to commit (use "trac add" or "trac commit -a")`)
+	ErrNoCommits            = errors.New("current branch does not have any commits yet")
+	ErrUnknownRevision      = errors.New("unknown revision")
+	ErrAmbiguousRevision    = errors.New("ambiguous revision")
+	ErrPathNotInCommit      = errors.New("path does not exist in commit")
+	ErrPathNotTracked       = errors.New("pathspec did not match any tracked file")
+	ErrInvalidCommit        = errors.New("invalid commit object")
+	ErrInvalidTag           = errors.New("invalid tag object")
+	ErrEmptyMessage         = errors.New("aborting commit due to empty commit message")
 )
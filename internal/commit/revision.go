@@ -0,0 +1,117 @@
+package commit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// minAbbrevLength is the shortest commit hash prefix accepted as a revision.
+const minAbbrevLength = 4
+
+// Resolve resolves a revision to a full commit hash.
+//
+// A revision is either "HEAD", a ref name, or a full or abbreviated commit hash,
+// optionally followed by any number of "^" or "~<n>" suffixes selecting an ancestor.
+// Ref names may be given in full, e.g. refs/heads/main, or as a tag, branch or
+// remote-tracking branch name such as origin/main, in that order of precedence.
+// A ref that points at an annotated tag resolves to the tag's commit.
+func Resolve(rev string, l *layout.Layout) (string, error) {
+	base, steps, err := splitAncestry(rev)
+	if err != nil {
+		return "", err
+	}
+	hash, err := resolveBase(base, l)
+	if err != nil {
+		return "", err
+	}
+	for range steps {
+		c, err := Load(hash, l)
+		if err != nil {
+			return "", err
+		}
+		if c.Parent == "" {
+			return "", fmt.Errorf("%w: %s", ErrUnknownRevision, rev)
+		}
+		hash = c.Parent
+	}
+	return hash, nil
+}
+
+// splitAncestry splits a revision into its base and the number of parent steps requested.
+func splitAncestry(rev string) (base string, steps int, err error) {
+	i := strings.IndexAny(rev, "^~")
+	if i == -1 {
+		return rev, 0, nil
+	}
+	base, suffix := rev[:i], rev[i:]
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		end := strings.IndexAny(suffix, "^~")
+		if end == -1 {
+			end = len(suffix)
+		}
+		n := 1
+		if end > 0 {
+			if op == '^' && suffix[:end] != "1" {
+				return "", 0, fmt.Errorf("%w: %s", ErrUnknownRevision, rev)
+			}
+			n, err = strconv.Atoi(suffix[:end])
+			if err != nil || n < 0 {
+				return "", 0, fmt.Errorf("%w: %s", ErrUnknownRevision, rev)
+			}
+		}
+		steps += n
+		suffix = suffix[end:]
+	}
+	return base, steps, nil
+}
+
+func resolveBase(base string, l *layout.Layout) (string, error) {
+	if base == "HEAD" || base == "" {
+		hash, err := GetParentHash(l)
+		if err != nil {
+			return "", err
+		}
+		if hash == "" {
+			return "", ErrNoCommits
+		}
+		return hash, nil
+	}
+	for _, name := range []string{base, refs.Tag(base), refs.Branch(base), refs.RemotesPrefix + base} {
+		if hash, err := refs.Read(l, name); err == nil {
+			return Peel(hash, l)
+		}
+	}
+	base = strings.ToLower(base)
+	if len(base) < minAbbrevLength || strings.Trim(base, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnknownRevision, base)
+	}
+	hashes, err := object.List(l)
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, hash := range hashes {
+		if !strings.HasPrefix(hash, base) {
+			continue
+		}
+		if _, err := Load(hash, l); err != nil {
+			continue
+		}
+		matches = append(matches, hash)
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrUnknownRevision, base)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrAmbiguousRevision, base)
+	}
+}
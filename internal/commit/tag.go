@@ -0,0 +1,84 @@
+package commit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// Tag is an annotated tag: an object recording a message about a commit, and
+// optionally a signature, that a tag ref points at instead of the commit.
+// Tags that point straight at a commit are lightweight and have no object.
+type Tag struct {
+	Object    string     `json:"object"` // Commit the tag is about
+	Name      string     `json:"tag"`    // Short name of the tag
+	Message   string     `json:"message"`
+	Timestamp time.Time  `json:"timestamp"`
+	Tagger    *Signature `json:"tagger,omitempty"`
+	Sig       string     `json:"sig,omitempty"` // Signature over the payload, if signed; see package sign
+}
+
+// Payload returns what a signature over the tag covers: the tag object as it
+// is stored, without its signature.
+func (t *Tag) Payload() ([]byte, error) {
+	unsigned := *t
+	unsigned.Sig = ""
+	return json.MarshalIndent(&unsigned, "", "  ")
+}
+
+// Write stores the tag object in the object database and returns its hash.
+func (t *Tag) Write(l *layout.Layout) (string, error) {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return object.Write(l, data)
+}
+
+// LoadTag loads a tag object from the object database. An object that is not
+// a tag, such as a commit, fails with ErrInvalidTag.
+func LoadTag(hash string, l *layout.Layout) (*Tag, error) {
+	data, err := object.Read(l, hash)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTag(data)
+}
+
+// ParseTag decodes and validates a tag object.
+func ParseTag(data []byte) (*Tag, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var tag Tag
+	if err := decoder.Decode(&tag); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTag, err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidTag)
+	}
+	if !object.IsHash(tag.Object) {
+		return nil, fmt.Errorf("%w: malformed object %q", ErrInvalidTag, tag.Object)
+	}
+	if tag.Name == "" || tag.Timestamp.IsZero() {
+		return nil, fmt.Errorf("%w: missing name or timestamp", ErrInvalidTag)
+	}
+	return &tag, nil
+}
+
+// Peel returns the commit hash refers to: the object of an annotated tag, or
+// hash itself otherwise.
+func Peel(hash string, l *layout.Layout) (string, error) {
+	t, err := LoadTag(hash, l)
+	if errors.Is(err, ErrInvalidTag) {
+		return hash, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return t.Object, nil
+}
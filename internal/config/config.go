@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/layout"
+)
+
+// Config holds the repository configuration as sections of key-value pairs.
+//
+// Keys are written as "<section>.<name>", where the section may itself contain
+// dots to name a subsection, e.g. "core.chunkThreshold" or "remote.origin.url".
+type Config struct {
+	Sections map[string]map[string]string `json:"sections"`
+}
+
+func New() *Config {
+	return &Config{Sections: make(map[string]map[string]string)}
+}
+
+// Load reads the repository configuration. A missing configuration file yields an empty configuration.
+func Load(l *layout.Layout) (*Config, error) {
+	c := New()
+	data, err := os.ReadFile(l.ConfigFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", l.ConfigFile, err)
+	}
+	if c.Sections == nil {
+		c.Sections = make(map[string]map[string]string)
+	}
+	return c, nil
+}
+
+// Save writes the configuration to the repository.
+func (c *Config) Save(l *layout.Layout) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.ConfigFile, append(data, '\n'), 0644)
+}
+
+// splitKey splits a key into its section and name.
+func splitKey(key string) (section, name string, err error) {
+	i := strings.LastIndex(key, ".")
+	if i <= 0 || i == len(key)-1 {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return key[:i], key[i+1:], nil
+}
+
+// Get returns the value of key.
+func (c *Config) Get(key string) (string, error) {
+	section, name, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+	value, ok := c.Sections[section][name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotSet, key)
+	}
+	return value, nil
+}
+
+// Set sets key to value.
+func (c *Config) Set(key, value string) error {
+	section, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	if c.Sections[section] == nil {
+		c.Sections[section] = make(map[string]string)
+	}
+	c.Sections[section][name] = value
+	return nil
+}
+
+// Unset removes key, and its section if it becomes empty.
+func (c *Config) Unset(key string) error {
+	section, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.Sections[section][name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotSet, key)
+	}
+	delete(c.Sections[section], name)
+	if len(c.Sections[section]) == 0 {
+		delete(c.Sections, section)
+	}
+	return nil
+}
+
+// Keys returns every key that is set, in sorted order.
+func (c *Config) Keys() []string {
+	var keys []string
+	for section, values := range c.Sections {
+		for name := range values {
+			keys = append(keys, section+"."+name)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Subsections returns the names of the subsections of section, in sorted order.
+// For example, the subsections of "remote" in a configuration containing
+// "remote.origin.url" are ["origin"].
+func (c *Config) Subsections(section string) []string {
+	var names []string
+	for s := range maps.Keys(c.Sections) {
+		if name, ok := strings.CutPrefix(s, section+"."); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// String returns the value of key, or fallback if it is not set.
+func (c *Config) String(key, fallback string) string {
+	value, err := c.Get(key)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Bool returns the value of key as a boolean, or fallback if it is not set.
+func (c *Config) Bool(key string, fallback bool) (bool, error) {
+	value, err := c.Get(key)
+	if err != nil {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value %q for %s", value, key)
+	}
+	return b, nil
+}
+
+// Size returns the value of key as a size in bytes, or fallback if it is not set.
+// Sizes may use the suffixes k, m and g for binary multiples, e.g. "64m".
+func (c *Config) Size(key string, fallback int64) (int64, error) {
+	value, err := c.Get(key)
+	if err != nil {
+		return fallback, nil
+	}
+	size, err := ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q for %s", value, key)
+	}
+	return size, nil
+}
+
+// ParseSize parses a size in bytes with an optional k, m or g suffix.
+func ParseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	switch strings.ToLower(s[len(s)-min(len(s), 1):]) {
+	case "k":
+		multiplier = 1 << 10
+	case "m":
+		multiplier = 1 << 20
+	case "g":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,8 @@
+package config
+
+import "errors"
+
+var (
+	ErrInvalidKey = errors.New("invalid config key")
+	ErrNotSet     = errors.New("config key is not set")
+)
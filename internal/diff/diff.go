@@ -0,0 +1,163 @@
+package diff
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Op identifies the kind of change an Edit represents.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is a single line-level step in transforming one version of a file into another.
+type Edit struct {
+	Op      Op
+	OldLine int    // Index of the line in the old version, or -1 for insertions
+	NewLine int    // Index of the line in the new version, or -1 for deletions
+	Text    string // Line content, taken from the new version unless the line was deleted
+}
+
+// EqualFunc reports whether two lines should be treated as identical.
+type EqualFunc func(a, b string) bool
+
+// Exact compares lines byte for byte.
+func Exact(a, b string) bool {
+	return a == b
+}
+
+// IgnoreWhitespace compares lines while disregarding all whitespace.
+func IgnoreWhitespace(a, b string) bool {
+	return stripWhitespace(a) == stripWhitespace(b)
+}
+
+func stripWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// SplitLines splits file content into lines without their trailing newlines.
+func SplitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	s := strings.TrimSuffix(string(data), "\n")
+	return strings.Split(s, "\n")
+}
+
+// IsBinary reports whether data looks like binary content, i.e. has a NUL byte near the start.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// Lines computes the shortest edit script transforming a into b.
+func Lines(a, b []string) []Edit {
+	return LinesFunc(a, b, Exact)
+}
+
+// LinesFunc computes the shortest edit script transforming a into b using eq to compare lines.
+func LinesFunc(a, b []string, eq EqualFunc) []Edit {
+	// Common prefixes and suffixes are cheap to match and keep the search space small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && eq(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && eq(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, max(len(a), len(b)))
+	for i := range prefix {
+		edits = append(edits, Edit{Op: Equal, OldLine: i, NewLine: i, Text: b[i]})
+	}
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], eq) {
+		if e.OldLine >= 0 {
+			e.OldLine += prefix
+		}
+		if e.NewLine >= 0 {
+			e.NewLine += prefix
+		}
+		edits = append(edits, e)
+	}
+	for i := range suffix {
+		oldLine, newLine := len(a)-suffix+i, len(b)-suffix+i
+		edits = append(edits, Edit{Op: Equal, OldLine: oldLine, NewLine: newLine, Text: b[newLine]})
+	}
+	return edits
+}
+
+// myers implements the greedy O(ND) difference algorithm described in
+// "An O(ND) Difference Algorithm and Its Variations" by Eugene W. Myers.
+func myers(a, b []string, eq EqualFunc) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && eq(a[x], b[y]) {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var edits []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Op: Equal, OldLine: x - 1, NewLine: y - 1, Text: b[y-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			edits = append(edits, Edit{Op: Insert, OldLine: -1, NewLine: y - 1, Text: b[y-1]})
+		} else {
+			edits = append(edits, Edit{Op: Delete, OldLine: x - 1, NewLine: -1, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(edits)
+	return edits
+}
@@ -0,0 +1,76 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// apply reconstructs the new version from the old version and an edit script.
+func apply(t *testing.T, a []string, edits []Edit) []string {
+	t.Helper()
+	var out []string
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			require.Equal(t, a[e.OldLine], e.Text)
+			out = append(out, e.Text)
+		case Insert:
+			out = append(out, e.Text)
+		case Delete:
+			require.Equal(t, a[e.OldLine], e.Text)
+		}
+	}
+	return out
+}
+
+func countChanges(edits []Edit) int {
+	n := 0
+	for _, e := range edits {
+		if e.Op != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		a, b    string
+		changes int
+	}{
+		{name: "identical", a: "a b c", b: "a b c", changes: 0},
+		{name: "empty to content", a: "", b: "a b", changes: 2},
+		{name: "content to empty", a: "a b", b: "", changes: 2},
+		{name: "insertion", a: "a c", b: "a b c", changes: 1},
+		{name: "deletion", a: "a b c", b: "a c", changes: 1},
+		{name: "replacement", a: "a b c", b: "a x c", changes: 2},
+		{name: "myers example", a: "a b c a b b a", b: "c b a b a c", changes: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			edits := Lines(a, b)
+			require.Equal(t, strings.Join(b, " "), strings.Join(apply(t, a, edits), " "))
+			require.Equal(t, tt.changes, countChanges(edits))
+		})
+	}
+}
+
+func TestIgnoreWhitespace(t *testing.T) {
+	t.Parallel()
+	edits := LinesFunc([]string{"a", "b c"}, []string{"a", "\tb  c "}, IgnoreWhitespace)
+	require.Equal(t, 0, countChanges(edits))
+}
+
+func TestSplitLines(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, SplitLines(nil))
+	require.Equal(t, []string{"a", "b"}, SplitLines([]byte("a\nb\n")))
+	require.Equal(t, []string{"a", "b"}, SplitLines([]byte("a\nb")))
+	require.Equal(t, []string{"a", ""}, SplitLines([]byte("a\n\n")))
+}
@@ -0,0 +1,5 @@
+package diff
+
+import "errors"
+
+var ErrHunkMismatch = errors.New("hunk does not apply")
@@ -0,0 +1,245 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hunk is a group of nearby changes along with the unchanged lines surrounding them.
+type Hunk struct {
+	OldStart int // Index of the first line of the hunk in the old version
+	NewStart int // Index of the first line of the hunk in the new version
+	Edits    []Edit
+}
+
+// OldLines returns the number of lines the hunk spans in the old version.
+func (h Hunk) OldLines() int {
+	n := 0
+	for _, e := range h.Edits {
+		if e.Op != Insert {
+			n++
+		}
+	}
+	return n
+}
+
+// NewLines returns the number of lines the hunk spans in the new version.
+func (h Hunk) NewLines() int {
+	n := 0
+	for _, e := range h.Edits {
+		if e.Op != Delete {
+			n++
+		}
+	}
+	return n
+}
+
+// Header returns the unified diff range header of the hunk, e.g. "@@ -1,3 +1,4 @@".
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", unifiedRange(h.OldStart, h.OldLines()), unifiedRange(h.NewStart, h.NewLines()))
+}
+
+// unifiedRange formats a range the way unified diffs do: the start is 1-based
+// unless the range is empty, in which case it names the line before the range.
+func unifiedRange(start, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, lines)
+	}
+}
+
+// String returns the hunk in unified diff format.
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header())
+	sb.WriteByte('\n')
+	for _, e := range h.Edits {
+		sb.WriteString(e.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// String returns the edit as a line of a unified diff.
+func (e Edit) String() string {
+	switch e.Op {
+	case Insert:
+		return "+" + e.Text
+	case Delete:
+		return "-" + e.Text
+	default:
+		return " " + e.Text
+	}
+}
+
+// Reverse returns a hunk that undoes h.
+func (h Hunk) Reverse() Hunk {
+	reversed := Hunk{OldStart: h.NewStart, NewStart: h.OldStart}
+	for _, e := range h.Edits {
+		r := Edit{Op: e.Op, OldLine: e.NewLine, NewLine: e.OldLine, Text: e.Text}
+		switch e.Op {
+		case Insert:
+			r.Op = Delete
+		case Delete:
+			r.Op = Insert
+		}
+		reversed.Edits = append(reversed.Edits, r)
+	}
+	return reversed
+}
+
+// Hunks groups an edit script into hunks, keeping up to context unchanged lines around each change.
+// Changes separated by no more than twice the context share a hunk.
+func Hunks(edits []Edit, context int) []Hunk {
+	// Track where each edit falls in both versions, since insertions and deletions only carry one side.
+	oldPos := make([]int, len(edits))
+	newPos := make([]int, len(edits))
+	o, n := 0, 0
+	for i, e := range edits {
+		oldPos[i], newPos[i] = o, n
+		if e.Op != Insert {
+			o++
+		}
+		if e.Op != Delete {
+			n++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		start := max(0, i-context)
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+		hunks = append(hunks, Hunk{OldStart: oldPos[start], NewStart: newPos[start], Edits: edits[start:end]})
+		i = end
+	}
+	return hunks
+}
+
+// Split breaks a hunk into smaller hunks at the unchanged lines between its changes.
+// Neighbouring hunks share the unchanged lines between them.
+func (h Hunk) Split() []Hunk {
+	// position returns where the edit at index k falls in the old and new versions.
+	position := func(k int) (int, int) {
+		o, n := h.OldStart, h.NewStart
+		for _, e := range h.Edits[:k] {
+			if e.Op != Insert {
+				o++
+			}
+			if e.Op != Delete {
+				n++
+			}
+		}
+		return o, n
+	}
+
+	var hunks []Hunk
+	leading := 0 // Index where the unchanged lines before the current change begin
+	for i := 0; i < len(h.Edits); {
+		if h.Edits[i].Op == Equal {
+			i++
+			continue
+		}
+		end := i
+		for end < len(h.Edits) && h.Edits[end].Op != Equal {
+			end++
+		}
+		trailing := end
+		for trailing < len(h.Edits) && h.Edits[trailing].Op == Equal {
+			trailing++
+		}
+		o, n := position(leading)
+		hunks = append(hunks, Hunk{OldStart: o, NewStart: n, Edits: h.Edits[leading:trailing]})
+		leading = end
+		i = trailing
+	}
+	if len(hunks) == 0 {
+		return []Hunk{h}
+	}
+	return hunks
+}
+
+// Apply applies hunks, ordered by position, to the old version of a file and
+// returns the new version. Hunks may overlap in their unchanged lines.
+func Apply(old []string, hunks []Hunk) ([]string, error) {
+	var result []string
+	pos := 0
+	for _, h := range hunks {
+		if h.OldStart < pos {
+			// Skip unchanged lines already consumed by the previous hunk.
+			skip := pos - h.OldStart
+			for skip > 0 && len(h.Edits) > 0 && h.Edits[0].Op == Equal {
+				h.Edits = h.Edits[1:]
+				h.OldStart++
+				skip--
+			}
+			if skip > 0 {
+				return nil, fmt.Errorf("%w: overlapping hunks at line %d", ErrHunkMismatch, h.OldStart+1)
+			}
+		}
+		if h.OldStart > len(old) {
+			return nil, fmt.Errorf("%w: line %d is past the end of the file", ErrHunkMismatch, h.OldStart+1)
+		}
+		result = append(result, old[pos:h.OldStart]...)
+		pos = h.OldStart
+		for _, e := range h.Edits {
+			if e.Op != Insert {
+				if pos >= len(old) || old[pos] != e.Text {
+					return nil, fmt.Errorf("%w: at line %d", ErrHunkMismatch, pos+1)
+				}
+				pos++
+			}
+			if e.Op != Delete {
+				result = append(result, e.Text)
+			}
+		}
+	}
+	return append(result, old[pos:]...), nil
+}
+
+// ParseHunk parses the body of a hunk in unified diff format, as produced by
+// Hunk.String without its header. Lines starting with "#" are ignored.
+func ParseHunk(oldStart, newStart int, text string) (Hunk, error) {
+	h := Hunk{OldStart: oldStart, NewStart: newStart}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line == "" {
+			// Editors commonly strip the trailing space of empty context lines.
+			h.Edits = append(h.Edits, Edit{Op: Equal})
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			h.Edits = append(h.Edits, Edit{Op: Equal, Text: line[1:]})
+		case '+':
+			h.Edits = append(h.Edits, Edit{Op: Insert, Text: line[1:]})
+		case '-':
+			h.Edits = append(h.Edits, Edit{Op: Delete, Text: line[1:]})
+		case '#':
+		default:
+			return Hunk{}, fmt.Errorf("invalid hunk line %q", line)
+		}
+	}
+	return h, nil
+}
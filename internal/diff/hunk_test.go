@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func numbered(n int, changes map[int]string) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+		if line, ok := changes[i+1]; ok {
+			lines[i] = line
+		}
+	}
+	return lines
+}
+
+func TestHunks(t *testing.T) {
+	t.Parallel()
+	a := numbered(20, nil)
+	b := numbered(20, map[int]string{2: "x", 15: "y"})
+	hunks := Hunks(Lines(a, b), 3)
+	require.Len(t, hunks, 2)
+	require.Equal(t, "@@ -1,5 +1,5 @@", hunks[0].Header())
+	require.Equal(t, "@@ -12,7 +12,7 @@", hunks[1].Header())
+
+	// nearby changes share a hunk
+	b = numbered(20, map[int]string{2: "x", 8: "y"})
+	require.Len(t, Hunks(Lines(a, b), 3), 1)
+
+	require.Empty(t, Hunks(Lines(a, a), 3))
+}
+
+func TestHunkHeaderEmptyRange(t *testing.T) {
+	t.Parallel()
+	hunks := Hunks(Lines(nil, []string{"a"}), 3)
+	require.Len(t, hunks, 1)
+	require.Equal(t, "@@ -0,0 +1 @@", hunks[0].Header())
+	require.Equal(t, "@@ -0,0 +1 @@\n+a\n", hunks[0].String())
+}
+
+func TestSplitAndApply(t *testing.T) {
+	t.Parallel()
+	a := numbered(10, nil)
+	b := numbered(10, map[int]string{3: "x", 6: "y"})
+	hunks := Hunks(Lines(a, b), 3)
+	require.Len(t, hunks, 1)
+
+	parts := hunks[0].Split()
+	require.Len(t, parts, 2)
+
+	result, err := Apply(a, parts)
+	require.NoError(t, err)
+	require.Equal(t, b, result)
+
+	result, err = Apply(a, parts[1:])
+	require.NoError(t, err)
+	require.Equal(t, numbered(10, map[int]string{6: "y"}), result)
+
+	result, err = Apply(b, []Hunk{parts[0].Reverse()})
+	require.NoError(t, err)
+	require.Equal(t, numbered(10, map[int]string{6: "y"}), result)
+}
+
+func TestApplyMismatch(t *testing.T) {
+	t.Parallel()
+	a := numbered(5, nil)
+	hunks := Hunks(Lines(a, numbered(5, map[int]string{3: "x"})), 1)
+	_, err := Apply(numbered(5, map[int]string{2: "z"}), hunks)
+	require.ErrorIs(t, err, ErrHunkMismatch)
+}
+
+func TestParseHunk(t *testing.T) {
+	t.Parallel()
+	h, err := ParseHunk(1, 1, "# comment\n line 2\n-line 3\n+x\n\n")
+	require.NoError(t, err)
+	require.Equal(t, 3, h.OldLines())
+	require.Equal(t, 3, h.NewLines())
+
+	_, err = ParseHunk(0, 0, "bogus")
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package diff
+
+import "slices"
+
+// Conflict markers written around the two sides of a conflicting change.
+const (
+	ConflictStart     = "<<<<<<<"
+	ConflictSeparator = "======="
+	ConflictEnd       = ">>>>>>>"
+)
+
+// Merge combines the changes ours and theirs each made to base. Where only one
+// side changed a region, or both made the same change, the change is taken;
+// where they made different changes, both are kept between conflict markers
+// labelled with oursLabel and theirsLabel. It returns the merged lines and the
+// number of conflicts.
+func Merge(base, ours, theirs []string, oursLabel, theirsLabel string) ([]string, int) {
+	oursMatch := matches(base, ours)
+	theirsMatch := matches(base, theirs)
+
+	var merged []string
+	conflicts := 0
+	i, a, b := 0, 0, 0
+	for i < len(base) || a < len(ours) || b < len(theirs) {
+		if i < len(base) && oursMatch[i] == a && theirsMatch[i] == b {
+			merged = append(merged, base[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+		// Find the next base line both sides kept; everything before it
+		// changed on at least one side.
+		k := i
+		for k < len(base) && (oursMatch[k] < 0 || theirsMatch[k] < 0) {
+			k++
+		}
+		oursEnd, theirsEnd := len(ours), len(theirs)
+		if k < len(base) {
+			oursEnd, theirsEnd = oursMatch[k], theirsMatch[k]
+		}
+		baseChunk, oursChunk, theirsChunk := base[i:k], ours[a:oursEnd], theirs[b:theirsEnd]
+		switch {
+		case slices.Equal(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case slices.Equal(theirsChunk, baseChunk), slices.Equal(oursChunk, theirsChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			merged = append(merged, ConflictStart+" "+oursLabel)
+			merged = append(merged, oursChunk...)
+			merged = append(merged, ConflictSeparator)
+			merged = append(merged, theirsChunk...)
+			merged = append(merged, ConflictEnd+" "+theirsLabel)
+			conflicts++
+		}
+		i, a, b = k, oursEnd, theirsEnd
+	}
+	return merged, conflicts
+}
+
+// matches returns, for each line of a, the index of the line of b it is kept
+// as, or -1 if it was deleted.
+func matches(a, b []string) []int {
+	match := make([]int, len(a))
+	for _, e := range Lines(a, b) {
+		switch e.Op {
+		case Equal:
+			match[e.OldLine] = e.NewLine
+		case Delete:
+			match[e.OldLine] = -1
+		}
+	}
+	return match
+}
@@ -0,0 +1,55 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	base := numbered(10, nil)
+
+	tests := map[string]struct {
+		ours, theirs []string
+		want         []string
+		conflicts    int
+	}{
+		"only ours changed": {
+			ours:   numbered(10, map[int]string{2: "ours"}),
+			theirs: base,
+			want:   numbered(10, map[int]string{2: "ours"}),
+		},
+		"separate changes": {
+			ours:   numbered(10, map[int]string{2: "ours"}),
+			theirs: numbered(10, map[int]string{8: "theirs"}),
+			want:   numbered(10, map[int]string{2: "ours", 8: "theirs"}),
+		},
+		"same change": {
+			ours:   numbered(10, map[int]string{5: "both"}),
+			theirs: numbered(10, map[int]string{5: "both"}),
+			want:   numbered(10, map[int]string{5: "both"}),
+		},
+		"insertions and deletions": {
+			ours:   append([]string{"first"}, base...),
+			theirs: base[:9],
+			want:   append([]string{"first"}, base[:9]...),
+		},
+		"conflict": {
+			ours:   numbered(10, map[int]string{5: "ours"}),
+			theirs: numbered(10, map[int]string{5: "theirs"}),
+			want: append(append(numbered(4, nil),
+				"<<<<<<< ours", "ours", "=======", "theirs", ">>>>>>> theirs"),
+				base[5:]...),
+			conflicts: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			merged, conflicts := Merge(base, tt.ours, tt.theirs, "ours", "theirs")
+			require.Equal(t, tt.want, merged)
+			require.Equal(t, tt.conflicts, conflicts)
+		})
+	}
+}
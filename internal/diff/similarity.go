@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rename detection settings. RenameThresholdKey is the config key for the
+// similarity, in percent, at which a deleted and an added file are taken to
+// be the same file renamed.
+const (
+	RenameThresholdKey     = "diff.renameThreshold"
+	DefaultRenameThreshold = 50
+)
+
+// Similarity returns how alike two versions of a file are, in percent: the
+// bytes of the lines they have in common, wherever those lines are, as a share
+// of the larger version. Only identical content scores 100.
+func Similarity(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return 100
+	}
+	counts := make(map[string]int)
+	for _, line := range bytes.SplitAfter(a, []byte("\n")) {
+		counts[string(line)]++
+	}
+	common := 0
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if counts[string(line)] > 0 {
+			counts[string(line)]--
+			common += len(line)
+		}
+	}
+	return min(common*100/max(len(a), len(b)), 99)
+}
+
+// ParseThreshold parses a similarity threshold written as a percentage, with
+// or without a trailing "%", such as "50" or "75%".
+func ParseThreshold(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n < 0 || n > 100 {
+		return 0, fmt.Errorf("invalid similarity %q, expected a percentage from 0 to 100", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"identical", "one\ntwo\n", "one\ntwo\n", 100},
+		{"both empty", "", "", 100},
+		{"one empty", "", "one\n", 0},
+		{"nothing in common", "one\n", "two\n", 0},
+		{"one line changed", "a\nb\nc\nd\n", "a\nb\nc\nx\n", 75},
+		{"lines moved", "one\ntwo\n", "two\none\n", 99},
+		{"line added", "one\ntwo\nthree\n", "one\ntwo\nthree\nfour\nfive\nsix\n", 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Similarity([]byte(tt.a), []byte(tt.b)))
+			require.Equal(t, tt.want, Similarity([]byte(tt.b), []byte(tt.a)))
+		})
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	t.Parallel()
+	for s, want := range map[string]int{"50": 50, "75%": 75, "0": 0, "100%": 100} {
+		got, err := ParseThreshold(s)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	for _, s := range []string{"", "abc", "101", "-1", "%"} {
+		_, err := ParseThreshold(s)
+		require.Error(t, err, s)
+	}
+}
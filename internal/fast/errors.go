@@ -0,0 +1,10 @@
+package fast
+
+import "errors"
+
+var (
+	ErrInvalidStream = errors.New("invalid fast-import stream")
+	ErrUnsupported   = errors.New("unsupported fast-import command")
+	ErrInvalidMarks  = errors.New("invalid marks file")
+	ErrUnknownMark   = errors.New("unknown mark")
+)
@@ -0,0 +1,315 @@
+package fast
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// ExportOptions controls an export.
+type ExportOptions struct {
+	Refs      []refs.Ref // Branches and tags to export, by full name, with the commit each points at
+	Exclude   []string   // Commits whose history is left out
+	Marks     Marks      // Marks from an earlier export; the blobs and commits they name are not written again
+	Committer string     // Identity written as the committer of commits with no recorded author, as "Name <email>"
+}
+
+// Export writes the history of the refs in opts to w as a fast-import stream
+// and returns every mark known at the end, including those passed in.
+//
+// trac records who wrote a change but not who committed it, so commits name
+// their author as the committer, or opts.Committer, or an empty identity, if
+// no author was recorded, with the time of the commit. Commits whose parent is
+// left out are written as root commits holding every file, and refs whose
+// history was written by an earlier export are reset to the mark it recorded.
+// Annotated tags are written as tag commands, without their signatures.
+func Export(ctx context.Context, l *layout.Layout, w io.Writer, opts ExportOptions) (Marks, error) {
+	ex := &exporter{
+		ctx:       ctx,
+		l:         l,
+		w:         bufio.NewWriter(w),
+		marks:     maps.Clone(opts.Marks),
+		marked:    make(map[string]int),
+		excluded:  make(map[string]bool),
+		committer: opts.Committer,
+	}
+	if ex.marks == nil {
+		ex.marks = make(Marks)
+	}
+	if ex.committer == "" {
+		ex.committer = "<>"
+	}
+	for num, hash := range ex.marks {
+		ex.marked[hash] = num
+		ex.next = max(ex.next, num)
+	}
+	for _, hash := range opts.Exclude {
+		if err := ex.exclude(hash); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range opts.Refs {
+		if err := ex.ref(ref); err != nil {
+			return nil, err
+		}
+	}
+	if err := ex.w.Flush(); err != nil {
+		return nil, err
+	}
+	return ex.marks, nil
+}
+
+type exporter struct {
+	ctx       context.Context
+	l         *layout.Layout
+	w         *bufio.Writer
+	marks     Marks
+	marked    map[string]int  // Mark of each exported blob and commit, by hash
+	excluded  map[string]bool // Commits whose history is left out
+	next      int             // Last mark used
+	committer string
+}
+
+// exclude leaves out hash and its ancestors.
+func (ex *exporter) exclude(hash string) error {
+	for hash != "" && !ex.excluded[hash] {
+		ex.excluded[hash] = true
+		c, err := commit.Load(hash, ex.l)
+		if err != nil {
+			return err
+		}
+		hash = c.Parent
+	}
+	return nil
+}
+
+// ref writes the history of ref, followed by a tag command if it is an
+// annotated tag.
+func (ex *exporter) ref(ref refs.Ref) error {
+	tip, err := commit.Peel(ref.Hash, ex.l)
+	if err != nil {
+		return err
+	}
+	if err := ex.history(ref.Name, tip, tip == ref.Hash); err != nil {
+		return err
+	}
+	if tip != ref.Hash {
+		return ex.tag(ref, tip)
+	}
+	return nil
+}
+
+// history writes the commits in the history of tip that have not been written
+// yet, oldest first, under ref, or with reset a reset of ref to tip if they
+// all have.
+func (ex *exporter) history(ref, tip string, reset bool) error {
+	var pending []string
+	for hash := tip; hash != "" && !ex.excluded[hash]; {
+		if _, ok := ex.marked[hash]; ok {
+			break
+		}
+		pending = append(pending, hash)
+		c, err := commit.Load(hash, ex.l)
+		if err != nil {
+			return err
+		}
+		hash = c.Parent
+	}
+	if len(pending) == 0 {
+		if num, ok := ex.marked[tip]; ok && reset {
+			fmt.Fprintf(ex.w, "reset %s\nfrom :%d\n\n", ref, num)
+		}
+		return nil
+	}
+	for _, hash := range slices.Backward(pending) {
+		if err := ex.commit(ref, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tag writes the annotated tag ref, about the commit tip. Its signature is
+// left out, as it does not cover the tag the importing side makes. A tag
+// without a recorded tagger names the committer identity, like commits do.
+func (ex *exporter) tag(ref refs.Ref, tip string) error {
+	num, ok := ex.marked[tip]
+	if !ok {
+		// The commit was left out, so there is nothing to tag.
+		return nil
+	}
+	t, err := commit.LoadTag(ref.Hash, ex.l)
+	if err != nil {
+		return err
+	}
+	tagger, when := ex.committer, t.Timestamp
+	if t.Tagger != nil {
+		tagger, when = t.Tagger.String(), t.Tagger.Time
+	}
+	message := t.Message + "\n"
+	fmt.Fprintf(ex.w, "tag %s\nfrom :%d\n", strings.TrimPrefix(ref.Name, refs.TagsPrefix), num)
+	fmt.Fprintf(ex.w, "tagger %s %s\n", tagger, formatTime(when))
+	fmt.Fprintf(ex.w, "data %d\n%s\n", len(message), message)
+	return nil
+}
+
+func (ex *exporter) commit(ref, hash string) error {
+	if err := ex.ctx.Err(); err != nil {
+		return err
+	}
+	c, err := commit.Load(hash, ex.l)
+	if err != nil {
+		return err
+	}
+	files, modes, err := snapshot(ex.l, c)
+	if err != nil {
+		return err
+	}
+	parentMark, hasParent := ex.marked[c.Parent]
+	parentFiles, parentModes := make(map[string]string), make(map[string]string)
+	if hasParent {
+		parent, err := commit.Load(c.Parent, ex.l)
+		if err != nil {
+			return err
+		}
+		if parentFiles, parentModes, err = snapshot(ex.l, parent); err != nil {
+			return err
+		}
+	}
+
+	// changed reports whether p has different content or mode than in the parent.
+	changed := func(p string) bool {
+		_, ok := parentFiles[p]
+		return !ok || files[p] != parentFiles[p] || filemode.Get(modes, p) != filemode.Get(parentModes, p)
+	}
+	paths := slices.Sorted(maps.Keys(files))
+	for _, p := range paths {
+		if blob := files[p]; changed(p) {
+			if err := ex.blob(blob); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !hasParent {
+		// Without a from command the commit would continue the ref as it
+		// stands in the importing repository.
+		fmt.Fprintf(ex.w, "reset %s\n", ref)
+	}
+	fmt.Fprintf(ex.w, "commit %s\n", ref)
+	fmt.Fprintf(ex.w, "mark :%d\n", ex.mark(hash))
+	if c.Author != nil {
+		fmt.Fprintf(ex.w, "author %s %s\n", c.Author, formatTime(c.Author.Time))
+	}
+	committer := ex.committer
+	if c.Author != nil {
+		committer = c.Author.String()
+	}
+	fmt.Fprintf(ex.w, "committer %s %s\n", committer, formatTime(c.Timestamp))
+	message := c.Message + "\n"
+	fmt.Fprintf(ex.w, "data %d\n%s", len(message), message)
+	if hasParent {
+		fmt.Fprintf(ex.w, "from :%d\n", parentMark)
+	}
+	for _, p := range slices.Sorted(maps.Keys(parentFiles)) {
+		if _, ok := files[p]; !ok {
+			fmt.Fprintf(ex.w, "D %s\n", quote(p))
+		}
+	}
+	for _, p := range paths {
+		if changed(p) {
+			fmt.Fprintf(ex.w, "M %s :%d %s\n", filemode.Get(modes, p), ex.marked[files[p]], quote(p))
+		}
+	}
+	_, err = ex.w.WriteString("\n")
+	return err
+}
+
+// blob writes the content of a blob that has not been written yet.
+func (ex *exporter) blob(hash string) error {
+	if _, ok := ex.marked[hash]; ok {
+		return nil
+	}
+	size, err := object.Size(ex.l, hash)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ex.w, "blob\nmark :%d\ndata %d\n", ex.mark(hash), size)
+	if err := object.WriteTo(ex.l, hash, ex.w); err != nil {
+		return err
+	}
+	_, err = ex.w.WriteString("\n")
+	return err
+}
+
+// mark assigns the next mark to hash.
+func (ex *exporter) mark(hash string) int {
+	ex.next++
+	ex.marks[ex.next] = hash
+	ex.marked[hash] = ex.next
+	return ex.next
+}
+
+// formatTime formats t as seconds since the epoch and a time zone offset.
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10) + " " + t.Format("-0700")
+}
+
+// quote quotes a path the way git fast-export does: C-style if it holds
+// special characters, and in plain quotes if it holds a space.
+func quote(p string) string {
+	var b strings.Builder
+	special := strings.HasPrefix(p, `"`)
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+			special = true
+		case c == '\n':
+			b.WriteString(`\n`)
+			special = true
+		case c == '\t':
+			b.WriteString(`\t`)
+			special = true
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+			special = true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if special || strings.Contains(p, " ") {
+		return `"` + b.String() + `"`
+	}
+	return p
+}
+
+// snapshot returns the files of a commit and the modes of those that are not
+// regular files, keyed by slash-separated path relative to the root, whatever
+// form its keys were recorded in.
+func snapshot(l *layout.Layout, c *commit.Commit) (files, modes map[string]string, err error) {
+	files = make(map[string]string, len(c.Changes))
+	modes = make(map[string]string, len(c.Modes))
+	for p, blob := range c.Changes {
+		rel, err := l.RelPath(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[rel] = blob
+		filemode.Set(modes, rel, filemode.Get(c.Modes, p))
+	}
+	return files, modes, nil
+}
@@ -0,0 +1,164 @@
+// Package fast reads and writes the fast-import stream format that git
+// fast-export writes and git fast-import reads, so that history can be moved
+// between trac and Git without either side linking the other's libraries.
+//
+// A trac commit has a single parent and records every file, so imported merge
+// commits keep their first parent only, with the content they had after the
+// merge. Regular and executable files and symbolic links keep their mode both
+// ways, and submodules are skipped.
+package fast
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// Marks maps the marks of a stream, the numbers it uses to refer to blobs and
+// commits it defines, to their hashes.
+type Marks map[int]string
+
+// ReadMarks reads a marks file, with a ":<mark> <hash>" line per mark.
+func ReadMarks(path string) (Marks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	marks := make(Marks)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		mark, hash, ok := strings.Cut(line, " ")
+		num, err := parseMark(mark)
+		if !ok || err != nil || !object.IsHash(hash) {
+			return nil, fmt.Errorf("%s:%d: %w: expected :<mark> <hash>", path, n, ErrInvalidMarks)
+		}
+		marks[num] = hash
+	}
+	return marks, scanner.Err()
+}
+
+// WriteMarks writes marks to a marks file in mark order.
+func WriteMarks(path string, marks Marks) error {
+	nums := make([]int, 0, len(marks))
+	for num := range marks {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	var b strings.Builder
+	for _, num := range nums {
+		fmt.Fprintf(&b, ":%d %s\n", num, marks[num])
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// parseMark parses a mark reference such as ":12".
+func parseMark(s string) (int, error) {
+	digits, ok := strings.CutPrefix(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid mark %q", s)
+	}
+	num, err := strconv.Atoi(digits)
+	if err != nil || num <= 0 {
+		return 0, fmt.Errorf("invalid mark %q", s)
+	}
+	return num, nil
+}
+
+// unquote decodes a path in the C-style quoting fast-import streams use for
+// paths with special characters.
+func unquote(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+		return "", fmt.Errorf("unterminated quoted path %s", s)
+	}
+	var b []byte
+	in := s[1 : len(s)-1]
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		if c != '\\' {
+			b = append(b, c)
+			continue
+		}
+		i++
+		if i == len(in) {
+			return "", fmt.Errorf("invalid escape in path %s", s)
+		}
+		switch c = in[i]; c {
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '\\', '"':
+			b = append(b, c)
+		case '0', '1', '2', '3':
+			if i+2 >= len(in) {
+				return "", fmt.Errorf("invalid escape in path %s", s)
+			}
+			n, err := strconv.ParseUint(in[i:i+3], 8, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in path %s", s)
+			}
+			b = append(b, byte(n))
+			i += 2
+		default:
+			return "", fmt.Errorf("invalid escape in path %s", s)
+		}
+	}
+	return string(b), nil
+}
+
+// splitPaths splits the arguments of a copy or rename: a source path, quoted
+// if it contains a space, followed by the destination path.
+func splitPaths(s string) (src, dst string, err error) {
+	if strings.HasPrefix(s, `"`) {
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) || end+1 >= len(s) || s[end+1] != ' ' {
+			return "", "", fmt.Errorf("invalid paths %q", s)
+		}
+		src, dst = s[:end+1], s[end+2:]
+	} else {
+		var ok bool
+		if src, dst, ok = strings.Cut(s, " "); !ok {
+			return "", "", fmt.Errorf("invalid paths %q", s)
+		}
+	}
+	if src, err = unquote(src); err != nil {
+		return "", "", err
+	}
+	if dst, err = unquote(dst); err != nil {
+		return "", "", err
+	}
+	return src, dst, nil
+}
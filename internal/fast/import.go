@@ -0,0 +1,784 @@
+package fast
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/checkout"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/internal/remote"
+)
+
+// nullHash is the all-zero Git object name streams use for "no commit".
+const nullHash = "0000000000000000000000000000000000000000"
+
+// ImportOptions controls an import.
+type ImportOptions struct {
+	Marks    Marks     // Marks from an earlier import, for streams that continue it
+	Force    bool      // Allow ref updates that are not fast-forwards
+	Progress io.Writer // Receives the messages of progress commands, when set
+}
+
+// Result describes what an import did.
+type Result struct {
+	Blobs   int
+	Commits int
+	Tags    int
+	Marks   Marks           // Every mark known at the end, including those passed in
+	Updates []remote.Update // How each ref the stream set was updated
+}
+
+// Import reads a fast-import stream from r, storing its blobs and commits in
+// l, and updates the branches and tags it sets once the whole stream has been
+// read. Branches and tags are only moved forward unless Force is set, and the
+// branch HEAD is on is only updated if it has no commits yet, in which case the
+// imported files are checked out.
+func Import(ctx context.Context, l *layout.Layout, r io.Reader, opts ImportOptions) (*Result, error) {
+	im := &importer{
+		ctx:    ctx,
+		l:      l,
+		r:      bufio.NewReader(r),
+		opts:   opts,
+		marks:  maps.Clone(opts.Marks),
+		refs:   make(map[string]string),
+		result: &Result{},
+	}
+	if im.marks == nil {
+		im.marks = make(Marks)
+	}
+	if err := im.run(); err != nil {
+		return nil, err
+	}
+	im.result.Marks = im.marks
+	if err := im.updateRefs(); err != nil {
+		return nil, err
+	}
+	return im.result, nil
+}
+
+type importer struct {
+	ctx    context.Context
+	l      *layout.Layout
+	r      *bufio.Reader
+	opts   ImportOptions
+	result *Result
+
+	lineNum int
+	pending *string // Line read ahead and pushed back
+
+	marks Marks
+	refs  map[string]string // Commit each ref was set to by the stream, empty when reset without one
+	order []string          // Refs in the order the stream first set them
+
+	// Tree of the last commit loaded or written, and the modes of its files
+	// that are not regular files, since commits usually build on the one before.
+	lastHash  string
+	lastTree  map[string]string
+	lastModes map[string]string
+}
+
+func (im *importer) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidStream, im.lineNum, fmt.Sprintf(format, args...))
+}
+
+// readLine returns the next line without its newline, or io.EOF at the end of the stream.
+func (im *importer) readLine() (string, error) {
+	if im.pending != nil {
+		line := *im.pending
+		im.pending = nil
+		return line, nil
+	}
+	line, err := im.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	im.lineNum++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (im *importer) unreadLine(line string) {
+	im.pending = &line
+}
+
+// optional reads the argument of an optional command, such as "mark :1",
+// returning false and leaving the stream alone if the next line is not one.
+func (im *importer) optional(command string) (string, bool, error) {
+	line, err := im.readLine()
+	if err == io.EOF {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	arg, ok := strings.CutPrefix(line, command+" ")
+	if !ok {
+		im.unreadLine(line)
+	}
+	return arg, ok, nil
+}
+
+// skipOptionalLF consumes the empty line that may follow data and some commands.
+func (im *importer) skipOptionalLF() error {
+	line, err := im.readLine()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		im.unreadLine(line)
+	}
+	return nil
+}
+
+func (im *importer) run() error {
+	var needDone bool
+	for {
+		if err := im.ctx.Err(); err != nil {
+			return err
+		}
+		line, err := im.readLine()
+		if err == io.EOF {
+			if needDone {
+				return im.errorf("stream ended without done")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case line == "blob":
+			err = im.blob()
+		case command == "commit":
+			err = im.commit(arg)
+		case command == "tag":
+			err = im.tag(arg)
+		case command == "reset":
+			err = im.reset(arg)
+		case line == "alias":
+			err = im.alias()
+		case command == "progress":
+			if im.opts.Progress != nil {
+				fmt.Fprintln(im.opts.Progress, arg)
+			}
+		case line == "checkpoint":
+			// Objects are stored as they are read and refs are updated at
+			// the end, so there is nothing to write out early.
+		case command == "feature":
+			switch arg {
+			case "done":
+				needDone = true
+			case "date-format=raw", "force":
+				im.opts.Force = im.opts.Force || arg == "force"
+			default:
+				return fmt.Errorf("%w: feature %s", ErrUnsupported, arg)
+			}
+		case command == "option":
+			// Options are for the importer the stream was written for.
+		case line == "done":
+			return nil
+		case command == "get-mark" || command == "cat-blob" || command == "ls":
+			return fmt.Errorf("%w: %s", ErrUnsupported, command)
+		default:
+			return im.errorf("unknown command %q", line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// data reads a data command, in either its counted or delimited form.
+func (im *importer) data() ([]byte, error) {
+	line, err := im.readLine()
+	if err != nil {
+		return nil, im.errorf("expected data")
+	}
+	arg, ok := strings.CutPrefix(line, "data ")
+	if !ok {
+		return nil, im.errorf("expected data, got %q", line)
+	}
+	if delim, ok := strings.CutPrefix(arg, "<<"); ok {
+		var b strings.Builder
+		for {
+			line, err := im.readLine()
+			if err != nil {
+				return nil, im.errorf("data not terminated by %s", delim)
+			}
+			if line == delim {
+				return []byte(b.String()), nil
+			}
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return nil, im.errorf("invalid data length %q", arg)
+	}
+	data, err := io.ReadAll(io.LimitReader(im.r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != n {
+		return nil, im.errorf("truncated data")
+	}
+	im.lineNum += strings.Count(string(data), "\n")
+	return data, im.skipOptionalLF()
+}
+
+// mark reads an optional mark command, returning 0 if there is none.
+func (im *importer) mark() (int, error) {
+	arg, ok, err := im.optional("mark")
+	if err != nil || !ok {
+		return 0, err
+	}
+	num, err := parseMark(arg)
+	if err != nil {
+		return 0, im.errorf("%v", err)
+	}
+	return num, nil
+}
+
+func (im *importer) blob() error {
+	num, err := im.mark()
+	if err != nil {
+		return err
+	}
+	if _, _, err := im.optional("original-oid"); err != nil {
+		return err
+	}
+	data, err := im.data()
+	if err != nil {
+		return err
+	}
+	hash, err := object.Write(im.l, data)
+	if err != nil {
+		return err
+	}
+	if num != 0 {
+		im.marks[num] = hash
+	}
+	im.result.Blobs++
+	return nil
+}
+
+func (im *importer) commit(ref string) error {
+	if err := im.checkRef(ref); err != nil {
+		return err
+	}
+	num, err := im.mark()
+	if err != nil {
+		return err
+	}
+	if _, _, err := im.optional("original-oid"); err != nil {
+		return err
+	}
+	var author *commit.Signature
+	if arg, ok, err := im.optional("author"); err != nil {
+		return err
+	} else if ok {
+		if author, err = parseIdent(arg); err != nil {
+			return im.errorf("%v", err)
+		}
+	}
+	arg, ok, err := im.optional("committer")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return im.errorf("commit without committer")
+	}
+	committer, err := parseIdent(arg)
+	if err != nil {
+		return im.errorf("%v", err)
+	}
+	if _, _, err := im.optional("encoding"); err != nil {
+		return err
+	}
+	message, err := im.data()
+	if err != nil {
+		return err
+	}
+
+	parent, reset := im.refs[ref]
+	if !reset {
+		parent, err = refs.Read(im.l, ref)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return err
+		}
+	}
+	if arg, ok, err := im.optional("from"); err != nil {
+		return err
+	} else if ok {
+		if parent, err = im.resolve(arg); err != nil {
+			return err
+		}
+	}
+	// Only the first parent of a merge is kept; the merged content is in the
+	// file commands that follow.
+	for {
+		_, ok, err := im.optional("merge")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	tree, modes, err := im.tree(parent)
+	if err != nil {
+		return err
+	}
+	if err := im.fileCommands(tree, modes); err != nil {
+		return err
+	}
+	if err := commit.CheckTree(tree); err != nil {
+		return im.errorf("%v", err)
+	}
+
+	c := &commit.Commit{
+		Parent:    parent,
+		Message:   strings.TrimRight(string(message), "\n"),
+		Timestamp: committer.Time,
+		Changes:   tree,
+		Modes:     filemode.Select(modes, tree),
+		Author:    author,
+	}
+	hash, err := c.Write(im.l)
+	if err != nil {
+		return err
+	}
+	im.lastHash, im.lastTree, im.lastModes = hash, tree, modes
+	if num != 0 {
+		im.marks[num] = hash
+	}
+	im.set(ref, hash)
+	im.result.Commits++
+	return nil
+}
+
+// fileCommands applies the file commands of a commit to tree and the modes of
+// its files.
+func (im *importer) fileCommands(tree, modes map[string]string) error {
+	for {
+		line, err := im.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch command {
+		case "M":
+			err = im.fileModify(tree, modes, arg)
+		case "D":
+			var p string
+			if p, err = im.path(arg); err == nil {
+				remove(tree, p)
+				remove(modes, p)
+			}
+		case "C", "R":
+			var src, dst string
+			if src, dst, err = splitPaths(arg); err != nil {
+				return im.errorf("%v", err)
+			}
+			if err := im.checkPaths(src, dst); err != nil {
+				return err
+			}
+			moved, movedModes := under(tree, src), under(modes, src)
+			if len(moved) == 0 {
+				return im.errorf("path not in commit: %s", src)
+			}
+			if command == "R" {
+				remove(tree, src)
+				remove(modes, src)
+			}
+			for p, hash := range moved {
+				tree[dst+strings.TrimPrefix(p, src)] = hash
+				filemode.Set(modes, dst+strings.TrimPrefix(p, src), filemode.Get(movedModes, p))
+			}
+		case "deleteall":
+			clear(tree)
+			clear(modes)
+		case "N":
+			// Notes are not supported, but inline ones must still be read past.
+			if strings.HasPrefix(arg, "inline ") {
+				_, err = im.data()
+			}
+		default:
+			if line != "" {
+				im.unreadLine(line)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (im *importer) fileModify(tree, modes map[string]string, arg string) error {
+	fields := strings.SplitN(arg, " ", 3)
+	if len(fields) != 3 {
+		return im.errorf("invalid file modify %q", arg)
+	}
+	mode, ref := fields[0], fields[1]
+	p, err := im.path(fields[2])
+	if err != nil {
+		return err
+	}
+	var hash string
+	switch ref {
+	case "inline":
+		data, err := im.data()
+		if err != nil {
+			return err
+		}
+		if hash, err = object.Write(im.l, data); err != nil {
+			return err
+		}
+	default:
+		if strings.HasPrefix(ref, ":") {
+			if hash, err = im.lookupMark(ref); err != nil {
+				return err
+			}
+		} else if object.IsHash(ref) && object.Has(im.l, ref) {
+			hash = ref
+		} else if mode != "160000" {
+			return im.errorf("cannot refer to object %s; the stream must define it", ref)
+		}
+	}
+	switch mode {
+	case "100644", "644":
+		tree[p] = hash
+		filemode.Set(modes, p, filemode.Regular)
+	case "100755", "755":
+		tree[p] = hash
+		filemode.Set(modes, p, filemode.Executable)
+	case "120000":
+		tree[p] = hash
+		filemode.Set(modes, p, filemode.Symlink)
+	case "160000":
+		// Submodules have no trac equivalent.
+	default:
+		return im.errorf("unsupported file mode %s for %s", mode, p)
+	}
+	return nil
+}
+
+// path decodes and checks a path in a file command.
+func (im *importer) path(s string) (string, error) {
+	p, err := unquote(s)
+	if err != nil {
+		return "", im.errorf("%v", err)
+	}
+	return p, im.checkPaths(p)
+}
+
+// checkPaths makes sure paths are clean and stay inside the repository.
+func (im *importer) checkPaths(paths ...string) error {
+	for _, p := range paths {
+		first, _, _ := strings.Cut(p, "/")
+		if p == "" || path.Clean(p) != p || path.IsAbs(p) || first == ".." || first == ".trac" {
+			return im.errorf("invalid path %q", p)
+		}
+	}
+	return nil
+}
+
+// under returns the files in tree at p or below the directory p.
+func under(tree map[string]string, p string) map[string]string {
+	files := make(map[string]string)
+	for name, hash := range tree {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			files[name] = hash
+		}
+	}
+	return files
+}
+
+func remove(tree map[string]string, p string) {
+	for name := range under(tree, p) {
+		delete(tree, name)
+	}
+}
+
+// tree returns a copy of the files of a commit and the modes of those that
+// are not regular files, keyed by slash-separated paths relative to the root,
+// or an empty tree for no commit.
+func (im *importer) tree(hash string) (files, modes map[string]string, err error) {
+	if hash == "" {
+		return make(map[string]string), make(map[string]string), nil
+	}
+	if hash == im.lastHash {
+		return maps.Clone(im.lastTree), maps.Clone(im.lastModes), nil
+	}
+	c, err := commit.Load(hash, im.l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshot(im.l, c)
+}
+
+func (im *importer) tag(name string) error {
+	ref := refs.Tag(name)
+	if err := im.checkRef(ref); err != nil {
+		return err
+	}
+	num, err := im.mark()
+	if err != nil {
+		return err
+	}
+	arg, ok, err := im.optional("from")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return im.errorf("tag %s without from", name)
+	}
+	hash, err := im.resolve(arg)
+	if err != nil {
+		return err
+	}
+	if hash, err = commit.Peel(hash, im.l); err != nil {
+		return err
+	}
+	c, err := commit.Load(hash, im.l)
+	if err != nil {
+		return im.errorf("tag %s: %v", name, err)
+	}
+	if _, _, err := im.optional("original-oid"); err != nil {
+		return err
+	}
+	t := &commit.Tag{Object: hash, Name: name, Timestamp: c.Timestamp}
+	if arg, ok, err := im.optional("tagger"); err != nil {
+		return err
+	} else if ok {
+		tagger, err := parseIdent(arg)
+		if err != nil {
+			return im.errorf("%v", err)
+		}
+		// An empty identity, as fast-export writes for tags that
+		// recorded none, only carries the time.
+		if t.Timestamp = tagger.Time; tagger.Name != "" || tagger.Email != "" {
+			t.Tagger = tagger
+		}
+	}
+	message, err := im.data()
+	if err != nil {
+		return err
+	}
+	t.Message = strings.TrimRight(string(message), "\n")
+	tagHash, err := t.Write(im.l)
+	if err != nil {
+		return err
+	}
+	if num != 0 {
+		im.marks[num] = tagHash
+	}
+	im.set(ref, tagHash)
+	im.result.Tags++
+	return nil
+}
+
+func (im *importer) reset(ref string) error {
+	if err := im.checkRef(ref); err != nil {
+		return err
+	}
+	hash := ""
+	if arg, ok, err := im.optional("from"); err != nil {
+		return err
+	} else if ok {
+		if hash, err = im.resolve(arg); err != nil {
+			return err
+		}
+	}
+	im.set(ref, hash)
+	return im.skipOptionalLF()
+}
+
+func (im *importer) alias() error {
+	num, err := im.mark()
+	if err != nil {
+		return err
+	}
+	arg, ok, err := im.optional("to")
+	if err != nil {
+		return err
+	}
+	if num == 0 || !ok {
+		return im.errorf("alias needs a mark and to")
+	}
+	hash, err := im.resolve(arg)
+	if err != nil {
+		return err
+	}
+	im.marks[num] = hash
+	return nil
+}
+
+func (im *importer) checkRef(ref string) error {
+	if !strings.HasPrefix(ref, "refs/") || refs.CheckName(strings.TrimPrefix(ref, "refs/")) != nil {
+		return im.errorf("invalid ref name %q", ref)
+	}
+	return nil
+}
+
+func (im *importer) set(ref, hash string) {
+	if _, ok := im.refs[ref]; !ok {
+		im.order = append(im.order, ref)
+	}
+	im.refs[ref] = hash
+}
+
+func (im *importer) lookupMark(s string) (string, error) {
+	num, err := parseMark(s)
+	if err != nil {
+		return "", im.errorf("%v", err)
+	}
+	hash, ok := im.marks[num]
+	if !ok {
+		return "", fmt.Errorf("%w: line %d: %s", ErrUnknownMark, im.lineNum, s)
+	}
+	return hash, nil
+}
+
+// resolve returns the commit a commit-ish in the stream refers to: a mark, a
+// ref the stream has set, or any revision of the repository. The null object
+// name means no commit.
+func (im *importer) resolve(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, ":"):
+		return im.lookupMark(s)
+	case s == nullHash:
+		return "", nil
+	}
+	if hash, ok := im.refs[s]; ok && hash != "" {
+		return hash, nil
+	}
+	hash, err := commit.Resolve(s, im.l)
+	if err != nil {
+		return "", fmt.Errorf("line %d: %w", im.lineNum, err)
+	}
+	return hash, nil
+}
+
+// parseIdent parses "Name <email> <seconds> <offset>", the raw date format.
+func parseIdent(s string) (*commit.Signature, error) {
+	lt := strings.IndexByte(s, '<')
+	gt := strings.LastIndexByte(s, '>')
+	if lt < 0 || gt < lt {
+		return nil, fmt.Errorf("invalid identity %q", s)
+	}
+	when := strings.Fields(s[gt+1:])
+	if len(when) != 2 {
+		return nil, fmt.Errorf("invalid date in %q", s)
+	}
+	secs, err := strconv.ParseInt(when[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date in %q", s)
+	}
+	zone, err := parseZone(when[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone in %q", s)
+	}
+	return &commit.Signature{
+		Name:  strings.TrimSpace(s[:lt]),
+		Email: s[lt+1 : gt],
+		Time:  time.Unix(secs, 0).In(zone),
+	}, nil
+}
+
+// parseZone parses a time zone offset such as +0100.
+func parseZone(s string) (*time.Location, error) {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return nil, fmt.Errorf("invalid offset %q", s)
+	}
+	hours, err1 := strconv.Atoi(s[1:3])
+	minutes, err2 := strconv.Atoi(s[3:])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("invalid offset %q", s)
+	}
+	offset := hours*3600 + minutes*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone("", offset), nil
+}
+
+// updateRefs moves the refs the stream set, checking out the files of the
+// branch HEAD is on if it had no commits before.
+func (im *importer) updateRefs() error {
+	current, _, err := refs.ReadHead(im.l)
+	if err != nil {
+		return err
+	}
+	for _, ref := range im.order {
+		hash := im.refs[ref]
+		if hash == "" {
+			continue
+		}
+		old, err := refs.Read(im.l, ref)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return err
+		}
+		u := remote.Update{Dst: ref, Old: old, New: hash}
+		switch {
+		case old == hash:
+			u.Status = remote.UpToDate
+		case ref == current && old != "":
+			u.Status = remote.CurrentBranch
+		case old == "":
+			u.Status = remote.Created
+		default:
+			ff, err := graph.IsAncestor(im.l, old, hash)
+			if err != nil {
+				return err
+			}
+			switch {
+			case ff:
+				u.Status = remote.FastForward
+			case im.opts.Force:
+				u.Status = remote.Forced
+			default:
+				u.Status = remote.NonFastForward
+			}
+		}
+		im.result.Updates = append(im.result.Updates, u)
+		if u.Status.Rejected() || u.Status == remote.UpToDate {
+			continue
+		}
+		if ref == current {
+			if err := checkout.Commit(im.l, hash); err != nil {
+				return err
+			}
+		}
+		if err := refs.Write(im.l, ref, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
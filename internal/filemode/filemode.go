@@ -0,0 +1,140 @@
+// Package filemode describes what kind of file an entry of a commit or the
+// index is: a regular file, an executable file or a symbolic link. Modes are
+// written as the octal strings Git uses for them in trees and fast-import
+// streams.
+//
+// Commits and the index record modes in a map next to their files, keyed the
+// same way, holding only the entries that are not regular files. Content
+// recorded before modes were tracked therefore reads back as regular files.
+// The content of a symbolic link is its target.
+package filemode
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/utils"
+)
+
+const (
+	Regular    = "100644"
+	Executable = "100755"
+	Symlink    = "120000"
+	Dir        = "040000" // Never recorded; returned for directories found where a file is tracked
+)
+
+// Key is the config key that, when set to false, makes trac ignore the
+// executable bit of files in the working tree: changes to it are not shown,
+// and adding a file keeps the mode it is already staged with. This is useful
+// on file systems that do not keep the bit.
+const Key = "core.fileMode"
+
+// Of returns the mode of a file described by info, which must come from
+// os.Lstat or a directory walk so that symbolic links are not followed.
+func Of(info fs.FileInfo) string {
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return Symlink
+	case info.IsDir():
+		return Dir
+	case info.Mode()&0111 != 0:
+		return Executable
+	}
+	return Regular
+}
+
+// Valid reports whether a file can be recorded with mode.
+func Valid(mode string) bool {
+	return mode == Regular || mode == Executable || mode == Symlink
+}
+
+// Get returns the mode of the entry key in a map of modes.
+func Get(modes map[string]string, key string) string {
+	if mode, ok := modes[key]; ok {
+		return mode
+	}
+	return Regular
+}
+
+// Set records the mode of the entry key in a map of modes, which must not be nil.
+func Set(modes map[string]string, key, mode string) {
+	if mode == Regular || mode == "" {
+		delete(modes, key)
+		return
+	}
+	modes[key] = mode
+}
+
+// Select returns the modes of the entries of files, or nil if they are all
+// regular files.
+func Select(modes, files map[string]string) map[string]string {
+	var selected map[string]string
+	for key, mode := range modes {
+		if _, ok := files[key]; ok && mode != Regular {
+			if selected == nil {
+				selected = make(map[string]string)
+			}
+			selected[key] = mode
+		}
+	}
+	return selected
+}
+
+// Read returns the content of the file at path and its mode, without
+// following symbolic links. Reading a directory is an error.
+func Read(path string) (data []byte, mode string, err error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if mode = Of(info); mode == Symlink {
+		target, err := os.Readlink(path)
+		return []byte(target), mode, err
+	}
+	data, err = os.ReadFile(path)
+	return data, mode, err
+}
+
+// Stat returns the mode of the file at path and the hash its content is
+// stored under, without following symbolic links. The hash of a directory is
+// empty.
+func Stat(path string) (hash, mode string, err error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", "", err
+	}
+	switch mode = Of(info); mode {
+	case Dir:
+		return "", mode, nil
+	case Symlink:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", "", err
+		}
+		return utils.HashBytes([]byte(target)), mode, nil
+	}
+	hash, err = utils.HashFile(path)
+	return hash, mode, err
+}
+
+// Ignore returns the mode to compare a file in the working tree with its
+// entry by when core.fileMode is false: the executable bit of the entry is
+// taken in place of the file's own.
+func Ignore(mode, recorded string) string {
+	if (mode == Regular || mode == Executable) && (recorded == Regular || recorded == Executable) {
+		return recorded
+	}
+	return mode
+}
+
+// Enabled reports whether the executable bit of files in the working tree is
+// tracked, i.e. core.fileMode is not false.
+func Enabled(l *layout.Layout) (bool, error) {
+	cfg, err := config.Load(l)
+	if err != nil {
+		return false, err
+	}
+	return cfg.Bool(Key, true)
+}
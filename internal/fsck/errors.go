@@ -0,0 +1,5 @@
+package fsck
+
+import "errors"
+
+var ErrProblemsFound = errors.New("object database check failed")
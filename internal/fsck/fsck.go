@@ -0,0 +1,293 @@
+package fsck
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// Kinds of problems found in the object database, in order of severity.
+const (
+	Corrupt  = "corrupt"  // Object content does not hash to its name
+	Invalid  = "invalid"  // Object is referenced as a commit but cannot be parsed as one
+	Missing  = "missing"  // Object is referenced but does not exist
+	Dangling = "dangling" // Object exists but nothing refers to it
+)
+
+// Types of objects.
+const (
+	TypeCommit = "commit"
+	TypeTag    = "tag"
+	TypeBlob   = "blob"
+	TypeObject = "object" // Type could not be determined
+)
+
+// Problem is an issue found in the object database.
+type Problem struct {
+	Kind   string
+	Type   string
+	Hash   string
+	Detail string
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%s %s %s", p.Kind, p.Type, p.Hash)
+	if p.Detail != "" {
+		s += " (" + p.Detail + ")"
+	}
+	return s
+}
+
+// Report is the outcome of checking the object database.
+type Report struct {
+	Objects  int // Number of objects checked
+	Problems []Problem
+}
+
+// Errors returns the number of problems that indicate damage to the repository.
+// Dangling objects are harmless and not counted.
+func (r *Report) Errors() int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Kind != Dangling {
+			n++
+		}
+	}
+	return n
+}
+
+// Dangling returns the dangling objects found.
+func (r *Report) Dangling() []Problem {
+	var dangling []Problem
+	for _, p := range r.Problems {
+		if p.Kind == Dangling {
+			dangling = append(dangling, p)
+		}
+	}
+	return dangling
+}
+
+// checker holds the state of a single check.
+type checker struct {
+	l         *layout.Layout
+	report    *Report
+	objects   map[string]bool // Every object in the database
+	corrupt   map[string]bool // Objects whose content does not match their name
+	reachable map[string]bool // Objects reachable from HEAD, any ref, bisect state or the index
+}
+
+// Check rehashes every object, walks history from all roots and the index,
+// and reports missing, corrupt, invalid and dangling objects.
+func Check(l *layout.Layout) (*Report, error) {
+	hashes, err := object.List(l)
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{
+		l:         l,
+		report:    &Report{Objects: len(hashes)},
+		objects:   make(map[string]bool, len(hashes)),
+		corrupt:   make(map[string]bool),
+		reachable: make(map[string]bool),
+	}
+	for _, hash := range hashes {
+		c.objects[hash] = true
+		err := object.Verify(l, hash)
+		if errors.Is(err, object.ErrCorrupt) {
+			c.corrupt[hash] = true
+			c.add(Corrupt, TypeObject, hash, "")
+			continue
+		}
+		// Missing chunks are reported when walking the blobs that refer to them.
+		if err != nil && !errors.Is(err, object.ErrMissing) {
+			return nil, err
+		}
+	}
+
+	roots, err := graph.Roots(l)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		c.walk(root)
+	}
+	if err := c.checkIndex(); err != nil {
+		return nil, err
+	}
+	if err := c.findDangling(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(c.report.Problems, func(a, b Problem) int {
+		kinds := []string{Corrupt, Invalid, Missing, Dangling}
+		return cmp.Or(
+			cmp.Compare(slices.Index(kinds, a.Kind), slices.Index(kinds, b.Kind)),
+			cmp.Compare(a.Hash, b.Hash),
+		)
+	})
+	return c.report, nil
+}
+
+func (c *checker) add(kind, objectType, hash, detail string) {
+	c.report.Problems = append(c.report.Problems, Problem{Kind: kind, Type: objectType, Hash: hash, Detail: detail})
+}
+
+// walk follows the parent chain from hash, checking each commit and the blobs it refers to.
+// If hash is an annotated tag, the walk starts from the commit it is about.
+func (c *checker) walk(hash string) {
+	for hash != "" && !c.reachable[hash] {
+		c.reachable[hash] = true
+		if !c.objects[hash] {
+			c.add(Missing, TypeCommit, hash, "")
+			return
+		}
+		if c.corrupt[hash] {
+			return
+		}
+		data, err := object.Read(c.l, hash)
+		if err != nil {
+			c.add(Missing, TypeCommit, hash, err.Error())
+			return
+		}
+		cm, err := commit.Parse(data)
+		if err != nil {
+			if tag, tagErr := commit.ParseTag(data); tagErr == nil {
+				hash = tag.Object
+				continue
+			}
+			c.add(Invalid, TypeCommit, hash, err.Error())
+			return
+		}
+		for _, path := range slices.Sorted(maps.Keys(cm.Changes)) {
+			blob := cm.Changes[path]
+			if c.reachable[blob] {
+				continue
+			}
+			c.checkBlob(blob, fmt.Sprintf("%s in commit %s", path, hash[:8]))
+		}
+		hash = cm.Parent
+	}
+}
+
+func (c *checker) checkIndex() error {
+	idx := index.New()
+	if err := idx.Load(c.l); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, path := range slices.Sorted(maps.Keys(idx.Staged)) {
+		blob := idx.Staged[path]
+		if !c.reachable[blob] {
+			c.checkBlob(blob, fmt.Sprintf("%s in index", path))
+		}
+	}
+	return nil
+}
+
+// checkBlob marks a blob and its chunks as reachable and reports any that are missing.
+// detail describes where the blob is referenced from.
+func (c *checker) checkBlob(blob, detail string) {
+	c.reachable[blob] = true
+	if !c.objects[blob] {
+		c.add(Missing, TypeBlob, blob, detail)
+		return
+	}
+	if c.corrupt[blob] {
+		return
+	}
+	chunks, err := object.Chunks(c.l, blob)
+	if err != nil {
+		c.add(Invalid, TypeBlob, blob, err.Error())
+		return
+	}
+	for _, chunk := range chunks {
+		if c.reachable[chunk] {
+			continue
+		}
+		c.reachable[chunk] = true
+		if !c.objects[chunk] {
+			c.add(Missing, TypeBlob, chunk, "chunk of "+blob[:8])
+		}
+	}
+}
+
+// findDangling reports unreachable objects that are not referred to by other unreachable commits.
+func (c *checker) findDangling() error {
+	unreachable := make(map[string]string) // hash -> type
+	referenced := make(map[string]bool)
+	for hash := range c.objects {
+		if c.reachable[hash] || c.corrupt[hash] {
+			continue
+		}
+		data, err := object.ReadRaw(c.l, hash)
+		if err != nil {
+			return err
+		}
+		if tag, err := commit.ParseTag(data); err == nil {
+			unreachable[hash] = TypeTag
+			referenced[tag.Object] = true
+			continue
+		}
+		cm, err := commit.Parse(data)
+		if err != nil {
+			unreachable[hash] = TypeBlob
+			chunks, err := object.Chunks(c.l, hash)
+			if err != nil {
+				return err
+			}
+			for _, chunk := range chunks {
+				referenced[chunk] = true
+			}
+			continue
+		}
+		unreachable[hash] = TypeCommit
+		referenced[cm.Parent] = true
+		for _, blob := range cm.Changes {
+			referenced[blob] = true
+		}
+	}
+	for hash, objectType := range unreachable {
+		if !referenced[hash] {
+			c.add(Dangling, objectType, hash, "")
+		}
+	}
+	return nil
+}
+
+// WriteLostFound saves dangling objects to the lost-found directory. Dangling
+// commits are recorded by hash under commit/, other objects are copied to other/.
+func WriteLostFound(l *layout.Layout, dangling []Problem) error {
+	for _, p := range dangling {
+		var data []byte
+		dir := filepath.Join(l.LostFound, "other")
+		if p.Type == TypeCommit {
+			dir = filepath.Join(l.LostFound, "commit")
+			data = []byte(p.Hash + "\n")
+		} else {
+			var err error
+			if data, err = object.Read(l, p.Hash); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, p.Hash), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
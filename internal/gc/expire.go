@@ -0,0 +1,53 @@
+package gc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// units maps the names accepted in relative expiry dates to their length.
+var units = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// ParseExpire parses an expiry date relative to now. It accepts "now", "never",
+// relative dates such as "2.weeks.ago" or "3 days ago", Go durations such as
+// "72h", and absolute dates in RFC 3339 or YYYY-MM-DD format.
+//
+// Objects older than the returned time may be pruned. "never" returns the zero
+// time, which no object is older than.
+func ParseExpire(s string, now time.Time) (time.Time, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "now", "all":
+		return now, nil
+	case "never":
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == '.' || r == ' ' })
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		unit, ok := units[strings.TrimSuffix(fields[1], "s")]
+		if err == nil && ok && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry date %q", s)
+}
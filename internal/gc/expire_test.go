@@ -0,0 +1,39 @@
+package gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpire(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{input: "now", expected: now},
+		{input: "never", expected: time.Time{}},
+		{input: "2.weeks.ago", expected: now.Add(-14 * 24 * time.Hour)},
+		{input: "1 day ago", expected: now.Add(-24 * time.Hour)},
+		{input: "3.hours.ago", expected: now.Add(-3 * time.Hour)},
+		{input: "90m", expected: now.Add(-90 * time.Minute)},
+		{input: "2024-06-01", expected: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2024-06-01T10:00:00Z", expected: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ParseExpire(tt.input, now)
+			require.NoError(t, err)
+			require.True(t, tt.expected.Equal(actual), "expected %s, got %s", tt.expected, actual)
+		})
+	}
+
+	_, err := ParseExpire("2.fortnights.ago", now)
+	require.Error(t, err)
+	_, err = ParseExpire("yesterday-ish", now)
+	require.Error(t, err)
+}
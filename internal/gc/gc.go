@@ -0,0 +1,171 @@
+package gc
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// DefaultExpire is the grace period gc gives unreachable objects before pruning them.
+const DefaultExpire = "2.weeks.ago"
+
+// Options controls which objects Prune removes.
+type Options struct {
+	Expire time.Time // Only prune objects last modified before this time
+	DryRun bool      // Report what would be pruned without removing anything
+}
+
+// Result describes what Prune removed.
+type Result struct {
+	Pruned []string // Hashes of the pruned objects
+	Bytes  int64    // Space reclaimed
+}
+
+// Prune removes objects in the object store that are not reachable from HEAD,
+// any ref, bisect state or the index (see graph.Reachable) and are older than
+// the expiry time. The grace period protects objects that are being written by
+// another command and are not referenced yet. Packed objects are left alone. Leftover temporary files from interrupted writes
+// are removed as well.
+func Prune(l *layout.Layout, opts Options) (*Result, error) {
+	reachable, err := graph.Reachable(l)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := object.ListLoose(l)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for _, hash := range hashes {
+		if reachable[hash] {
+			continue
+		}
+		removed, err := pruneObject(l, hash, opts, result)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			result.Pruned = append(result.Pruned, hash)
+		}
+	}
+	if err := pruneTemporary(l, opts, result); err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		if err := removeEmptyDirs(l); err != nil {
+			return nil, err
+		}
+		if err := compact(l); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// pruneObject removes an object from the store if it is older than the expiry
+// time and records the space reclaimed.
+func pruneObject(l *layout.Layout, hash string, opts Options, result *Result) (bool, error) {
+	modTime, err := object.ModTime(l, hash)
+	if err != nil {
+		return false, err
+	}
+	if !modTime.Before(opts.Expire) {
+		return false, nil
+	}
+	data, err := object.ReadRaw(l, hash)
+	if err != nil {
+		return false, err
+	}
+	if !opts.DryRun {
+		if err := object.Delete(l, hash); err != nil {
+			return false, err
+		}
+	}
+	result.Bytes += int64(len(data))
+	return true, nil
+}
+
+// compact reclaims the space left behind by deleted objects in stores that need it.
+func compact(l *layout.Layout) error {
+	s, err := object.OpenStore(l)
+	if err != nil {
+		return err
+	}
+	if c, ok := s.(object.Compacter); ok {
+		return c.Compact()
+	}
+	return nil
+}
+
+// pruneFile removes path if it is older than the expiry time and records the space reclaimed.
+func pruneFile(path string, opts Options, result *Result) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.ModTime().Before(opts.Expire) {
+		return false, nil
+	}
+	if !opts.DryRun {
+		if err := os.Remove(path); err != nil {
+			return false, err
+		}
+	}
+	result.Bytes += info.Size()
+	return true, nil
+}
+
+func pruneTemporary(l *layout.Layout, opts Options, result *Result) error {
+	for _, dir := range []string{l.Objects, l.Packs} {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), "tmp-") {
+				continue
+			}
+			if _, err := pruneFile(filepath.Join(dir, entry.Name()), opts, result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes fan-out directories left empty by pruning.
+func removeEmptyDirs(l *layout.Layout) error {
+	entries, err := os.ReadDir(l.Objects)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) != 2 {
+			continue
+		}
+		dir := filepath.Join(l.Objects, entry.Name())
+		contents, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		if len(contents) == 0 {
+			if err := os.Remove(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package gc
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/pack"
+)
+
+// Default delta search parameters for repacking.
+const (
+	DefaultWindow = 10
+	DefaultDepth  = 50
+)
+
+// RepackOptions controls how objects are repacked.
+type RepackOptions struct {
+	Window int                    // Number of preceding objects to try as delta bases
+	Depth  int                    // Maximum length of a delta chain
+	All    bool                   // Include objects that are already packed, replacing existing packs
+	Delete bool                   // Remove loose objects and packs made redundant by the new pack
+	Filter func(hash string) bool // When set, only objects it accepts are packed
+}
+
+// sortable is an object to be packed along with the properties used to order it.
+type sortable struct {
+	hash     string
+	isCommit bool
+	name     string // Base name of a path the object is stored under, for blobs
+	size     int
+}
+
+// Repack writes loose objects, and packed objects with All, into a new pack.
+// It returns nil stats when there was nothing to pack.
+//
+// Objects are ordered so that likely delta bases are close together: commits
+// first, then blobs grouped by file name from largest to smallest, since
+// removing content from a larger version is cheaper to encode than adding it.
+func Repack(l *layout.Layout, opts RepackOptions) (*pack.Stats, error) {
+	hashes, err := object.ListLoose(l)
+	if err != nil {
+		return nil, err
+	}
+	loose := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		loose[hash] = true
+	}
+	var oldPacks []*pack.Pack
+	if opts.All {
+		if oldPacks, err = object.Packs(l); err != nil {
+			return nil, err
+		}
+		packed, err := object.ListPacked(l)
+		if err != nil {
+			return nil, err
+		}
+		for hash := range packed {
+			if !loose[hash] {
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	if opts.Filter != nil {
+		hashes = slices.DeleteFunc(hashes, func(hash string) bool { return !opts.Filter(hash) })
+	}
+
+	var stats *pack.Stats
+	if len(hashes) > 0 {
+		objects, err := sortObjects(l, hashes)
+		if err != nil {
+			return nil, err
+		}
+		ordered := make([]string, len(objects))
+		for i, o := range objects {
+			ordered[i] = o.hash
+		}
+		read := func(hash string) ([]byte, error) { return object.ReadRaw(l, hash) }
+		stats, err = pack.Write(l.Packs, ordered, read, pack.Options{Window: opts.Window, Depth: opts.Depth})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.Delete {
+		return stats, nil
+	}
+	for _, p := range oldPacks {
+		if stats != nil && filepath.Base(p.Path()) == stats.Name+pack.PackExt {
+			continue
+		}
+		// Remove the index first so readers never find an index without its pack.
+		idxPath := strings.TrimSuffix(p.Path(), pack.PackExt) + pack.IndexExt
+		for _, path := range []string{idxPath, p.Path()} {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	for _, hash := range hashes {
+		if !loose[hash] {
+			continue
+		}
+		if err := object.Delete(l, hash); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := removeEmptyDirs(l); err != nil {
+		return nil, err
+	}
+	return stats, compact(l)
+}
+
+func sortObjects(l *layout.Layout, hashes []string) ([]sortable, error) {
+	names, err := objectNames(l)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]sortable, 0, len(hashes))
+	for _, hash := range hashes {
+		data, err := object.ReadRaw(l, hash)
+		if err != nil {
+			return nil, err
+		}
+		name, named := names[hash]
+		_, parseErr := commit.Parse(data)
+		objects = append(objects, sortable{
+			hash:     hash,
+			isCommit: !named && parseErr == nil,
+			name:     name,
+			size:     len(data),
+		})
+	}
+	slices.SortFunc(objects, func(a, b sortable) int {
+		if a.isCommit != b.isCommit {
+			if a.isCommit {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(
+			cmp.Compare(a.name, b.name),
+			cmp.Compare(b.size, a.size),
+			cmp.Compare(a.hash, b.hash),
+		)
+	})
+	return objects, nil
+}
+
+// objectNames maps blobs reachable from the roots and the index to the base name of a path they are stored under.
+func objectNames(l *layout.Layout) (map[string]string, error) {
+	names := make(map[string]string)
+	roots, err := graph.Roots(l)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		hash, err := commit.Peel(root, l)
+		if err != nil {
+			return nil, err
+		}
+		for hash != "" && !seen[hash] {
+			seen[hash] = true
+			c, err := commit.Load(hash, l)
+			if err != nil {
+				return nil, err
+			}
+			for path, blob := range c.Changes {
+				if _, ok := names[blob]; !ok {
+					names[blob] = filepath.Base(path)
+				}
+			}
+			hash = c.Parent
+		}
+	}
+	idx := index.New()
+	if err := idx.Load(l); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for path, blob := range idx.Staged {
+		if _, ok := names[blob]; !ok {
+			names[blob] = filepath.Base(path)
+		}
+	}
+	return names, nil
+}
+
+// UnpackUnreachable writes unreachable packed objects that are newer than the
+// expiry time back out as loose objects, keeping the modification time of their
+// pack. This lets a full repack drop unreachable objects from packs without
+// cutting their grace period short.
+func UnpackUnreachable(l *layout.Layout, expire time.Time) error {
+	reachable, err := graph.Reachable(l)
+	if err != nil {
+		return err
+	}
+	packed, err := object.ListPacked(l)
+	if err != nil {
+		return err
+	}
+	hashes, err := object.ListLoose(l)
+	if err != nil {
+		return err
+	}
+	loose := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		loose[hash] = true
+	}
+	for hash, modTime := range packed {
+		if reachable[hash] || loose[hash] || modTime.Before(expire) {
+			continue
+		}
+		data, err := object.ReadRaw(l, hash)
+		if err != nil {
+			return err
+		}
+		if err := object.WriteRaw(l, hash, data, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+
+	"github.com/lucasrod16/trac/internal/bisect"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Roots returns the commits that history is reachable from: HEAD, every branch
+// and tag, and any commits recorded by a bisect session in progress. Tags that
+// are annotated are returned as the hash of the tag object; see commit.Peel.
+func Roots(l *layout.Layout) ([]string, error) {
+	var roots []string
+	head, err := commit.GetParentHash(l)
+	if err != nil {
+		return nil, err
+	}
+	if head != "" {
+		roots = append(roots, head)
+	}
+	all, err := refs.List(l, "refs/")
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range all {
+		if !slices.Contains(roots, ref.Hash) {
+			roots = append(roots, ref.Hash)
+		}
+	}
+	state, err := bisect.Load(l)
+	if err != nil && !errors.Is(err, bisect.ErrNotBisecting) {
+		return nil, err
+	}
+	if state != nil {
+		for _, hash := range slices.Concat([]string{state.Start, state.Bad}, state.Good, state.Skip) {
+			if hash != "" && !slices.Contains(roots, hash) {
+				roots = append(roots, hash)
+			}
+		}
+	}
+	return roots, nil
+}
+
+// Reachable returns every object reachable from the roots and the index:
+// annotated tags, the commits in their history, the blobs those commits and
+// the index refer to, and the chunks of chunked blobs.
+func Reachable(l *layout.Layout) (map[string]bool, error) {
+	roots, err := Roots(l)
+	if err != nil {
+		return nil, err
+	}
+	reachable := make(map[string]bool)
+	for _, root := range roots {
+		hash, err := peel(l, reachable, root)
+		if err != nil {
+			return nil, err
+		}
+		for hash != "" && !reachable[hash] {
+			c, err := commit.Load(hash, l)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load commit %s: %w", hash, err)
+			}
+			reachable[hash] = true
+			for _, blob := range c.Changes {
+				if err := addBlob(l, reachable, blob); err != nil {
+					return nil, err
+				}
+			}
+			hash = c.Parent
+		}
+	}
+	idx := index.New()
+	if err := idx.Load(l); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, blob := range idx.Staged {
+		if err := addBlob(l, reachable, blob); err != nil {
+			return nil, err
+		}
+	}
+	return reachable, nil
+}
+
+// peel returns the commit hash refers to, marking hash as well if it is an
+// annotated tag.
+func peel(l *layout.Layout, marked map[string]bool, hash string) (string, error) {
+	peeled, err := commit.Peel(hash, l)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", hash, err)
+	}
+	if peeled != hash {
+		marked[hash] = true
+	}
+	return peeled, nil
+}
+
+// addBlob marks a blob and its chunks as reachable.
+func addBlob(l *layout.Layout, reachable map[string]bool, blob string) error {
+	if reachable[blob] {
+		return nil
+	}
+	reachable[blob] = true
+	chunks, err := object.Chunks(l, blob)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read blob %s: %w", blob, err)
+	}
+	for _, c := range chunks {
+		reachable[c] = true
+	}
+	return nil
+}
+
+// Walk returns the objects reachable from tips that are needed to transfer
+// their history to another repository. History is not followed past commits
+// for which have returns true, and the blobs and chunks of those commits are
+// left out too, so objects the receiving side already has are mostly left out.
+//
+// Objects are ordered so that every object comes after the objects it refers
+// to: chunks before their blobs, blobs before the commits that refer to them,
+// parents before their children, and commits before annotated tags of them.
+// Writing them in order never leaves a commit in the receiving repository
+// whose content is missing.
+func Walk(l *layout.Layout, tips []string, have func(hash string) bool) ([]string, error) {
+	var objects []string
+	added := make(map[string]bool)
+	for _, tip := range tips {
+		// Collect the history of this tip that has not been walked yet, then
+		// emit it oldest first. Its oldest commit's parent was either emitted
+		// for an earlier tip or is one the receiving side has.
+		var chain []*commit.Commit
+		var hashes []string
+		var tag string
+		hash, err := commit.Peel(tip, l)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", tip, err)
+		}
+		if hash != tip && !added[tip] && !have(tip) {
+			tag = tip
+			added[tag] = true
+		}
+		for hash != "" && !added[hash] && !have(hash) {
+			c, err := commit.Load(hash, l)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load commit %s: %w", hash, err)
+			}
+			added[hash] = true
+			chain = append(chain, c)
+			hashes = append(hashes, hash)
+			hash = c.Parent
+		}
+		if hash != "" && !added[hash] {
+			// The receiving side has this commit, and with it its content.
+			added[hash] = true
+			c, err := commit.Load(hash, l)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load commit %s: %w", hash, err)
+			}
+			for _, blob := range c.Changes {
+				if err := addBlob(l, added, blob); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			for _, blob := range slices.Sorted(maps.Values(chain[i].Changes)) {
+				if added[blob] {
+					continue
+				}
+				added[blob] = true
+				chunks, err := object.Chunks(l, blob)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read blob %s: %w", blob, err)
+				}
+				for _, c := range chunks {
+					if !added[c] {
+						added[c] = true
+						objects = append(objects, c)
+					}
+				}
+				objects = append(objects, blob)
+			}
+			objects = append(objects, hashes[i])
+		}
+		if tag != "" {
+			objects = append(objects, tag)
+		}
+	}
+	return objects, nil
+}
+
+// IsAncestor reports whether ancestor is in the history of hash. A commit is
+// its own ancestor. Annotated tags stand for the commits they are about.
+func IsAncestor(l *layout.Layout, ancestor, hash string) (bool, error) {
+	if peeled, err := commit.Peel(ancestor, l); err == nil {
+		ancestor = peeled
+	}
+	hash, err := commit.Peel(hash, l)
+	if err != nil {
+		return false, err
+	}
+	for hash != "" {
+		if hash == ancestor {
+			return true, nil
+		}
+		c, err := commit.Load(hash, l)
+		if err != nil {
+			return false, err
+		}
+		hash = c.Parent
+	}
+	return false, nil
+}
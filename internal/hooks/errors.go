@@ -0,0 +1,5 @@
+package hooks
+
+import "errors"
+
+var ErrFailed = errors.New("hook failed")
@@ -0,0 +1,119 @@
+// Package hooks runs the executables a repository provides to check or extend
+// commands at fixed points, such as before a commit is recorded.
+//
+// A hook is an executable file named after the point it runs at, in
+// .trac/hooks or the directory set by core.hooksPath. Hooks run in the root of
+// the working tree with TRAC_DIR set to the .trac directory. For the hooks
+// that can stop an operation, a non-zero exit status stops it; the status of
+// the others is ignored.
+//
+//	pre-commit          Runs before a commit is recorded, with no arguments.
+//	prepare-commit-msg  Runs with the path of a file holding the commit message and the source of
+//	                    the message: "message", "template", or none. The hook may edit the file.
+//	commit-msg          Runs with the path of a file holding the commit message, after
+//	                    prepare-commit-msg and the editor. The hook may edit the file.
+//	post-commit         Runs after a commit is recorded, with no arguments. Cannot stop the commit.
+//	pre-push            Runs before anything is sent to a remote, with the remote's name and URL as
+//	                    arguments. Standard input has a line for each ref the push would update:
+//	                    "<local ref> <local hash> <remote ref> <remote hash>", where a missing ref
+//	                    has a hash of 64 zeros.
+//	post-checkout       Runs after HEAD is moved by a checkout, with the commit HEAD pointed at
+//	                    before, the commit it points at now, and "1" for a checkout of a branch or
+//	                    commit. Cannot stop the checkout.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/layout"
+)
+
+// Names of the hooks trac runs.
+const (
+	PreCommit        = "pre-commit"
+	PrepareCommitMsg = "prepare-commit-msg"
+	CommitMsg        = "commit-msg"
+	PostCommit       = "post-commit"
+	PrePush          = "pre-push"
+	PostCheckout     = "post-checkout"
+)
+
+// PathKey is the config key naming the directory hooks are looked up in,
+// absolute or relative to the root of the working tree.
+const PathKey = "core.hooksPath"
+
+// Dir returns the directory hooks are looked up in.
+func Dir(l *layout.Layout) (string, error) {
+	cfg, err := config.Load(l)
+	if err != nil {
+		return "", err
+	}
+	dir := cfg.String(PathKey, "")
+	if dir == "" {
+		return l.Hooks, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(l.Root, dir)
+	}
+	return dir, nil
+}
+
+// Find returns the path of the named hook, or "" if there is no hook by that
+// name. A file that is not executable is not a hook.
+func Find(l *layout.Layout, name string) (string, error) {
+	dir, err := Dir(l)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return "", nil
+	}
+	return path, nil
+}
+
+// Options controls how a hook runs.
+type Options struct {
+	Args   []string  // Arguments to the hook
+	Stdin  io.Reader // Standard input of the hook; empty when nil
+	Output io.Writer // Receives the standard output and error of the hook; discarded when nil
+}
+
+// Run runs the named hook, if the repository has one, and reports whether it
+// did. An error wrapping ErrFailed is returned if the hook exits with a
+// non-zero status.
+func Run(ctx context.Context, l *layout.Layout, name string, opts Options) (bool, error) {
+	path, err := Find(l, name)
+	if err != nil || path == "" {
+		return false, err
+	}
+	cmd := exec.CommandContext(ctx, path, opts.Args...)
+	cmd.Dir = l.Root
+	cmd.Env = append(os.Environ(), "TRAC_DIR="+l.Config)
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Output
+	cmd.Stderr = opts.Output
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return true, fmt.Errorf("%w: %s hook exited with status %d", ErrFailed, name, exitErr.ExitCode())
+		}
+		return true, fmt.Errorf("%w: %s hook: %w", ErrFailed, name, err)
+	}
+	return true, nil
+}
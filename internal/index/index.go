@@ -3,38 +3,147 @@ package index
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/filemode"
 	"github.com/lucasrod16/trac/internal/layout"
-	"github.com/lucasrod16/trac/internal/utils"
+	"github.com/lucasrod16/trac/internal/object"
 )
 
 // Index represents the index of staged files.
 type Index struct {
 	Staged map[string]string `json:"staged"`
+	Modes  map[string]string `json:"modes,omitempty"` // Modes of staged files that are not regular files; see package filemode
+
+	chunkThreshold *int64 // core.chunkThreshold, loaded on first Add
+	fileMode       *bool  // core.fileMode, loaded on first Add
 }
 
+// ChunkThresholdKey is the config key for the size in bytes at which files are
+// stored as content-defined chunks rather than whole. Zero or unset disables chunking.
+const ChunkThresholdKey = "core.chunkThreshold"
+
 func New() *Index {
 	return &Index{
 		Staged: make(map[string]string),
+		Modes:  make(map[string]string),
 	}
 }
 
-// Add adds an entry (file) to the index by calculating its SHA-256 hash and storing it.
+// Add adds an entry (file) to the index by writing its content to the object
+// database and recording the resulting SHA-256 hash and the file's mode.
+// Relative paths are taken to be relative to the repository root. The entry
+// is keyed by the file's slash-separated path relative to the root, so that
+// the commits it ends up in can be checked out wherever the repository is
+// cloned to.
+//
+// Symbolic links are not followed: their target is stored as their content.
+// When core.fileMode is false, a file keeps the mode it is already staged with
+// unless it has become or stopped being a symbolic link.
 func (idx *Index) Add(filePath string, l *layout.Layout) error {
 	if err := l.ValidatePathInRepo(filePath); err != nil {
 		return err
 	}
-	hash, err := utils.HashFile(filePath)
+	key, err := l.RelPath(filePath)
+	if err != nil {
+		return err
+	}
+	key = filepath.ToSlash(key)
+	path := l.AbsPath(filePath)
+	info, err := os.Lstat(path)
 	if err != nil {
 		return err
 	}
-	idx.Staged[filePath] = hash
+	mode := filemode.Of(info)
+	var hash string
+	if mode == filemode.Symlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if hash, err = object.Write(l, []byte(target)); err != nil {
+			return err
+		}
+	} else {
+		threshold, err := idx.threshold(l)
+		if err != nil {
+			return err
+		}
+		write := object.WriteFile
+		if threshold > 0 && info.Size() >= threshold {
+			write = object.WriteChunked
+		}
+		if hash, err = write(l, path); err != nil {
+			return err
+		}
+		enabled, err := idx.fileModeEnabled(l)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			mode = filemode.Ignore(mode, filemode.Get(idx.Modes, key))
+		}
+	}
+	idx.Staged[key] = hash
+	if idx.Modes == nil {
+		idx.Modes = make(map[string]string)
+	}
+	filemode.Set(idx.Modes, key, mode)
 	return nil
 }
 
-// Write serializes the index to a JSON file.
+func (idx *Index) threshold(l *layout.Layout) (int64, error) {
+	if idx.chunkThreshold == nil {
+		cfg, err := config.Load(l)
+		if err != nil {
+			return 0, err
+		}
+		threshold, err := cfg.Size(ChunkThresholdKey, 0)
+		if err != nil {
+			return 0, err
+		}
+		idx.chunkThreshold = &threshold
+	}
+	return *idx.chunkThreshold, nil
+}
+
+func (idx *Index) fileModeEnabled(l *layout.Layout) (bool, error) {
+	if idx.fileMode == nil {
+		enabled, err := filemode.Enabled(l)
+		if err != nil {
+			return false, err
+		}
+		idx.fileMode = &enabled
+	}
+	return *idx.fileMode, nil
+}
+
+// Find returns the key under which path is staged.
+// Paths are compared relative to the repository root, so it does not matter
+// whether path or the staged path is absolute.
+func (idx *Index) Find(path string, l *layout.Layout) (key string, ok bool, err error) {
+	want, err := l.RelPath(path)
+	if err != nil {
+		return "", false, err
+	}
+	for key := range idx.Staged {
+		got, err := l.RelPath(key)
+		if err != nil {
+			return "", false, err
+		}
+		if got == want {
+			return key, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Write serializes the index to a JSON file. Modes of files that are no
+// longer staged are dropped.
 func (idx *Index) Write(l *layout.Layout) error {
-	file, err := os.OpenFile(l.Index, os.O_CREATE|os.O_WRONLY, 0644)
+	idx.Modes = filemode.Select(idx.Modes, idx.Staged)
+	file, err := os.OpenFile(l.Index, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
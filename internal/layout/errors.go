@@ -2,4 +2,7 @@ package layout
 
 import "errors"
 
-var ErrNotTracRepository = errors.New("not a trac repository (or any of the parent directories): .trac")
+var (
+	ErrNotTracRepository = errors.New("not a trac repository (or any of the parent directories): .trac")
+	ErrBeyondSymlink     = errors.New("path is beyond a symbolic link")
+)
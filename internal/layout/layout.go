@@ -7,15 +7,25 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 )
 
 // Layout represents the filesystem structure of a trac repository.
 type Layout struct {
-	Root     string // Path to the root of the repository (the directory containing .trac)
-	Config   string // Path to the .trac/ directory (repository configuration)
-	Objects  string // Path to the objects/ directory
-	HeadFile string // Path to the HEAD file
-	Index    string // Path to the index file (index.json)
+	Root       string // Path to the root of the repository (the directory containing .trac)
+	Config     string // Path to the .trac/ directory (repository configuration)
+	Objects    string // Path to the objects/ directory
+	Packs      string // Path to the objects/pack/ directory
+	ObjectDB   string // Path to the single-file object store (objects.db)
+	HeadFile   string // Path to the HEAD file
+	Refs       string // Path to the refs/ directory of branches and tags
+	Index      string // Path to the index file (index.json)
+	ConfigFile string // Path to the repository configuration file (config.json)
+	Bisect     string // Path to the bisect state file (bisect.json)
+	BisectLog  string // Path to the bisect log (BISECT_LOG)
+	LostFound  string // Path to the lost-found/ directory of recovered objects
+	Hooks      string // Path to the hooks/ directory of executables run by commands
+	CommitMsg  string // Path to the file the message of the commit being made is edited in (COMMIT_EDITMSG)
 }
 
 // New creates a new Layout instance with paths initialized based on repoPath.
@@ -29,11 +39,20 @@ func New(repoPath string) (*Layout, error) {
 	}
 	configPath := filepath.Join(rootPath, ".trac")
 	return &Layout{
-		Root:     rootPath,
-		Config:   configPath,
-		Objects:  filepath.Join(configPath, "objects"),
-		HeadFile: filepath.Join(configPath, "HEAD"),
-		Index:    filepath.Join(configPath, "index.json"),
+		Root:       rootPath,
+		Config:     configPath,
+		Objects:    filepath.Join(configPath, "objects"),
+		Packs:      filepath.Join(configPath, "objects", "pack"),
+		ObjectDB:   filepath.Join(configPath, "objects.db"),
+		HeadFile:   filepath.Join(configPath, "HEAD"),
+		Refs:       filepath.Join(configPath, "refs"),
+		Index:      filepath.Join(configPath, "index.json"),
+		ConfigFile: filepath.Join(configPath, "config.json"),
+		Bisect:     filepath.Join(configPath, "bisect.json"),
+		BisectLog:  filepath.Join(configPath, "BISECT_LOG"),
+		LostFound:  filepath.Join(configPath, "lost-found"),
+		Hooks:      filepath.Join(configPath, "hooks"),
+		CommitMsg:  filepath.Join(configPath, "COMMIT_EDITMSG"),
 	}, nil
 }
 
@@ -41,13 +60,17 @@ func New(repoPath string) (*Layout, error) {
 func (l *Layout) Init() error {
 	directories := []string{
 		l.Objects,
+		filepath.Join(l.Refs, "heads"),
+		filepath.Join(l.Refs, "tags"),
+		l.Hooks,
 	}
 	for _, dir := range directories {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return err
 		}
 	}
-	if err := os.WriteFile(l.HeadFile, []byte(""), 0644); err != nil {
+	// HEAD starts out on the default branch, which is created by the first commit.
+	if err := os.WriteFile(l.HeadFile, []byte("ref: refs/heads/main\n"), 0644); err != nil {
 		return err
 	}
 	return nil
@@ -68,12 +91,71 @@ func (l *Layout) ValidateIsRepo() error {
 
 // ValidatePathInRepo validates if a given path is within the repository root.
 func (l *Layout) ValidatePathInRepo(path string) error {
-	absPath, err := filepath.Abs(path)
+	absPath := l.AbsPath(path)
+	if absPath != l.Root && !strings.HasPrefix(absPath, l.Root+string(filepath.Separator)) {
+		return fmt.Errorf("%q is outside the repository at %q", absPath, l.Root)
+	}
+	return nil
+}
+
+// AbsPath returns the absolute form of path. Relative paths are taken to be
+// relative to the repository root.
+func (l *Layout) AbsPath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(l.Root, path)
+}
+
+// RelPath returns path relative to the repository root. Relative paths are
+// taken to be relative to the repository root already.
+func (l *Layout) RelPath(path string) (string, error) {
+	return filepath.Rel(l.Root, l.AbsPath(path))
+}
+
+// CheckParents fails with ErrBeyondSymlink if a directory leading to path
+// inside the repository is a symbolic link, so that writing or removing the
+// file at path would reach outside the working tree. Directories that do not
+// exist yet are fine.
+func (l *Layout) CheckParents(path string) error {
+	return l.walkParents(path, false)
+}
+
+// MkdirParents creates the directories leading to path like os.MkdirAll, but
+// fails with ErrBeyondSymlink rather than going through a symbolic link.
+func (l *Layout) MkdirParents(path string) error {
+	return l.walkParents(path, true)
+}
+
+func (l *Layout) walkParents(path string, create bool) error {
+	if err := l.ValidatePathInRepo(path); err != nil {
+		return err
+	}
+	rel, err := l.RelPath(path)
 	if err != nil {
 		return err
 	}
-	if !strings.HasPrefix(absPath, l.Root) {
-		return fmt.Errorf("%q is outside the repository at %q", absPath, l.Root)
+	dir := l.Root
+	for _, name := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if name == "." {
+			continue
+		}
+		dir = filepath.Join(dir, name)
+		info, err := os.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && create:
+			if err := os.Mkdir(dir, 0755); err != nil {
+				return err
+			}
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%w: %s", ErrBeyondSymlink, l.AbsPath(path))
+		case !info.IsDir():
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
 	}
 	return nil
 }
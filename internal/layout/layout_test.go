@@ -1,6 +1,7 @@
 package layout
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -16,11 +17,20 @@ func TestNew(t *testing.T) {
 	require.NotNil(t, actual)
 
 	expected := &Layout{
-		Root:     tmpdir,
-		Config:   filepath.Join(tmpdir, ".trac"),
-		Objects:  filepath.Join(tmpdir, ".trac", "objects"),
-		HeadFile: filepath.Join(tmpdir, ".trac", "HEAD"),
-		Index:    filepath.Join(tmpdir, ".trac", "index.json"),
+		Root:       tmpdir,
+		Config:     filepath.Join(tmpdir, ".trac"),
+		Objects:    filepath.Join(tmpdir, ".trac", "objects"),
+		ObjectDB:   filepath.Join(tmpdir, ".trac", "objects.db"),
+		Packs:      filepath.Join(tmpdir, ".trac", "objects", "pack"),
+		HeadFile:   filepath.Join(tmpdir, ".trac", "HEAD"),
+		Refs:       filepath.Join(tmpdir, ".trac", "refs"),
+		Index:      filepath.Join(tmpdir, ".trac", "index.json"),
+		ConfigFile: filepath.Join(tmpdir, ".trac", "config.json"),
+		Bisect:     filepath.Join(tmpdir, ".trac", "bisect.json"),
+		BisectLog:  filepath.Join(tmpdir, ".trac", "BISECT_LOG"),
+		LostFound:  filepath.Join(tmpdir, ".trac", "lost-found"),
+		Hooks:      filepath.Join(tmpdir, ".trac", "hooks"),
+		CommitMsg:  filepath.Join(tmpdir, ".trac", "COMMIT_EDITMSG"),
 	}
 	require.Equal(t, expected, actual)
 }
@@ -42,3 +52,19 @@ func TestValidateIsRepo(t *testing.T) {
 	require.NoError(t, l.Init())
 	require.NoError(t, l.ValidateIsRepo())
 }
+
+func TestMkdirParents(t *testing.T) {
+	t.Parallel()
+	tmpdir := t.TempDir()
+	l, err := New(tmpdir)
+	require.NoError(t, err)
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(tmpdir, "link")))
+
+	require.NoError(t, l.MkdirParents(filepath.Join("a", "b", "f")))
+	require.DirExists(t, filepath.Join(tmpdir, "a", "b"))
+	require.NoError(t, l.CheckParents(filepath.Join("missing", "f")))
+	require.ErrorIs(t, l.MkdirParents(filepath.Join("link", "d", "f")), ErrBeyondSymlink)
+	require.ErrorIs(t, l.CheckParents(filepath.Join("link", "f")), ErrBeyondSymlink)
+	require.NoDirExists(t, filepath.Join(outside, "d"))
+}
@@ -0,0 +1,226 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/chunk"
+	"github.com/lucasrod16/trac/internal/layout"
+)
+
+// manifestMagic starts every chunk manifest.
+//
+// A chunked object is stored under the hash of its full content, but its stored
+// bytes are a manifest listing the chunks that make up that content:
+//
+//	trac-chunked-v1
+//	size <total size in bytes>
+//	<chunk hash>
+//	...
+//
+// Each chunk is an ordinary object. Since a manifest never hashes to the name it
+// is stored under, an object whose content merely starts with the magic is not
+// mistaken for one.
+const manifestMagic = "trac-chunked-v1\n"
+
+type manifest struct {
+	size   int64
+	chunks []string
+}
+
+// isManifest reports whether data, stored under hash, is a chunk manifest.
+func isManifest(data []byte, hash string) bool {
+	if !bytes.HasPrefix(data, []byte(manifestMagic)) {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) != hash
+}
+
+func (m *manifest) encode() []byte {
+	var b strings.Builder
+	b.WriteString(manifestMagic)
+	fmt.Fprintf(&b, "size %d\n", m.size)
+	for _, hash := range m.chunks {
+		b.WriteString(hash + "\n")
+	}
+	return []byte(b.String())
+}
+
+func parseManifest(data []byte) (*manifest, error) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 2 || lines[0]+"\n" != manifestMagic {
+		return nil, fmt.Errorf("%w: malformed chunk manifest", ErrCorrupt)
+	}
+	sizeStr, ok := strings.CutPrefix(lines[1], "size ")
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if !ok || err != nil || size < 0 {
+		return nil, fmt.Errorf("%w: malformed chunk manifest size %q", ErrCorrupt, lines[1])
+	}
+	m := &manifest{size: size}
+	for _, hash := range lines[2:] {
+		if !IsHash(hash) {
+			return nil, fmt.Errorf("%w: malformed chunk hash %q", ErrCorrupt, hash)
+		}
+		m.chunks = append(m.chunks, hash)
+	}
+	return m, nil
+}
+
+// WriteChunked stores the contents of the file at path in the object database as a
+// sequence of content-defined chunks and returns the hash of the full content.
+// The object reads back exactly like one written with WriteFile, but files that
+// differ only in places share all their other chunks.
+func WriteChunked(l *layout.Layout, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	m := &manifest{}
+	err = chunk.Split(io.TeeReader(f, h), func(c []byte) error {
+		hash, err := Write(l, c)
+		if err != nil {
+			return err
+		}
+		m.chunks = append(m.chunks, hash)
+		m.size += int64(len(c))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if Has(l, hash) {
+		return hash, nil
+	}
+	if err := WriteRaw(l, hash, m.encode(), time.Time{}); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Chunks returns the hashes of the chunks an object is stored as,
+// or nil if it is stored whole.
+func Chunks(l *layout.Layout, hash string) ([]string, error) {
+	// Avoid reading large objects in full just to find they are not chunked.
+	r, err := open(l, hash)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, len(manifestMagic))
+	_, err = io.ReadFull(r, prefix)
+	r.Close()
+	if err != nil || string(prefix) != manifestMagic {
+		return nil, nil
+	}
+	data, err := ReadRaw(l, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !isManifest(data, hash) {
+		return nil, nil
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.chunks, nil
+}
+
+// Size returns the size of an object's content, without reassembling chunked objects.
+func Size(l *layout.Layout, hash string) (int64, error) {
+	data, err := ReadRaw(l, hash)
+	if err != nil {
+		return 0, err
+	}
+	if !isManifest(data, hash) {
+		return int64(len(data)), nil
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return 0, err
+	}
+	return m.size, nil
+}
+
+// WriteTo writes the content of an object to w, reassembling chunked objects
+// one chunk at a time rather than holding the whole content in memory.
+func WriteTo(l *layout.Layout, hash string, w io.Writer) error {
+	data, err := ReadRaw(l, hash)
+	if err != nil {
+		return err
+	}
+	if !isManifest(data, hash) {
+		_, err := w.Write(data)
+		return err
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, c := range m.chunks {
+		data, err := ReadRaw(l, c)
+		if err != nil {
+			return fmt.Errorf("chunk %s of %s: %w", c, hash, err)
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// reassemble returns the full content of the chunked object described by data.
+func reassemble(l *layout.Layout, hash string, data []byte) ([]byte, error) {
+	m, err := parseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, 0, m.size)
+	for _, c := range m.chunks {
+		data, err := ReadRaw(l, c)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %s of %s: %w", c, hash, err)
+		}
+		content = append(content, data...)
+	}
+	return content, nil
+}
+
+// verifyChunked checks that every chunk of a chunked object exists and that
+// together they hash to the object's name.
+func verifyChunked(l *layout.Layout, hash string, data []byte) error {
+	m, err := parseManifest(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", hash, err)
+	}
+	h := sha256.New()
+	var size int64
+	for _, c := range m.chunks {
+		data, err := ReadRaw(l, c)
+		if err != nil {
+			return fmt.Errorf("%w: chunk %s of %s", ErrMissing, c, hash)
+		}
+		h.Write(data)
+		size += int64(len(data))
+	}
+	if size != m.size {
+		return fmt.Errorf("%w: %s (chunks total %d bytes, manifest says %d)", ErrCorrupt, hash, size, m.size)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != hash {
+		return fmt.Errorf("%w: %s (chunks hash to %s)", ErrCorrupt, hash, actual)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package object
+
+import "errors"
+
+var (
+	ErrInvalidHash    = errors.New("invalid object hash")
+	ErrMissing        = errors.New("object missing from object database")
+	ErrCorrupt        = errors.New("object content does not match its hash")
+	ErrUnknownBackend = errors.New("unknown object store backend")
+	ErrInvalidStore   = errors.New("invalid object store file")
+)
@@ -0,0 +1,240 @@
+package object
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/layout"
+)
+
+// Path returns the location of an object in the loose object store.
+func Path(l *layout.Layout, hash string) string {
+	return filepath.Join(l.Objects, hash[:2], hash[2:])
+}
+
+// Read reads the contents of an object from the object database, looking
+// in the object store first and then at packs. Chunked objects are reassembled.
+func Read(l *layout.Layout, hash string) ([]byte, error) {
+	data, err := ReadRaw(l, hash)
+	if err != nil || !isManifest(data, hash) {
+		return data, err
+	}
+	return reassemble(l, hash, data)
+}
+
+// ReadRaw reads an object as it is stored, returning the manifest rather than
+// the content for chunked objects.
+func ReadRaw(l *layout.Layout, hash string) ([]byte, error) {
+	if len(hash) < 3 {
+		return nil, ErrInvalidHash
+	}
+	s, err := OpenStore(l)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.Get(hash)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return data, err
+	}
+	p, packErr := findPack(l, hash)
+	if packErr != nil {
+		return nil, packErr
+	}
+	if p == nil {
+		return nil, err
+	}
+	return p.Read(hash)
+}
+
+// open returns a reader for an object as it is stored.
+func open(l *layout.Layout, hash string) (io.ReadCloser, error) {
+	s, err := OpenStore(l)
+	if err != nil {
+		return nil, err
+	}
+	if ss, ok := s.(StreamStore); ok {
+		r, err := ss.Open(hash)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return r, err
+		}
+	}
+	data, err := ReadRaw(l, hash)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Has reports whether an object exists in the object database, in the store or packed.
+func Has(l *layout.Layout, hash string) bool {
+	if len(hash) < 3 {
+		return false
+	}
+	s, err := OpenStore(l)
+	if err != nil {
+		return false
+	}
+	if ok, err := s.Has(hash); err == nil && ok {
+		return true
+	}
+	p, err := findPack(l, hash)
+	return err == nil && p != nil
+}
+
+// Write stores data in the object database under its SHA-256 hash and returns the hash.
+// Objects that already exist are left untouched.
+func Write(l *layout.Layout, data []byte) (string, error) {
+	return write(l, bytes.NewReader(data))
+}
+
+// WriteFile stores the contents of the file at path in the object database and returns its hash.
+func WriteFile(l *layout.Layout, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return write(l, f)
+}
+
+func write(l *layout.Layout, r io.Reader) (string, error) {
+	s, err := OpenStore(l)
+	if err != nil {
+		return "", err
+	}
+	if ss, ok := s.(StreamStore); ok {
+		return ss.WriteStream(r, func(hash string) bool { return Has(l, hash) })
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if Has(l, hash) {
+		return hash, nil
+	}
+	return hash, s.Put(hash, data)
+}
+
+// WriteRaw stores data under hash as is, without checking that it hashes to it.
+// It is used to move objects out of packs and to store chunk manifests under the
+// hash of their content. If modTime is not zero and the store records modification
+// times, the object's modification time is set to it.
+func WriteRaw(l *layout.Layout, hash string, data []byte, modTime time.Time) error {
+	s, err := OpenStore(l)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(hash, data); err != nil {
+		return err
+	}
+	if ts, ok := s.(TimeStore); ok && !modTime.IsZero() {
+		return ts.SetModTime(hash, modTime)
+	}
+	return nil
+}
+
+// Delete removes an object from the object store. Packed objects are not affected.
+func Delete(l *layout.Layout, hash string) error {
+	s, err := OpenStore(l)
+	if err != nil {
+		return err
+	}
+	return s.Delete(hash)
+}
+
+// ModTime returns when an object in the object store was written, or the zero
+// time if the store does not record it.
+func ModTime(l *layout.Layout, hash string) (time.Time, error) {
+	s, err := OpenStore(l)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ts, ok := s.(TimeStore); ok {
+		return ts.ModTime(hash)
+	}
+	return time.Time{}, nil
+}
+
+// Verify checks that an object exists and that its content hashes to its name.
+// For chunked objects, the content is reassembled from its chunks, and a missing
+// chunk is reported as ErrMissing.
+func Verify(l *layout.Layout, hash string) error {
+	if len(hash) < 3 {
+		return ErrInvalidHash
+	}
+	if !Has(l, hash) {
+		return fmt.Errorf("%w: %s", ErrMissing, hash)
+	}
+	r, err := open(l, hash)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrCorrupt, hash, err)
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != hash {
+		if data, err := ReadRaw(l, hash); err == nil && isManifest(data, hash) {
+			return verifyChunked(l, hash, data)
+		}
+		return fmt.Errorf("%w: %s (content hashes to %s)", ErrCorrupt, hash, actual)
+	}
+	return nil
+}
+
+// List returns the hashes of all objects in the object database, in the store or packed.
+func List(l *layout.Layout) ([]string, error) {
+	hashes, err := ListLoose(l)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := ListPacked(l)
+	if err != nil {
+		return nil, err
+	}
+	loose := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		loose[hash] = true
+	}
+	for hash := range packed {
+		if !loose[hash] {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// ListLoose returns the hashes of all objects held in the object store rather than in packs.
+func ListLoose(l *layout.Layout) ([]string, error) {
+	s, err := OpenStore(l)
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	err = s.Iterate(func(hash string) error {
+		hashes = append(hashes, hash)
+		return nil
+	})
+	return hashes, err
+}
+
+// IsHash reports whether s is a well-formed object hash.
+func IsHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
@@ -0,0 +1,94 @@
+package object
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/pack"
+)
+
+// packCache holds the packs opened for each pack directory. A directory's packs
+// are reopened whenever the set of index files in it changes.
+var packCache = struct {
+	sync.Mutex
+	dirs map[string]*packSet
+}{dirs: make(map[string]*packSet)}
+
+type packSet struct {
+	names []string
+	packs []*pack.Pack
+}
+
+// Packs returns the packs in the object database.
+func Packs(l *layout.Layout) ([]*pack.Pack, error) {
+	entries, err := os.ReadDir(l.Packs)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), pack.IndexExt) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	packCache.Lock()
+	defer packCache.Unlock()
+	if set, ok := packCache.dirs[l.Packs]; ok && slices.Equal(set.names, names) {
+		return set.packs, nil
+	}
+	set := &packSet{names: names}
+	for _, name := range names {
+		p, err := pack.Open(filepath.Join(l.Packs, name))
+		if err != nil {
+			return nil, err
+		}
+		set.packs = append(set.packs, p)
+	}
+	packCache.dirs[l.Packs] = set
+	return set.packs, nil
+}
+
+// findPack returns the pack containing an object, or nil if no pack contains it.
+func findPack(l *layout.Layout, hash string) (*pack.Pack, error) {
+	packs, err := Packs(l)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		if p.Has(hash) {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListPacked returns the hashes of all packed objects along with the
+// modification time of the pack containing them.
+func ListPacked(l *layout.Layout) (map[string]time.Time, error) {
+	packs, err := Packs(l)
+	if err != nil {
+		return nil, err
+	}
+	packed := make(map[string]time.Time)
+	for _, p := range packs {
+		info, err := os.Stat(p.Path())
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range p.Hashes() {
+			packed[hash] = info.ModTime()
+		}
+	}
+	return packed, nil
+}
@@ -0,0 +1,125 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/layout"
+)
+
+// Store persists objects by hash. It is the backend behind the functions in this
+// package; packs are layered on top of it regardless of the backend in use.
+//
+// Implementations must be safe for concurrent use. Get and Delete return an error
+// wrapping fs.ErrNotExist for objects that are not in the store.
+type Store interface {
+	Has(hash string) (bool, error)
+	Get(hash string) ([]byte, error)
+	// Put stores data under hash. Storing an object that already exists is not an error.
+	Put(hash string, data []byte) error
+	// Iterate calls fn with the hash of every object in the store, stopping at the first error.
+	Iterate(fn func(hash string) error) error
+	Delete(hash string) error
+}
+
+// TimeStore is implemented by stores that record when each object was written.
+// Pruning uses the time to give unreachable objects a grace period; objects in
+// stores that do not record it are pruned regardless of their age.
+type TimeStore interface {
+	ModTime(hash string) (time.Time, error)
+	SetModTime(hash string, t time.Time) error
+}
+
+// StreamStore is implemented by stores that can write and read objects without
+// holding their whole content in memory.
+type StreamStore interface {
+	// WriteStream stores the content of r under its SHA-256 hash and returns the hash.
+	// The object is not stored if exists reports that it is already present.
+	WriteStream(r io.Reader, exists func(hash string) bool) (string, error)
+	Open(hash string) (io.ReadCloser, error)
+}
+
+// Compacter is implemented by stores that can reclaim space left behind by deleted objects.
+type Compacter interface {
+	Compact() error
+}
+
+// Backends selectable with the core.objectStore config key.
+const (
+	StoreKey = "core.objectStore"
+
+	BackendLoose  = "loose"  // One file per object under .trac/objects (the default)
+	BackendFile   = "file"   // A single append-only file, .trac/objects.db
+	BackendMemory = "memory" // Kept in memory for the lifetime of the process; only for UseStore, not core.objectStore
+)
+
+// stores holds the store opened for each repository, keyed by its objects directory.
+var stores = struct {
+	sync.Mutex
+	repos map[string]Store
+}{repos: make(map[string]Store)}
+
+// OpenStore returns the object store for a repository, opening the backend
+// selected by core.objectStore the first time it is needed.
+func OpenStore(l *layout.Layout) (Store, error) {
+	stores.Lock()
+	defer stores.Unlock()
+	if s, ok := stores.repos[l.Objects]; ok {
+		return s, nil
+	}
+	cfg, err := config.Load(l)
+	if err != nil {
+		return nil, err
+	}
+	var s Store
+	switch backend := cfg.String(StoreKey, BackendLoose); backend {
+	case BackendLoose:
+		s = NewLooseStore(l.Objects)
+	case BackendFile:
+		if s, err = OpenFileStore(l.ObjectDB); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, backend)
+	}
+	stores.repos[l.Objects] = s
+	return s, nil
+}
+
+// UseStore makes the repository use s for its objects instead of the backend
+// selected by its configuration. It lets programs embedding trac supply their own persistence.
+func UseStore(l *layout.Layout, s Store) {
+	stores.Lock()
+	defer stores.Unlock()
+	stores.repos[l.Objects] = s
+}
+
+// ReleaseStore closes the repository's store, if open, so the next use reopens
+// it according to the current configuration.
+func ReleaseStore(l *layout.Layout) error {
+	stores.Lock()
+	defer stores.Unlock()
+	s, ok := stores.repos[l.Objects]
+	if !ok {
+		return nil
+	}
+	delete(stores.repos, l.Objects)
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ValidBackend reports whether name is a backend that can be selected with
+// core.objectStore. The memory backend cannot: every process would start with
+// an empty store and lose the objects written by the last.
+func ValidBackend(name string) bool {
+	switch name {
+	case BackendLoose, BackendFile:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package object
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FileStore keeps every object in a single append-only file, which suits
+// embedding trac where a directory of many small files is undesirable.
+//
+// The file starts with a magic number and is followed by records:
+//
+//	op (1 byte) | hash (32 bytes) | modification time (8 bytes, Unix nanoseconds)
+//	| length (4 bytes) | data (length bytes) | CRC-32 of the preceding fields (4 bytes)
+//
+// Deletions and modification time updates are appended as records of their own,
+// and the latest record for a hash wins. An index of the live records is built
+// in memory when the file is opened. A record cut short by a crash is discarded.
+//
+// The store is safe for concurrent use within a process, but only one process
+// should have a repository's store open for writing at a time.
+type FileStore struct {
+	path    string
+	mu      sync.RWMutex
+	f       *os.File
+	size    int64 // Length of the valid records
+	index   map[string]fileEntry
+	garbage int64 // Bytes taken up by records that have been superseded
+}
+
+type fileEntry struct {
+	offset  int64 // Offset of the record
+	length  uint32
+	modTime time.Time
+}
+
+const (
+	fileStoreMagic = "TKV1"
+	recordHeader   = 1 + 32 + 8 + 4
+	recordTrailer  = 4
+
+	opPut    byte = 1
+	opDelete byte = 2
+	opTouch  byte = 3
+)
+
+// OpenFileStore opens the store at path, creating it if it does not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+	if err := s.open(); err != nil {
+		if s.f != nil {
+			s.f.Close()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) open() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.index = make(map[string]fileEntry)
+	s.garbage = 0
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		if _, err := f.WriteAt([]byte(fileStoreMagic), 0); err != nil {
+			return err
+		}
+		s.size = int64(len(fileStoreMagic))
+		return nil
+	}
+	if err := s.load(); err != nil {
+		return err
+	}
+	// Discard a partially written record so new records follow the last valid one.
+	if s.size < info.Size() {
+		return f.Truncate(s.size)
+	}
+	return nil
+}
+
+// load reads every record in the file into the index.
+func (s *FileStore) load() error {
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, 1<<62))
+	magic := make([]byte, len(fileStoreMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != fileStoreMagic {
+		return fmt.Errorf("%w: %s", ErrInvalidStore, s.path)
+	}
+	s.size = int64(len(magic))
+	header := make([]byte, recordHeader)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil
+		}
+		length := binary.BigEndian.Uint32(header[41:45])
+		rest := make([]byte, int(length)+recordTrailer)
+		if _, err := io.ReadFull(r, rest); err != nil {
+			return nil
+		}
+		crc := crc32.NewIEEE()
+		crc.Write(header)
+		crc.Write(rest[:length])
+		if crc.Sum32() != binary.BigEndian.Uint32(rest[length:]) {
+			return nil
+		}
+		s.apply(header, s.size)
+		s.size += int64(recordHeader) + int64(length) + recordTrailer
+	}
+}
+
+// apply updates the index with the record at offset.
+func (s *FileStore) apply(header []byte, offset int64) {
+	hash := hex.EncodeToString(header[1:33])
+	modTime := time.Unix(0, int64(binary.BigEndian.Uint64(header[33:41])))
+	length := binary.BigEndian.Uint32(header[41:45])
+	size := int64(recordHeader) + int64(length) + recordTrailer
+	old, exists := s.index[hash]
+	switch header[0] {
+	case opPut:
+		if exists {
+			s.garbage += int64(recordHeader) + int64(old.length) + recordTrailer
+		}
+		s.index[hash] = fileEntry{offset: offset, length: length, modTime: modTime}
+	case opDelete:
+		if exists {
+			s.garbage += int64(recordHeader) + int64(old.length) + recordTrailer
+		}
+		s.garbage += size
+		delete(s.index, hash)
+	case opTouch:
+		if exists {
+			old.modTime = modTime
+			s.index[hash] = old
+		}
+		s.garbage += size
+	}
+}
+
+// appendRecord writes a record to the end of the file and applies it to the index.
+func (s *FileStore) appendRecord(op byte, hash string, modTime time.Time, data []byte) error {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || !IsHash(hash) {
+		return fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+	record := make([]byte, recordHeader, recordHeader+len(data)+recordTrailer)
+	record[0] = op
+	copy(record[1:33], raw)
+	binary.BigEndian.PutUint64(record[33:41], uint64(modTime.UnixNano()))
+	binary.BigEndian.PutUint32(record[41:45], uint32(len(data)))
+	record = append(record, data...)
+	record = binary.BigEndian.AppendUint32(record, crc32.ChecksumIEEE(record))
+	if _, err := s.f.WriteAt(record, s.size); err != nil {
+		return err
+	}
+	s.apply(record[:recordHeader], s.size)
+	s.size += int64(len(record))
+	return nil
+}
+
+func (s *FileStore) Has(hash string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.index[hash]
+	return ok, nil
+}
+
+func (s *FileStore) Get(hash string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.index[hash]
+	if !ok {
+		return nil, fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	data := make([]byte, e.length)
+	if _, err := s.f.ReadAt(data, e.offset+recordHeader); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *FileStore) Put(hash string, data []byte) error {
+	if uint64(len(data)) > 1<<32-1 {
+		return fmt.Errorf("object %s is too large for the file store", hash)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[hash]; ok {
+		return nil
+	}
+	return s.appendRecord(opPut, hash, time.Now(), data)
+}
+
+// Iterate visits the objects in sorted order. fn may modify the store.
+func (s *FileStore) Iterate(fn func(hash string) error) error {
+	s.mu.RLock()
+	hashes := slices.Sorted(maps.Keys(s.index))
+	s.mu.RUnlock()
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[hash]; !ok {
+		return fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	return s.appendRecord(opDelete, hash, time.Now(), nil)
+}
+
+func (s *FileStore) ModTime(hash string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.index[hash]
+	if !ok {
+		return time.Time{}, fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	return e.modTime, nil
+}
+
+func (s *FileStore) SetModTime(hash string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[hash]; !ok {
+		return fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	return s.appendRecord(opTouch, hash, t, nil)
+}
+
+// Compact rewrites the file with only the live objects, reclaiming the space
+// taken up by deleted and superseded records. It does nothing if there is nothing to reclaim.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.garbage == 0 {
+		return nil
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.Remove(tmpPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	tmp, err := OpenFileStore(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = s.copyLive(tmp)
+	if err == nil {
+		err = tmp.f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		tmp.f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	s.f.Close()
+	s.f, s.index, s.size, s.garbage = tmp.f, tmp.index, tmp.size, 0
+	return nil
+}
+
+// copyLive appends every live object to dst.
+func (s *FileStore) copyLive(dst *FileStore) error {
+	for _, hash := range slices.Sorted(maps.Keys(s.index)) {
+		e := s.index[hash]
+		data := make([]byte, e.length)
+		if _, err := s.f.ReadAt(data, e.offset+recordHeader); err != nil {
+			return err
+		}
+		if err := dst.appendRecord(opPut, hash, e.modTime, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
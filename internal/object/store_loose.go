@@ -0,0 +1,150 @@
+package object
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LooseStore keeps each object in its own file, named by its hash and fanned out
+// into subdirectories by the first two characters of the hash.
+type LooseStore struct {
+	dir string
+}
+
+func NewLooseStore(dir string) *LooseStore {
+	return &LooseStore{dir: dir}
+}
+
+// Path returns the location of an object's file.
+func (s *LooseStore) Path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash[2:])
+}
+
+func (s *LooseStore) Has(hash string) (bool, error) {
+	_, err := os.Stat(s.Path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *LooseStore) Get(hash string) ([]byte, error) {
+	return os.ReadFile(s.Path(hash))
+}
+
+func (s *LooseStore) Open(hash string) (io.ReadCloser, error) {
+	return os.Open(s.Path(hash))
+}
+
+func (s *LooseStore) Put(hash string, data []byte) error {
+	tmp, err := s.createTemp()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return s.install(tmp.Name(), hash)
+}
+
+// WriteStream streams r into a temporary file while hashing it, then moves it into place.
+// Writing through a temporary file ensures an object never exists with partial content.
+func (s *LooseStore) WriteStream(r io.Reader, exists func(hash string) bool) (string, error) {
+	tmp, err := s.createTemp()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if exists(hash) {
+		return hash, nil
+	}
+	return hash, s.install(tmp.Name(), hash)
+}
+
+func (s *LooseStore) createTemp() (*os.File, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(s.dir, "tmp-")
+}
+
+// install moves a temporary file into place as the object hash.
+func (s *LooseStore) install(tmp, hash string) error {
+	path := s.Path(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *LooseStore) Iterate(fn func(hash string) error) error {
+	dirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if hash := dir.Name() + entry.Name(); IsHash(hash) {
+				if err := fn(hash); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Delete removes an object's file, and its fan-out directory if that becomes empty.
+func (s *LooseStore) Delete(hash string) error {
+	path := s.Path(hash)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	// Fails harmlessly if other objects share the directory.
+	_ = os.Remove(filepath.Dir(path))
+	return nil
+}
+
+func (s *LooseStore) ModTime(hash string) (time.Time, error) {
+	info, err := os.Stat(s.Path(hash))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (s *LooseStore) SetModTime(hash string, t time.Time) error {
+	return os.Chtimes(s.Path(hash), t, t)
+}
@@ -0,0 +1,97 @@
+package object
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps objects in memory. It is intended for tests and for programs
+// that embed trac and manage persistence themselves.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+func (s *MemoryStore) Has(hash string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.objects[hash]
+	return ok, nil
+}
+
+func (s *MemoryStore) Get(hash string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.objects[hash]
+	if !ok {
+		return nil, fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	return slices.Clone(o.data), nil
+}
+
+func (s *MemoryStore) Put(hash string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[hash]; !ok {
+		s.objects[hash] = memoryObject{data: slices.Clone(data), modTime: time.Now()}
+	}
+	return nil
+}
+
+// Iterate visits the objects in sorted order. fn may modify the store.
+func (s *MemoryStore) Iterate(fn func(hash string) error) error {
+	s.mu.RLock()
+	hashes := slices.Sorted(maps.Keys(s.objects))
+	s.mu.RUnlock()
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[hash]; !ok {
+		return fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	delete(s.objects, hash)
+	return nil
+}
+
+func (s *MemoryStore) ModTime(hash string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.objects[hash]
+	if !ok {
+		return time.Time{}, fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	return o.modTime, nil
+}
+
+func (s *MemoryStore) SetModTime(hash string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[hash]
+	if !ok {
+		return fmt.Errorf("object %s: %w", hash, fs.ErrNotExist)
+	}
+	o.modTime = t
+	s.objects[hash] = o
+	return nil
+}
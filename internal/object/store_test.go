@@ -0,0 +1,146 @@
+package object
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func hashOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"loose":  func(t *testing.T) Store { return NewLooseStore(t.TempDir()) },
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"file": func(t *testing.T) Store {
+			s, err := OpenFileStore(filepath.Join(t.TempDir(), "objects.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			a, b := hashOf("a"), hashOf("b")
+
+			ok, err := s.Has(a)
+			require.NoError(t, err)
+			require.False(t, ok)
+			_, err = s.Get(a)
+			require.ErrorIs(t, err, fs.ErrNotExist)
+			require.ErrorIs(t, s.Delete(a), fs.ErrNotExist)
+
+			require.NoError(t, s.Put(a, []byte("a")))
+			require.NoError(t, s.Put(a, []byte("a")))
+			require.NoError(t, s.Put(b, []byte("b")))
+			ok, err = s.Has(a)
+			require.NoError(t, err)
+			require.True(t, ok)
+			data, err := s.Get(b)
+			require.NoError(t, err)
+			require.Equal(t, "b", string(data))
+
+			var hashes []string
+			require.NoError(t, s.Iterate(func(hash string) error {
+				hashes = append(hashes, hash)
+				return nil
+			}))
+			require.ElementsMatch(t, []string{a, b}, hashes)
+
+			ts := s.(TimeStore)
+			modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			require.NoError(t, ts.SetModTime(a, modTime))
+			got, err := ts.ModTime(a)
+			require.NoError(t, err)
+			require.True(t, modTime.Equal(got))
+
+			require.NoError(t, s.Delete(a))
+			ok, err = s.Has(a)
+			require.NoError(t, err)
+			require.False(t, ok)
+			_, err = s.Get(a)
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "objects.db")
+	a, b, c := hashOf("a"), hashOf("b"), hashOf("c")
+
+	t.Run("persists across reopening", func(t *testing.T) {
+		s, err := OpenFileStore(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(a, []byte("a")))
+		require.NoError(t, s.Put(b, []byte("b")))
+		require.NoError(t, s.Delete(a))
+		require.NoError(t, s.Close())
+
+		s, err = OpenFileStore(path)
+		require.NoError(t, err)
+		defer s.Close()
+		ok, err := s.Has(a)
+		require.NoError(t, err)
+		require.False(t, ok)
+		data, err := s.Get(b)
+		require.NoError(t, err)
+		require.Equal(t, "b", string(data))
+	})
+
+	t.Run("discards a partially written record", func(t *testing.T) {
+		s, err := OpenFileStore(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(c, []byte("c")))
+		require.NoError(t, s.Close())
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(path, info.Size()-2))
+
+		s, err = OpenFileStore(path)
+		require.NoError(t, err)
+		defer s.Close()
+		ok, err := s.Has(c)
+		require.NoError(t, err)
+		require.False(t, ok)
+		require.NoError(t, s.Put(c, []byte("c")))
+		data, err := s.Get(c)
+		require.NoError(t, err)
+		require.Equal(t, "c", string(data))
+	})
+
+	t.Run("compaction drops deleted objects", func(t *testing.T) {
+		s, err := OpenFileStore(path)
+		require.NoError(t, err)
+		defer s.Close()
+		before, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Compact())
+		after, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Less(t, after.Size(), before.Size())
+
+		data, err := s.Get(b)
+		require.NoError(t, err)
+		require.Equal(t, "b", string(data))
+		ok, err := s.Has(a)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("rejects other files", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "other")
+		require.NoError(t, os.WriteFile(other, []byte("not a store"), 0644))
+		_, err := OpenFileStore(other)
+		require.ErrorIs(t, err, ErrInvalidStore)
+	})
+}
@@ -0,0 +1,35 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/pack"
+)
+
+// ReadStream reads a pack stream into the object database and returns the
+// hashes of the objects it held. Objects that already exist are skipped, and
+// every object written is verified against its hash, so a bad stream never
+// leaves objects under the wrong name.
+func ReadStream(l *layout.Layout, r io.Reader) ([]string, error) {
+	read := func(hash string) ([]byte, error) { return ReadRaw(l, hash) }
+	write := func(hash string, data []byte) error {
+		if !IsHash(hash) {
+			return fmt.Errorf("%w: invalid object hash %q", pack.ErrInvalidPack, hash)
+		}
+		if Has(l, hash) {
+			return nil
+		}
+		if err := WriteRaw(l, hash, data, time.Time{}); err != nil {
+			return err
+		}
+		if err := Verify(l, hash); err != nil {
+			Delete(l, hash)
+			return err
+		}
+		return nil
+	}
+	return pack.ReadStream(r, read, write)
+}
@@ -0,0 +1,139 @@
+package pack
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// blockSize is the length of the base blocks indexed when searching for matches.
+// Matches shorter than a block are stored as insertions.
+const blockSize = 16
+
+// maxCandidates limits how many base offsets are compared for each block, keeping
+// delta computation fast for bases with many repeated blocks.
+const maxCandidates = 64
+
+// maxInsert is the longest run of literal bytes a single insert instruction holds.
+const maxInsert = 0x7f
+
+// copyOp marks a copy instruction. Any other instruction byte is the length of an insertion.
+const copyOp = 0x80
+
+// Delta encodes target as a sequence of instructions that rebuild it from base.
+//
+// A delta starts with the lengths of base and target as uvarints, followed by
+// instructions. A copy instruction is the byte 0x80 followed by a uvarint offset
+// into base and a uvarint length. Any other byte n between 1 and 127 inserts the
+// n bytes that follow it.
+func Delta(base, target []byte) []byte {
+	index := make(map[string][]int)
+	for off := 0; off+blockSize <= len(base); off += blockSize {
+		key := string(base[off : off+blockSize])
+		if len(index[key]) < maxCandidates {
+			index[key] = append(index[key], off)
+		}
+	}
+
+	delta := binary.AppendUvarint(nil, uint64(len(base)))
+	delta = binary.AppendUvarint(delta, uint64(len(target)))
+	pending := 0 // Start of target bytes not yet encoded
+	for i := 0; i+blockSize <= len(target); {
+		bestOff, bestLen, bestBack := 0, 0, 0
+		for _, off := range index[string(target[i:i+blockSize])] {
+			n := 0
+			for off+n < len(base) && i+n < len(target) && base[off+n] == target[i+n] {
+				n++
+			}
+			// Extend backwards over bytes that would otherwise be inserted.
+			back := 0
+			for back < i-pending && back < off && base[off-back-1] == target[i-back-1] {
+				back++
+			}
+			if n+back > bestLen+bestBack {
+				bestOff, bestLen, bestBack = off, n, back
+			}
+		}
+		if bestLen < blockSize {
+			i++
+			continue
+		}
+		delta = appendInsert(delta, target[pending:i-bestBack])
+		delta = append(delta, copyOp)
+		delta = binary.AppendUvarint(delta, uint64(bestOff-bestBack))
+		delta = binary.AppendUvarint(delta, uint64(bestLen+bestBack))
+		i += bestLen
+		pending = i
+	}
+	return appendInsert(delta, target[pending:])
+}
+
+func appendInsert(delta, data []byte) []byte {
+	for len(data) > 0 {
+		n := min(len(data), maxInsert)
+		delta = append(delta, byte(n))
+		delta = append(delta, data[:n]...)
+		data = data[n:]
+	}
+	return delta
+}
+
+// ApplyDelta rebuilds the target of a delta from its base.
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	baseLen, n := binary.Uvarint(delta)
+	if n <= 0 || baseLen != uint64(len(base)) {
+		return nil, fmt.Errorf("%w: base length mismatch", ErrInvalidDelta)
+	}
+	delta = delta[n:]
+	targetLen, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: malformed target length", ErrInvalidDelta)
+	}
+	delta = delta[n:]
+	// Every instruction takes at least one byte, and a copy, which takes at
+	// least three, adds at most the whole base, so a target longer than that
+	// cannot be what the delta builds. Nor is the header trusted for more
+	// memory than the base and delta take up.
+	if targetLen > uint64(len(delta))+uint64(len(delta)/3)*uint64(len(base)) {
+		return nil, fmt.Errorf("%w: target length %d out of range", ErrInvalidDelta, targetLen)
+	}
+	target := make([]byte, 0, min(targetLen, uint64(len(base)+len(delta))))
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op == copyOp:
+			off, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return nil, fmt.Errorf("%w: malformed copy offset", ErrInvalidDelta)
+			}
+			delta = delta[n:]
+			size, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return nil, fmt.Errorf("%w: malformed copy length", ErrInvalidDelta)
+			}
+			delta = delta[n:]
+			if off > uint64(len(base)) || size > uint64(len(base))-off {
+				return nil, fmt.Errorf("%w: copy out of range", ErrInvalidDelta)
+			}
+			if size > targetLen-uint64(len(target)) {
+				return nil, fmt.Errorf("%w: target length mismatch", ErrInvalidDelta)
+			}
+			target = append(target, base[off:off+size]...)
+		case op > 0 && op <= maxInsert:
+			if int(op) > len(delta) {
+				return nil, fmt.Errorf("%w: truncated insert", ErrInvalidDelta)
+			}
+			if uint64(op) > targetLen-uint64(len(target)) {
+				return nil, fmt.Errorf("%w: target length mismatch", ErrInvalidDelta)
+			}
+			target = append(target, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, fmt.Errorf("%w: unknown instruction 0x%02x", ErrInvalidDelta, op)
+		}
+	}
+	if uint64(len(target)) != targetLen {
+		return nil, fmt.Errorf("%w: target length mismatch", ErrInvalidDelta)
+	}
+	return target, nil
+}
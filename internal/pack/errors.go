@@ -0,0 +1,9 @@
+package pack
+
+import "errors"
+
+var (
+	ErrInvalidDelta = errors.New("invalid delta")
+	ErrInvalidPack  = errors.New("invalid pack")
+	ErrNotFound     = errors.New("object not found in pack")
+)
@@ -0,0 +1,441 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// A pack stores many objects in a single file, each either zlib-compressed in
+// full or as a zlib-compressed delta against another object in the same pack.
+// Every pack is accompanied by an index for looking objects up by hash.
+//
+// Pack file layout:
+//
+//	"TPCK" | version (uint32) | object count (uint32)
+//	entries...
+//	SHA-256 of everything above
+//
+// Each entry is a type byte, the uncompressed length of its data as a uvarint,
+// the raw 32-byte hash of the base object for deltas, and the zlib-compressed
+// data (the object itself for full entries, the delta for delta entries).
+//
+// Index file layout:
+//
+//	"TIDX" | version (uint32)
+//	fanout table: 256 uint32s, entry i counting objects whose first hash byte is <= i
+//	sorted raw object hashes (32 bytes each)
+//	pack offsets (uint64 each), in the same order as the hashes
+//	SHA-256 of the pack file
+//	SHA-256 of everything above
+const (
+	packMagic = "TPCK"
+	idxMagic  = "TIDX"
+	version   = 1
+	hashSize  = sha256.Size
+)
+
+// Entry types.
+const (
+	typeFull  byte = 1
+	typeDelta byte = 2
+)
+
+// Extensions of pack and index files.
+const (
+	PackExt  = ".pack"
+	IndexExt = ".idx"
+)
+
+// Pack is an opened pack and its index.
+type Pack struct {
+	path    string // Path to the pack file
+	fanout  [256]uint32
+	hashes  []byte // Sorted raw hashes, hashSize bytes each
+	offsets []uint64
+}
+
+// Open opens the pack described by the index file at idxPath.
+func Open(idxPath string) (*Pack, error) {
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(idxMagic) + 4
+	fanoutLen := 256 * 4
+	if len(data) < headerLen+fanoutLen+2*hashSize || string(data[:len(idxMagic)]) != idxMagic {
+		return nil, fmt.Errorf("%w: %s: bad index header", ErrInvalidPack, idxPath)
+	}
+	sum := sha256.Sum256(data[:len(data)-hashSize])
+	if !bytes.Equal(sum[:], data[len(data)-hashSize:]) {
+		return nil, fmt.Errorf("%w: %s: index checksum mismatch", ErrInvalidPack, idxPath)
+	}
+	if v := binary.BigEndian.Uint32(data[len(idxMagic):]); v != version {
+		return nil, fmt.Errorf("%w: %s: unsupported version %d", ErrInvalidPack, idxPath, v)
+	}
+
+	p := &Pack{path: strings.TrimSuffix(idxPath, IndexExt) + PackExt}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[headerLen+i*4:])
+	}
+	count := int(p.fanout[255])
+	body := data[headerLen+fanoutLen : len(data)-2*hashSize]
+	if len(body) != count*(hashSize+8) {
+		return nil, fmt.Errorf("%w: %s: truncated index", ErrInvalidPack, idxPath)
+	}
+	p.hashes = body[:count*hashSize]
+	p.offsets = make([]uint64, count)
+	for i := range p.offsets {
+		p.offsets[i] = binary.BigEndian.Uint64(body[count*hashSize+i*8:])
+	}
+	return p, nil
+}
+
+// Path returns the path of the pack file.
+func (p *Pack) Path() string {
+	return p.path
+}
+
+// Hashes returns the hashes of all objects in the pack, in sorted order.
+func (p *Pack) Hashes() []string {
+	hashes := make([]string, len(p.offsets))
+	for i := range hashes {
+		hashes[i] = hex.EncodeToString(p.hashes[i*hashSize : (i+1)*hashSize])
+	}
+	return hashes
+}
+
+// Has reports whether the pack contains the object.
+func (p *Pack) Has(hash string) bool {
+	_, ok := p.find(hash)
+	return ok
+}
+
+// find returns the offset of an object in the pack file.
+func (p *Pack) find(hash string) (uint64, bool) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != hashSize {
+		return 0, false
+	}
+	lo := 0
+	if raw[0] > 0 {
+		lo = int(p.fanout[raw[0]-1])
+	}
+	hi := int(p.fanout[raw[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*hashSize:(lo+i+1)*hashSize], raw) >= 0
+	})
+	if i < hi && bytes.Equal(p.hashes[i*hashSize:(i+1)*hashSize], raw) {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+// Read returns the contents of an object, resolving any chain of deltas. A
+// chain that refers back to itself, or is longer than any Write produces, is
+// an invalid pack.
+func (p *Pack) Read(hash string) ([]byte, error) {
+	offset, ok := p.find(hash)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Follow the chain down to the full object, then apply the deltas on
+	// the way back up.
+	var deltas [][]byte
+	seen := map[string]bool{hash: true}
+	for current := hash; ; {
+		r := bufio.NewReader(io.NewSectionReader(f, int64(offset), 1<<62))
+		entryType, base, data, err := readEntry(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPack, current, err)
+		}
+		if entryType == typeFull {
+			for i := len(deltas) - 1; i >= 0; i-- {
+				if data, err = ApplyDelta(data, deltas[i]); err != nil {
+					return nil, err
+				}
+			}
+			return data, nil
+		}
+		deltas = append(deltas, data)
+		switch {
+		case seen[base]:
+			return nil, fmt.Errorf("%w: %s: delta chain refers back to %s", ErrInvalidPack, hash, base)
+		case len(deltas) > MaxDepth:
+			return nil, fmt.Errorf("%w: %s: delta chain longer than %d", ErrInvalidPack, hash, MaxDepth)
+		}
+		seen[base] = true
+		if offset, ok = p.find(base); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, base)
+		}
+		current = base
+	}
+}
+
+// byteReader is a reader that zlib can decompress from without reading past
+// the end of the compressed data.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// readEntry reads an entry, returning its type, the hash of its base for deltas
+// and its uncompressed data. The compressed data is read to its end, leaving r
+// at the start of whatever follows the entry.
+func readEntry(r byteReader) (entryType byte, base string, data []byte, err error) {
+	entryType, err = r.ReadByte()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	switch entryType {
+	case typeFull:
+	case typeDelta:
+		raw := make([]byte, hashSize)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return 0, "", nil, err
+		}
+		base = hex.EncodeToString(raw)
+	default:
+		return 0, "", nil, fmt.Errorf("unknown entry type %d", entryType)
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer zr.Close()
+	// Reading past the data consumes the zlib checksum and checks it.
+	data, err = io.ReadAll(io.LimitReader(zr, int64(size)+1))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if uint64(len(data)) != size {
+		return 0, "", nil, fmt.Errorf("entry is %d bytes, expected %d", len(data), size)
+	}
+	return entryType, base, data, nil
+}
+
+// MaxDepth is the longest delta chain Write produces, whatever Options.Depth
+// asks for, and so the longest Read follows.
+const MaxDepth = 4095
+
+// Options controls how objects are deltified when writing a pack.
+type Options struct {
+	Window int // Number of preceding objects to try as delta bases, zero disables deltas
+	Depth  int // Maximum length of a delta chain, at most MaxDepth
+}
+
+// Stats describes a written pack.
+type Stats struct {
+	Name    string // Name of the pack, without extension
+	Objects int
+	Deltas  int
+	Size    int64 // Size of the pack file in bytes
+}
+
+// candidate is a recently written object that may serve as a delta base.
+type candidate struct {
+	hash  string
+	data  []byte
+	depth int
+}
+
+// Write packs the given objects into dir, reading each object's content with read.
+// Objects are tried as delta bases for the objects that follow them, so callers
+// should order similar objects next to each other.
+func Write(dir string, hashes []string, read func(hash string) ([]byte, error), opts Options) (*Stats, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, "tmp-pack-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := &countingWriter{w: bufio.NewWriter(tmp), h: sha256.New()}
+	w.Write([]byte(packMagic))
+	w.Write(binary.BigEndian.AppendUint32(nil, version))
+	w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(hashes))))
+
+	stats := &Stats{Objects: len(hashes)}
+	offsets := make(map[string]uint64, len(hashes))
+	e := &encoder{w: w, opts: opts, stats: stats}
+	for _, hash := range hashes {
+		data, err := read(hash)
+		if err != nil {
+			return nil, err
+		}
+		offsets[hash] = uint64(w.n)
+		if err := e.encode(hash, data); err != nil {
+			return nil, err
+		}
+	}
+	checksum := w.h.Sum(nil)
+	w.Write(checksum)
+	if err := w.w.Flush(); err != nil {
+		return nil, err
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	stats.Name = "pack-" + hex.EncodeToString(checksum)
+	stats.Size = w.n
+	packPath := filepath.Join(dir, stats.Name+PackExt)
+	if err := os.Rename(tmp.Name(), packPath); err != nil {
+		return nil, err
+	}
+	// The index is written last so readers never find an index without its pack.
+	if err := writeIndex(filepath.Join(dir, stats.Name+IndexExt), offsets, checksum); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// encoder writes pack entries, trying recently written objects as delta bases.
+type encoder struct {
+	w      *countingWriter
+	opts   Options
+	window []candidate
+	stats  *Stats
+}
+
+// encode writes the entry for an object, as a delta if one of the objects in
+// the window makes a good base.
+func (e *encoder) encode(hash string, data []byte) error {
+	var best *candidate
+	var bestDelta []byte
+	for i := range e.window {
+		c := &e.window[i]
+		if c.depth >= min(e.opts.Depth, MaxDepth) {
+			continue
+		}
+		delta := Delta(c.data, data)
+		if len(delta) < len(data)/2 && (bestDelta == nil || len(delta) < len(bestDelta)) {
+			best, bestDelta = c, delta
+		}
+	}
+
+	var err error
+	depth := 0
+	if best != nil {
+		raw, _ := hex.DecodeString(best.hash)
+		e.w.Write([]byte{typeDelta})
+		e.w.Write(binary.AppendUvarint(nil, uint64(len(bestDelta))))
+		e.w.Write(raw)
+		err = compress(e.w, bestDelta)
+		depth = best.depth + 1
+		e.stats.Deltas++
+	} else {
+		e.w.Write([]byte{typeFull})
+		e.w.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		err = compress(e.w, data)
+	}
+	if err != nil {
+		return err
+	}
+	if e.w.err != nil {
+		return e.w.err
+	}
+
+	if e.opts.Window > 0 {
+		e.window = append(e.window, candidate{hash: hash, data: data, depth: depth})
+		if len(e.window) > e.opts.Window {
+			e.window = e.window[1:]
+		}
+	}
+	return nil
+}
+
+func compress(w io.Writer, data []byte) error {
+	zw := zlib.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeIndex(path string, offsets map[string]uint64, packChecksum []byte) error {
+	hashes := make([]string, 0, len(offsets))
+	for hash := range offsets {
+		hashes = append(hashes, hash)
+	}
+	slices.Sort(hashes)
+
+	var fanout [256]uint32
+	raw := make([]byte, 0, len(hashes)*hashSize)
+	for _, hash := range hashes {
+		b, err := hex.DecodeString(hash)
+		if err != nil || len(b) != hashSize {
+			return fmt.Errorf("invalid object hash %q", hash)
+		}
+		raw = append(raw, b...)
+		fanout[b[0]]++
+	}
+	for i := 1; i < len(fanout); i++ {
+		fanout[i] += fanout[i-1]
+	}
+
+	data := []byte(idxMagic)
+	data = binary.BigEndian.AppendUint32(data, version)
+	for _, n := range fanout {
+		data = binary.BigEndian.AppendUint32(data, n)
+	}
+	data = append(data, raw...)
+	for _, hash := range hashes {
+		data = binary.BigEndian.AppendUint64(data, offsets[hash])
+	}
+	data = append(data, packChecksum...)
+	sum := sha256.Sum256(data)
+	data = append(data, sum[:]...)
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// countingWriter hashes and counts everything written through it, remembering the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	h   hash.Hash
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.h.Write(p[:n])
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,198 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelta(t *testing.T) {
+	t.Parallel()
+	var base bytes.Buffer
+	for i := range 200 {
+		fmt.Fprintf(&base, "line %d of the original file\n", i)
+	}
+	tests := []struct {
+		name   string
+		base   []byte
+		target []byte
+	}{
+		{name: "identical", base: base.Bytes(), target: base.Bytes()},
+		{name: "empty base", base: nil, target: []byte("some content")},
+		{name: "empty target", base: base.Bytes(), target: nil},
+		{name: "insertion", base: base.Bytes(), target: bytes.Replace(base.Bytes(), []byte("line 100 "), []byte("inserted\nline 100 "), 1)},
+		{name: "deletion", base: base.Bytes(), target: bytes.Replace(base.Bytes(), []byte("line 50 of the original file\n"), nil, 1)},
+		{name: "long insertion", base: []byte("short"), target: bytes.Repeat([]byte("x"), 1000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			delta := Delta(tt.base, tt.target)
+			actual, err := ApplyDelta(tt.base, delta)
+			require.NoError(t, err)
+			require.Equal(t, string(tt.target), string(actual))
+		})
+	}
+
+	// small edits to large content produce small deltas
+	target := bytes.Replace(base.Bytes(), []byte("line 100 "), []byte("LINE 100 "), 1)
+	require.Less(t, len(Delta(base.Bytes(), target)), 100)
+
+	_, err := ApplyDelta([]byte("wrong base"), Delta(base.Bytes(), target))
+	require.ErrorIs(t, err, ErrInvalidDelta)
+
+	// a target length no delta of this size could build is rejected before
+	// anything is allocated for it
+	oversized := binary.AppendUvarint(nil, 4)
+	oversized = binary.AppendUvarint(oversized, 1<<45)
+	oversized = append(oversized, 3, 'a', 'b', 'c')
+	_, err = ApplyDelta([]byte("base"), oversized)
+	require.ErrorIs(t, err, ErrInvalidDelta)
+	// nor does a delta build more than its header says
+	longer := binary.AppendUvarint(nil, 4)
+	longer = binary.AppendUvarint(longer, 2)
+	longer = append(longer, 3, 'a', 'b', 'c')
+	_, err = ApplyDelta([]byte("base"), longer)
+	require.ErrorIs(t, err, ErrInvalidDelta)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	objects := make(map[string][]byte)
+	var hashes []string
+	for i := range 20 {
+		var content bytes.Buffer
+		for j := range 100 {
+			if j == i {
+				fmt.Fprintf(&content, "version %d\n", i)
+				continue
+			}
+			fmt.Fprintf(&content, "shared line %d\n", j)
+		}
+		sum := sha256.Sum256(content.Bytes())
+		hash := hex.EncodeToString(sum[:])
+		objects[hash] = content.Bytes()
+		hashes = append(hashes, hash)
+	}
+	read := func(hash string) ([]byte, error) { return objects[hash], nil }
+
+	stats, err := Write(dir, hashes, read, Options{Window: 4, Depth: 3})
+	require.NoError(t, err)
+	require.Equal(t, 20, stats.Objects)
+	require.Positive(t, stats.Deltas)
+
+	p, err := Open(filepath.Join(dir, stats.Name+IndexExt))
+	require.NoError(t, err)
+	require.Len(t, p.Hashes(), 20)
+	for hash, content := range objects {
+		require.True(t, p.Has(hash))
+		data, err := p.Read(hash)
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+	}
+	require.False(t, p.Has(hex.EncodeToString(make([]byte, sha256.Size))))
+	_, err = p.Read(hex.EncodeToString(make([]byte, sha256.Size)))
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+	objects := make(map[string][]byte)
+	var hashes []string
+	for i := range 10 {
+		content := bytes.Repeat([]byte(fmt.Sprintf("shared line %d\n", i%2)), 100)
+		content = fmt.Appendf(content, "version %d\n", i)
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		objects[hash] = content
+		hashes = append(hashes, hash)
+	}
+	read := func(hash string) ([]byte, error) { return objects[hash], nil }
+
+	var buf bytes.Buffer
+	stats, err := WriteStream(&buf, hashes, read, Options{Window: 4, Depth: 3})
+	require.NoError(t, err)
+	require.Equal(t, 10, stats.Objects)
+	require.Positive(t, stats.Deltas)
+	require.EqualValues(t, buf.Len(), stats.Size)
+
+	received := make(map[string][]byte)
+	readReceived := func(hash string) ([]byte, error) { return received[hash], nil }
+	write := func(hash string, data []byte) error {
+		received[hash] = data
+		return nil
+	}
+	got, err := ReadStream(bytes.NewReader(buf.Bytes()), readReceived, write)
+	require.NoError(t, err)
+	require.Equal(t, hashes, got)
+	require.Equal(t, objects, received)
+
+	// a damaged stream is rejected
+	damaged := bytes.Clone(buf.Bytes())
+	damaged[len(damaged)-1] ^= 0xff
+	_, err = ReadStream(bytes.NewReader(damaged), readReceived, write)
+	require.ErrorIs(t, err, ErrInvalidPack)
+	_, err = ReadStream(bytes.NewReader(buf.Bytes()[:buf.Len()/2]), readReceived, write)
+	require.ErrorIs(t, err, ErrInvalidPack)
+	_, err = ReadStream(strings.NewReader("TPCK"), readReceived, write)
+	require.ErrorIs(t, err, ErrInvalidPack)
+}
+
+func TestReadDeltaCycle(t *testing.T) {
+	t.Parallel()
+	hashOf := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	a, b := hashOf("a"), hashOf("b")
+
+	tests := []struct {
+		name  string
+		bases map[string]string // Base of each delta in the pack
+	}{
+		{name: "self-referencing delta", bases: map[string]string{a: a}},
+		{name: "cyclic deltas", bases: map[string]string{a: b, b: a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			var buf bytes.Buffer
+			buf.WriteString(packMagic)
+			buf.Write(binary.BigEndian.AppendUint32(nil, version))
+			buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(tt.bases))))
+			offsets := make(map[string]uint64)
+			for hash, base := range tt.bases {
+				offsets[hash] = uint64(buf.Len())
+				delta := Delta([]byte("base"), []byte("target"))
+				raw, err := hex.DecodeString(base)
+				require.NoError(t, err)
+				buf.WriteByte(typeDelta)
+				buf.Write(binary.AppendUvarint(nil, uint64(len(delta))))
+				buf.Write(raw)
+				require.NoError(t, compress(&buf, delta))
+			}
+			checksum := sha256.Sum256(buf.Bytes())
+			buf.Write(checksum[:])
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "pack-test"+PackExt), buf.Bytes(), 0644))
+			require.NoError(t, writeIndex(filepath.Join(dir, "pack-test"+IndexExt), offsets, checksum[:]))
+
+			p, err := Open(filepath.Join(dir, "pack-test"+IndexExt))
+			require.NoError(t, err)
+			for hash := range tt.bases {
+				_, err := p.Read(hash)
+				require.ErrorIs(t, err, ErrInvalidPack)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// A pack stream carries objects from one repository to another. It is laid
+// out like a pack file, except that each entry is preceded by the raw hash of
+// its object, since the receiving side has no index to find it in and objects
+// such as chunk manifests are not named by the hash of their data:
+//
+//	"TSTR" | version (uint32) | object count (uint32)
+//	(raw object hash | entry)...
+//	SHA-256 of everything above
+//
+// Delta bases always come earlier in the stream than the objects based on them.
+const streamMagic = "TSTR"
+
+// WriteStream writes the given objects to w as a pack stream, reading each
+// object's content with read. As with Write, similar objects should be next to
+// each other for deltas to be found.
+func WriteStream(w io.Writer, hashes []string, read func(hash string) ([]byte, error), opts Options) (*Stats, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw, h: sha256.New()}
+	cw.Write([]byte(streamMagic))
+	cw.Write(binary.BigEndian.AppendUint32(nil, version))
+	cw.Write(binary.BigEndian.AppendUint32(nil, uint32(len(hashes))))
+
+	stats := &Stats{Objects: len(hashes)}
+	e := &encoder{w: cw, opts: opts, stats: stats}
+	for _, hash := range hashes {
+		raw, err := hex.DecodeString(hash)
+		if err != nil || len(raw) != hashSize {
+			return nil, fmt.Errorf("invalid object hash %q", hash)
+		}
+		data, err := read(hash)
+		if err != nil {
+			return nil, err
+		}
+		cw.Write(raw)
+		if err := e.encode(hash, data); err != nil {
+			return nil, err
+		}
+	}
+	cw.Write(cw.h.Sum(nil))
+	if cw.err != nil {
+		return nil, cw.err
+	}
+	stats.Size = cw.n
+	return stats, bw.Flush()
+}
+
+// ReadStream reads a pack stream from r, passing each object to write in the
+// order they were sent. The bases of deltas are read back with read, so write
+// must store objects where read can find them. It returns the hashes of the
+// objects read. The stream's checksum is only verified once every object has
+// been written, so callers should treat the objects as untrusted until
+// ReadStream returns without error.
+func ReadStream(r io.Reader, read func(hash string) ([]byte, error), write func(hash string, data []byte) error) ([]string, error) {
+	hr := &hashingReader{r: bufio.NewReader(r), h: sha256.New()}
+	header := make([]byte, len(streamMagic)+8)
+	if _, err := io.ReadFull(hr, header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPack, err)
+	}
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return nil, fmt.Errorf("%w: not a pack stream", ErrInvalidPack)
+	}
+	if v := binary.BigEndian.Uint32(header[len(streamMagic):]); v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidPack, v)
+	}
+	count := binary.BigEndian.Uint32(header[len(streamMagic)+4:])
+
+	var hashes []string
+	raw := make([]byte, hashSize)
+	for range count {
+		if _, err := io.ReadFull(hr, raw); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPack, err)
+		}
+		hash := hex.EncodeToString(raw)
+		entryType, base, data, err := readEntry(hr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPack, hash, err)
+		}
+		if entryType == typeDelta {
+			baseData, err := read(base)
+			if err != nil {
+				return nil, err
+			}
+			if data, err = ApplyDelta(baseData, data); err != nil {
+				return nil, fmt.Errorf("%s: %w", hash, err)
+			}
+		}
+		if err := write(hash, data); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	sum := hr.h.Sum(nil)
+	checksum := make([]byte, hashSize)
+	if _, err := io.ReadFull(hr.r, checksum); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPack, err)
+	}
+	if !bytes.Equal(sum, checksum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidPack)
+	}
+	return hashes, nil
+}
+
+// hashingReader hashes everything read through it.
+type hashingReader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	hr.h.Write(p[:n])
+	return n, err
+}
+
+func (hr *hashingReader) ReadByte() (byte, error) {
+	b, err := hr.r.ReadByte()
+	if err == nil {
+		hr.h.Write([]byte{b})
+	}
+	return b, err
+}
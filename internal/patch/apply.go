@@ -0,0 +1,109 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/diff"
+)
+
+// HunkResult describes where a hunk was applied.
+type HunkResult struct {
+	Line   int // Line of the old content, counting from 1, at which the hunk applied
+	Offset int // Lines between where the hunk was recorded and where it applied
+	Fuzz   int // Context lines ignored at each end of the hunk for it to apply
+}
+
+// Apply applies the hunks of f to old, lines split by Lines, and returns the
+// new lines and where each hunk applied.
+//
+// Each hunk is first looked for where it was recorded, shifted by the offset
+// the hunk before it applied at, and then at increasing distances on either
+// side. If that fails, the search is repeated ignoring up to fuzz lines of
+// context at either end of the hunk, for a patch made against a version that
+// differs near its changes.
+func (f *File) Apply(old []string, fuzz int) ([]string, []HunkResult, error) {
+	if f.Binary {
+		return nil, nil, fmt.Errorf("%w: %s: binary changes record no content", ErrDoesNotApply, f.Path())
+	}
+	var result []string
+	var results []HunkResult
+	pos, offset := 0, 0
+	for n, h := range f.Hunks {
+		applied := false
+		for fz := 0; fz <= fuzz && !applied; fz++ {
+			trimmed := trimContext(h, fz)
+			preimage := lines(trimmed, diff.Insert)
+			at := find(old, preimage, trimmed.OldStart+offset, pos)
+			if at < 0 {
+				continue
+			}
+			result = append(result, old[pos:at]...)
+			result = append(result, lines(trimmed, diff.Delete)...)
+			pos = at + len(preimage)
+			offset = at - trimmed.OldStart
+			results = append(results, HunkResult{Line: at + 1, Offset: offset, Fuzz: fz})
+			applied = true
+		}
+		if !applied {
+			return nil, nil, fmt.Errorf("%w: %s: hunk #%d at line %d", ErrDoesNotApply, f.Path(), n+1, h.OldStart+1)
+		}
+	}
+	return append(result, old[pos:]...), results, nil
+}
+
+// trimContext returns h without up to n unchanged lines at its start and end.
+func trimContext(h diff.Hunk, n int) diff.Hunk {
+	edits := h.Edits
+	for i := 0; i < n && len(edits) > 0 && edits[0].Op == diff.Equal; i++ {
+		edits = edits[1:]
+		h.OldStart++
+		h.NewStart++
+	}
+	for i := 0; i < n && len(edits) > 0 && edits[len(edits)-1].Op == diff.Equal; i++ {
+		edits = edits[:len(edits)-1]
+	}
+	h.Edits = edits
+	return h
+}
+
+// lines returns the text of the edits of h, leaving out those with op.
+func lines(h diff.Hunk, skip diff.Op) []string {
+	var text []string
+	for _, e := range h.Edits {
+		if e.Op != skip {
+			text = append(text, e.Text)
+		}
+	}
+	return text
+}
+
+// find returns the position at or after from closest to want at which old
+// holds lines, or -1.
+func find(old, lines []string, want, from int) int {
+	last := len(old) - len(lines)
+	for d := 0; ; d++ {
+		before, after := want-d, want+d
+		if before < from && after > last {
+			return -1
+		}
+		for _, at := range []int{before, after} {
+			if at >= from && at <= last && slices.Equal(old[at:at+len(lines)], lines) {
+				return at
+			}
+		}
+	}
+}
+
+// Merge merges the change from base to theirs into ours, all split by Lines,
+// as diff.Merge does. The last line of ours or theirs may end up inside a
+// conflict rather than at the end, so only the last merged line keeps its
+// mark for a missing newline.
+func Merge(base, ours, theirs []string) ([]string, int) {
+	merged, conflicts := diff.Merge(base, ours, theirs, "ours", "theirs")
+	for i := range max(len(merged)-1, 0) {
+		merged[i] = strings.TrimSuffix(merged[i], noNewline)
+	}
+	return merged, conflicts
+}
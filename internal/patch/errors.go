@@ -0,0 +1,10 @@
+package patch
+
+import "errors"
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrDoesNotApply = errors.New("patch does not apply")
+	ErrNoPatches    = errors.New("no patches found")
+	ErrConflict     = errors.New("patch applied with conflicts")
+)
@@ -0,0 +1,193 @@
+package patch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Message is a commit carried as a patch email.
+type Message struct {
+	Hash    string        // Commit the patch was made from, if known
+	Author  *mail.Address // Who wrote the change, if known
+	Date    time.Time     // When the change was written
+	Subject string        // First line of the commit message
+	Body    string        // Rest of the commit message, without the blank line after the subject
+	Files   []*File
+}
+
+// Message returns the commit message the email carries.
+func (m *Message) Message() string {
+	if m.Body == "" {
+		return m.Subject
+	}
+	return m.Subject + "\n\n" + m.Body
+}
+
+// mboxDate is the fixed date git puts in the "From " line that starts each
+// message, which marks the message as a patch rather than delivered mail.
+const mboxDate = "Mon Sep 17 00:00:00 2001"
+
+// fromLine matches body lines that need quoting in an mbox file, since a line
+// starting with "From " would begin a new message.
+var fromLine = regexp.MustCompile(`^>*From `)
+
+// subjectPrefix matches tags such as "[PATCH 2/3] " at the start of a subject.
+var subjectPrefix = regexp.MustCompile(`^(\s*\[[^\]]*\])+\s*`)
+
+// Write writes m as a message of an mbox file. number and total place the
+// patch in its series, shown in the subject as "[PATCH 2/3]", or as "[PATCH]"
+// when the series has a single patch.
+func (m *Message) Write(w io.Writer, number, total int) error {
+	bw := bufio.NewWriter(w)
+	hash := m.Hash
+	if hash == "" {
+		hash = zeroHash
+	}
+	fmt.Fprintf(bw, "From %s %s\n", hash, mboxDate)
+	if m.Author != nil {
+		fmt.Fprintf(bw, "From: %s\n", formatAddress(m.Author))
+	}
+	fmt.Fprintf(bw, "Date: %s\n", m.Date.Format(time.RFC1123Z))
+	tag := "[PATCH]"
+	if total > 1 {
+		tag = fmt.Sprintf("[PATCH %d/%d]", number, total)
+	}
+	fmt.Fprintf(bw, "Subject: %s %s\n", tag, mime.QEncoding.Encode("utf-8", m.Subject))
+	if !isASCII(m.Subject + m.Body) {
+		fmt.Fprintf(bw, "MIME-Version: 1.0\nContent-Type: text/plain; charset=UTF-8\nContent-Transfer-Encoding: 8bit\n")
+	}
+	bw.WriteString("\n")
+	if m.Body != "" {
+		for _, line := range strings.Split(m.Body, "\n") {
+			if fromLine.MatchString(line) {
+				line = ">" + line
+			}
+			bw.WriteString(line + "\n")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("---\n")
+	for _, f := range m.Files {
+		bw.WriteString(f.String())
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// formatAddress formats an address as "Name <email>", quoting the name only
+// if it needs to be.
+func formatAddress(a *mail.Address) string {
+	plain := a.Name + " <" + a.Address + ">"
+	if parsed, err := mail.ParseAddress(plain); err == nil && parsed.Name == a.Name && isASCII(a.Name) {
+		return plain
+	}
+	return a.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadMailbox reads the patch emails in an mbox file. Input without the
+// "From " line that starts each message in an mbox file is read as a single
+// email.
+func ReadMailbox(r io.Reader) ([]*Message, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var texts []string
+	var current strings.Builder
+	for i, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.HasPrefix(line, "From ") {
+			if i > 0 {
+				texts = append(texts, current.String())
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteString(line)
+	}
+	texts = append(texts, current.String())
+
+	var messages []*Message
+	for _, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		m, err := readMessage(text)
+		if err != nil {
+			return nil, fmt.Errorf("patch %d: %w", len(messages)+1, err)
+		}
+		messages = append(messages, m)
+	}
+	if len(messages) == 0 {
+		return nil, ErrNoPatches
+	}
+	return messages, nil
+}
+
+// readMessage reads a single patch email.
+func readMessage(text string) (*Message, error) {
+	msg, err := mail.ReadMessage(strings.NewReader(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	m := &Message{}
+	if from := msg.Header.Get("From"); from != "" {
+		if m.Author, err = mail.ParseAddress(from); err != nil {
+			return nil, fmt.Errorf("%w: From: %w", ErrInvalidPatch, err)
+		}
+	}
+	if date := msg.Header.Get("Date"); date != "" {
+		if m.Date, err = mail.ParseDate(date); err != nil {
+			return nil, fmt.Errorf("%w: Date: %w", ErrInvalidPatch, err)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: Subject: %w", ErrInvalidPatch, err)
+	}
+	m.Subject = subjectPrefix.ReplaceAllString(subject, "")
+
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	// The commit message ends at the "---" line, or at the first change for
+	// patches written without one.
+	var message []string
+	lines := strings.SplitAfter(string(body), "\n")
+	rest := len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "diff --") {
+			rest = i
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") && fromLine.MatchString(trimmed) {
+			trimmed = trimmed[1:]
+		}
+		message = append(message, trimmed)
+	}
+	m.Body = strings.TrimSpace(strings.Join(message, "\n"))
+	if m.Files, err = Parse(strings.NewReader(strings.Join(lines[rest:], ""))); err != nil {
+		return nil, err
+	}
+	if len(m.Files) == 0 {
+		return nil, fmt.Errorf("%w: %q has no changes", ErrInvalidPatch, m.Subject)
+	}
+	return m, nil
+}
@@ -0,0 +1,398 @@
+// Package patch reads and writes changes to files as unified diffs, applies
+// them to content that may have drifted since the diff was made, and carries
+// commits as patch emails in mbox files.
+package patch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/internal/filemode"
+)
+
+// noNewline marks the last line of content that does not end in a newline.
+// Text files never hold a NUL byte, since content with one is binary, so the
+// marker cannot be mistaken for part of a line.
+const noNewline = "\x00"
+
+// noNewlineLine follows a diff line that has no newline at the end of the file.
+const noNewlineLine = `\ No newline at end of file`
+
+// zeroHash stands in the index line for the content of a file that does not exist.
+var zeroHash = strings.Repeat("0", 64)
+
+// File is the change a patch makes to one file.
+type File struct {
+	OldPath string // Slash-separated path before the change, empty for added files
+	NewPath string // Slash-separated path after the change, empty for deleted files
+	OldHash string // Hash of the content before the change, when the patch records it
+	NewHash string // Hash of the content after the change, when the patch records it
+	OldMode string // Mode before the change, such as filemode.Regular, when the patch records it
+	NewMode string // Mode after the change, when the patch records it
+	Binary  bool   // The patch only records that the content changed, not how
+	Hunks   []diff.Hunk
+}
+
+// Path returns the path of the file the change applies to.
+func (f *File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Diff returns the change that turns old into new, with context lines of
+// context around each change. Empty hashes stand for missing files.
+func Diff(path, oldHash, newHash string, old, new []byte, context int) *File {
+	f := &File{OldPath: path, NewPath: path, OldHash: oldHash, NewHash: newHash}
+	if oldHash == "" {
+		f.OldPath = ""
+	}
+	if newHash == "" {
+		f.NewPath = ""
+	}
+	if diff.IsBinary(old) || diff.IsBinary(new) {
+		f.Binary = true
+		return f
+	}
+	f.Hunks = diff.Hunks(diff.Lines(Lines(old), Lines(new)), context)
+	return f
+}
+
+// Lines splits content into lines, marking the last line if the content does
+// not end in a newline, so that diffs and merges of the lines keep track of it.
+func Lines(data []byte) []string {
+	lines := diff.SplitLines(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// Join turns lines split by Lines back into content.
+func Join(lines []string) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	s := strings.Join(lines, "\n")
+	if trimmed, ok := strings.CutSuffix(s, noNewline); ok {
+		return []byte(trimmed)
+	}
+	return []byte(s + "\n")
+}
+
+// String returns the change in unified diff format.
+func (f *File) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --trac a/%s b/%s\n", f.Path(), f.Path())
+	oldName, newName := "a/"+f.OldPath, "b/"+f.NewPath
+	switch {
+	case f.OldPath == "":
+		sb.WriteString(withMode("new file", f.NewMode))
+		oldName = "/dev/null"
+	case f.NewPath == "":
+		sb.WriteString(withMode("deleted file", f.OldMode))
+		newName = "/dev/null"
+	case f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode:
+		fmt.Fprintf(&sb, "old mode %s\nnew mode %s\n", f.OldMode, f.NewMode)
+		if f.OldHash != "" && f.OldHash == f.NewHash {
+			// Only the mode changed.
+			return sb.String()
+		}
+	}
+	if f.OldHash != "" || f.NewHash != "" {
+		fmt.Fprintf(&sb, "index %s..%s\n", orZero(f.OldHash), orZero(f.NewHash))
+	}
+	if f.Binary {
+		fmt.Fprintf(&sb, "Binary files %s and %s differ\n", oldName, newName)
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range f.Hunks {
+		sb.WriteString(h.Header() + "\n")
+		for _, e := range h.Edits {
+			line, noEOL := strings.CutSuffix(e.String(), noNewline)
+			sb.WriteString(line + "\n")
+			if noEOL {
+				sb.WriteString(noNewlineLine + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// withMode returns the line announcing a new or deleted file, naming its mode if known.
+func withMode(line, mode string) string {
+	if mode == "" {
+		return line + "\n"
+	}
+	return line + " mode " + mode + "\n"
+}
+
+func orZero(hash string) string {
+	if hash == "" {
+		return zeroHash
+	}
+	return hash
+}
+
+// Parse reads the changes in a unified diff, as written by trac, by git, or by
+// diff -u. Text around the changes, such as the message of a patch email, is
+// skipped. Paths in a/ and b/ are taken to be relative to the root.
+func Parse(r io.Reader) ([]*File, error) {
+	p := &parser{r: bufio.NewReader(r)}
+	var files []*File
+	var f *File
+	for {
+		line, err := p.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --trac ") || strings.HasPrefix(line, "diff --git "):
+			f = &File{}
+			files = append(files, f)
+			_, paths, _ := strings.Cut(line[len("diff --"):], " ")
+			f.OldPath, f.NewPath = diffLinePaths(paths)
+		case f != nil && (strings.HasPrefix(line, "new file") || strings.HasPrefix(line, "deleted file")):
+			_, mode, _ := strings.Cut(line, " mode ")
+			if line[0] == 'n' {
+				f.OldPath, f.NewMode = "", mode
+			} else {
+				f.NewPath, f.OldMode = "", mode
+			}
+		case f != nil && strings.HasPrefix(line, "old mode "):
+			f.OldMode = strings.TrimPrefix(line, "old mode ")
+		case f != nil && strings.HasPrefix(line, "new mode "):
+			f.NewMode = strings.TrimPrefix(line, "new mode ")
+		case f != nil && strings.HasPrefix(line, "index "):
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				return nil, p.errorf("invalid index line %q", line)
+			}
+			oldHash, newHash, ok := strings.Cut(fields[1], "..")
+			if !ok {
+				return nil, p.errorf("invalid index line %q", line)
+			}
+			f.OldHash, f.NewHash = fromZero(oldHash), fromZero(newHash)
+		case f != nil && strings.HasPrefix(line, "Binary files "):
+			f.Binary = true
+			f = nil
+		case strings.HasPrefix(line, "--- "):
+			next, err := p.readLine()
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if !strings.HasPrefix(next, "+++ ") {
+				p.unread(next)
+				continue
+			}
+			if f == nil {
+				f = &File{}
+				files = append(files, f)
+			}
+			f.OldPath, f.NewPath = headerPath(line[4:]), headerPath(next[4:])
+			if err := p.hunks(f); err != nil {
+				return nil, err
+			}
+			f = nil
+		}
+	}
+	for _, f := range files {
+		if f.OldPath == "" && f.NewPath == "" {
+			return nil, fmt.Errorf("%w: change without a file name", ErrInvalidPatch)
+		}
+		for _, mode := range []string{f.OldMode, f.NewMode} {
+			if mode != "" && !filemode.Valid(mode) {
+				return nil, fmt.Errorf("%w: unsupported mode %q for %s", ErrInvalidPatch, mode, f.Path())
+			}
+		}
+		for _, p := range []string{f.OldPath, f.NewPath} {
+			first, _, _ := strings.Cut(p, "/")
+			if p != "" && (path.Clean(p) != p || path.IsAbs(p) || first == ".." || first == ".trac") {
+				return nil, fmt.Errorf("%w: invalid path %q", ErrInvalidPatch, p)
+			}
+		}
+	}
+	return files, nil
+}
+
+type parser struct {
+	r       *bufio.Reader
+	lineNum int
+	pending *string
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidPatch, p.lineNum, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) readLine() (string, error) {
+	if p.pending != nil {
+		line := *p.pending
+		p.pending = nil
+		return line, nil
+	}
+	line, err := p.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	p.lineNum++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func (p *parser) unread(line string) {
+	p.pending = &line
+}
+
+// hunks reads the hunks that follow the ---/+++ lines of a file.
+func (p *parser) hunks(f *File) error {
+	for {
+		line, err := p.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "@@ ") {
+			p.unread(line)
+			return nil
+		}
+		h, oldLines, newLines, err := parseHunkHeader(line)
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		oldLine, newLine := h.OldStart, h.NewStart
+		for oldLines > 0 || newLines > 0 {
+			line, err := p.readLine()
+			if err == io.EOF {
+				return p.errorf("hunk ends early")
+			}
+			if err != nil {
+				return err
+			}
+			e := diff.Edit{Op: diff.Equal}
+			switch {
+			case line == "":
+				// Mailers and editors commonly strip the trailing space of empty context lines.
+			case line[0] == ' ':
+				e.Text = line[1:]
+			case line[0] == '+':
+				e = diff.Edit{Op: diff.Insert, Text: line[1:]}
+			case line[0] == '-':
+				e = diff.Edit{Op: diff.Delete, Text: line[1:]}
+			case line[0] == '\\' && len(h.Edits) > 0:
+				h.Edits[len(h.Edits)-1].Text += noNewline
+				continue
+			default:
+				return p.errorf("invalid hunk line %q", line)
+			}
+			e.OldLine, e.NewLine = -1, -1
+			if e.Op != diff.Insert {
+				e.OldLine = oldLine
+				oldLine++
+				oldLines--
+			}
+			if e.Op != diff.Delete {
+				e.NewLine = newLine
+				newLine++
+				newLines--
+			}
+			if oldLines < 0 || newLines < 0 {
+				return p.errorf("hunk is longer than its header says")
+			}
+			h.Edits = append(h.Edits, e)
+		}
+		// The marker for a missing newline follows the last line of the hunk.
+		if line, err := p.readLine(); err == nil {
+			if strings.HasPrefix(line, `\`) && len(h.Edits) > 0 {
+				h.Edits[len(h.Edits)-1].Text += noNewline
+			} else {
+				p.unread(line)
+			}
+		}
+		f.Hunks = append(f.Hunks, h)
+	}
+}
+
+// parseHunkHeader parses a header such as "@@ -1,3 +1,4 @@" into an empty
+// hunk and the number of old and new lines it spans.
+func parseHunkHeader(line string) (h diff.Hunk, oldLines, newLines int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return h, 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	oldStart, oldLines, err1 := parseRange(fields[1][1:])
+	newStart, newLines, err2 := parseRange(fields[2][1:])
+	if err1 != nil || err2 != nil {
+		return h, 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	return diff.Hunk{OldStart: oldStart, NewStart: newStart}, oldLines, newLines, nil
+}
+
+// parseRange parses a range such as "3,4" into the index of its first line
+// and its length. Empty ranges name the line before the range.
+func parseRange(s string) (start, lines int, err error) {
+	startText, linesText, ok := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startText); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	lines = 1
+	if ok {
+		if lines, err = strconv.Atoi(linesText); err != nil || lines < 0 {
+			return 0, 0, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	if lines > 0 {
+		start--
+	}
+	return max(start, 0), lines, nil
+}
+
+// diffLinePaths splits the paths of a "diff --trac a/<path> b/<path>" line.
+// The two paths are the same unless the file was renamed, so paths with
+// spaces are found by splitting the line in half.
+func diffLinePaths(s string) (oldPath, newPath string) {
+	if half := len(s) / 2; len(s)%2 == 1 && s[half] == ' ' && s[2:half] == s[half+3:] {
+		return stripPrefix(s[:half]), stripPrefix(s[half+1:])
+	}
+	oldPath, newPath, _ = strings.Cut(s, " ")
+	return stripPrefix(oldPath), stripPrefix(newPath)
+}
+
+// headerPath returns the path named by a --- or +++ line, without the
+// timestamp diff -u adds, or "" for /dev/null.
+func headerPath(s string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	if s == "/dev/null" {
+		return ""
+	}
+	return stripPrefix(s)
+}
+
+// stripPrefix removes the a/ or b/ prefix from a path in a diff.
+func stripPrefix(s string) string {
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		return s[2:]
+	}
+	return s
+}
+
+func fromZero(hash string) string {
+	if strings.Trim(hash, "0") == "" {
+		return ""
+	}
+	return hash
+}
@@ -0,0 +1,219 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// numbered returns content of n lines "line 1" to "line n", with the lines in
+// replace changed.
+func numbered(n int, replace map[int]string) []byte {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = fmt.Sprintf("line %d", i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return []byte(b.String())
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trips changes with a missing newline at the end", func(t *testing.T) {
+		t.Parallel()
+		old := []byte("one\ntwo\nthree")
+		new := []byte("one\ntwo\nthree\nfour\n")
+		f := Diff("dir/file.txt", "aa", "bb", old, new, 3)
+		text := f.String()
+		require.Contains(t, text, "-three\n\\ No newline at end of file\n+three\n+four\n")
+
+		files, err := Parse(strings.NewReader("Some text before the diff\n\n" + text))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, f, files[0])
+
+		got, _, err := files[0].Apply(Lines(old), 0)
+		require.NoError(t, err)
+		require.Equal(t, new, Join(got))
+	})
+
+	t.Run("reads added, deleted and binary files", func(t *testing.T) {
+		t.Parallel()
+		text := Diff("new.txt", "", "cc", nil, []byte("hello\n"), 3).String() +
+			Diff("old.txt", "dd", "", []byte("bye\n"), nil, 3).String() +
+			Diff("image.png", "ee", "ff", []byte("\x00a"), []byte("\x00b"), 3).String()
+		files, err := Parse(strings.NewReader(text))
+		require.NoError(t, err)
+		require.Len(t, files, 3)
+		require.Equal(t, "", files[0].OldPath)
+		require.Equal(t, "new.txt", files[0].Path())
+		require.Equal(t, "", files[1].NewPath)
+		require.Equal(t, "old.txt", files[1].Path())
+		require.True(t, files[2].Binary)
+		require.Equal(t, "ee", files[2].OldHash)
+		require.Equal(t, "ff", files[2].NewHash)
+	})
+
+	t.Run("round trips file modes", func(t *testing.T) {
+		t.Parallel()
+		chmod := Diff("run.sh", "aa", "aa", []byte("echo\n"), []byte("echo\n"), 3)
+		chmod.OldMode, chmod.NewMode = "100644", "100755"
+		link := Diff("link", "", "bb", nil, []byte("run.sh"), 3)
+		link.NewMode = "120000"
+		gone := Diff("old.sh", "cc", "", []byte("echo\n"), nil, 3)
+		gone.OldMode = "100755"
+		text := chmod.String() + link.String() + gone.String()
+		require.Contains(t, text, "diff --trac a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\ndiff --trac a/link b/link\nnew file mode 120000\n")
+		require.Contains(t, text, "deleted file mode 100755\n")
+
+		files, err := Parse(strings.NewReader(text))
+		require.NoError(t, err)
+		// As in git, a change of mode alone has no index line.
+		modeOnly := *chmod
+		modeOnly.OldHash, modeOnly.NewHash = "", ""
+		require.Equal(t, []*File{&modeOnly, link, gone}, files)
+
+		_, err = Parse(strings.NewReader("diff --git a/sub b/sub\nnew file mode 160000\nindex 0000000..1234567\n"))
+		require.ErrorIs(t, err, ErrInvalidPatch)
+	})
+
+	t.Run("reads diff -u output", func(t *testing.T) {
+		t.Parallel()
+		text := "--- file.txt\t2024-01-01 00:00:00\n+++ file.txt\t2024-01-02 00:00:00\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"
+		files, err := Parse(strings.NewReader(text))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, "file.txt", files[0].Path())
+		require.Len(t, files[0].Hunks, 1)
+	})
+
+	t.Run("rejects paths outside the working tree", func(t *testing.T) {
+		t.Parallel()
+		for _, path := range []string{"../escape", "/etc/passwd", ".trac/HEAD", "a/../../b"} {
+			text := fmt.Sprintf("--- a/%s\n+++ b/%s\n@@ -1 +1 @@\n-a\n+b\n", path, path)
+			_, err := Parse(strings.NewReader(text))
+			require.ErrorIs(t, err, ErrInvalidPatch, path)
+		}
+	})
+
+	t.Run("rejects truncated hunks", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse(strings.NewReader("--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n"))
+		require.ErrorIs(t, err, ErrInvalidPatch)
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	base := numbered(20, nil)
+	f := Diff("f", "", "", base, numbered(20, map[int]string{10: "changed"}), 3)
+
+	tests := map[string]struct {
+		old     []byte
+		fuzz    int
+		want    []byte
+		results []HunkResult
+		err     error
+	}{
+		"in place": {
+			old:     base,
+			want:    numbered(20, map[int]string{10: "changed"}),
+			results: []HunkResult{{Line: 7}},
+		},
+		"with an offset": {
+			old:     append([]byte("new 1\nnew 2\n"), base...),
+			want:    append([]byte("new 1\nnew 2\n"), numbered(20, map[int]string{10: "changed"})...),
+			results: []HunkResult{{Line: 9, Offset: 2}},
+		},
+		"with fuzz": {
+			old:     numbered(20, map[int]string{7: "edited"}),
+			fuzz:    1,
+			want:    numbered(20, map[int]string{7: "edited", 10: "changed"}),
+			results: []HunkResult{{Line: 8, Fuzz: 1}},
+		},
+		"not without fuzz": {
+			old: numbered(20, map[int]string{7: "edited"}),
+			err: ErrDoesNotApply,
+		},
+		"not when the changed line differs": {
+			old:  numbered(20, map[int]string{10: "edited"}),
+			fuzz: 3,
+			err:  ErrDoesNotApply,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, results, err := f.Apply(Lines(tt.old), tt.fuzz)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, string(tt.want), string(Join(got)))
+			require.Equal(t, tt.results, results)
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	base := Lines([]byte("a\nb\nc"))
+	ours := Lines([]byte("a\nb\nours"))
+	theirs := Lines([]byte("a\nb\ntheirs"))
+	merged, conflicts := Merge(base, ours, theirs)
+	require.Equal(t, 1, conflicts)
+	require.Equal(t, "a\nb\n<<<<<<< ours\nours\n=======\ntheirs\n>>>>>>> theirs\n", string(Join(merged)))
+}
+
+func TestMailbox(t *testing.T) {
+	t.Parallel()
+	date := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("", 2*60*60))
+	messages := []*Message{
+		{
+			Author:  &mail.Address{Name: "Ada Lovelace", Address: "ada@example.com"},
+			Date:    date,
+			Subject: "Add notes",
+			Body:    "From the start, quoted.\n>From here too.",
+			Files:   []*File{Diff("notes.txt", "", "aa", nil, []byte("notes\n"), 3)},
+		},
+		{
+			Author:  &mail.Address{Name: "Zoë Señor", Address: "zoe@example.com"},
+			Date:    date,
+			Subject: "Édit notes",
+			Files:   []*File{Diff("notes.txt", "aa", "bb", []byte("notes\n"), []byte("notes\nmore"), 3)},
+		},
+	}
+	var buf bytes.Buffer
+	for i, m := range messages {
+		require.NoError(t, m.Write(&buf, i+1, len(messages)))
+	}
+	require.Contains(t, buf.String(), "Subject: [PATCH 1/2] Add notes\n")
+	require.Contains(t, buf.String(), "\n>From the start, quoted.\n>>From here too.\n")
+
+	got, err := ReadMailbox(&buf)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for i, m := range got {
+		require.Equal(t, messages[i].Author, m.Author)
+		require.True(t, messages[i].Date.Equal(m.Date))
+		require.Equal(t, messages[i].Subject, m.Subject)
+		require.Equal(t, messages[i].Body, m.Body)
+		require.Equal(t, messages[i].Files, m.Files)
+	}
+	require.Equal(t, "Add notes\n\nFrom the start, quoted.\n>From here too.", got[0].Message())
+
+	_, err = ReadMailbox(strings.NewReader("Subject: nothing\n\njust text\n"))
+	require.ErrorIs(t, err, ErrInvalidPatch)
+	_, err = ReadMailbox(strings.NewReader("\n"))
+	require.ErrorIs(t, err, ErrNoPatches)
+}
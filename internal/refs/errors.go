@@ -0,0 +1,9 @@
+package refs
+
+import "errors"
+
+var (
+	ErrNotFound    = errors.New("ref not found")
+	ErrExists      = errors.New("ref already exists")
+	ErrInvalidName = errors.New("invalid ref name")
+)
@@ -0,0 +1,238 @@
+// Package refs manages named references to commits: branches, tags, remote-tracking branches and HEAD.
+//
+// Each ref is a file under .trac/ named after the ref, such as
+// .trac/refs/heads/main, holding a commit hash. HEAD either holds a commit
+// hash directly ("detached") or names the branch it is on as "ref: refs/heads/<name>".
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/layout"
+)
+
+const (
+	Head          = "HEAD"
+	HeadsPrefix   = "refs/heads/"
+	TagsPrefix    = "refs/tags/"
+	RemotesPrefix = "refs/remotes/"
+	DefaultBranch = "main"
+
+	symbolicPrefix = "ref: "
+)
+
+// Ref is a named reference to a commit.
+type Ref struct {
+	Name string // Full name, e.g. refs/heads/main
+	Hash string
+}
+
+// Short returns the name without its refs/heads/, refs/tags/ or refs/remotes/ prefix.
+func (r Ref) Short() string {
+	return Short(r.Name)
+}
+
+// Short returns a full ref name without its refs/heads/, refs/tags/ or refs/remotes/ prefix.
+func Short(name string) string {
+	for _, prefix := range []string{HeadsPrefix, TagsPrefix, RemotesPrefix} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
+
+// CheckName validates a short branch or tag name.
+func CheckName(name string) error {
+	invalid := name == "" || name == Head || name == "@" ||
+		strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") ||
+		strings.ContainsAny(name, " ~^:?*[\\\x7f")
+	for _, component := range strings.Split(name, "/") {
+		invalid = invalid || strings.HasPrefix(component, ".")
+	}
+	for _, r := range name {
+		invalid = invalid || r < 0x20
+	}
+	if invalid {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+func refPath(l *layout.Layout, name string) string {
+	return filepath.Join(l.Config, filepath.FromSlash(name))
+}
+
+// checkFullName validates a full ref name such as refs/heads/main.
+func checkFullName(name string) error {
+	rest, ok := strings.CutPrefix(name, "refs/")
+	if !ok || CheckName(rest) != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// Read returns the commit hash a ref points at.
+func Read(l *layout.Layout, name string) (string, error) {
+	if err := checkFullName(name); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(refPath(l, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Exists reports whether a ref exists.
+func Exists(l *layout.Layout, name string) bool {
+	_, err := Read(l, name)
+	return err == nil
+}
+
+// Write points a ref at a commit, creating it if needed.
+func Write(l *layout.Layout, name, hash string) error {
+	if err := checkFullName(name); err != nil {
+		return err
+	}
+	return writeFile(refPath(l, name), hash+"\n")
+}
+
+// writeFile replaces the file at path through a temporary file, so readers
+// never see a partially written ref.
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".lock"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Delete removes a ref, along with any directories left empty under refs/.
+func Delete(l *layout.Layout, name string) error {
+	if err := checkFullName(name); err != nil {
+		return err
+	}
+	p := refPath(l, name)
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return err
+	}
+	refsDir := refPath(l, "refs")
+	for dir := filepath.Dir(p); strings.HasPrefix(dir, refsDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List returns the refs whose full names start with prefix, sorted by name.
+func List(l *layout.Layout, prefix string) ([]Ref, error) {
+	var refs []Ref
+	root := refPath(l, "refs")
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Config, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		hash, err := Read(l, name)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, Ref{Name: name, Hash: hash})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(refs, func(a, b Ref) int { return strings.Compare(a.Name, b.Name) })
+	return refs, nil
+}
+
+// ReadHead returns the branch HEAD is on and the commit it resolves to.
+// branch is the full ref name, or empty when HEAD is detached. hash is empty
+// when HEAD is on a branch that has no commits yet.
+func ReadHead(l *layout.Layout) (branch, hash string, err error) {
+	data, err := os.ReadFile(l.HeadFile)
+	if err != nil {
+		return "", "", err
+	}
+	content := strings.TrimSpace(string(data))
+	target, symbolic := strings.CutPrefix(content, symbolicPrefix)
+	if !symbolic {
+		return "", content, nil
+	}
+	hash, err = Read(l, target)
+	if errors.Is(err, ErrNotFound) {
+		return target, "", nil
+	}
+	return target, hash, err
+}
+
+// SetHead puts HEAD on a branch, given by its full ref name.
+func SetHead(l *layout.Layout, branch string) error {
+	return writeFile(l.HeadFile, symbolicPrefix+branch+"\n")
+}
+
+// DetachHead points HEAD directly at a commit.
+func DetachHead(l *layout.Layout, hash string) error {
+	return writeFile(l.HeadFile, hash+"\n")
+}
+
+// UpdateHead points HEAD at a commit: the branch HEAD is on is moved to it, or
+// HEAD itself if it is detached.
+func UpdateHead(l *layout.Layout, hash string) error {
+	branch, _, err := ReadHead(l)
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		return DetachHead(l, hash)
+	}
+	return Write(l, branch, hash)
+}
+
+// Branch returns the full name of a branch.
+func Branch(name string) string {
+	return HeadsPrefix + name
+}
+
+// Tag returns the full name of a tag.
+func Tag(name string) string {
+	return TagsPrefix + name
+}
+
+// RemoteBranch returns the full name of the remote-tracking branch for a branch of a remote.
+func RemoteBranch(remote, branch string) string {
+	return RemotesPrefix + remote + "/" + branch
+}
@@ -0,0 +1,12 @@
+package remote
+
+import "errors"
+
+var (
+	ErrNotFound       = errors.New("no such remote")
+	ErrExists         = errors.New("remote already exists")
+	ErrInvalidRefspec = errors.New("invalid refspec")
+	ErrNotRepository  = errors.New("remote is not a trac repository")
+	ErrAuthRequired   = errors.New("authentication required")
+	ErrForbidden      = errors.New("permission denied")
+)
@@ -0,0 +1,221 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/gc"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/pack"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// The smart HTTP protocol has three endpoints under a repository's URL:
+//
+//	GET  info/refs     lists the refs, as a refsResponse
+//	POST upload-pack   takes an uploadRequest and answers with a pack stream of
+//	                   the history of its wants, leaving out the history of its haves
+//	POST receive-pack  takes a receiveRequest as a line of JSON followed by a
+//	                   pack stream, and answers with a receiveResponse
+//
+// Haves are the tips of the client's refs. The server stops walking history at
+// any it has, so only the objects the client is missing are sent, apart from
+// blobs shared with older commits. Pushes work the other way around, with the
+// client stopping at the tips of the server's refs.
+const (
+	refsPath    = "info/refs"
+	uploadPath  = "upload-pack"
+	receivePath = "receive-pack"
+
+	packContentType = "application/x-trac-pack"
+)
+
+type refsResponse struct {
+	Head string    `json:"head,omitempty"`
+	Refs []wireRef `json:"refs"`
+}
+
+type wireRef struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+type uploadRequest struct {
+	Wants []string `json:"wants"`
+	Haves []string `json:"haves"`
+}
+
+type receiveRequest struct {
+	Updates []wireUpdate `json:"updates"`
+}
+
+type receiveResponse struct {
+	Updates []wireUpdate `json:"updates"`
+}
+
+type wireUpdate struct {
+	Src    string `json:"src"`
+	Dst    string `json:"dst"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new"`
+	Force  bool   `json:"force,omitempty"`
+	Status Status `json:"status"`
+}
+
+// HTTPOptions configures connections to repositories served over HTTP.
+type HTTPOptions struct {
+	Client *http.Client // http.DefaultClient when nil
+
+	// Credentials returns the basic auth credentials to send to a server that
+	// asks for them, when the URL does not hold any.
+	Credentials func(url string) (username, password string, ok bool)
+}
+
+type httpTransport struct {
+	base     string // URL of the repository, ending in a slash
+	username string
+	password string
+	auth     bool // Whether credentials were found
+	opts     HTTPOptions
+}
+
+func newHTTPTransport(url string, opts HTTPOptions) *httpTransport {
+	t := &httpTransport{opts: opts}
+	if u, err := neturl.Parse(url); err == nil && u.User != nil {
+		t.username = u.User.Username()
+		t.password, _ = u.User.Password()
+		t.auth = true
+		u.User = nil
+		url = u.String()
+	} else if opts.Credentials != nil {
+		t.username, t.password, t.auth = opts.Credentials(url)
+	}
+	if opts.Client == nil {
+		t.opts.Client = http.DefaultClient
+	}
+	t.base = strings.TrimSuffix(url, "/") + "/"
+	return t
+}
+
+// do sends a request to an endpoint and returns the response if it succeeded.
+func (t *httpTransport) do(ctx context.Context, method, endpoint, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.base+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if t.auth {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	resp, err := t.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s", ErrAuthRequired, t.base)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, strings.TrimSpace(string(msg)))
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotRepository, t.base)
+	}
+	return nil, fmt.Errorf("%s %s: %s: %s", method, t.base+endpoint, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func (t *httpTransport) list(ctx context.Context) (string, []refs.Ref, error) {
+	resp, err := t.do(ctx, http.MethodGet, refsPath, "", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	var r refsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", nil, fmt.Errorf("invalid ref list from %s: %w", t.base, err)
+	}
+	all := make([]refs.Ref, len(r.Refs))
+	for i, ref := range r.Refs {
+		if !object.IsHash(ref.Hash) || refs.CheckName(refs.Short(ref.Name)) != nil {
+			return "", nil, fmt.Errorf("invalid ref %q from %s", ref.Name, t.base)
+		}
+		all[i] = refs.Ref{Name: ref.Name, Hash: ref.Hash}
+	}
+	return r.Head, all, nil
+}
+
+func (t *httpTransport) fetch(ctx context.Context, l *layout.Layout, wants, haves []string) error {
+	body, err := json.Marshal(uploadRequest{Wants: wants, Haves: haves})
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodPost, uploadPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = object.ReadStream(l, resp.Body)
+	return err
+}
+
+func (t *httpTransport) push(ctx context.Context, l *layout.Layout, updates []Update) error {
+	_, remoteRefs, err := t.list(ctx)
+	if err != nil {
+		return err
+	}
+	remoteTips := make(map[string]bool, len(remoteRefs))
+	for _, ref := range remoteRefs {
+		remoteTips[ref.Hash] = true
+	}
+	req := receiveRequest{Updates: make([]wireUpdate, len(updates))}
+	news := make([]string, len(updates))
+	for i, u := range updates {
+		req.Updates[i] = wireUpdate{Src: u.Src, Dst: u.Dst, New: u.New, Force: u.force}
+		news[i] = u.New
+	}
+	hashes, err := graph.Walk(l, news, func(hash string) bool { return remoteTips[hash] })
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := json.NewEncoder(pw).Encode(req)
+		if err == nil {
+			read := func(hash string) ([]byte, error) { return object.ReadRaw(l, hash) }
+			_, err = pack.WriteStream(pw, hashes, read, pack.Options{Window: gc.DefaultWindow, Depth: gc.DefaultDepth})
+		}
+		pw.CloseWithError(err)
+	}()
+	resp, err := t.do(ctx, http.MethodPost, receivePath, packContentType, pr)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result receiveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid push result from %s: %w", t.base, err)
+	}
+	if len(result.Updates) != len(updates) {
+		return fmt.Errorf("invalid push result from %s: %d updates, expected %d", t.base, len(result.Updates), len(updates))
+	}
+	for i, u := range result.Updates {
+		updates[i].Old = u.Old
+		updates[i].Status = u.Status
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Refspec maps refs of one repository to refs of another, e.g.
+// "+refs/heads/*:refs/remotes/origin/*". A trailing "*" in both sides matches
+// any remainder of a name. A leading "+" allows updates that are not fast-forwards.
+type Refspec struct {
+	Force bool
+	Src   string
+	Dst   string
+}
+
+// ParseRefspec parses a refspec of the form "[+]<src>[:<dst>]". Without a
+// destination, refs are mapped to the same name.
+func ParseRefspec(s string) (Refspec, error) {
+	var r Refspec
+	spec, force := strings.CutPrefix(s, "+")
+	r.Force = force
+	src, dst, ok := strings.Cut(spec, ":")
+	if !ok {
+		dst = src
+	}
+	r.Src, r.Dst = src, dst
+	srcGlob, dstGlob := strings.Count(src, "*"), strings.Count(dst, "*")
+	valid := strings.HasPrefix(src, "refs/") && strings.HasPrefix(dst, "refs/") &&
+		srcGlob == dstGlob && srcGlob <= 1 &&
+		(srcGlob == 0 || strings.HasSuffix(src, "/*") && strings.HasSuffix(dst, "/*"))
+	if !valid {
+		return Refspec{}, fmt.Errorf("%w: %q", ErrInvalidRefspec, s)
+	}
+	return r, nil
+}
+
+// ParseRefspecs parses a whitespace-separated list of refspecs.
+func ParseRefspecs(s string) ([]Refspec, error) {
+	var specs []Refspec
+	for _, field := range strings.Fields(s) {
+		r, err := ParseRefspec(field)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, r)
+	}
+	return specs, nil
+}
+
+// String returns the refspec in the form it is parsed from.
+func (r Refspec) String() string {
+	s := r.Src + ":" + r.Dst
+	if r.Force {
+		s = "+" + s
+	}
+	return s
+}
+
+// Match reports whether name matches the source side, and returns the name it maps to.
+func (r Refspec) Match(name string) (string, bool) {
+	prefix, glob := strings.CutSuffix(r.Src, "*")
+	if !glob {
+		return r.Dst, name == r.Src
+	}
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok || rest == "" {
+		return "", false
+	}
+	return strings.TrimSuffix(r.Dst, "*") + rest, true
+}
@@ -0,0 +1,39 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRefspec(t *testing.T) {
+	r, err := ParseRefspec("+refs/heads/*:refs/remotes/origin/*")
+	require.NoError(t, err)
+	require.Equal(t, Refspec{Force: true, Src: "refs/heads/*", Dst: "refs/remotes/origin/*"}, r)
+	require.Equal(t, "+refs/heads/*:refs/remotes/origin/*", r.String())
+
+	r, err = ParseRefspec("refs/tags/v1")
+	require.NoError(t, err)
+	require.Equal(t, Refspec{Src: "refs/tags/v1", Dst: "refs/tags/v1"}, r)
+
+	for _, invalid := range []string{"", "main", "refs/heads/*:refs/remotes/origin/main", "refs/heads/a*:refs/heads/b*", "refs/*/*:refs/*/*"} {
+		_, err := ParseRefspec(invalid)
+		require.ErrorIs(t, err, ErrInvalidRefspec, invalid)
+	}
+}
+
+func TestRefspecMatch(t *testing.T) {
+	glob := Refspec{Src: "refs/heads/*", Dst: "refs/remotes/origin/*"}
+	dst, ok := glob.Match("refs/heads/feature/x")
+	require.True(t, ok)
+	require.Equal(t, "refs/remotes/origin/feature/x", dst)
+	_, ok = glob.Match("refs/tags/v1")
+	require.False(t, ok)
+
+	exact := Refspec{Src: "refs/heads/main", Dst: "refs/heads/release"}
+	dst, ok = exact.Match("refs/heads/main")
+	require.True(t, ok)
+	require.Equal(t, "refs/heads/release", dst)
+	_, ok = exact.Match("refs/heads/mainline")
+	require.False(t, ok)
+}
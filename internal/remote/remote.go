@@ -0,0 +1,123 @@
+// Package remote manages the remotes of a repository and transfers history
+// between repositories.
+//
+// A remote is a named repository to fetch from and push to, configured by
+// remote.<name>.url and remote.<name>.fetch in the repository config. The fetch
+// key holds the refspecs used by fetch, separated by whitespace, and
+// defaults to mapping the remote's branches to refs/remotes/<name>/*.
+//
+// The URL of a remote is either the path of a repository on the same machine,
+// which is read and written directly, or the http:// or https:// URL of a
+// repository served by Server, which is spoken to with the smart HTTP
+// protocol described in http.go.
+package remote
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Remote is a configured remote repository.
+type Remote struct {
+	Name  string
+	URL   string
+	Fetch []Refspec
+}
+
+func key(name, field string) string {
+	return "remote." + name + "." + field
+}
+
+// DefaultFetch returns the refspec a new remote fetches with.
+func DefaultFetch(name string) Refspec {
+	return Refspec{Force: true, Src: refs.HeadsPrefix + "*", Dst: refs.RemotesPrefix + name + "/*"}
+}
+
+// Add configures a new remote with the default fetch refspec.
+func Add(l *layout.Layout, name, url string) (*Remote, error) {
+	if err := refs.CheckName(name); err != nil || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid remote name %q", name)
+	}
+	cfg, err := config.Load(l)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cfg.Get(key(name, "url")); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, name)
+	}
+	r := &Remote{Name: name, URL: url, Fetch: []Refspec{DefaultFetch(name)}}
+	if err := cfg.Set(key(name, "url"), url); err != nil {
+		return nil, err
+	}
+	if err := cfg.Set(key(name, "fetch"), r.Fetch[0].String()); err != nil {
+		return nil, err
+	}
+	return r, cfg.Save(l)
+}
+
+// Get returns a configured remote.
+func Get(l *layout.Layout, name string) (*Remote, error) {
+	cfg, err := config.Load(l)
+	if err != nil {
+		return nil, err
+	}
+	return get(cfg, name)
+}
+
+func get(cfg *config.Config, name string) (*Remote, error) {
+	url, err := cfg.Get(key(name, "url"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	fetch, err := ParseRefspecs(cfg.String(key(name, "fetch"), DefaultFetch(name).String()))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key(name, "fetch"), err)
+	}
+	return &Remote{Name: name, URL: url, Fetch: fetch}, nil
+}
+
+// List returns the configured remotes, sorted by name.
+func List(l *layout.Layout) ([]*Remote, error) {
+	cfg, err := config.Load(l)
+	if err != nil {
+		return nil, err
+	}
+	var remotes []*Remote
+	for _, name := range cfg.Subsections("remote") {
+		r, err := get(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, r)
+	}
+	return remotes, nil
+}
+
+// Remove deletes a remote's configuration and its remote-tracking branches.
+func Remove(l *layout.Layout, name string) error {
+	cfg, err := config.Load(l)
+	if err != nil {
+		return err
+	}
+	if _, err := get(cfg, name); err != nil {
+		return err
+	}
+	delete(cfg.Sections, "remote."+name)
+	if err := cfg.Save(l); err != nil {
+		return err
+	}
+	tracking, err := refs.List(l, refs.RemotesPrefix+name+"/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range tracking {
+		if err := refs.Delete(l, ref.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/gc"
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/pack"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// maxRequestSize limits the JSON part of requests.
+const maxRequestSize = 1 << 20
+
+// Server serves a repository over HTTP, for other repositories to fetch from
+// and push to with http:// URLs.
+type Server struct {
+	Layout   *layout.Layout
+	ReadOnly bool // Refuse every push
+
+	// Auth decides whether a request may go ahead, given the basic auth
+	// credentials it carries, if any, and whether it pushes. When nil, every
+	// request is allowed, pushes included unless ReadOnly is set.
+	Auth func(username, password string, ok, push bool) bool
+
+	once sync.Once
+	mux  *http.ServeMux
+	mu   sync.Mutex // Serializes pushes
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.once.Do(func() {
+		s.mux = http.NewServeMux()
+		s.mux.HandleFunc("GET /"+refsPath, s.handleRefs)
+		s.mux.HandleFunc("POST /"+uploadPath, s.handleUpload)
+		s.mux.HandleFunc("POST /"+receivePath, s.handleReceive)
+	})
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorize checks a request against Auth, answering it if it is refused.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, push bool) bool {
+	if push && s.ReadOnly {
+		http.Error(w, "pushing is disabled", http.StatusForbidden)
+		return false
+	}
+	if s.Auth == nil {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if s.Auth(username, password, ok, push) {
+		return true
+	}
+	if ok {
+		http.Error(w, "permission denied", http.StatusForbidden)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Basic realm="trac"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+	}
+	return false
+}
+
+func (s *Server) handleRefs(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, false) {
+		return
+	}
+	head, all, err := (&localTransport{l: s.Layout}).list(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := refsResponse{Head: head, Refs: make([]wireRef, len(all))}
+	for i, ref := range all {
+		resp.Refs[i] = wireRef{Name: ref.Name, Hash: ref.Hash}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, false) {
+		return
+	}
+	var req uploadRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Only history reachable from refs is served.
+	advertised, err := tips(s.Layout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, want := range req.Wants {
+		if !slices.Contains(advertised, want) {
+			http.Error(w, fmt.Sprintf("not a ref tip: %s", want), http.StatusBadRequest)
+			return
+		}
+	}
+	haves := make(map[string]bool, len(req.Haves))
+	for _, have := range req.Haves {
+		haves[have] = true
+	}
+	hashes, err := graph.Walk(s.Layout, req.Wants, func(hash string) bool { return haves[hash] })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", packContentType)
+	read := func(hash string) ([]byte, error) { return object.ReadRaw(s.Layout, hash) }
+	// Errors past this point cut the stream short, which the client notices
+	// from the missing checksum.
+	pack.WriteStream(w, hashes, read, pack.Options{Window: gc.DefaultWindow, Depth: gc.DefaultDepth})
+}
+
+func (s *Server) handleReceive(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, true) {
+		return
+	}
+	body := bufio.NewReader(r.Body)
+	line, err := body.ReadSlice('\n')
+	if err != nil {
+		http.Error(w, "invalid push request", http.StatusBadRequest)
+		return
+	}
+	var req receiveRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updates := make([]Update, len(req.Updates))
+	for i, u := range req.Updates {
+		if err := refs.CheckName(refs.Short(u.Dst)); err != nil || !object.IsHash(u.New) {
+			http.Error(w, fmt.Sprintf("invalid update of %q", u.Dst), http.StatusBadRequest)
+			return
+		}
+		updates[i] = Update{Src: u.Src, Dst: u.Dst, New: u.New, force: u.Force}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := object.ReadStream(s.Layout, body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkConnected(updates); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := apply(s.Layout, updates); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := receiveResponse{Updates: make([]wireUpdate, len(updates))}
+	for i, u := range updates {
+		resp.Updates[i] = wireUpdate{Src: u.Src, Dst: u.Dst, Old: u.Old, New: u.New, Status: u.Status}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkConnected makes sure the repository holds the full history of every
+// commit the updates point at, so a push that left objects out cannot create
+// refs to history that is not there. Annotated tags stand for the commits
+// they are about. History is followed until it reaches the tip of an existing
+// ref.
+func (s *Server) checkConnected(updates []Update) error {
+	existing, err := tips(s.Layout)
+	if err != nil {
+		return err
+	}
+	checked := make(map[string]bool)
+	for _, u := range updates {
+		hash := u.New
+		if hash != "" {
+			// Peeling reads the tag object, so a missing one is caught here.
+			if hash, err = commit.Peel(hash, s.Layout); err != nil {
+				return fmt.Errorf("incomplete history for %s: %w", u.Dst, err)
+			}
+		}
+		for hash != "" && !checked[hash] && !slices.Contains(existing, hash) {
+			c, err := commit.Load(hash, s.Layout)
+			if err != nil {
+				return fmt.Errorf("incomplete history for %s: %w", u.Dst, err)
+			}
+			for _, blob := range c.Changes {
+				if !object.Has(s.Layout, blob) {
+					return fmt.Errorf("incomplete history for %s: %w: %s", u.Dst, object.ErrMissing, blob)
+				}
+			}
+			checked[hash] = true
+			hash = c.Parent
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/graph"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Status is the outcome of updating a ref.
+type Status int
+
+const (
+	UpToDate       Status = iota // The ref already pointed at the commit
+	Created                      // The ref did not exist
+	FastForward                  // The ref moved to a descendant of its commit
+	Forced                       // The ref was moved to a commit that is not a descendant
+	NonFastForward               // Rejected: the update would discard commits and was not forced
+	CurrentBranch                // Rejected: the ref is the branch HEAD is on in the receiving repository
+)
+
+// Rejected reports whether the update was refused.
+func (s Status) Rejected() bool {
+	return s == NonFastForward || s == CurrentBranch
+}
+
+// Update describes how a ref was updated by a fetch or push.
+type Update struct {
+	Src    string // Ref on the sending side
+	Dst    string // Ref updated on the receiving side
+	Old    string // Commit the ref pointed at, empty if it did not exist
+	New    string // Commit the ref points at on the sending side
+	Status Status
+
+	force bool
+}
+
+// FetchOptions controls a fetch.
+type FetchOptions struct {
+	Refspecs []Refspec // Refspecs to fetch with instead of the remote's configured ones
+	Force    bool      // Allow updates that are not fast-forwards for every refspec
+	Tags     bool      // Also fetch every tag into refs/tags/
+	HTTP     HTTPOptions
+}
+
+// Fetch copies the commits the remote's refs point at, and their content, into
+// the repository, and updates the refs the fetch refspecs map them to.
+func Fetch(ctx context.Context, l *layout.Layout, r *Remote, opts FetchOptions) ([]Update, error) {
+	t, err := dial(l.Root, r.URL, opts.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	specs := opts.Refspecs
+	if len(specs) == 0 {
+		specs = r.Fetch
+	}
+	if opts.Tags {
+		specs = append(specs, Refspec{Src: refs.TagsPrefix + "*", Dst: refs.TagsPrefix + "*"})
+	}
+	_, remoteRefs, err := t.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updates := match(remoteRefs, specs, opts.Force)
+	var wants []string
+	for _, u := range updates {
+		if !object.Has(l, u.New) && !slices.Contains(wants, u.New) {
+			wants = append(wants, u.New)
+		}
+	}
+	if len(wants) > 0 {
+		haves, err := tips(l)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.fetch(ctx, l, wants, haves); err != nil {
+			return nil, err
+		}
+	}
+	return updates, apply(l, updates)
+}
+
+// PushOptions controls a push.
+type PushOptions struct {
+	Force bool // Allow updates that are not fast-forwards for every refspec
+	HTTP  HTTPOptions
+	// PrePush, if set, is called before anything is sent with the updates that
+	// would change a remote ref, their Old set from the remote's refs. An
+	// error stops the push.
+	PrePush func(updates []Update) error
+}
+
+// Push copies the commits local refs point at, and their content, to the
+// remote and updates the remote refs the refspecs map them to. Remote-tracking
+// branches of updated refs are moved along with them.
+func Push(ctx context.Context, l *layout.Layout, r *Remote, specs []Refspec, opts PushOptions) ([]Update, error) {
+	t, err := dial(l.Root, r.URL, opts.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	localRefs, err := refs.List(l, "refs/")
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range specs {
+		if !strings.Contains(spec.Src, "*") && !refs.Exists(l, spec.Src) {
+			return nil, fmt.Errorf("%w: %s", refs.ErrNotFound, spec.Src)
+		}
+	}
+	updates := match(localRefs, specs, opts.Force)
+	if opts.PrePush != nil {
+		if err := prePush(ctx, t, updates, opts.PrePush); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.push(ctx, l, updates); err != nil {
+		return nil, err
+	}
+	for _, u := range updates {
+		if u.Status.Rejected() || u.Status == UpToDate {
+			continue
+		}
+		for _, spec := range r.Fetch {
+			if tracking, ok := spec.Match(u.Dst); ok {
+				if err := refs.Write(l, tracking, u.New); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+	return updates, nil
+}
+
+// prePush calls fn with the updates that would change a remote ref.
+func prePush(ctx context.Context, t transport, updates []Update, fn func([]Update) error) error {
+	_, remoteRefs, err := t.list(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]string, len(remoteRefs))
+	for _, ref := range remoteRefs {
+		current[ref.Name] = ref.Hash
+	}
+	var pending []Update
+	for _, u := range updates {
+		u.Old = current[u.Dst]
+		if u.Old != u.New {
+			pending = append(pending, u)
+		}
+	}
+	return fn(pending)
+}
+
+// LsRemote returns the refs of the repository at url and the branch its HEAD
+// is on, empty if HEAD is detached. Local paths are relative to dir.
+func LsRemote(ctx context.Context, dir, url string, opts HTTPOptions) (head string, all []refs.Ref, err error) {
+	t, err := dial(dir, url, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.list(ctx)
+}
+
+// match maps refs through the first refspec each matches.
+func match(all []refs.Ref, specs []Refspec, force bool) []Update {
+	var updates []Update
+	for _, ref := range all {
+		for _, spec := range specs {
+			if dst, ok := spec.Match(ref.Name); ok {
+				updates = append(updates, Update{Src: ref.Name, Dst: dst, New: ref.Hash, force: force || spec.Force})
+				break
+			}
+		}
+	}
+	return updates
+}
+
+// transfer copies the objects needed for the history of tips from src to dst.
+func transfer(ctx context.Context, dst, src *layout.Layout, tips []string) error {
+	have := func(hash string) bool { return object.Has(dst, hash) }
+	hashes, err := graph.Walk(src, tips, have)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if have(hash) {
+			continue
+		}
+		data, err := object.ReadRaw(src, hash)
+		if err != nil {
+			return err
+		}
+		if err := object.WriteRaw(dst, hash, data, time.Time{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tips returns the commits the refs of l point at.
+func tips(l *layout.Layout) ([]string, error) {
+	all, err := refs.List(l, "refs/")
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for _, ref := range all {
+		if !slices.Contains(hashes, ref.Hash) {
+			hashes = append(hashes, ref.Hash)
+		}
+	}
+	return hashes, nil
+}
+
+// apply decides the status of each update in the receiving repository l, whose
+// objects are already complete, and moves the refs that are not rejected.
+func apply(l *layout.Layout, updates []Update) error {
+	current, _, err := refs.ReadHead(l)
+	if err != nil {
+		return err
+	}
+	for i := range updates {
+		u := &updates[i]
+		old, err := refs.Read(l, u.Dst)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return err
+		}
+		u.Old = old
+		switch {
+		case old == u.New:
+			u.Status = UpToDate
+			continue
+		case u.Dst == current:
+			// Moving the branch HEAD is on would leave the index and working
+			// tree out of step with it.
+			u.Status = CurrentBranch
+			continue
+		case old == "":
+			u.Status = Created
+		default:
+			ff, err := graph.IsAncestor(l, old, u.New)
+			if err != nil {
+				return err
+			}
+			switch {
+			case ff:
+				u.Status = FastForward
+			case u.force:
+				u.Status = Forced
+			default:
+				u.Status = NonFastForward
+				continue
+			}
+		}
+		if err := refs.Write(l, u.Dst, u.New); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpandRefspec expands a refspec given on the command line, whose sides may be
+// short branch or tag names, using the refs of the sending repository l. A
+// short source is looked up as a branch, then as a tag, and a short
+// destination is taken to be of the same kind as the source.
+func ExpandRefspec(l *layout.Layout, s string) (Refspec, error) {
+	spec, force := strings.CutPrefix(s, "+")
+	src, dst, ok := strings.Cut(spec, ":")
+	if !ok {
+		dst = src
+	}
+	if !strings.HasPrefix(src, "refs/") {
+		switch {
+		case refs.Exists(l, refs.Branch(src)):
+			src = refs.Branch(src)
+		case refs.Exists(l, refs.Tag(src)):
+			src = refs.Tag(src)
+		default:
+			return Refspec{}, fmt.Errorf("%w: %s", refs.ErrNotFound, src)
+		}
+	}
+	if !strings.HasPrefix(dst, "refs/") {
+		prefix := refs.HeadsPrefix
+		if strings.HasPrefix(src, refs.TagsPrefix) {
+			prefix = refs.TagsPrefix
+		}
+		dst = prefix + dst
+	}
+	r := Refspec{Force: force, Src: src, Dst: dst}
+	return ParseRefspec(r.String())
+}
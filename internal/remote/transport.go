@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/bundle"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// transport is a connection to the repository a remote URL names.
+type transport interface {
+	// list returns the refs of the remote repository and the branch its HEAD
+	// is on, empty if HEAD is detached.
+	list(ctx context.Context) (head string, all []refs.Ref, err error)
+	// fetch copies the history of wants into l. Haves are commits l already
+	// has, whose history need not be sent.
+	fetch(ctx context.Context, l *layout.Layout, wants, haves []string) error
+	// push sends the objects the updates need to the remote and applies them
+	// there, filling in the old commit and status of each update.
+	push(ctx context.Context, l *layout.Layout, updates []Update) error
+}
+
+// dial returns the transport for a URL: an http:// or https:// URL of a
+// repository served by Server, or the path of a local repository or bundle,
+// optionally prefixed with file://. Relative paths are relative to dir.
+func dial(dir, url string, opts HTTPOptions) (transport, error) {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return newHTTPTransport(url, opts), nil
+	}
+	path := strings.TrimPrefix(url, "file://")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if bundle.Is(path) {
+		return &bundleTransport{path: path}, nil
+	}
+	l, err := layout.New(path)
+	if err != nil {
+		return nil, err
+	}
+	if !l.Exists() {
+		return nil, fmt.Errorf("%w: %s", ErrNotRepository, url)
+	}
+	return &localTransport{l: l}, nil
+}
+
+// localTransport reads and writes another repository on the same machine directly.
+type localTransport struct {
+	l *layout.Layout
+}
+
+func (t *localTransport) list(ctx context.Context) (string, []refs.Ref, error) {
+	head, _, err := refs.ReadHead(t.l)
+	if err != nil {
+		return "", nil, err
+	}
+	all, err := refs.List(t.l, "refs/")
+	return head, all, err
+}
+
+func (t *localTransport) fetch(ctx context.Context, l *layout.Layout, wants, haves []string) error {
+	return transfer(ctx, l, t.l, wants)
+}
+
+func (t *localTransport) push(ctx context.Context, l *layout.Layout, updates []Update) error {
+	news := make([]string, len(updates))
+	for i, u := range updates {
+		news[i] = u.New
+	}
+	if err := transfer(ctx, t.l, l, news); err != nil {
+		return err
+	}
+	return apply(t.l, updates)
+}
+
+// bundleTransport fetches from a bundle file. Bundles cannot be pushed to.
+type bundleTransport struct {
+	path string
+}
+
+// list returns the refs in the bundle. The bundle's HEAD is taken to be on the
+// first branch pointing at the same commit, if any.
+func (t *bundleTransport) list(ctx context.Context) (string, []refs.Ref, error) {
+	br, err := bundle.Open(t.path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer br.Close()
+	var head string
+	var all []refs.Ref
+	for _, ref := range br.Refs {
+		if ref.Name != "HEAD" {
+			all = append(all, ref)
+		}
+	}
+	for _, ref := range br.Refs {
+		if ref.Name != "HEAD" {
+			continue
+		}
+		for _, branch := range all {
+			if branch.Hash == ref.Hash && strings.HasPrefix(branch.Name, refs.HeadsPrefix) {
+				head = branch.Name
+				break
+			}
+		}
+	}
+	return head, all, nil
+}
+
+func (t *bundleTransport) fetch(ctx context.Context, l *layout.Layout, wants, haves []string) error {
+	br, err := bundle.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer br.Close()
+	return br.Unbundle(l)
+}
+
+func (t *bundleTransport) push(ctx context.Context, l *layout.Layout, updates []Update) error {
+	return fmt.Errorf("%w: cannot push to a bundle", ErrForbidden)
+}
@@ -0,0 +1,14 @@
+package sign
+
+import "errors"
+
+var (
+	ErrInvalidKey       = errors.New("invalid signing key")
+	ErrInvalidSigners   = errors.New("invalid allowed signers file")
+	ErrInvalidSignature = errors.New("malformed signature")
+	ErrBadSignature     = errors.New("bad signature")
+	ErrUnknownSigner    = errors.New("signature is good but the key is not an allowed signer")
+	ErrUnsigned         = errors.New("no signature")
+	ErrNoKey            = errors.New("no signing key configured (set signing.key)")
+	ErrNoSigners        = errors.New("no allowed signers file configured (set signing.allowedSigners)")
+)
@@ -0,0 +1,319 @@
+// Package sign signs commits and tags with Ed25519 keys and verifies the
+// signatures against a list of allowed signers.
+//
+// Private keys are read from OpenSSH private key files, as written by
+// ssh-keygen -t ed25519 without a passphrase, or from PKCS #8 PEM files. Public
+// keys and allowed signers use the OpenSSH formats: a key is written as
+// "ssh-ed25519 <base64>", and each line of an allowed signers file is
+//
+//	<principal>[,<principal>...] [<options>] ssh-ed25519 <base64> [<comment>]
+//
+// where options, such as namespaces="git", are accepted but ignored. Blank
+// lines, lines starting with "#", and keys of other types are skipped.
+//
+// A signature is stored as a single line, the public key it was made with
+// followed by the base64 Ed25519 signature:
+//
+//	ssh-ed25519 <base64 key> <base64 signature>
+//
+// What is signed is the object's payload prefixed with a namespace, such as
+// "commit" or "tag", so a signature over one kind of object cannot be passed
+// off as a signature over another.
+package sign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Namespaces of the objects that are signed.
+const (
+	NamespaceCommit = "commit"
+	NamespaceTag    = "tag"
+)
+
+// Config keys naming the private key to sign with and the allowed signers
+// file to verify against.
+const (
+	KeyPathKey        = "signing.key"
+	AllowedSignersKey = "signing.allowedSigners"
+)
+
+// keyType is the OpenSSH name of Ed25519 keys.
+const keyType = "ssh-ed25519"
+
+// opensshMagic starts the binary content of an OpenSSH private key file.
+const opensshMagic = "openssh-key-v1\x00"
+
+// LoadKey reads a private key from the file at path.
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseKey decodes a PEM-encoded OpenSSH or PKCS #8 Ed25519 private key.
+func ParseKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: not a PEM file", ErrInvalidKey)
+	}
+	switch block.Type {
+	case "OPENSSH PRIVATE KEY":
+		return parseOpenSSHKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		}
+		ed, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T is not an Ed25519 key", ErrInvalidKey, key)
+		}
+		return ed, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported PEM block %q", ErrInvalidKey, block.Type)
+	}
+}
+
+// parseOpenSSHKey decodes the content of an unencrypted OpenSSH private key
+// file holding a single Ed25519 key.
+func parseOpenSSHKey(data []byte) (ed25519.PrivateKey, error) {
+	rest, ok := bytes.CutPrefix(data, []byte(opensshMagic))
+	if !ok {
+		return nil, fmt.Errorf("%w: not an OpenSSH private key", ErrInvalidKey)
+	}
+	r := &reader{data: rest}
+	cipher, kdf := r.string(), r.string()
+	r.string() // KDF options
+	n := r.uint32()
+	r.string() // Public key
+	private := &reader{data: r.bytes()}
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, r.err)
+	}
+	if cipher != "none" || kdf != "none" {
+		return nil, fmt.Errorf("%w: encrypted keys are not supported", ErrInvalidKey)
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("%w: file holds %d keys", ErrInvalidKey, n)
+	}
+	check1, check2 := private.uint32(), private.uint32()
+	typ := private.string()
+	pub := private.bytes()
+	priv := private.bytes()
+	if private.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, private.err)
+	}
+	switch {
+	case check1 != check2:
+		return nil, fmt.Errorf("%w: corrupt private section", ErrInvalidKey)
+	case typ != keyType:
+		return nil, fmt.Errorf("%w: %s keys are not supported", ErrInvalidKey, typ)
+	case len(pub) != ed25519.PublicKeySize || len(priv) != ed25519.PrivateKeySize:
+		return nil, fmt.Errorf("%w: wrong key size", ErrInvalidKey)
+	case !bytes.Equal(priv[ed25519.SeedSize:], pub):
+		return nil, fmt.Errorf("%w: public and private keys do not match", ErrInvalidKey)
+	}
+	return ed25519.PrivateKey(bytes.Clone(priv)), nil
+}
+
+// MarshalPublicKey formats a public key as "ssh-ed25519 <base64>".
+func MarshalPublicKey(pub ed25519.PublicKey) string {
+	return keyType + " " + base64.StdEncoding.EncodeToString(wireKey(pub))
+}
+
+// ParsePublicKey decodes the base64 part of a key written as
+// "ssh-ed25519 <base64>".
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	r := &reader{data: data}
+	typ, key := r.string(), r.bytes()
+	switch {
+	case r.err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, r.err)
+	case typ != keyType:
+		return nil, fmt.Errorf("%w: %s keys are not supported", ErrInvalidKey, typ)
+	case len(key) != ed25519.PublicKeySize || len(r.data) != 0:
+		return nil, fmt.Errorf("%w: wrong key size", ErrInvalidKey)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a public key in the form
+// ssh-keygen -l prints it, "SHA256:<unpadded base64>".
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(wireKey(pub))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// wireKey encodes a public key in the SSH wire format.
+func wireKey(pub ed25519.PublicKey) []byte {
+	var b []byte
+	b = appendString(b, []byte(keyType))
+	return appendString(b, pub)
+}
+
+func appendString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// message returns the bytes that are signed for a payload in a namespace.
+func message(namespace string, payload []byte) []byte {
+	return append([]byte("trac-signature\x00"+namespace+"\x00"), payload...)
+}
+
+// Sign signs payload in namespace and returns the signature line.
+func Sign(key ed25519.PrivateKey, namespace string, payload []byte) string {
+	sig := ed25519.Sign(key, message(namespace, payload))
+	return MarshalPublicKey(key.Public().(ed25519.PublicKey)) + " " + base64.StdEncoding.EncodeToString(sig)
+}
+
+// Signer is an entry of an allowed signers file.
+type Signer struct {
+	Principals []string
+	Key        ed25519.PublicKey
+}
+
+// LoadSigners reads the allowed signers file at path.
+func LoadSigners(path string) ([]Signer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	signers, err := ParseSigners(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return signers, nil
+}
+
+// ParseSigners reads the lines of an allowed signers file.
+func ParseSigners(r io.Reader) ([]Signer, error) {
+	var signers []Signer
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		i := 1
+		if i < len(fields) && !isKeyType(fields[i]) {
+			i++ // Options
+		}
+		if i+1 >= len(fields) {
+			return nil, fmt.Errorf("%w: line %d: missing key", ErrInvalidSigners, n)
+		}
+		if fields[i] != keyType {
+			continue
+		}
+		key, err := ParsePublicKey(fields[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidSigners, n, err)
+		}
+		signers = append(signers, Signer{Principals: strings.Split(fields[0], ","), Key: key})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+// Result describes a good signature.
+type Result struct {
+	Principal   string // First principal of the allowed signer whose key made the signature
+	Fingerprint string // Fingerprint of the key that made the signature
+}
+
+// Verify checks that signature is a good signature over payload in namespace
+// by one of signers. A good signature by a key that is not an allowed signer
+// returns the result, without a principal, and ErrUnknownSigner.
+func Verify(signature, namespace string, payload []byte, signers []Signer) (*Result, error) {
+	fields := strings.Fields(signature)
+	if len(fields) != 3 || fields[0] != keyType {
+		return nil, ErrInvalidSignature
+	}
+	pub, err := ParsePublicKey(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	result := &Result{Fingerprint: Fingerprint(pub)}
+	if !ed25519.Verify(pub, message(namespace, payload), sig) {
+		return nil, fmt.Errorf("%w from %s", ErrBadSignature, result.Fingerprint)
+	}
+	for _, s := range signers {
+		if s.Key.Equal(pub) {
+			result.Principal = s.Principals[0]
+			return result, nil
+		}
+	}
+	return result, fmt.Errorf("%w: %s", ErrUnknownSigner, result.Fingerprint)
+}
+
+// reader decodes the SSH wire format, remembering the first error.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	n := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return n
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.data)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+// isKeyType reports whether field names a type of OpenSSH public key, rather
+// than being the options of an allowed signer.
+func isKeyType(field string) bool {
+	return strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") || strings.HasPrefix(field, "sk-")
+}
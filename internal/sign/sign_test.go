@@ -0,0 +1,112 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// marshalOpenSSH encodes key as an unencrypted OpenSSH private key file.
+func marshalOpenSSH(key ed25519.PrivateKey) []byte {
+	pub := key.Public().(ed25519.PublicKey)
+	private := binary.BigEndian.AppendUint32(nil, 0x12345678)
+	private = binary.BigEndian.AppendUint32(private, 0x12345678)
+	private = appendString(private, []byte(keyType))
+	private = appendString(private, pub)
+	private = appendString(private, key)
+	private = appendString(private, []byte("test@example.com"))
+	for i := byte(1); len(private)%8 != 0; i++ {
+		private = append(private, i)
+	}
+	data := []byte(opensshMagic)
+	data = appendString(data, []byte("none"))
+	data = appendString(data, []byte("none"))
+	data = appendString(data, nil)
+	data = binary.BigEndian.AppendUint32(data, 1)
+	data = appendString(data, wireKey(pub))
+	data = appendString(data, private)
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data})
+}
+
+func TestParseKey(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	parsed, err := ParseKey(marshalOpenSSH(key))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err = ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	_, err = ParseKey([]byte("not a key"))
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	encoded := strings.Fields(MarshalPublicKey(pub))[1]
+	parsedPub, err := ParsePublicKey(encoded)
+	require.NoError(t, err)
+	require.True(t, pub.Equal(parsedPub))
+}
+
+func TestParseKeyFromSSHKeygen(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "test", "-f", path).CombinedOutput()
+	require.NoError(t, err, string(out))
+	key, err := LoadKey(path)
+	require.NoError(t, err)
+	pubLine, err := os.ReadFile(path + ".pub")
+	require.NoError(t, err)
+	require.Equal(t, MarshalPublicKey(key.Public().(ed25519.PublicKey)), strings.Join(strings.Fields(string(pubLine))[:2], " "))
+
+	out, err = exec.Command("ssh-keygen", "-l", "-E", "sha256", "-f", path+".pub").CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, strings.Fields(string(out))[1], Fingerprint(key.Public().(ed25519.PublicKey)))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signers, err := ParseSigners(strings.NewReader("# release keys\n\n" +
+		"alice@example.com,release@example.com namespaces=\"git\" " + MarshalPublicKey(pub) + " laptop\n" +
+		"bob@example.com ssh-rsa AAAAB3NzaC1yc2E=\n"))
+	require.NoError(t, err)
+	require.Len(t, signers, 1)
+
+	payload := []byte("payload")
+	sig := Sign(key, NamespaceCommit, payload)
+	result, err := Verify(sig, NamespaceCommit, payload, signers)
+	require.NoError(t, err)
+	require.Equal(t, &Result{Principal: "alice@example.com", Fingerprint: Fingerprint(pub)}, result)
+
+	_, err = Verify(sig, NamespaceCommit, []byte("tampered"), signers)
+	require.ErrorIs(t, err, ErrBadSignature)
+	_, err = Verify(sig, NamespaceTag, payload, signers)
+	require.ErrorIs(t, err, ErrBadSignature)
+	_, err = Verify("garbage", NamespaceCommit, payload, signers)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	result, err = Verify(Sign(other, NamespaceCommit, payload), NamespaceCommit, payload, signers)
+	require.ErrorIs(t, err, ErrUnknownSigner)
+	require.Empty(t, result.Principal)
+
+	_, err = ParseSigners(strings.NewReader("alice@example.com\n"))
+	require.ErrorIs(t, err, ErrInvalidSigners)
+}
@@ -21,3 +21,9 @@ func HashFile(path string) (string, error) {
 	hashString := hex.EncodeToString(hash.Sum(nil))
 	return hashString, nil
 }
+
+// HashBytes returns the hash data is stored under in the object database.
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,152 @@
+package trac
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/bundle"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// BundleHeader describes what a bundle holds and what it needs.
+type BundleHeader struct {
+	Refs          []Ref    // Refs in the bundle; HEAD, when included, is named "HEAD"
+	Prerequisites []string // Commits whose history the bundle leaves out, which the receiving repository must have
+}
+
+func newBundleHeader(h *bundle.Header) *BundleHeader {
+	result := &BundleHeader{Prerequisites: h.Prerequisites}
+	for _, ref := range h.Refs {
+		result.Refs = append(result.Refs, Ref{Name: ref.Name, Hash: ref.Hash})
+	}
+	return result
+}
+
+// CreateBundle writes a bundle of refs and their history to w, for another
+// repository to clone, fetch or unbundle from without reaching this one.
+//
+// Each rev is either a ref to include, given by its full or short name or as
+// HEAD, a range A..B including B but leaving out the history of A, or ^A,
+// leaving out the history of A. The commits left out become the bundle's
+// prerequisites.
+func (r *Repository) CreateBundle(ctx context.Context, w io.Writer, revs []string) (*BundleHeader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	include, exclude, err := r.revisionRefs(revs)
+	if err != nil {
+		return nil, err
+	}
+	h := &bundle.Header{Refs: include, Prerequisites: exclude}
+	if _, err := bundle.Write(w, r.l, h); err != nil {
+		return nil, err
+	}
+	return newBundleHeader(h), nil
+}
+
+// revisionRefs resolves revs given as refs to include, ranges A..B and
+// exclusions ^A into the refs to include and the commits whose history is
+// left out.
+func (r *Repository) revisionRefs(revs []string) (include []refs.Ref, exclude []string, err error) {
+	for _, rev := range revs {
+		var in string
+		out, isExclude := strings.CutPrefix(rev, "^")
+		if !isExclude {
+			var isRange bool
+			out, in, isRange = strings.Cut(rev, "..")
+			if !isRange {
+				out, in = "", rev
+			}
+		}
+		if out != "" {
+			hash, err := r.Resolve(out)
+			if err != nil {
+				return nil, nil, err
+			}
+			exclude = append(exclude, hash)
+		}
+		if in != "" {
+			name, err := r.fullRefName(in)
+			if err != nil {
+				return nil, nil, err
+			}
+			// Reading the ref rather than resolving it keeps annotated tags.
+			var hash string
+			if name == "HEAD" {
+				hash, err = r.Resolve(name)
+			} else {
+				hash, err = refs.Read(r.l, name)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, refs.Ref{Name: name, Hash: hash})
+		}
+	}
+	return include, exclude, nil
+}
+
+// fullRefName returns the full name of the ref a short name resolves to,
+// looking it up the same way Resolve does.
+func (r *Repository) fullRefName(name string) (string, error) {
+	if name == "HEAD" {
+		return name, nil
+	}
+	candidates := []string{refs.Tag(name), refs.Branch(name), refs.RemotesPrefix + name}
+	if strings.HasPrefix(name, "refs/") {
+		candidates = []string{name}
+	}
+	for _, full := range candidates {
+		if refs.Exists(r.l, full) {
+			return full, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s is not a branch or tag", ErrRefNotFound, name)
+}
+
+// ReadBundleHeader reads the header of the bundle at path.
+func ReadBundleHeader(path string) (*BundleHeader, error) {
+	br, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer br.Close()
+	return newBundleHeader(&br.Header), nil
+}
+
+// VerifyBundle checks that the bundle at path is intact and that the
+// repository has its prerequisites, so that it could be unbundled here.
+func (r *Repository) VerifyBundle(ctx context.Context, path string) (*BundleHeader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	br, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer br.Close()
+	if err := br.Verify(r.l); err != nil {
+		return nil, err
+	}
+	return newBundleHeader(&br.Header), nil
+}
+
+// Unbundle stores the objects of the bundle at path in the repository and
+// returns its header. No refs are updated; fetch from the bundle as a remote,
+// or set refs from the returned header, to make its history reachable.
+func (r *Repository) Unbundle(ctx context.Context, path string) (*BundleHeader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	br, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer br.Close()
+	if err := br.Unbundle(r.l); err != nil {
+		return nil, err
+	}
+	return newBundleHeader(&br.Header), nil
+}
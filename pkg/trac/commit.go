@@ -0,0 +1,422 @@
+package trac
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/sign"
+)
+
+// Commit is a recorded snapshot of the repository.
+type Commit struct {
+	Hash    string
+	Parent  string // Empty for the first commit
+	Message string
+	Time    time.Time
+	Files   map[string]string // Content hash of every file, keyed by slash-separated path relative to the root
+	Modes   map[string]string // Mode of every file that is not a regular file, such as ModeExecutable, keyed like Files
+	Author  *Signature        // Who wrote the change, when recorded separately from the commit
+}
+
+// Signature identifies a person and when they acted.
+type Signature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Time  time.Time `json:"time"`
+}
+
+// String formats the signature as "Name <email>".
+func (s *Signature) String() string {
+	return s.Name + " <" + s.Email + ">"
+}
+
+// Summary returns the first line of the commit message.
+func (c *Commit) Summary() string {
+	summary, _, _ := strings.Cut(c.Message, "\n")
+	return summary
+}
+
+// CommitOptions controls how a commit is created.
+type CommitOptions struct {
+	Author *Signature // Who wrote the change, if not the person committing it
+	// Amend replaces the commit HEAD points at rather than adding to it: the
+	// new commit takes its parent and, unless Author is set, its author.
+	Amend bool
+	// All stages the current content of every file in the index first,
+	// removing files that have been deleted from the working tree.
+	All bool
+	// Paths limits the commit to the current content of the named files, or
+	// the files beneath named directories, that are tracked: other files are
+	// recorded as they are at HEAD, and keep any changes staged for them.
+	// The index entries of the named files are updated to match.
+	Paths []string
+	// Staged takes the files All or Paths name as they are in the index,
+	// rather than staging their current content first, for a caller that has
+	// staged them already with StageTracked.
+	Staged bool
+	// AllowEmpty records the commit even if it has the same files as its
+	// parent, and even if nothing has ever been staged.
+	AllowEmpty bool
+	// AllowEmptyMessage records the commit even if its message is empty or
+	// only whitespace.
+	AllowEmptyMessage bool
+	// Sign signs the commit with the key set by signing.key; see VerifyCommit.
+	Sign bool
+}
+
+// Commit records the staged files as a new commit on top of HEAD and moves the
+// current branch, or HEAD if it is detached, to it.
+//
+// Unless opts.AllowEmpty is set, a commit with the same files as its parent is
+// refused with ErrNothingStaged if the working tree has changes that are not
+// staged, ErrNothingAdded if it only has untracked files, and
+// ErrNothingToCommit otherwise.
+func (r *Repository) Commit(ctx context.Context, message string, opts CommitOptions) (*Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !opts.AllowEmptyMessage && strings.TrimSpace(message) == "" {
+		return nil, ErrEmptyMessage
+	}
+	var key ed25519.PrivateKey
+	if opts.Sign {
+		var err error
+		if key, err = r.signingKey(); err != nil {
+			return nil, err
+		}
+	}
+	idx := index.New()
+	if err := idx.Load(r.l); err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !opts.AllowEmpty {
+			return nil, ErrNothingAdded
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	parent, err := commit.GetParentHash(r.l)
+	if err != nil {
+		return nil, err
+	}
+	head := &commit.Commit{}
+	if parent != "" {
+		if head, err = commit.Load(parent, r.l); err != nil {
+			return nil, err
+		}
+	}
+
+	files, modes := idx.Staged, idx.Modes
+	switch {
+	case opts.All:
+		if !opts.Staged {
+			tracked, err := r.tree(idx.Staged)
+			if err != nil {
+				return nil, err
+			}
+			if err := r.stageWorkTree(idx, slices.Collect(maps.Keys(tracked))); err != nil {
+				return nil, err
+			}
+		}
+		modes = idx.Modes
+	case len(opts.Paths) > 0:
+		if files, modes, err = r.stagePaths(idx, head, opts.Paths, !opts.Staged); err != nil {
+			return nil, err
+		}
+	}
+
+	author := head.Author
+	if opts.Amend {
+		if parent == "" {
+			return nil, ErrNoCommits
+		}
+		parent = head.Parent
+	} else {
+		author = nil
+	}
+	if opts.Author != nil {
+		author = &commit.Signature{Name: opts.Author.Name, Email: opts.Author.Email, Time: opts.Author.Time}
+	}
+	if !opts.AllowEmpty {
+		if err := r.checkChanged(ctx, files, modes, parent); err != nil {
+			return nil, err
+		}
+	}
+	c := commit.New(message, parent, files)
+	c.Modes = filemode.Select(modes, files)
+	c.Author = author
+	if key != nil {
+		payload, err := c.Payload()
+		if err != nil {
+			return nil, err
+		}
+		c.Sig = sign.Sign(key, sign.NamespaceCommit, payload)
+	}
+	hash, err := c.Save(r.l)
+	if err != nil {
+		return nil, err
+	}
+	if opts.All || len(opts.Paths) > 0 {
+		if err := idx.Write(r.l); err != nil {
+			return nil, fmt.Errorf("failed to write updated index: %w", err)
+		}
+	}
+	return r.ReadCommit(hash)
+}
+
+// checkChanged returns an error explaining why there is nothing to commit if
+// files, and their modes, are the same as those of the commit parent.
+func (r *Repository) checkChanged(ctx context.Context, files, modes map[string]string, parent string) error {
+	tree, err := r.tree(files)
+	if err != nil {
+		return err
+	}
+	treeModes, err := r.tree(filemode.Select(modes, files))
+	if err != nil {
+		return err
+	}
+	parentTree, parentModes := map[string]string{}, map[string]string{}
+	if parent != "" {
+		if parentTree, parentModes, err = r.commitTree(parent); err != nil {
+			return err
+		}
+	}
+	if len(compareTrees(parentTree, tree, parentModes, treeModes)) > 0 {
+		return nil
+	}
+	st, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(st.Unstaged) > 0:
+		return ErrNothingStaged
+	case len(st.Untracked) > 0:
+		return ErrNothingAdded
+	}
+	return ErrNothingToCommit
+}
+
+// stagePaths stages the current content of the tracked files matching paths,
+// unless stage is false, and returns the files at HEAD, and their modes, with
+// those files replaced by their staged content and mode. Each path must match
+// a file in the index or at HEAD.
+func (r *Repository) stagePaths(idx *index.Index, head *commit.Commit, paths []string, stage bool) (files, modes map[string]string, err error) {
+	matched, err := r.trackedPaths(idx, head, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stage {
+		if err := r.stageWorkTree(idx, matched); err != nil {
+			return nil, nil, err
+		}
+	}
+	headTree, err := r.tree(head.Changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	headModes, err := r.tree(head.Modes)
+	if err != nil {
+		return nil, nil, err
+	}
+	staged, err := r.tree(idx.Staged)
+	if err != nil {
+		return nil, nil, err
+	}
+	stagedModes, err := r.tree(idx.Modes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range matched {
+		if hash, ok := staged[path]; ok {
+			headTree[path] = hash
+			filemode.Set(headModes, path, filemode.Get(stagedModes, path))
+		} else {
+			delete(headTree, path)
+			delete(headModes, path)
+		}
+	}
+	return headTree, headModes, nil
+}
+
+// trackedPaths returns the files in the index or at HEAD that match paths,
+// relative to the root. Each path must match one of them.
+func (r *Repository) trackedPaths(idx *index.Index, head *commit.Commit, paths []string) ([]string, error) {
+	pathspecs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		rel, err := r.relPath(path)
+		if err != nil {
+			return nil, err
+		}
+		pathspecs = append(pathspecs, rel)
+	}
+	headTree, err := r.tree(head.Changes)
+	if err != nil {
+		return nil, err
+	}
+	staged, err := r.tree(idx.Staged)
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, tree := range []map[string]string{staged, headTree} {
+		for path := range tree {
+			if matchPathspec(path, pathspecs) && !slices.Contains(matched, path) {
+				matched = append(matched, path)
+			}
+		}
+	}
+	for i, spec := range pathspecs {
+		if !slices.ContainsFunc(matched, func(path string) bool { return matchPathspec(path, []string{spec}) }) {
+			return nil, fmt.Errorf("%w: %s", ErrPathNotTracked, paths[i])
+		}
+	}
+	return matched, nil
+}
+
+// stageWorkTree updates the index entries of files, given relative to the
+// root, to their content in the working tree, removing those that are gone.
+func (r *Repository) stageWorkTree(idx *index.Index, files []string) error {
+	for _, file := range files {
+		info, err := os.Lstat(r.workTreePath(file))
+		switch {
+		case err == nil && (info.Mode().IsRegular() || info.Mode()&fs.ModeSymlink != 0):
+			if err := add(idx, r, file); err != nil {
+				return &fs.PathError{Op: "add", Path: file, Err: err}
+			}
+		case err == nil || errors.Is(err, fs.ErrNotExist):
+			key, ok, err := idx.Find(file, r.l)
+			if err != nil {
+				return err
+			}
+			if ok {
+				delete(idx.Staged, key)
+			}
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadCommit returns the commit with the given full hash.
+func (r *Repository) ReadCommit(hash string) (*Commit, error) {
+	c, err := commit.Load(hash, r.l)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.tree(c.Changes)
+	if err != nil {
+		return nil, err
+	}
+	modes, err := r.tree(c.Modes)
+	if err != nil {
+		return nil, err
+	}
+	result := &Commit{Hash: hash, Parent: c.Parent, Message: c.Message, Time: c.Timestamp, Files: files, Modes: modes}
+	if c.Author != nil {
+		result.Author = &Signature{Name: c.Author.Name, Email: c.Author.Email, Time: c.Author.Time}
+	}
+	return result, nil
+}
+
+// Log iterates over the history of a revision, starting with the commit it
+// refers to and following parents back to the first commit. Iteration stops
+// after the first error, which is yielded along with a nil commit.
+func (r *Repository) Log(ctx context.Context, rev string) iter.Seq2[*Commit, error] {
+	return func(yield func(*Commit, error) bool) {
+		hash, err := r.Resolve(rev)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for hash != "" {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			c, err := r.ReadCommit(hash)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(c, nil) {
+				return
+			}
+			hash = c.Parent
+		}
+	}
+}
+
+// FileChange is a commit along with the change it made to a file.
+type FileChange struct {
+	Commit *Commit
+	Change Change
+}
+
+// Follow iterates like Log over the commits in the history of rev that change
+// the file at path, following it back through renames: a commit that renamed
+// or copied the file to path is followed by the changes to the file it came
+// from. opts controls how renames are found, and Renames is implied.
+// Iteration stops at the commit that added the file.
+func (r *Repository) Follow(ctx context.Context, rev, path string, opts *DiffOptions) iter.Seq2[*FileChange, error] {
+	return func(yield func(*FileChange, error) bool) {
+		followOpts := &DiffOptions{Renames: true}
+		if opts != nil {
+			followOpts.Copies, followOpts.RenameThreshold = opts.Copies, opts.RenameThreshold
+		}
+		path, err := r.relPath(path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for c, err := range r.Log(ctx, rev) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			oldTree, oldModes := map[string]string{}, map[string]string{}
+			if c.Parent != "" {
+				if oldTree, oldModes, err = r.commitTree(c.Parent); err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+			changes := compareTrees(oldTree, c.Files, oldModes, c.Modes)
+			i := slices.IndexFunc(changes, func(ch Change) bool { return ch.Path == path })
+			if i < 0 {
+				continue
+			}
+			if changes[i].Kind == Added {
+				// Only look for renames when the file appears, to spare
+				// comparing the content of every commit.
+				if changes, err = r.changes(oldTree, c.Files, oldModes, c.Modes, r.readObject, followOpts); err != nil {
+					yield(nil, err)
+					return
+				}
+				i = slices.IndexFunc(changes, func(ch Change) bool { return ch.Path == path })
+			}
+			change := changes[i]
+			if !yield(&FileChange{Commit: c, Change: change}, nil) {
+				return
+			}
+			switch change.Kind {
+			case Added:
+				return
+			case Renamed, Copied:
+				path = change.OldPath
+			}
+		}
+	}
+}
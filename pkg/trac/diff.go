@@ -0,0 +1,335 @@
+package trac
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// LineOp is the role of a line in a hunk. Its value is the prefix used for the
+// line in unified diff output.
+type LineOp byte
+
+const (
+	OpContext LineOp = ' '
+	OpInsert  LineOp = '+'
+	OpDelete  LineOp = '-'
+)
+
+// Line is a line of a hunk, without its trailing newline.
+type Line struct {
+	Op   LineOp
+	Text string
+}
+
+// Hunk is a region of a file that changed, with surrounding context lines.
+// Starts are 1-based line numbers as they appear in unified diff headers.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the unified diff range header of the hunk, e.g. "@@ -1,3 +1,4 @@".
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", unifiedRange(h.OldStart, h.OldLines), unifiedRange(h.NewStart, h.NewLines))
+}
+
+func unifiedRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// FileDiff is the difference between two versions of a file. The old version
+// of a renamed or copied file is the file at OldPath.
+type FileDiff struct {
+	Path       string // Slash-separated path relative to the root
+	Kind       ChangeKind
+	OldHash    string // Empty for added files
+	NewHash    string // Empty for deleted files
+	OldMode    string // Empty for added files
+	NewMode    string // Empty for deleted files
+	OldPath    string // Path the file was renamed or copied from, empty for other changes
+	Similarity int    // How alike the file is to the one at OldPath, in percent
+	Binary     bool   // Either version is binary, so no hunks are computed
+	Hunks      []Hunk
+}
+
+// Header returns the lines of the unified diff that come before the hunks.
+func (d *FileDiff) Header() string {
+	var sb strings.Builder
+	oldPath := cmp.Or(d.OldPath, d.Path)
+	fmt.Fprintf(&sb, "diff --trac a/%s b/%s\n", oldPath, d.Path)
+	oldName, newName := "a/"+oldPath, "b/"+d.Path
+	switch d.Kind {
+	case Added:
+		fmt.Fprintf(&sb, "new file mode %s\n", cmp.Or(d.NewMode, ModeRegular))
+		oldName = "/dev/null"
+	case Deleted:
+		fmt.Fprintf(&sb, "deleted file mode %s\n", cmp.Or(d.OldMode, ModeRegular))
+		newName = "/dev/null"
+	case Modified, Renamed, Copied:
+		if d.Kind != Modified {
+			verb := "rename"
+			if d.Kind == Copied {
+				verb = "copy"
+			}
+			fmt.Fprintf(&sb, "similarity index %d%%\n%s from %s\n%s to %s\n", d.Similarity, verb, d.OldPath, verb, d.Path)
+		}
+		if d.OldMode != d.NewMode {
+			fmt.Fprintf(&sb, "old mode %s\nnew mode %s\n", d.OldMode, d.NewMode)
+		}
+		if d.OldHash == d.NewHash {
+			return sb.String()
+		}
+	}
+	if d.Binary {
+		fmt.Fprintf(&sb, "Binary files %s and %s differ\n", oldName, newName)
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	return sb.String()
+}
+
+// String returns the difference in unified diff format.
+func (d *FileDiff) String() string {
+	var sb strings.Builder
+	sb.WriteString(d.Header())
+	for _, h := range d.Hunks {
+		sb.WriteString(h.Header() + "\n")
+		for _, line := range h.Lines {
+			sb.WriteByte(byte(line.Op))
+			sb.WriteString(line.Text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// DiffOptions controls how differences are computed.
+type DiffOptions struct {
+	Context int // Lines of context around each change; 3 when zero, none when negative
+	// Renames pairs deleted files with added files that have the same or
+	// similar content, and reports them as renamed.
+	Renames bool
+	// Copies also reports added files like a file of the old version that is
+	// kept as copied from it. It implies Renames.
+	Copies bool
+	// RenameThreshold is how alike, in percent, two files must be to be paired
+	// as a rename or copy; when zero, that set by diff.renameThreshold, or 50.
+	RenameThreshold int
+}
+
+func (o *DiffOptions) context() int {
+	switch {
+	case o == nil || o.Context == 0:
+		return 3
+	case o.Context < 0:
+		return 0
+	}
+	return o.Context
+}
+
+// Diff returns the differences between the files of two revisions. An empty
+// from compares against no files at all, so every file in to shows up as added.
+func (r *Repository) Diff(ctx context.Context, from, to string, opts *DiffOptions) ([]FileDiff, error) {
+	oldTree, oldModes := map[string]string{}, map[string]string{}
+	if from != "" {
+		var err error
+		if oldTree, oldModes, err = r.revisionTree(from); err != nil {
+			return nil, err
+		}
+	}
+	newTree, newModes, err := r.revisionTree(to)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := r.changes(oldTree, newTree, oldModes, newModes, r.readObject, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.diffTrees(ctx, changes, r.readObject, opts)
+}
+
+// Changes returns the files that differ between two revisions, like Diff
+// but without computing hunks.
+func (r *Repository) Changes(ctx context.Context, from, to string, opts *DiffOptions) ([]Change, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	oldTree, oldModes := map[string]string{}, map[string]string{}
+	if from != "" {
+		var err error
+		if oldTree, oldModes, err = r.revisionTree(from); err != nil {
+			return nil, err
+		}
+	}
+	newTree, newModes, err := r.revisionTree(to)
+	if err != nil {
+		return nil, err
+	}
+	return r.changes(oldTree, newTree, oldModes, newModes, r.readObject, opts)
+}
+
+// changes compares two trees of files, finding renames and copies as opts ask.
+// readNew reads the content of files in newTree.
+func (r *Repository) changes(oldTree, newTree, oldModes, newModes map[string]string, readNew func(path, hash string) ([]byte, error), opts *DiffOptions) ([]Change, error) {
+	changes := compareTrees(oldTree, newTree, oldModes, newModes)
+	if opts == nil || !opts.Renames && !opts.Copies {
+		return changes, nil
+	}
+	threshold, err := r.renameThreshold(opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.findRenames(changes, oldTree, oldModes, readNew, opts.Copies, threshold)
+}
+
+// DiffCached returns the differences between a revision and the index, i.e.
+// the changes that would be committed on top of it. An empty rev means HEAD, or
+// no files at all before the first commit.
+func (r *Repository) DiffCached(ctx context.Context, rev string, opts *DiffOptions) ([]FileDiff, error) {
+	var oldTree, oldModes map[string]string
+	var err error
+	if rev == "" {
+		oldTree, oldModes, err = r.headTree()
+	} else {
+		oldTree, oldModes, err = r.revisionTree(rev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	newTree, newModes, err := r.indexTree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := r.changes(oldTree, newTree, oldModes, newModes, r.readObject, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.diffTrees(ctx, changes, r.readObject, opts)
+}
+
+// DiffWorkTree returns the differences between the index and the working tree
+// for staged files, i.e. the changes that have not been staged yet.
+func (r *Repository) DiffWorkTree(ctx context.Context, opts *DiffOptions) ([]FileDiff, error) {
+	oldTree, oldModes, err := r.indexTree()
+	if err != nil {
+		return nil, err
+	}
+	fileMode, err := filemode.Enabled(r.l)
+	if err != nil {
+		return nil, err
+	}
+	newTree := make(map[string]string, len(oldTree))
+	newModes := make(map[string]string)
+	for path := range oldTree {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hash, mode, err := r.statWorkTree(path, filemode.Get(oldModes, path), fileMode)
+		if os.IsNotExist(err) || mode == filemode.Dir {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		newTree[path] = hash
+		filemode.Set(newModes, path, mode)
+	}
+	readFile := func(path, _ string) ([]byte, error) { return r.readWorkTree(path) }
+	changes, err := r.changes(oldTree, newTree, oldModes, newModes, readFile, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.diffTrees(ctx, changes, readFile, opts)
+}
+
+// readWorkTree reads the content of a file in the working tree; see filemode.Read.
+func (r *Repository) readWorkTree(path string) ([]byte, error) {
+	data, _, err := filemode.Read(r.workTreePath(path))
+	return data, err
+}
+
+func (r *Repository) workTreePath(path string) string {
+	return filepath.Join(r.l.Root, filepath.FromSlash(path))
+}
+
+func (r *Repository) revisionTree(rev string) (files, modes map[string]string, err error) {
+	hash, err := r.Resolve(rev)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.commitTree(hash)
+}
+
+func (r *Repository) readObject(_, hash string) ([]byte, error) {
+	return object.Read(r.l, hash)
+}
+
+// diffTrees computes the differences of changes between two trees of files.
+// readNew reads the content of files in the newer tree.
+func (r *Repository) diffTrees(ctx context.Context, changes []Change, readNew func(path, hash string) ([]byte, error), opts *DiffOptions) ([]FileDiff, error) {
+	var diffs []FileDiff
+	for _, change := range changes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d := FileDiff{Path: change.Path, Kind: change.Kind, OldHash: change.OldHash, NewHash: change.NewHash, OldMode: change.OldMode, NewMode: change.NewMode, OldPath: change.OldPath, Similarity: change.Similarity}
+		var oldData, newData []byte
+		var err error
+		if d.OldHash != "" {
+			if oldData, err = r.readObject(d.Path, d.OldHash); err != nil {
+				return nil, err
+			}
+		}
+		if d.NewHash != "" {
+			if newData, err = readNew(d.Path, d.NewHash); err != nil {
+				return nil, err
+			}
+		}
+		if diff.IsBinary(oldData) || diff.IsBinary(newData) {
+			d.Binary = true
+		} else {
+			d.Hunks = Hunks(oldData, newData, opts)
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// Hunks computes the hunks that turn old into new.
+func Hunks(old, new []byte, opts *DiffOptions) []Hunk {
+	edits := diff.Lines(diff.SplitLines(old), diff.SplitLines(new))
+	var hunks []Hunk
+	for _, h := range diff.Hunks(edits, opts.context()) {
+		hunk := Hunk{OldStart: h.OldStart + 1, OldLines: h.OldLines(), NewStart: h.NewStart + 1, NewLines: h.NewLines()}
+		// Empty ranges name the line before the range, as in unified diffs.
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		for _, e := range h.Edits {
+			op := OpContext
+			switch e.Op {
+			case diff.Insert:
+				op = OpInsert
+			case diff.Delete:
+				op = OpDelete
+			}
+			hunk.Lines = append(hunk.Lines, Line{Op: op, Text: e.Text})
+		}
+		hunks = append(hunks, hunk)
+	}
+	return hunks
+}
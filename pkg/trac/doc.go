@@ -0,0 +1,45 @@
+// Package trac provides programmatic access to trac repositories, for embedding
+// trac in Go programs without shelling out to the trac binary.
+//
+// A Repository is opened with Open or created with Init. Files are staged with
+// Add, recorded with Commit, and history is walked with Log:
+//
+//	repo, err := trac.Open("/path/to/repo")
+//	if err != nil {
+//		return err
+//	}
+//	if err := repo.Add(ctx, "README.md"); err != nil {
+//		return err
+//	}
+//	if _, err := repo.Commit(ctx, "Update README", trac.CommitOptions{}); err != nil {
+//		return err
+//	}
+//	for c, err := range repo.Log(ctx, "HEAD") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(c.Hash[:8], c.Summary())
+//	}
+//
+// The files of any revision can be read without checking it out through the
+// fs.FS returned by FS.
+//
+// History is shared with other repositories through remotes, with Clone, Fetch
+// and Push. A remote is either another repository on the same machine or one
+// served over HTTP by the handler Handler returns. Repositories that cannot
+// reach each other exchange history through bundle files, written with
+// CreateBundle and used as the URL of a remote. Commits can also be sent as
+// patch emails, written with FormatPatches and committed again with Am, and
+// diffs applied to the working tree with Apply.
+//
+// Commits and annotated tags can be signed with an Ed25519 key, by setting
+// CommitOptions.Sign or TagOptions.Sign, and their signatures checked against
+// an allowed signers file with VerifyCommit and VerifyTag.
+//
+// Paths passed to a Repository may be absolute or relative to the root of the
+// repository. Paths returned by it are slash-separated and relative to the root.
+//
+// Errors can be matched with errors.Is against the Err values in this package.
+// Operations that take a context stop early and return the context's error
+// when it is cancelled.
+package trac
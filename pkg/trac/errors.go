@@ -0,0 +1,63 @@
+package trac
+
+import (
+	"errors"
+
+	"github.com/lucasrod16/trac/internal/bundle"
+	"github.com/lucasrod16/trac/internal/checkout"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/fast"
+	"github.com/lucasrod16/trac/internal/hooks"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/patch"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/internal/remote"
+	"github.com/lucasrod16/trac/internal/sign"
+)
+
+var (
+	ErrNotRepository        = layout.ErrNotTracRepository
+	ErrRepositoryExists     = errors.New("trac repository already exists")
+	ErrNothingToCommit      = commit.ErrWorkingTreeClean
+	ErrNothingAdded         = commit.ErrNothingAddedToCommit
+	ErrNothingStaged        = commit.ErrNothingStaged
+	ErrEmptyMessage         = commit.ErrEmptyMessage
+	ErrNoCommits            = commit.ErrNoCommits
+	ErrUnknownRevision      = commit.ErrUnknownRevision
+	ErrAmbiguousRevision    = commit.ErrAmbiguousRevision
+	ErrPathNotInCommit      = commit.ErrPathNotInCommit
+	ErrPathNotTracked       = commit.ErrPathNotTracked
+	ErrInvalidTag           = commit.ErrInvalidTag
+	ErrInvalidSigningKey    = sign.ErrInvalidKey
+	ErrInvalidSigners       = sign.ErrInvalidSigners
+	ErrInvalidSignature     = sign.ErrInvalidSignature
+	ErrBadSignature         = sign.ErrBadSignature
+	ErrUnknownSigner        = sign.ErrUnknownSigner
+	ErrUnsigned             = sign.ErrUnsigned
+	ErrNoSigningKey         = sign.ErrNoKey
+	ErrNoAllowedSigners     = sign.ErrNoSigners
+	ErrRefNotFound          = refs.ErrNotFound
+	ErrRefExists            = refs.ErrExists
+	ErrInvalidRefName       = refs.ErrInvalidName
+	ErrCurrentBranch        = errors.New("branch is checked out")
+	ErrLocalChanges         = checkout.ErrLocalChanges
+	ErrUntrackedChanges     = checkout.ErrUntrackedChanges
+	ErrRemoteNotFound       = remote.ErrNotFound
+	ErrRemoteExists         = remote.ErrExists
+	ErrInvalidRefspec       = remote.ErrInvalidRefspec
+	ErrNotRemote            = remote.ErrNotRepository
+	ErrAuthRequired         = remote.ErrAuthRequired
+	ErrPermissionDenied     = remote.ErrForbidden
+	ErrNotBundle            = bundle.ErrNotBundle
+	ErrInvalidBundle        = bundle.ErrInvalid
+	ErrMissingPrerequisites = bundle.ErrMissingPrerequisite
+	ErrInvalidStream        = fast.ErrInvalidStream
+	ErrUnsupportedCommand   = fast.ErrUnsupported
+	ErrUnknownMark          = fast.ErrUnknownMark
+	ErrInvalidPatch         = patch.ErrInvalidPatch
+	ErrPatchDoesNotApply    = patch.ErrDoesNotApply
+	ErrNoPatches            = patch.ErrNoPatches
+	ErrPatchConflict        = patch.ErrConflict
+	ErrDirtyIndex           = errors.New("index has changes that are not committed")
+	ErrHookFailed           = hooks.ErrFailed
+)
@@ -0,0 +1,54 @@
+package trac
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/fast"
+)
+
+// FastExportOptions controls FastExport.
+type FastExportOptions struct {
+	Marks     map[int]string // Marks from an earlier export; the history they name is not written again
+	Committer string         // Identity written as the committer of commits with no recorded author, as "Name <email>"
+}
+
+// FastExport writes the history of revs to w as a stream that git fast-import
+// reads, and returns every mark known at the end, for continuing the export
+// later. Revs are given as for CreateBundle; HEAD stands for the branch it is
+// on.
+//
+// trac does not record who made a commit, so commits name their author as the
+// committer, or opts.Committer, or an empty identity, if no author was recorded.
+func (r *Repository) FastExport(ctx context.Context, w io.Writer, revs []string, opts *FastExportOptions) (map[int]string, error) {
+	if opts == nil {
+		opts = &FastExportOptions{}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	include, exclude, err := r.revisionRefs(revs)
+	if err != nil {
+		return nil, err
+	}
+	for i, ref := range include {
+		if ref.Name != "HEAD" {
+			continue
+		}
+		branch, _, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+		if branch == "" {
+			return nil, fmt.Errorf("%w: HEAD is not on a branch", ErrRefNotFound)
+		}
+		include[i].Name = branch
+	}
+	return fast.Export(ctx, r.l, w, fast.ExportOptions{
+		Refs:      include,
+		Exclude:   exclude,
+		Marks:     opts.Marks,
+		Committer: opts.Committer,
+	})
+}
@@ -0,0 +1,47 @@
+package trac
+
+import (
+	"context"
+	"io"
+
+	"github.com/lucasrod16/trac/internal/fast"
+)
+
+// FastImportOptions controls FastImport.
+type FastImportOptions struct {
+	Marks    map[int]string // Marks from an earlier import, for streams that continue it
+	Force    bool           // Allow ref updates that are not fast-forwards
+	Progress io.Writer      // Receives the messages of progress commands, when set
+}
+
+// FastImportResult describes what FastImport did.
+type FastImportResult struct {
+	Blobs   int
+	Commits int
+	Tags    int
+	Marks   map[int]string // Every mark known at the end, for continuing the import later
+	Updates []RefUpdate    // How each branch and tag the stream set was updated
+}
+
+// FastImport reads a stream in the format git fast-export writes and records
+// its history in the repository. Commits keep their message, author and
+// committer time; merges keep their first parent only. Branches and tags are
+// updated once the whole stream has been read, and only moved forward unless
+// Force is set. The branch HEAD is on is only updated if it has no commits
+// yet, in which case the imported files are checked out.
+func (r *Repository) FastImport(ctx context.Context, in io.Reader, opts *FastImportOptions) (*FastImportResult, error) {
+	if opts == nil {
+		opts = &FastImportOptions{}
+	}
+	res, err := fast.Import(ctx, r.l, in, fast.ImportOptions{Marks: opts.Marks, Force: opts.Force, Progress: opts.Progress})
+	if err != nil {
+		return nil, err
+	}
+	return &FastImportResult{
+		Blobs:   res.Blobs,
+		Commits: res.Commits,
+		Tags:    res.Tags,
+		Marks:   res.Marks,
+		Updates: refUpdates(res.Updates),
+	}, nil
+}
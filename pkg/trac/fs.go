@@ -0,0 +1,208 @@
+package trac
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// FS returns a read-only file system holding the files of a revision. It
+// implements fs.ReadDirFS, fs.ReadFileFS and fs.StatFS, so it can be used with
+// fs.WalkDir, template.ParseFS or http.FS. Directories are implied by the paths
+// of the files in them, and every entry has the commit's time as its
+// modification time. Executable files have mode 0555 rather than 0444, and
+// symbolic links have fs.ModeSymlink set and their target as their content.
+//
+// File contents are read from the object database when a file is opened. The
+// file system does not change if refs move after it is created.
+func (r *Repository) FS(rev string) (fs.FS, error) {
+	hash, err := r.Resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	fsys := &commitFS{r: r, modTime: c.Time, files: c.Files, modes: c.Modes, dirs: map[string][]string{".": nil}}
+	for name := range c.Files {
+		// Register the file in its directory, and each directory in its parent
+		// until reaching one that is already known.
+		for {
+			dir := path.Dir(name)
+			_, known := fsys.dirs[dir]
+			fsys.dirs[dir] = append(fsys.dirs[dir], path.Base(name))
+			if known {
+				break
+			}
+			name = dir
+		}
+	}
+	for _, entries := range fsys.dirs {
+		slices.Sort(entries)
+	}
+	return fsys, nil
+}
+
+type commitFS struct {
+	r       *Repository
+	modTime time.Time
+	files   map[string]string   // Content hash by path
+	modes   map[string]string   // Mode by path, for files that are not regular files
+	dirs    map[string][]string // Sorted names of the entries of each directory
+}
+
+var (
+	_ fs.ReadDirFS  = (*commitFS)(nil)
+	_ fs.ReadFileFS = (*commitFS)(nil)
+	_ fs.StatFS     = (*commitFS)(nil)
+)
+
+func (fsys *commitFS) Open(name string) (fs.File, error) {
+	info, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dir{info: info, entries: entries}, nil
+	}
+	data, err := fsys.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+func (fsys *commitFS) ReadFile(name string) ([]byte, error) {
+	hash, ok := fsys.files[name]
+	if !ok {
+		if _, err := fsys.stat("read", name); err != nil {
+			return nil, err
+		}
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	data, err := object.Read(fsys.r.l, hash)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+func (fsys *commitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	names, ok := fsys.dirs[name]
+	if !ok {
+		if _, err := fsys.stat("readdir", name); err != nil {
+			return nil, err
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, base := range names {
+		info, err := fsys.stat("readdir", path.Join(name, base))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+func (fsys *commitFS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.stat("stat", name)
+}
+
+func (fsys *commitFS) stat(op, name string) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := fsys.dirs[name]; ok {
+		return &fileInfo{name: path.Base(name), mode: fs.ModeDir | 0555, modTime: fsys.modTime}, nil
+	}
+	hash, ok := fsys.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	size, err := object.Size(fsys.r.l, hash)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	mode := fs.FileMode(0444)
+	switch filemode.Get(fsys.modes, name) {
+	case filemode.Executable:
+		mode = 0555
+	case filemode.Symlink:
+		mode = fs.ModeSymlink | 0777
+	}
+	return &fileInfo{name: path.Base(name), size: size, mode: mode, modTime: fsys.modTime}, nil
+}
+
+// Errors for operations on the wrong kind of entry, matching the messages of the os package.
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+// file is an open regular file. Embedding bytes.Reader makes it an io.Seeker
+// and io.ReaderAt, which http.FileServer relies on.
+type file struct {
+	info *fileInfo
+	*bytes.Reader
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+// dir is an open directory.
+type dir struct {
+	info    *fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errIsDir}
+}
+
+// ReadDir follows the semantics of fs.ReadDirFile: with n > 0 it returns at
+// most n entries and io.EOF at the end, otherwise all remaining entries.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,85 @@
+package trac_test
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	repo := newRepository(t)
+	first := commit(t, repo, "templates/hello.tmpl", "Hello, {{.}}!", "first")
+	commit(t, repo, "templates/hello.tmpl", "Goodbye, {{.}}!", "second")
+	commit(t, repo, "static/css/site.css", "body {}\n", "third")
+	commit(t, repo, "README", "readme\n", "fourth")
+
+	fsys, err := repo.FS("HEAD")
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(fsys, "README", "static/css/site.css", "templates/hello.tmpl"))
+
+	t.Run("walk", func(t *testing.T) {
+		var paths []string
+		err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			paths = append(paths, path)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{".", "README", "static", "static/css", "static/css/site.css", "templates", "templates/hello.tmpl"}, paths)
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		info, err := fs.Stat(fsys, "static/css/site.css")
+		require.NoError(t, err)
+		require.Equal(t, int64(8), info.Size())
+		require.False(t, info.IsDir())
+		info, err = fs.Stat(fsys, "static")
+		require.NoError(t, err)
+		require.True(t, info.IsDir())
+
+		_, err = fs.Stat(fsys, "missing")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = fs.Stat(fsys, "/README")
+		require.ErrorIs(t, err, fs.ErrInvalid)
+		_, err = fs.ReadFile(fsys, "static")
+		require.Error(t, err)
+		_, err = fs.ReadDir(fsys, "README")
+		require.Error(t, err)
+	})
+
+	t.Run("older revision", func(t *testing.T) {
+		old, err := repo.FS(first.Hash)
+		require.NoError(t, err)
+		tmpl, err := template.ParseFS(old, "templates/*.tmpl")
+		require.NoError(t, err)
+		var sb strings.Builder
+		require.NoError(t, tmpl.Execute(&sb, "world"))
+		require.Equal(t, "Hello, world!", sb.String())
+		_, err = fs.Stat(old, "README")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("file server", func(t *testing.T) {
+		srv := httptest.NewServer(http.FileServer(http.FS(fsys)))
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/static/css/site.css")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "body {}\n", string(body))
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		_, err := repo.FS("nope")
+		require.ErrorIs(t, err, trac.ErrUnknownRevision)
+	})
+}
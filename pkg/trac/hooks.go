@@ -0,0 +1,45 @@
+package trac
+
+import (
+	"context"
+
+	"github.com/lucasrod16/trac/internal/hooks"
+)
+
+// Names of the hooks commands run; see trac help commit, push and checkout
+// for when each runs and what it is passed.
+const (
+	PreCommitHook        = hooks.PreCommit
+	PrepareCommitMsgHook = hooks.PrepareCommitMsg
+	CommitMsgHook        = hooks.CommitMsg
+	PostCommitHook       = hooks.PostCommit
+	PrePushHook          = hooks.PrePush
+	PostCheckoutHook     = hooks.PostCheckout
+)
+
+// HookOptions controls how a hook runs: its arguments, standard input, and
+// where its output goes.
+type HookOptions = hooks.Options
+
+// HasHook reports whether the repository has the named hook: an executable
+// file of that name in .trac/hooks, or the directory set by core.hooksPath.
+func (r *Repository) HasHook(name string) (bool, error) {
+	path, err := hooks.Find(r.l, name)
+	return path != "", err
+}
+
+// RunHook runs the named hook, if the repository has one, in the root of the
+// working tree, and reports whether it did. An error wrapping ErrHookFailed is
+// returned if the hook exits with a non-zero status.
+//
+// Operations of a Repository do not run hooks themselves; callers that
+// want them, like the trac command, run them around the operations.
+func (r *Repository) RunHook(ctx context.Context, name string, opts HookOptions) (bool, error) {
+	return hooks.Run(ctx, r.l, name, opts)
+}
+
+// CommitMsgFile returns the path of the file a commit message is written to
+// for the prepare-commit-msg and commit-msg hooks, and for editing.
+func (r *Repository) CommitMsgFile() string {
+	return r.l.CommitMsg
+}
@@ -0,0 +1,460 @@
+package trac
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/patch"
+	"github.com/lucasrod16/trac/internal/utils"
+)
+
+// Patch is a commit formatted as a patch email.
+type Patch struct {
+	Commit *Commit
+	Text   []byte // The email, as a message of an mbox file
+}
+
+// FormatPatchOptions controls FormatPatches.
+type FormatPatchOptions struct {
+	Root bool   // Format every commit up to rev, rather than those after it
+	From string // Sender, as "Name <email>", of patches for commits with no recorded author
+}
+
+// FormatPatches formats commits as a series of patch emails, oldest first.
+// rev is a range A..B, for the commits after A up to B, or a single revision
+// A, for the commits after A up to HEAD, or with Root, the commits up to A.
+//
+// Each email names the commit's author as its sender, or opts.From if no
+// author was recorded, and carries the commit message and the changes in
+// unified diff format, with the hashes of the content before and after each
+// change so that Apply can fall back to a three-way merge.
+func (r *Repository) FormatPatches(ctx context.Context, rev string, opts *FormatPatchOptions) ([]Patch, error) {
+	if opts == nil {
+		opts = &FormatPatchOptions{}
+	}
+	var from *mail.Address
+	if opts.From != "" {
+		var err error
+		if from, err = mail.ParseAddress(opts.From); err != nil {
+			return nil, fmt.Errorf("invalid sender %q: %w", opts.From, err)
+		}
+	}
+	since, until, isRange := strings.Cut(rev, "..")
+	switch {
+	case opts.Root:
+		since, until = "", rev
+	case !isRange:
+		until = "HEAD"
+	}
+	if until == "" {
+		until = "HEAD"
+	}
+	excluded := make(map[string]bool)
+	if since != "" {
+		hash, err := r.Resolve(since)
+		if err != nil {
+			return nil, err
+		}
+		for c, err := range r.Log(ctx, hash) {
+			if err != nil {
+				return nil, err
+			}
+			excluded[c.Hash] = true
+		}
+	}
+	tip, err := r.Resolve(until)
+	if err != nil {
+		return nil, err
+	}
+	var commits []*Commit
+	for c, err := range r.Log(ctx, tip) {
+		if err != nil {
+			return nil, err
+		}
+		if excluded[c.Hash] {
+			break
+		}
+		commits = append([]*Commit{c}, commits...)
+	}
+
+	patches := make([]Patch, 0, len(commits))
+	for i, c := range commits {
+		m, err := r.patchMessage(ctx, c, from)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := m.Write(&buf, i+1, len(commits)); err != nil {
+			return nil, err
+		}
+		patches = append(patches, Patch{Commit: c, Text: buf.Bytes()})
+	}
+	return patches, nil
+}
+
+// patchMessage returns the patch email for a commit.
+func (r *Repository) patchMessage(ctx context.Context, c *Commit, from *mail.Address) (*patch.Message, error) {
+	m := &patch.Message{Hash: c.Hash, Author: from, Date: c.Time}
+	if c.Author != nil {
+		m.Author = &mail.Address{Name: c.Author.Name, Address: c.Author.Email}
+		m.Date = c.Author.Time
+	}
+	m.Subject, m.Body, _ = strings.Cut(c.Message, "\n")
+	m.Body = strings.TrimSpace(m.Body)
+
+	oldTree, oldModes := map[string]string{}, map[string]string{}
+	if c.Parent != "" {
+		var err error
+		if oldTree, oldModes, err = r.commitTree(c.Parent); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range compareTrees(oldTree, c.Files, oldModes, c.Modes) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var oldData, newData []byte
+		var err error
+		if change.OldHash != "" {
+			if oldData, err = object.Read(r.l, change.OldHash); err != nil {
+				return nil, err
+			}
+		}
+		if change.NewHash != "" {
+			if newData, err = object.Read(r.l, change.NewHash); err != nil {
+				return nil, err
+			}
+		}
+		f := patch.Diff(change.Path, change.OldHash, change.NewHash, oldData, newData, 3)
+		f.OldMode, f.NewMode = change.OldMode, change.NewMode
+		m.Files = append(m.Files, f)
+	}
+	return m, nil
+}
+
+// ApplyOptions controls Apply.
+type ApplyOptions struct {
+	Check    bool // Only check that the patch applies, changing nothing
+	Index    bool // Apply to the index as well as the working tree; the files must not have unstaged changes
+	Cached   bool // Apply to the index only, leaving the working tree alone
+	ThreeWay bool // Merge with the content the patch was made from when it does not apply directly
+	Fuzz     int  // Lines of context that may be ignored at each end of a hunk that does not apply otherwise
+}
+
+// PatchedFile describes how a patch changed a file.
+type PatchedFile struct {
+	Path      string
+	Kind      ChangeKind
+	Hunks     []AppliedHunk
+	Merged    bool // The change was merged with the content the patch was made from
+	Conflicts int  // Conflicts the merge left in the working tree file
+}
+
+// AppliedHunk describes where a hunk of a patch applied.
+type AppliedHunk struct {
+	Line   int // Line of the file before the change, counting from 1, at which the hunk applied
+	Offset int // Lines between where the hunk was recorded and where it applied
+	Fuzz   int // Context lines ignored at each end of the hunk for it to apply
+}
+
+// Apply applies a patch in unified diff format, such as one written by
+// FormatPatches or trac diff, to the working tree, the index, or both.
+//
+// A hunk whose context has moved is applied where the context is now found,
+// and with Fuzz, one whose context has partly changed is applied ignoring
+// some of it. With ThreeWay, a file the patch does not apply to is merged
+// instead, if the repository has the content the patch was made from;
+// conflicts are left in the working tree between conflict markers, and the
+// file's index entry is left as it was. Nothing is changed unless every file
+// in the patch applies.
+func (r *Repository) Apply(ctx context.Context, in io.Reader, opts *ApplyOptions) ([]PatchedFile, error) {
+	if opts == nil {
+		opts = &ApplyOptions{}
+	}
+	files, err := patch.Parse(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, patch.ErrNoPatches
+	}
+	return r.applyFiles(ctx, files, opts)
+}
+
+// patchedContent is the content a patched file ends up with.
+type patchedContent struct {
+	PatchedFile
+	data []byte // New content, or nil if the file is deleted
+	mode string // New mode, or empty if the file is deleted
+}
+
+func (r *Repository) applyFiles(ctx context.Context, files []*patch.File, opts *ApplyOptions) ([]PatchedFile, error) {
+	idx, err := r.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	var results []patchedContent
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := r.applyFile(f, opts, idx)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+
+	patched := make([]PatchedFile, 0, len(results))
+	for _, result := range results {
+		patched = append(patched, result.PatchedFile)
+	}
+	if opts.Check {
+		return patched, nil
+	}
+	for _, result := range results {
+		if !opts.Cached {
+			if err := r.writeWorkTreeFile(result.Path, result.data, result.mode); err != nil {
+				return nil, err
+			}
+		}
+		if (opts.Index || opts.Cached) && result.Conflicts == 0 {
+			key, _, err := idx.Find(result.Path, r.l)
+			if err != nil {
+				return nil, err
+			}
+			delete(idx.Staged, key)
+			delete(idx.Modes, key)
+			if result.Kind != Deleted {
+				hash, err := object.Write(r.l, result.data)
+				if err != nil {
+					return nil, err
+				}
+				idx.Staged[result.Path] = hash
+				if idx.Modes == nil {
+					idx.Modes = make(map[string]string)
+				}
+				filemode.Set(idx.Modes, result.Path, result.mode)
+			}
+		}
+	}
+	if opts.Index || opts.Cached {
+		if err := idx.Write(r.l); err != nil {
+			return nil, fmt.Errorf("failed to write updated index: %w", err)
+		}
+	}
+	return patched, nil
+}
+
+// applyFile works out what applying f would turn its file into, reading the
+// file from the working tree, or the index with Cached. The file keeps its
+// mode unless the patch changes it.
+func (r *Repository) applyFile(f *patch.File, opts *ApplyOptions, idx *index.Index) (*patchedContent, error) {
+	path := f.Path()
+	result := &patchedContent{PatchedFile: PatchedFile{Path: path, Kind: Modified}}
+	switch {
+	case f.OldPath == "":
+		result.Kind = Added
+	case f.NewPath == "":
+		result.Kind = Deleted
+	}
+
+	stagedHash, stagedMode := "", ""
+	if opts.Index || opts.Cached {
+		tree, err := r.tree(idx.Staged)
+		if err != nil {
+			return nil, err
+		}
+		modes, err := r.tree(filemode.Select(idx.Modes, idx.Staged))
+		if err != nil {
+			return nil, err
+		}
+		stagedHash, stagedMode = tree[path], filemode.Get(modes, path)
+	}
+	var current []byte
+	var err error
+	exists, mode := true, stagedMode
+	if opts.Cached {
+		exists = stagedHash != ""
+		if exists {
+			current, err = object.Read(r.l, stagedHash)
+		}
+	} else {
+		current, mode, err = filemode.Read(r.workTreePath(path))
+		if errors.Is(err, fs.ErrNotExist) {
+			exists, err = false, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case result.Kind == Deleted:
+	case f.NewMode != "":
+		result.mode = f.NewMode
+	case exists:
+		result.mode = mode
+	default:
+		result.mode = ModeRegular
+	}
+	if opts.Index {
+		hash := ""
+		if exists {
+			hash = utils.HashBytes(current)
+		}
+		if hash != stagedHash {
+			return nil, fmt.Errorf("%w: %s has changes that are not staged", ErrPatchDoesNotApply, path)
+		}
+	}
+	switch {
+	case result.Kind == Added && exists:
+		return nil, fmt.Errorf("%w: %s already exists", ErrPatchDoesNotApply, path)
+	case result.Kind != Added && !exists:
+		return nil, fmt.Errorf("%w: %s does not exist", ErrPatchDoesNotApply, path)
+	}
+
+	if f.Binary {
+		// A binary change can only be applied if the repository has the new
+		// content, as it does when the patch was made here.
+		if exists && utils.HashBytes(current) != f.OldHash {
+			return nil, fmt.Errorf("%w: %s does not match the binary patch", ErrPatchDoesNotApply, path)
+		}
+		if result.Kind != Deleted {
+			if f.NewHash == "" || !object.Has(r.l, f.NewHash) {
+				return nil, fmt.Errorf("%w: %s: binary patch without the new content", ErrPatchDoesNotApply, path)
+			}
+			if result.data, err = object.Read(r.l, f.NewHash); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	ours := patch.Lines(current)
+	lines, hunks, err := f.Apply(ours, opts.Fuzz)
+	if err != nil {
+		if !opts.ThreeWay || f.OldHash == "" || !object.Has(r.l, f.OldHash) {
+			return nil, err
+		}
+		base, readErr := object.Read(r.l, f.OldHash)
+		if readErr != nil {
+			return nil, readErr
+		}
+		baseLines := patch.Lines(base)
+		theirs, _, theirsErr := f.Apply(baseLines, 0)
+		if theirsErr != nil {
+			return nil, err
+		}
+		lines, result.Conflicts = patch.Merge(baseLines, ours, theirs)
+		result.Merged = true
+		if result.Conflicts > 0 && opts.Cached {
+			return nil, fmt.Errorf("%w: %s", ErrPatchConflict, path)
+		}
+	}
+	for _, h := range hunks {
+		result.Hunks = append(result.Hunks, AppliedHunk{Line: h.Line, Offset: h.Offset, Fuzz: h.Fuzz})
+	}
+	result.data = patch.Join(lines)
+	if result.Kind == Deleted && len(result.data) > 0 {
+		return nil, fmt.Errorf("%w: %s would still have content after being deleted", ErrPatchDoesNotApply, path)
+	}
+	return result, nil
+}
+
+// writeWorkTreeFile writes a file with the given mode to the working tree, or
+// removes it if mode is empty. What is there is replaced rather than written
+// through, and it refuses to go through a symbolic link to a directory.
+func (r *Repository) writeWorkTreeFile(path string, data []byte, mode string) error {
+	full := r.workTreePath(path)
+	if err := r.l.CheckParents(full); err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if mode == "" {
+		return nil
+	}
+	if err := r.l.MkdirParents(full); err != nil {
+		return err
+	}
+	if mode == ModeSymlink {
+		return os.Symlink(string(data), full)
+	}
+	perm := fs.FileMode(0644)
+	if mode == ModeExecutable {
+		perm = 0755
+	}
+	return os.WriteFile(full, data, perm)
+}
+
+// AmOptions controls Am.
+type AmOptions struct {
+	ThreeWay bool // Merge with the content a patch was made from when it does not apply directly
+	Fuzz     int  // Lines of context that may be ignored at each end of a hunk that does not apply otherwise
+}
+
+// Am applies the patch emails in an mbox file, such as one written from the
+// patches of FormatPatches, to the index and working tree and commits each
+// one, with the message of its email and its sender and date as the author.
+//
+// The index must not hold changes that have not been committed. Am stops at
+// the first patch that does not apply, or that leaves conflicts, and returns
+// the commits made before it along with the error.
+func (r *Repository) Am(ctx context.Context, mbox io.Reader, opts *AmOptions) ([]*Commit, error) {
+	if opts == nil {
+		opts = &AmOptions{}
+	}
+	messages, err := patch.ReadMailbox(mbox)
+	if err != nil {
+		return nil, err
+	}
+	head, headModes, err := r.headTree()
+	if err != nil {
+		return nil, err
+	}
+	staged, stagedModes, err := r.indexTree()
+	if err != nil {
+		return nil, err
+	}
+	if len(compareTrees(head, staged, headModes, stagedModes)) > 0 {
+		return nil, ErrDirtyIndex
+	}
+
+	applyOpts := &ApplyOptions{Index: true, ThreeWay: opts.ThreeWay, Fuzz: opts.Fuzz}
+	var commits []*Commit
+	for i, m := range messages {
+		failed := func(err error) ([]*Commit, error) {
+			return commits, fmt.Errorf("patch %d/%d %q: %w", i+1, len(messages), m.Subject, err)
+		}
+		patched, err := r.applyFiles(ctx, m.Files, applyOpts)
+		if err != nil {
+			return failed(err)
+		}
+		for _, p := range patched {
+			if p.Conflicts > 0 {
+				return failed(fmt.Errorf("%w in %s", ErrPatchConflict, p.Path))
+			}
+		}
+		commitOpts := CommitOptions{}
+		if m.Author != nil {
+			commitOpts.Author = &Signature{Name: m.Author.Name, Email: m.Author.Address, Time: m.Date}
+		}
+		c, err := r.Commit(ctx, m.Message(), commitOpts)
+		if err != nil {
+			return failed(err)
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
@@ -0,0 +1,123 @@
+package trac
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lucasrod16/trac/internal/checkout"
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Ref is a branch or tag.
+type Ref struct {
+	Name string // Full name, e.g. refs/heads/main or refs/tags/v1.0
+	Hash string // Commit the ref points at
+}
+
+// Short returns the name of the ref without its refs/heads/ or refs/tags/ prefix.
+func (r Ref) Short() string {
+	return refs.Short(r.Name)
+}
+
+// Branches returns the branches in the repository, sorted by name.
+func (r *Repository) Branches() ([]Ref, error) {
+	return r.listRefs(refs.HeadsPrefix)
+}
+
+// Tags returns the tags in the repository, sorted by name.
+func (r *Repository) Tags() ([]Ref, error) {
+	return r.listRefs(refs.TagsPrefix)
+}
+
+func (r *Repository) listRefs(prefix string) ([]Ref, error) {
+	list, err := refs.List(r.l, prefix)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Ref, len(list))
+	for i, ref := range list {
+		hash, err := commit.Peel(ref.Hash, r.l)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = Ref{Name: ref.Name, Hash: hash}
+	}
+	return result, nil
+}
+
+// CreateBranch creates a branch pointing at rev. It fails with ErrRefExists if the branch exists.
+func (r *Repository) CreateBranch(name, rev string) error {
+	return r.createRef(refs.Branch(name), name, rev, false)
+}
+
+// SetBranch points a branch at rev, creating it if needed. The branch HEAD is
+// on cannot be moved this way, since that would leave the index and working tree out of step with it.
+func (r *Repository) SetBranch(name, rev string) error {
+	if err := r.checkNotCurrent(name); err != nil {
+		return err
+	}
+	return r.createRef(refs.Branch(name), name, rev, true)
+}
+
+// DeleteBranch deletes a branch. The branch HEAD is on cannot be deleted.
+func (r *Repository) DeleteBranch(name string) error {
+	if err := r.checkNotCurrent(name); err != nil {
+		return err
+	}
+	return refs.Delete(r.l, refs.Branch(name))
+}
+
+// CreateTag creates a tag pointing at rev. It fails with ErrRefExists if the tag exists.
+func (r *Repository) CreateTag(name, rev string) error {
+	return r.createRef(refs.Tag(name), name, rev, false)
+}
+
+// DeleteTag deletes a tag.
+func (r *Repository) DeleteTag(name string) error {
+	return refs.Delete(r.l, refs.Tag(name))
+}
+
+func (r *Repository) createRef(fullName, name, rev string, force bool) error {
+	if err := refs.CheckName(name); err != nil {
+		return err
+	}
+	if !force && refs.Exists(r.l, fullName) {
+		return fmt.Errorf("%w: %s", ErrRefExists, name)
+	}
+	hash, err := r.Resolve(rev)
+	if err != nil {
+		return err
+	}
+	return refs.Write(r.l, fullName, hash)
+}
+
+func (r *Repository) checkNotCurrent(name string) error {
+	branch, _, err := r.Head()
+	if err != nil {
+		return err
+	}
+	if branch == refs.Branch(name) {
+		return fmt.Errorf("%w: %s", ErrCurrentBranch, name)
+	}
+	return nil
+}
+
+// Checkout updates the working tree and index to match rev. If rev names a
+// branch, HEAD is put on the branch; otherwise HEAD is detached at the commit.
+//
+// Checkout fails with ErrLocalChanges or ErrUntrackedChanges rather than
+// discard changes that have not been committed.
+func (r *Repository) Checkout(ctx context.Context, rev string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if refs.CheckName(rev) == nil && refs.Exists(r.l, refs.Branch(rev)) {
+		return checkout.Branch(r.l, refs.Branch(rev))
+	}
+	hash, err := r.Resolve(rev)
+	if err != nil {
+		return err
+	}
+	return checkout.Detach(r.l, hash)
+}
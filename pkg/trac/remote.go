@@ -0,0 +1,243 @@
+package trac
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/checkout"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/internal/remote"
+)
+
+// DefaultRemote is the name given to the remote a repository is cloned from.
+const DefaultRemote = "origin"
+
+// Remote is a repository that history is fetched from and pushed to.
+type Remote struct {
+	Name  string
+	URL   string
+	Fetch []string // Refspecs mapping the remote's refs to local ones, e.g. +refs/heads/*:refs/remotes/origin/*
+}
+
+// UpdateStatus is the outcome of updating a ref in a fetch or push.
+type UpdateStatus = remote.Status
+
+const (
+	UpToDate       = remote.UpToDate
+	Created        = remote.Created
+	FastForward    = remote.FastForward
+	Forced         = remote.Forced
+	NonFastForward = remote.NonFastForward
+	CurrentBranch  = remote.CurrentBranch
+)
+
+// HTTPOptions configures connections to remotes with http:// and https:// URLs:
+// the client to use, and a hook supplying basic auth credentials for servers
+// that ask for them when the URL does not hold any.
+type HTTPOptions = remote.HTTPOptions
+
+// RefUpdate describes how a ref was updated by a fetch or push.
+type RefUpdate struct {
+	Src    string // Full name of the ref on the sending side
+	Dst    string // Full name of the ref on the receiving side
+	Old    string // Commit the receiving ref pointed at, empty if it did not exist
+	New    string // Commit it was to be moved to
+	Status UpdateStatus
+}
+
+// Remotes returns the configured remotes, sorted by name.
+func (r *Repository) Remotes() ([]Remote, error) {
+	list, err := remote.List(r.l)
+	if err != nil {
+		return nil, err
+	}
+	remotes := make([]Remote, len(list))
+	for i, rm := range list {
+		remotes[i] = Remote{Name: rm.Name, URL: rm.URL}
+		for _, spec := range rm.Fetch {
+			remotes[i].Fetch = append(remotes[i].Fetch, spec.String())
+		}
+	}
+	return remotes, nil
+}
+
+// AddRemote configures a remote that fetches every branch into
+// refs/remotes/<name>/. The URL is the path of another repository, absolute or
+// relative to the root of this one, or the http:// or https:// URL of a
+// repository served with Handler.
+func (r *Repository) AddRemote(name, url string) error {
+	_, err := remote.Add(r.l, name, url)
+	return err
+}
+
+// RemoveRemote deletes a remote along with its remote-tracking branches.
+func (r *Repository) RemoveRemote(name string) error {
+	return remote.Remove(r.l, name)
+}
+
+// FetchOptions controls a fetch.
+type FetchOptions struct {
+	Refspecs []string // Full refspecs to fetch instead of the remote's configured ones
+	Force    bool     // Allow updates that are not fast-forwards
+	Tags     bool     // Also fetch every tag
+	HTTP     HTTPOptions
+}
+
+// Fetch copies the history of a remote's refs into the repository and updates
+// the local refs the fetch refspecs map them to. Updates that are not
+// fast-forwards are rejected unless forced, either by Force or by a refspec
+// starting with "+"; rejected updates are reported with their status rather
+// than as an error.
+func (r *Repository) Fetch(ctx context.Context, name string, opts *FetchOptions) ([]RefUpdate, error) {
+	if opts == nil {
+		opts = &FetchOptions{}
+	}
+	rm, err := remote.Get(r.l, name)
+	if err != nil {
+		return nil, err
+	}
+	var specs []remote.Refspec
+	for _, s := range opts.Refspecs {
+		spec, err := remote.ParseRefspec(s)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	updates, err := remote.Fetch(ctx, r.l, rm, remote.FetchOptions{Refspecs: specs, Force: opts.Force, Tags: opts.Tags, HTTP: opts.HTTP})
+	return refUpdates(updates), err
+}
+
+// PushOptions controls a push.
+type PushOptions struct {
+	Force bool // Allow updates that are not fast-forwards
+	HTTP  HTTPOptions
+	// PrePush, if set, is called before anything is sent with the updates
+	// that would change a remote ref. Their Status is not yet known. An error
+	// stops the push and is returned by Push.
+	PrePush func(updates []RefUpdate) error
+}
+
+// Push copies the history of local refs to a remote and updates the remote refs
+// the refspecs map them to. Refspecs may name branches and tags by their short
+// names, as in "main" or "main:release"; with none, the current branch is
+// pushed to the branch of the same name. The branch checked out in the remote
+// repository is never updated.
+func (r *Repository) Push(ctx context.Context, name string, refspecs []string, opts *PushOptions) ([]RefUpdate, error) {
+	if opts == nil {
+		opts = &PushOptions{}
+	}
+	rm, err := remote.Get(r.l, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(refspecs) == 0 {
+		branch, _, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+		if branch == "" {
+			return nil, fmt.Errorf("%w: HEAD is detached, name the refs to push", ErrRefNotFound)
+		}
+		refspecs = []string{branch}
+	}
+	var specs []remote.Refspec
+	for _, s := range refspecs {
+		spec, err := remote.ExpandRefspec(r.l, s)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	pushOpts := remote.PushOptions{Force: opts.Force, HTTP: opts.HTTP}
+	if opts.PrePush != nil {
+		pushOpts.PrePush = func(updates []remote.Update) error { return opts.PrePush(refUpdates(updates)) }
+	}
+	updates, err := remote.Push(ctx, r.l, rm, specs, pushOpts)
+	return refUpdates(updates), err
+}
+
+func refUpdates(updates []remote.Update) []RefUpdate {
+	result := make([]RefUpdate, len(updates))
+	for i, u := range updates {
+		result[i] = RefUpdate{Src: u.Src, Dst: u.Dst, Old: u.Old, New: u.New, Status: u.Status}
+	}
+	return result
+}
+
+// CloneOptions controls how a repository is cloned.
+type CloneOptions struct {
+	Remote      string // Name of the remote to create; DefaultRemote when empty
+	Branch      string // Branch to check out instead of the one the source's HEAD is on
+	ObjectStore string // Object store backend of the new repository, as in InitOptions
+	HTTP        HTTPOptions
+}
+
+// isHTTP reports whether a URL names a repository served over HTTP.
+func isHTTP(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// Clone creates a repository at path holding the history of the repository at
+// url, a local path or the http:// or https:// URL of a repository served
+// with Handler. The source is configured as a remote, its branches are
+// fetched as remote-tracking branches and its tags as tags, and the branch its
+// HEAD is on is created locally and checked out.
+func Clone(ctx context.Context, url, path string, opts *CloneOptions) (*Repository, error) {
+	if opts == nil {
+		opts = &CloneOptions{}
+	}
+	name := opts.Remote
+	if name == "" {
+		name = DefaultRemote
+	}
+	if !isHTTP(url) {
+		abs, err := filepath.Abs(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		url = abs
+	}
+	srcBranch, _, err := remote.LsRemote(ctx, "", url, opts.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Init(path, &InitOptions{ObjectStore: opts.ObjectStore})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.AddRemote(name, url); err != nil {
+		return nil, err
+	}
+	if _, err := r.Fetch(ctx, name, &FetchOptions{Tags: true, HTTP: opts.HTTP}); err != nil {
+		return nil, err
+	}
+
+	branch := opts.Branch
+	if branch == "" {
+		branch = refs.Short(srcBranch)
+	}
+	if branch == "" {
+		// The source's HEAD is detached, so there is no branch to follow.
+		return r, nil
+	}
+	hash, err := refs.Read(r.l, refs.RemoteBranch(name, branch))
+	if err != nil {
+		if opts.Branch != "" {
+			return nil, fmt.Errorf("remote branch %s not found in %s: %w", branch, name, err)
+		}
+		// The source has no commits yet; start out on the same branch.
+		return r, refs.SetHead(r.l, refs.Branch(branch))
+	}
+	// Check out the files while HEAD is still unborn, so the new branch is not
+	// mistaken for a commit whose files were deleted.
+	if err := checkout.Commit(r.l, hash); err != nil {
+		return nil, err
+	}
+	if err := refs.Write(r.l, refs.Branch(branch), hash); err != nil {
+		return nil, err
+	}
+	return r, refs.SetHead(r.l, refs.Branch(branch))
+}
@@ -0,0 +1,165 @@
+package trac
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/diff"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/object"
+)
+
+// RenameThresholdKey is the config key for the similarity, in percent, at
+// which a deleted and an added file are taken to be the same file renamed,
+// when DiffOptions.RenameThreshold is not set. It defaults to 50.
+const RenameThresholdKey = diff.RenameThresholdKey
+
+// renameThreshold returns the similarity opts ask for, or the configured one.
+func (r *Repository) renameThreshold(opts *DiffOptions) (int, error) {
+	if opts != nil && opts.RenameThreshold > 0 {
+		return opts.RenameThreshold, nil
+	}
+	value, err := r.ConfigValue(RenameThresholdKey)
+	if err != nil || value == "" {
+		return diff.DefaultRenameThreshold, err
+	}
+	return diff.ParseThreshold(value)
+}
+
+// renameSource is a file of the old tree that an added file may have been
+// renamed or copied from.
+type renameSource struct {
+	path, hash, mode string
+	deleted          bool // Deleted by the changes, so it can be renamed
+}
+
+// findRenames replaces the added and deleted files of changes that pair up
+// as renames with Renamed changes, and, with copies, added files that are
+// like a file of oldTree with Copied changes. Files with the same content are
+// paired first, then files at least threshold percent similar, best matches
+// first. A deleted file is renamed at most once; further files like it are
+// copies of it. readNew reads the content of added files.
+func (r *Repository) findRenames(changes []Change, oldTree, oldModes map[string]string, readNew func(path, hash string) ([]byte, error), copies bool, threshold int) ([]Change, error) {
+	var added []int
+	var sources []*renameSource
+	for i, c := range changes {
+		switch c.Kind {
+		case Added:
+			added = append(added, i)
+		case Deleted:
+			sources = append(sources, &renameSource{path: c.Path, hash: c.OldHash, mode: c.OldMode, deleted: true})
+		}
+	}
+	if len(added) == 0 || len(sources) == 0 && !copies {
+		return changes, nil
+	}
+	if copies {
+		for _, path := range slices.Sorted(maps.Keys(oldTree)) {
+			if !slices.ContainsFunc(sources, func(s *renameSource) bool { return s.path == path }) {
+				sources = append(sources, &renameSource{path: path, hash: oldTree[path], mode: filemode.Get(oldModes, path)})
+			}
+		}
+	}
+
+	renamed := make(map[string]bool) // Deleted files that have been renamed
+	paired := make(map[int]Change)   // Replacements for added files, by index
+	pair := func(i int, s *renameSource, similarity int) {
+		c := changes[i]
+		c.Kind, c.OldPath, c.OldHash, c.OldMode, c.Similarity = Copied, s.path, s.hash, s.mode, similarity
+		if s.deleted && !renamed[s.path] {
+			c.Kind = Renamed
+			renamed[s.path] = true
+		}
+		paired[i] = c
+	}
+	// usable reports whether an added file may be paired with s.
+	usable := func(s *renameSource) bool {
+		return copies || s.deleted && !renamed[s.path]
+	}
+
+	for _, i := range added {
+		var match *renameSource
+		for _, s := range sources {
+			if s.hash != changes[i].NewHash || !usable(s) {
+				continue
+			}
+			if s.deleted && !renamed[s.path] {
+				match = s
+				break
+			}
+			if match == nil {
+				match = s
+			}
+		}
+		if match != nil {
+			pair(i, match, 100)
+		}
+	}
+
+	type candidate struct {
+		added      int
+		source     *renameSource
+		similarity int
+	}
+	var candidates []candidate
+	oldData := make(map[string][]byte)
+	for _, i := range added {
+		if _, ok := paired[i]; ok {
+			continue
+		}
+		newData, err := readNew(changes[i].Path, changes[i].NewHash)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sources {
+			if !usable(s) {
+				continue
+			}
+			size, err := object.Size(r.l, s.hash)
+			if err != nil {
+				return nil, err
+			}
+			// Files whose sizes differ too much cannot be similar enough.
+			if larger := max(size, int64(len(newData))); larger > 0 && min(size, int64(len(newData)))*100 < int64(threshold)*larger {
+				continue
+			}
+			data, ok := oldData[s.hash]
+			if !ok {
+				if data, err = object.Read(r.l, s.hash); err != nil {
+					return nil, err
+				}
+				oldData[s.hash] = data
+			}
+			if similarity := diff.Similarity(data, newData); similarity >= threshold {
+				candidates = append(candidates, candidate{added: i, source: s, similarity: similarity})
+			}
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(
+			cmp.Compare(b.similarity, a.similarity),
+			strings.Compare(changes[a.added].Path, changes[b.added].Path),
+		)
+	})
+	for _, c := range candidates {
+		if _, ok := paired[c.added]; !ok && usable(c.source) {
+			pair(c.added, c.source, c.similarity)
+		}
+	}
+
+	result := make([]Change, 0, len(changes))
+	for i, c := range changes {
+		switch {
+		case c.Kind == Deleted && renamed[c.Path]:
+			continue
+		case c.Kind == Added:
+			if p, ok := paired[i]; ok {
+				c = p
+			}
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
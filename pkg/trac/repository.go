@@ -0,0 +1,208 @@
+package trac
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/config"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/index"
+	"github.com/lucasrod16/trac/internal/layout"
+	"github.com/lucasrod16/trac/internal/object"
+	"github.com/lucasrod16/trac/internal/refs"
+)
+
+// Repository is a trac repository on disk. It is safe to use from multiple
+// goroutines for reading, but operations that modify the repository must not run concurrently.
+type Repository struct {
+	l *layout.Layout
+}
+
+// InitOptions controls how a repository is created.
+type InitOptions struct {
+	// ObjectStore selects the object store backend: "loose" (the default) or
+	// "file", recorded as the core.objectStore option of trac config, or
+	// "memory", which keeps objects only for as long as the process using
+	// the returned Repository runs and is not recorded.
+	ObjectStore string
+}
+
+// Init creates an empty repository at path. If a repository already exists
+// there, it is returned along with an error wrapping ErrRepositoryExists.
+func Init(path string, opts *InitOptions) (*Repository, error) {
+	if opts == nil {
+		opts = &InitOptions{}
+	}
+	backend := opts.ObjectStore
+	if backend == "" {
+		backend = object.BackendLoose
+	}
+	if backend != object.BackendMemory && !object.ValidBackend(backend) {
+		return nil, fmt.Errorf("%w: %q", object.ErrUnknownBackend, backend)
+	}
+	l, err := layout.New(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Repository{l: l}
+	if l.Exists() {
+		return r, fmt.Errorf("%w: %s", ErrRepositoryExists, l.Config)
+	}
+	if err := l.Init(); err != nil {
+		return nil, err
+	}
+	switch backend {
+	case object.BackendLoose:
+	case object.BackendMemory:
+		object.UseStore(l, object.NewMemoryStore())
+	default:
+		cfg := config.New()
+		if err := cfg.Set(object.StoreKey, backend); err != nil {
+			return nil, err
+		}
+		if err := cfg.Save(l); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Open opens the repository whose root is path.
+func Open(path string) (*Repository, error) {
+	l, err := layout.New(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.ValidateIsRepo(); err != nil {
+		return nil, err
+	}
+	return &Repository{l: l}, nil
+}
+
+// Root returns the absolute path of the root of the repository's working tree.
+func (r *Repository) Root() string {
+	return r.l.Root
+}
+
+// Dir returns the absolute path of the .trac directory.
+func (r *Repository) Dir() string {
+	return r.l.Config
+}
+
+// ConfigValue returns the value of a repository option, such as
+// commit.template, or "" if it is not set.
+func (r *Repository) ConfigValue(key string) (string, error) {
+	cfg, err := config.Load(r.l)
+	if err != nil {
+		return "", err
+	}
+	return cfg.String(key, ""), nil
+}
+
+// SetConfigValue sets a repository option, such as signing.key.
+func (r *Repository) SetConfigValue(key, value string) error {
+	cfg, err := config.Load(r.l)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(key, value); err != nil {
+		return err
+	}
+	return cfg.Save(r.l)
+}
+
+// Head returns the branch HEAD is on, as a full ref name such as refs/heads/main,
+// and the commit HEAD points at. branch is empty when HEAD is detached, and hash
+// is empty when the current branch has no commits yet.
+func (r *Repository) Head() (branch, hash string, err error) {
+	return refs.ReadHead(r.l)
+}
+
+// Resolve returns the commit hash a revision refers to. Revisions are HEAD,
+// branch and tag names, full or abbreviated commit hashes, and any of those
+// followed by ^ or ~<n> to select an ancestor.
+func (r *Repository) Resolve(rev string) (string, error) {
+	return commit.Resolve(rev, r.l)
+}
+
+// relPath returns path relative to the repository root, slash-separated.
+func (r *Repository) relPath(path string) (string, error) {
+	if err := r.l.ValidatePathInRepo(path); err != nil {
+		return "", err
+	}
+	rel, err := r.l.RelPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// tree returns files keyed by their slash-separated path relative to the root.
+// Internally, files may be recorded under absolute or relative paths.
+func (r *Repository) tree(files map[string]string) (map[string]string, error) {
+	tree := make(map[string]string, len(files))
+	for path, hash := range files {
+		rel, err := r.relPath(path)
+		if err != nil {
+			return nil, err
+		}
+		tree[rel] = hash
+	}
+	return tree, nil
+}
+
+// loadIndex reads the index, returning an empty one if nothing has been staged yet.
+func (r *Repository) loadIndex() (*index.Index, error) {
+	idx := index.New()
+	if err := idx.Load(r.l); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// headTree returns the files at HEAD and the modes of those that are not
+// regular files, or an empty tree if there are no commits yet.
+func (r *Repository) headTree() (files, modes map[string]string, err error) {
+	hash, err := commit.GetParentHash(r.l)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hash == "" {
+		return map[string]string{}, map[string]string{}, nil
+	}
+	return r.commitTree(hash)
+}
+
+func (r *Repository) commitTree(hash string) (files, modes map[string]string, err error) {
+	c, err := commit.Load(hash, r.l)
+	if err != nil {
+		return nil, nil, err
+	}
+	if files, err = r.tree(c.Changes); err != nil {
+		return nil, nil, err
+	}
+	if modes, err = r.tree(c.Modes); err != nil {
+		return nil, nil, err
+	}
+	return files, modes, nil
+}
+
+// indexTree returns the staged files and the modes of those that are not
+// regular files.
+func (r *Repository) indexTree() (files, modes map[string]string, err error) {
+	idx, err := r.loadIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	if files, err = r.tree(maps.Clone(idx.Staged)); err != nil {
+		return nil, nil, err
+	}
+	if modes, err = r.tree(filemode.Select(idx.Modes, idx.Staged)); err != nil {
+		return nil, nil, err
+	}
+	return files, modes, nil
+}
@@ -0,0 +1,31 @@
+package trac
+
+import (
+	"net/http"
+
+	"github.com/lucasrod16/trac/internal/remote"
+)
+
+// ServeOptions controls how a repository is served over HTTP.
+type ServeOptions struct {
+	ReadOnly bool // Refuse every push
+
+	// Auth decides whether a request may go ahead, given the basic auth
+	// credentials it carries, ok being false when it has none, and whether it
+	// pushes. When nil, anyone may fetch and push.
+	Auth func(username, password string, ok, push bool) bool
+}
+
+// Handler returns an http.Handler serving the repository, for other
+// repositories to clone, fetch from and push to with its URL. The handler
+// serves the repository from the root of its URL space; use
+// http.StripPrefix to mount it elsewhere.
+//
+// As with local remotes, pushes never move the branch checked out in the
+// repository.
+func (r *Repository) Handler(opts *ServeOptions) http.Handler {
+	if opts == nil {
+		opts = &ServeOptions{}
+	}
+	return &remote.Server{Layout: r.l, ReadOnly: opts.ReadOnly, Auth: opts.Auth}
+}
@@ -0,0 +1,199 @@
+package trac_test
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler records how many bytes of packs the wrapped handler sends.
+type countingHandler struct {
+	h    http.Handler
+	sent atomic.Int64
+}
+
+func (c *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/upload-pack") {
+		w = &countingWriter{ResponseWriter: w, n: &c.sent}
+	}
+	c.h.ServeHTTP(w, r)
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
+
+func TestHTTP(t *testing.T) {
+	ctx := context.Background()
+	server := newRepository(t)
+	data := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+	commit(t, server, "large.bin", string(data), "Add large file")
+	second := commit(t, server, "docs/README", "readme", "Add README")
+	require.NoError(t, server.CreateTag("v1", "HEAD"))
+
+	handler := &countingHandler{h: server.Handler(nil)}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "clone")
+	client, err := trac.Clone(ctx, srv.URL, path, nil)
+	require.NoError(t, err)
+	branch, head, err := client.Head()
+	require.NoError(t, err)
+	require.Equal(t, "refs/heads/main", branch)
+	require.Equal(t, second.Hash, head)
+	got, err := os.ReadFile(filepath.Join(path, "large.bin"))
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	tag, err := client.Resolve("v1")
+	require.NoError(t, err)
+	require.Equal(t, second.Hash, tag)
+	require.Greater(t, handler.sent.Load(), int64(len(data)))
+
+	t.Run("fetch sends only missing objects", func(t *testing.T) {
+		third := commit(t, server, "docs/README", "updated readme", "Update README")
+		handler.sent.Store(0)
+		updates, err := client.Fetch(ctx, trac.DefaultRemote, nil)
+		require.NoError(t, err)
+		require.Equal(t, []trac.RefUpdate{{
+			Src:    "refs/heads/main",
+			Dst:    "refs/remotes/origin/main",
+			Old:    second.Hash,
+			New:    third.Hash,
+			Status: trac.FastForward,
+		}}, updates)
+		require.Less(t, handler.sent.Load(), int64(1024))
+
+		// nothing is sent when everything is up to date
+		handler.sent.Store(0)
+		_, err = client.Fetch(ctx, trac.DefaultRemote, nil)
+		require.NoError(t, err)
+		require.Zero(t, handler.sent.Load())
+	})
+
+	t.Run("push", func(t *testing.T) {
+		require.NoError(t, client.CreateBranch("feature", "origin/main"))
+		require.NoError(t, client.Checkout(ctx, "feature"))
+		pushed := commit(t, client, "feature.txt", "feature", "Add feature")
+
+		updates, err := client.Push(ctx, trac.DefaultRemote, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, updates, 1)
+		require.Equal(t, trac.Created, updates[0].Status)
+		hash, err := server.Resolve("feature")
+		require.NoError(t, err)
+		require.Equal(t, pushed.Hash, hash)
+		hash, err = client.Resolve("origin/feature")
+		require.NoError(t, err)
+		require.Equal(t, pushed.Hash, hash)
+		fsys, err := server.FS("feature")
+		require.NoError(t, err)
+		content, err := fsys.(interface {
+			ReadFile(string) ([]byte, error)
+		}).ReadFile("feature.txt")
+		require.NoError(t, err)
+		require.Equal(t, "feature", string(content))
+
+		// the branch checked out on the server is left alone
+		updates, err = client.Push(ctx, trac.DefaultRemote, []string{"feature:main"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, trac.CurrentBranch, updates[0].Status)
+	})
+
+	t.Run("push an annotated tag", func(t *testing.T) {
+		require.NoError(t, client.CreateAnnotatedTag("v2", "feature", &trac.TagOptions{Message: "release"}))
+		updates, err := client.Push(ctx, trac.DefaultRemote, []string{"refs/tags/v2"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, trac.Created, updates[0].Status)
+		want, err := client.Resolve("feature")
+		require.NoError(t, err)
+		hash, err := server.Resolve("v2")
+		require.NoError(t, err)
+		require.Equal(t, want, hash)
+		_, err = server.VerifyTag("v2")
+		require.ErrorIs(t, err, trac.ErrUnsigned)
+		require.NotContains(t, err.Error(), "lightweight")
+	})
+}
+
+func TestHTTPAuth(t *testing.T) {
+	ctx := context.Background()
+	server := newRepository(t)
+	commit(t, server, "test.txt", "content", "Initial commit")
+	// anyone may fetch, only alice may push
+	auth := func(username, password string, ok, push bool) bool {
+		return !push || ok && username == "alice" && password == "secret"
+	}
+	srv := httptest.NewServer(server.Handler(&trac.ServeOptions{Auth: auth}))
+	defer srv.Close()
+
+	client, err := trac.Clone(ctx, srv.URL, filepath.Join(t.TempDir(), "clone"), nil)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateBranch("topic", "HEAD"))
+
+	_, err = client.Push(ctx, trac.DefaultRemote, []string{"topic"}, nil)
+	require.ErrorIs(t, err, trac.ErrAuthRequired)
+
+	credentials := func(password string) trac.HTTPOptions {
+		return trac.HTTPOptions{Credentials: func(url string) (string, string, bool) {
+			require.Equal(t, srv.URL, url)
+			return "alice", password, true
+		}}
+	}
+	_, err = client.Push(ctx, trac.DefaultRemote, []string{"topic"}, &trac.PushOptions{HTTP: credentials("wrong")})
+	require.ErrorIs(t, err, trac.ErrPermissionDenied)
+	_, err = client.Push(ctx, trac.DefaultRemote, []string{"topic"}, &trac.PushOptions{HTTP: credentials("secret")})
+	require.NoError(t, err)
+
+	// credentials in the URL are used as they are
+	require.NoError(t, client.AddRemote("alice", strings.Replace(srv.URL, "http://", "http://alice:secret@", 1)))
+	require.NoError(t, client.CreateBranch("other", "HEAD"))
+	_, err = client.Push(ctx, "alice", []string{"other"}, nil)
+	require.NoError(t, err)
+
+	readOnly := httptest.NewServer(server.Handler(&trac.ServeOptions{ReadOnly: true}))
+	defer readOnly.Close()
+	require.NoError(t, client.AddRemote("mirror", readOnly.URL))
+	_, err = client.Fetch(ctx, "mirror", nil)
+	require.NoError(t, err)
+	_, err = client.Push(ctx, "mirror", []string{"topic:new"}, nil)
+	require.ErrorIs(t, err, trac.ErrPermissionDenied)
+}
+
+func TestHTTPRejectsBadPushes(t *testing.T) {
+	server := newRepository(t)
+	srv := httptest.NewServer(server.Handler(nil))
+	defer srv.Close()
+
+	body := `{"updates":[{"src":"refs/heads/main","dst":"refs/heads/evil","new":"` + strings.Repeat("ab", 32) + `"}]}` + "\nnot a pack"
+	resp, err := http.Post(srv.URL+"/receive-pack", "application/x-trac-pack", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	msg, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, string(msg))
+	_, err = server.Resolve("evil")
+	require.ErrorIs(t, err, trac.ErrUnknownRevision)
+
+	resp, err = http.Get(srv.URL + "/nowhere")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
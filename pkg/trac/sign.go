@@ -0,0 +1,175 @@
+package trac
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/refs"
+	"github.com/lucasrod16/trac/internal/sign"
+)
+
+// Config keys for signing: the private key commits and tags are signed with,
+// and the allowed signers file signatures are verified against. Paths are
+// absolute, relative to the root of the working tree, or start with ~/ for the
+// home directory. See package sign for the file formats.
+const (
+	SigningKeyKey     = sign.KeyPathKey
+	AllowedSignersKey = sign.AllowedSignersKey
+)
+
+// Verification describes a good signature.
+type Verification struct {
+	Principal   string // Allowed signer whose key made the signature; empty with ErrUnknownSigner
+	Fingerprint string // SHA-256 fingerprint of the key that made the signature
+}
+
+// TagOptions controls how an annotated tag is created.
+type TagOptions struct {
+	Message string     // What the tag is about
+	Tagger  *Signature // Who made the tag, if recorded
+	Sign    bool       // Sign the tag with the key set by signing.key
+}
+
+// CreateAnnotatedTag creates a tag object about rev, holding a message and
+// optionally a signature, and a tag pointing at it. It fails with ErrRefExists
+// if the tag exists.
+func (r *Repository) CreateAnnotatedTag(name, rev string, opts *TagOptions) error {
+	if opts == nil {
+		opts = &TagOptions{}
+	}
+	if err := refs.CheckName(name); err != nil {
+		return err
+	}
+	if refs.Exists(r.l, refs.Tag(name)) {
+		return fmt.Errorf("%w: %s", ErrRefExists, name)
+	}
+	hash, err := r.Resolve(rev)
+	if err != nil {
+		return err
+	}
+	t := &commit.Tag{Object: hash, Name: name, Message: opts.Message, Timestamp: time.Now()}
+	if opts.Tagger != nil {
+		t.Tagger = &commit.Signature{Name: opts.Tagger.Name, Email: opts.Tagger.Email, Time: opts.Tagger.Time}
+	}
+	if opts.Sign {
+		key, err := r.signingKey()
+		if err != nil {
+			return err
+		}
+		payload, err := t.Payload()
+		if err != nil {
+			return err
+		}
+		t.Sig = sign.Sign(key, sign.NamespaceTag, payload)
+	}
+	tagHash, err := t.Write(r.l)
+	if err != nil {
+		return err
+	}
+	return refs.Write(r.l, refs.Tag(name), tagHash)
+}
+
+// VerifyCommit checks the signature of the commit rev resolves to against the
+// allowed signers file. It fails with ErrUnsigned if the commit is not
+// signed, ErrBadSignature if the signature does not match the commit, and
+// ErrUnknownSigner, along with the verification, if the signature is good but
+// was made by a key that is not an allowed signer.
+func (r *Repository) VerifyCommit(rev string) (*Verification, error) {
+	hash, err := r.Resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	c, err := commit.Load(hash, r.l)
+	if err != nil {
+		return nil, err
+	}
+	if c.Sig == "" {
+		return nil, fmt.Errorf("%w: commit %s", ErrUnsigned, hash)
+	}
+	payload, err := c.Payload()
+	if err != nil {
+		return nil, err
+	}
+	return r.verify(c.Sig, sign.NamespaceCommit, payload)
+}
+
+// VerifyTag checks the signature of the named tag like VerifyCommit. A
+// lightweight tag has no signature.
+func (r *Repository) VerifyTag(name string) (*Verification, error) {
+	hash, err := refs.Read(r.l, refs.Tag(name))
+	if err != nil {
+		return nil, err
+	}
+	t, err := commit.LoadTag(hash, r.l)
+	if errors.Is(err, commit.ErrInvalidTag) {
+		return nil, fmt.Errorf("%w: %s is a lightweight tag", ErrUnsigned, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Sig == "" {
+		return nil, fmt.Errorf("%w: tag %s", ErrUnsigned, name)
+	}
+	payload, err := t.Payload()
+	if err != nil {
+		return nil, err
+	}
+	return r.verify(t.Sig, sign.NamespaceTag, payload)
+}
+
+func (r *Repository) verify(signature, namespace string, payload []byte) (*Verification, error) {
+	path, err := r.configPath(AllowedSignersKey)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, sign.ErrNoSigners
+	}
+	signers, err := sign.LoadSigners(path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sign.Verify(signature, namespace, payload, signers)
+	if result == nil {
+		return nil, err
+	}
+	return &Verification{Principal: result.Principal, Fingerprint: result.Fingerprint}, err
+}
+
+// signingKey loads the private key set by signing.key.
+func (r *Repository) signingKey() (ed25519.PrivateKey, error) {
+	path, err := r.configPath(SigningKeyKey)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, sign.ErrNoKey
+	}
+	return sign.LoadKey(path)
+}
+
+// configPath returns the path a config option names, resolving ~/ and paths
+// relative to the root, or "" if the option is not set.
+func (r *Repository) configPath(key string) (string, error) {
+	path, err := r.ConfigValue(key)
+	if err != nil || path == "" {
+		return "", err
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.l.Root, path)
+	}
+	return path, nil
+}
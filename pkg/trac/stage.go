@@ -0,0 +1,220 @@
+package trac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/commit"
+	"github.com/lucasrod16/trac/internal/filemode"
+	"github.com/lucasrod16/trac/internal/index"
+)
+
+// Add stages the current content of the given files. Directories are added
+// recursively, skipping .trac and .git directories. A staged file that no
+// longer exists in the working tree is removed from the index.
+func (r *Repository) Add(ctx context.Context, paths ...string) error {
+	idx, err := r.loadIndex()
+	if err != nil {
+		return err
+	}
+	files, err := r.expand(paths)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := add(idx, r, file); err != nil {
+			return &fs.PathError{Op: "add", Path: file, Err: err}
+		}
+	}
+	if err := idx.Write(r.l); err != nil {
+		return fmt.Errorf("failed to write updated index: %w", err)
+	}
+	return nil
+}
+
+// StageTracked stages the current content of every tracked file, or of the
+// tracked files matching paths, removing those deleted from the working tree
+// from the index, as Commit does with All or Paths. Each path must match a
+// file in the index or at HEAD. It returns a function that puts the index back
+// as it was, for a caller that goes on to abandon the commit.
+func (r *Repository) StageTracked(ctx context.Context, paths ...string) (restore func() error, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	idx, err := r.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if len(paths) == 0 {
+		tracked, err := r.tree(idx.Staged)
+		if err != nil {
+			return nil, err
+		}
+		files = slices.Collect(maps.Keys(tracked))
+	} else {
+		parent, err := commit.GetParentHash(r.l)
+		if err != nil {
+			return nil, err
+		}
+		head := &commit.Commit{}
+		if parent != "" {
+			if head, err = commit.Load(parent, r.l); err != nil {
+				return nil, err
+			}
+		}
+		if files, err = r.trackedPaths(idx, head, paths); err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return func() error { return nil }, nil
+	}
+	saved, err := os.ReadFile(r.l.Index)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	restore = func() error {
+		if saved == nil {
+			return os.Remove(r.l.Index)
+		}
+		return os.WriteFile(r.l.Index, saved, 0644)
+	}
+	if err := r.stageWorkTree(idx, files); err != nil {
+		return nil, err
+	}
+	if err := idx.Write(r.l); err != nil {
+		return nil, fmt.Errorf("failed to write updated index: %w", err)
+	}
+	return restore, nil
+}
+
+// add stages a file, replacing an entry for the same file staged under an absolute path.
+func add(idx *index.Index, r *Repository, file string) error {
+	key, ok, err := idx.Find(file, r.l)
+	if err != nil {
+		return err
+	}
+	if ok {
+		delete(idx.Staged, key)
+		if _, err := os.Lstat(r.l.AbsPath(file)); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	return idx.Add(file, r.l)
+}
+
+// expand replaces directories in paths with the files they contain.
+// Files found in directories are named relative to the repository root.
+func (r *Repository) expand(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Lstat(r.l.AbsPath(path))
+		if err != nil || !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(r.l.AbsPath(path), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".trac" || d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(r.l.Root, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Unstage resets the index entries of the given paths to their state at HEAD,
+// without touching the working tree. Paths that do not exist at HEAD are removed
+// from the index, and a directory matches every file beneath it. With no paths,
+// the whole index is reset.
+func (r *Repository) Unstage(ctx context.Context, paths ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	head := &commit.Commit{}
+	if hash, err := commit.GetParentHash(r.l); err != nil {
+		return err
+	} else if hash != "" {
+		if head, err = commit.Load(hash, r.l); err != nil {
+			return err
+		}
+	}
+	idx, err := r.loadIndex()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		idx.Staged = make(map[string]string, len(head.Changes))
+		for key, hash := range head.Changes {
+			idx.Staged[key] = hash
+		}
+		idx.Modes = maps.Clone(head.Modes)
+		return idx.Write(r.l)
+	}
+
+	pathspecs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		rel, err := r.relPath(path)
+		if err != nil {
+			return err
+		}
+		pathspecs = append(pathspecs, rel)
+	}
+	for key := range idx.Staged {
+		rel, err := r.relPath(key)
+		if err != nil {
+			return err
+		}
+		if matchPathspec(rel, pathspecs) {
+			delete(idx.Staged, key)
+		}
+	}
+	for key, hash := range head.Changes {
+		rel, err := r.relPath(key)
+		if err != nil {
+			return err
+		}
+		if matchPathspec(rel, pathspecs) {
+			idx.Staged[key] = hash
+			if idx.Modes == nil {
+				idx.Modes = make(map[string]string)
+			}
+			filemode.Set(idx.Modes, key, filemode.Get(head.Modes, key))
+		}
+	}
+	return idx.Write(r.l)
+}
+
+// matchPathspec reports whether a slash-separated path relative to the root is
+// one of pathspecs or lies beneath one of them. "." matches every path.
+func matchPathspec(path string, pathspecs []string) bool {
+	return slices.ContainsFunc(pathspecs, func(spec string) bool {
+		spec = strings.TrimSuffix(spec, "/")
+		return spec == "." || path == spec || strings.HasPrefix(path, spec+"/")
+	})
+}
@@ -0,0 +1,207 @@
+package trac
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lucasrod16/trac/internal/filemode"
+)
+
+// ChangeKind is how a file differs between two versions of the repository.
+// Its value is the letter used for it in short status output.
+type ChangeKind byte
+
+const (
+	Added    ChangeKind = 'A'
+	Modified ChangeKind = 'M'
+	Deleted  ChangeKind = 'D'
+	Renamed  ChangeKind = 'R'
+	Copied   ChangeKind = 'C'
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "new file"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	case Renamed:
+		return "renamed"
+	case Copied:
+		return "copied"
+	}
+	return string(k)
+}
+
+// Change is a file that differs between two versions of the repository, in
+// content or in mode. A renamed or copied file has the content and mode of
+// the file at OldPath as its old content and mode.
+type Change struct {
+	Path       string // Slash-separated path relative to the root
+	Kind       ChangeKind
+	OldHash    string // Content hash before the change, empty for added files
+	NewHash    string // Content hash after the change, empty for deleted files
+	OldMode    string // Mode before the change, such as ModeRegular, empty for added files
+	NewMode    string // Mode after the change, empty for deleted files
+	OldPath    string // Path the file was renamed or copied from, empty for other changes
+	Similarity int    // How alike the file is to the one at OldPath, in percent
+}
+
+// Modes of files, as recorded in commits and the index. The content of a
+// symbolic link is its target.
+const (
+	ModeRegular    = filemode.Regular
+	ModeExecutable = filemode.Executable
+	ModeSymlink    = filemode.Symlink
+)
+
+// FileModeKey is the config key that, when set to false, makes changes to the
+// executable bit of files in the working tree be ignored.
+const FileModeKey = filemode.Key
+
+// Status describes the state of the index and working tree.
+type Status struct {
+	Branch    string   // Full name of the branch HEAD is on, empty when detached
+	Head      string   // Commit HEAD points at, empty before the first commit
+	Staged    []Change // Differences between HEAD and the index
+	Unstaged  []Change // Differences between the index and the working tree
+	Untracked []string // Files in the working tree that are not in the index
+}
+
+// Clean reports whether there is nothing to commit and no untracked files.
+func (s *Status) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0
+}
+
+// Status compares HEAD, the index and the working tree. Changes and untracked
+// files are sorted by path. Staged files that were deleted and added with
+// similar content are reported as renamed; see RenameThresholdKey.
+func (r *Repository) Status(ctx context.Context) (*Status, error) {
+	branch, head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Branch: branch, Head: head}
+	headTree, headModes, err := r.headTree()
+	if err != nil {
+		return nil, err
+	}
+	indexTree, indexModes, err := r.indexTree()
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := r.renameThreshold(nil)
+	if err != nil {
+		return nil, err
+	}
+	st.Staged, err = r.findRenames(compareTrees(headTree, indexTree, headModes, indexModes), headTree, headModes, r.readObject, false, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := r.workTreeFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fileMode, err := filemode.Enabled(r.l)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range slices.Sorted(maps.Keys(indexTree)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		oldMode := filemode.Get(indexModes, path)
+		if !files[path] {
+			st.Unstaged = append(st.Unstaged, Change{Path: path, Kind: Deleted, OldHash: indexTree[path], OldMode: oldMode})
+			continue
+		}
+		hash, mode, err := r.statWorkTree(path, oldMode, fileMode)
+		if err != nil {
+			return nil, err
+		}
+		if hash != indexTree[path] || mode != oldMode {
+			st.Unstaged = append(st.Unstaged, Change{Path: path, Kind: Modified, OldHash: indexTree[path], NewHash: hash, OldMode: oldMode, NewMode: mode})
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		if _, ok := indexTree[path]; !ok {
+			st.Untracked = append(st.Untracked, path)
+		}
+	}
+	return st, nil
+}
+
+// statWorkTree returns the content hash and mode of a file in the working
+// tree. Unless fileMode is set, the executable bit is taken from recorded,
+// the mode the file is staged with.
+func (r *Repository) statWorkTree(path, recorded string, fileMode bool) (hash, mode string, err error) {
+	hash, mode, err = filemode.Stat(r.workTreePath(path))
+	if err != nil {
+		return "", "", err
+	}
+	if !fileMode {
+		mode = filemode.Ignore(mode, recorded)
+	}
+	return hash, mode, nil
+}
+
+// compareTrees returns the changes that turn old into new, sorted by path.
+// The modes of files that are not regular files are given by oldModes and
+// newModes; with nil modes only content is compared.
+func compareTrees(old, new, oldModes, newModes map[string]string) []Change {
+	var changes []Change
+	for path, hash := range new {
+		oldHash, ok := old[path]
+		oldMode, newMode := filemode.Get(oldModes, path), filemode.Get(newModes, path)
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: path, Kind: Added, NewHash: hash, NewMode: newMode})
+		case oldHash != hash || oldMode != newMode:
+			changes = append(changes, Change{Path: path, Kind: Modified, OldHash: oldHash, NewHash: hash, OldMode: oldMode, NewMode: newMode})
+		}
+	}
+	for path, hash := range old {
+		if _, ok := new[path]; !ok {
+			changes = append(changes, Change{Path: path, Kind: Deleted, OldHash: hash, OldMode: filemode.Get(oldModes, path)})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return changes
+}
+
+// workTreeFiles returns the files in the working tree, skipping .trac and .git directories.
+func (r *Repository) workTreeFiles(ctx context.Context) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(r.l.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".trac" || d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(r.l.Root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return files, err
+}
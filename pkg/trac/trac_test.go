@@ -0,0 +1,265 @@
+package trac_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrod16/trac/internal/sign"
+	"github.com/lucasrod16/trac/pkg/trac"
+	"github.com/stretchr/testify/require"
+)
+
+func newRepository(t *testing.T) *trac.Repository {
+	t.Helper()
+	repo, err := trac.Init(t.TempDir(), nil)
+	require.NoError(t, err)
+	return repo
+}
+
+func writeFile(t *testing.T, repo *trac.Repository, path, content string) {
+	t.Helper()
+	path = filepath.Join(repo.Root(), filepath.FromSlash(path))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func commit(t *testing.T, repo *trac.Repository, path, content, message string) *trac.Commit {
+	t.Helper()
+	ctx := context.Background()
+	writeFile(t, repo, path, content)
+	require.NoError(t, repo.Add(ctx, path))
+	c, err := repo.Commit(ctx, message, trac.CommitOptions{})
+	require.NoError(t, err)
+	return c
+}
+
+func TestInitOpen(t *testing.T) {
+	dir := t.TempDir()
+	_, err := trac.Open(dir)
+	require.ErrorIs(t, err, trac.ErrNotRepository)
+
+	repo, err := trac.Init(dir, nil)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, ".trac"), repo.Dir())
+	_, err = trac.Init(dir, nil)
+	require.ErrorIs(t, err, trac.ErrRepositoryExists)
+
+	repo, err = trac.Open(dir)
+	require.NoError(t, err)
+	branch, hash, err := repo.Head()
+	require.NoError(t, err)
+	require.Equal(t, "refs/heads/main", branch)
+	require.Empty(t, hash)
+}
+
+func TestInitMemoryStore(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := trac.Init(dir, &trac.InitOptions{ObjectStore: "memory"})
+	require.NoError(t, err)
+	c := commit(t, repo, "a.txt", "one\n", "first")
+	entries, err := os.ReadDir(filepath.Join(dir, ".trac", "objects"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	// The store is not recorded, so another process opens the loose store.
+	require.NoFileExists(t, filepath.Join(dir, ".trac", "config.json"))
+	opened, err := trac.Open(dir)
+	require.NoError(t, err)
+	read, err := opened.ReadCommit(c.Hash)
+	require.NoError(t, err)
+	require.Equal(t, c.Files, read.Files)
+}
+
+func TestCommitAndLog(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	_, err := repo.Commit(ctx, "empty", trac.CommitOptions{})
+	require.ErrorIs(t, err, trac.ErrNothingAdded)
+
+	first := commit(t, repo, "dir/a.txt", "one\n", "first\n\nbody")
+	require.Equal(t, "first", first.Summary())
+	require.Empty(t, first.Parent)
+	require.Equal(t, map[string]string{"dir/a.txt": first.Files["dir/a.txt"]}, first.Files)
+	second := commit(t, repo, "b.txt", "two\n", "second")
+	require.Equal(t, first.Hash, second.Parent)
+	require.Len(t, second.Files, 2)
+
+	_, err = repo.Commit(ctx, "again", trac.CommitOptions{})
+	require.ErrorIs(t, err, trac.ErrNothingToCommit)
+	_, err = repo.Commit(ctx, " \n", trac.CommitOptions{AllowEmpty: true})
+	require.ErrorIs(t, err, trac.ErrEmptyMessage)
+
+	var hashes []string
+	for c, err := range repo.Log(ctx, "HEAD") {
+		require.NoError(t, err)
+		hashes = append(hashes, c.Hash)
+	}
+	require.Equal(t, []string{second.Hash, first.Hash}, hashes)
+
+	for _, err := range repo.Log(ctx, "nope") {
+		require.ErrorIs(t, err, trac.ErrUnknownRevision)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for _, err := range repo.Log(cancelled, "HEAD") {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	require.ErrorIs(t, repo.Add(cancelled, "b.txt"), context.Canceled)
+}
+
+func TestStageAndStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	commit(t, repo, "tracked.txt", "one\n", "first")
+
+	writeFile(t, repo, "tracked.txt", "changed\n")
+	writeFile(t, repo, "new/file.txt", "new\n")
+	st, err := repo.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "refs/heads/main", st.Branch)
+	require.Empty(t, st.Staged)
+	require.Len(t, st.Unstaged, 1)
+	require.Equal(t, "tracked.txt", st.Unstaged[0].Path)
+	require.Equal(t, trac.Modified, st.Unstaged[0].Kind)
+	require.NotEqual(t, st.Unstaged[0].OldHash, st.Unstaged[0].NewHash)
+	require.Equal(t, []string{"new/file.txt"}, st.Untracked)
+
+	require.NoError(t, repo.Add(ctx, "new"))
+	st, err = repo.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, st.Staged, 1)
+	require.Equal(t, trac.Change{Path: "new/file.txt", Kind: trac.Added, NewHash: st.Staged[0].NewHash, NewMode: trac.ModeRegular}, st.Staged[0])
+	require.NotEmpty(t, st.Staged[0].NewHash)
+	require.Empty(t, st.Untracked)
+
+	require.NoError(t, repo.Unstage(ctx, "new"))
+	st, err = repo.Status(ctx)
+	require.NoError(t, err)
+	require.Empty(t, st.Staged)
+	require.False(t, st.Clean())
+
+	err = repo.Add(ctx, "missing.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRefsAndCheckout(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	first := commit(t, repo, "a.txt", "one\n", "first")
+	second := commit(t, repo, "a.txt", "two\n", "second")
+
+	require.NoError(t, repo.CreateBranch("feature", first.Hash[:8]))
+	require.ErrorIs(t, repo.CreateBranch("feature", "HEAD"), trac.ErrRefExists)
+	require.ErrorIs(t, repo.CreateBranch("-bad", "HEAD"), trac.ErrInvalidRefName)
+	require.NoError(t, repo.CreateTag("v1", "HEAD~1"))
+
+	branches, err := repo.Branches()
+	require.NoError(t, err)
+	require.Equal(t, []trac.Ref{{Name: "refs/heads/feature", Hash: first.Hash}, {Name: "refs/heads/main", Hash: second.Hash}}, branches)
+	tags, err := repo.Tags()
+	require.NoError(t, err)
+	require.Equal(t, "v1", tags[0].Short())
+
+	require.NoError(t, repo.Checkout(ctx, "feature"))
+	branch, hash, err := repo.Head()
+	require.NoError(t, err)
+	require.Equal(t, "refs/heads/feature", branch)
+	require.Equal(t, first.Hash, hash)
+	data, err := os.ReadFile(filepath.Join(repo.Root(), "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "one\n", string(data))
+	require.ErrorIs(t, repo.DeleteBranch("feature"), trac.ErrCurrentBranch)
+
+	require.NoError(t, repo.Checkout(ctx, "v1"))
+	branch, _, err = repo.Head()
+	require.NoError(t, err)
+	require.Empty(t, branch)
+
+	writeFile(t, repo, "a.txt", "local\n")
+	require.ErrorIs(t, repo.Checkout(ctx, "main"), trac.ErrLocalChanges)
+
+	require.NoError(t, repo.DeleteBranch("feature"))
+	require.ErrorIs(t, repo.DeleteBranch("feature"), trac.ErrRefNotFound)
+	require.NoError(t, repo.DeleteTag("v1"))
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	commit(t, repo, "a.txt", "one\ntwo\n", "first")
+	commit(t, repo, "a.txt", "one\nthree\n", "second")
+
+	diffs, err := repo.Diff(ctx, "HEAD~1", "HEAD", nil)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	require.Equal(t, trac.Modified, diffs[0].Kind)
+	require.Equal(t, []trac.Hunk{{
+		OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2,
+		Lines: []trac.Line{{Op: trac.OpContext, Text: "one"}, {Op: trac.OpDelete, Text: "two"}, {Op: trac.OpInsert, Text: "three"}},
+	}}, diffs[0].Hunks)
+
+	diffs, err = repo.Diff(ctx, "", "HEAD", nil)
+	require.NoError(t, err)
+	require.Equal(t, trac.Added, diffs[0].Kind)
+
+	writeFile(t, repo, "a.txt", "one\nthree\nfour\n")
+	diffs, err = repo.DiffWorkTree(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	diffs, err = repo.DiffCached(ctx, "", nil)
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+
+	writeFile(t, repo, "bin", "\x00\x01")
+	require.NoError(t, repo.Add(ctx, "bin"))
+	diffs, err = repo.DiffCached(ctx, "", nil)
+	require.NoError(t, err)
+	require.True(t, diffs[0].Binary)
+	require.Contains(t, diffs[0].String(), "Binary files /dev/null and b/bin differ")
+
+	_, err = repo.Diff(ctx, "HEAD~5", "HEAD", nil)
+	require.ErrorIs(t, err, trac.ErrUnknownRevision)
+}
+
+func TestSignedHistoryTravels(t *testing.T) {
+	ctx := context.Background()
+	src := newRepository(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	signers := filepath.Join(t.TempDir(), "allowed_signers")
+	line := "release@example.com " + sign.MarshalPublicKey(key.Public().(ed25519.PublicKey)) + "\n"
+	require.NoError(t, os.WriteFile(signers, []byte(line), 0644))
+	require.NoError(t, src.SetConfigValue(trac.SigningKeyKey, keyFile))
+
+	writeFile(t, src, "a.txt", "one\n")
+	require.NoError(t, src.Add(ctx, "a.txt"))
+	c, err := src.Commit(ctx, "signed", trac.CommitOptions{Sign: true})
+	require.NoError(t, err)
+	require.NoError(t, src.CreateAnnotatedTag("v1.0", "HEAD", &trac.TagOptions{Message: "Release", Sign: true}))
+	tags, err := src.Tags()
+	require.NoError(t, err)
+	require.Equal(t, []trac.Ref{{Name: "refs/tags/v1.0", Hash: c.Hash}}, tags)
+
+	dst := newRepository(t)
+	require.NoError(t, dst.AddRemote("origin", src.Root()))
+	_, err = dst.Fetch(ctx, "origin", &trac.FetchOptions{Tags: true})
+	require.NoError(t, err)
+	require.NoError(t, dst.SetConfigValue(trac.AllowedSignersKey, signers))
+	v, err := dst.VerifyTag("v1.0")
+	require.NoError(t, err)
+	require.Equal(t, "release@example.com", v.Principal)
+	v, err = dst.VerifyCommit("v1.0")
+	require.NoError(t, err)
+	require.Equal(t, "release@example.com", v.Principal)
+}